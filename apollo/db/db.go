@@ -0,0 +1,76 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollodb
+
+import (
+	"github.com/lib/pq"
+	"peridot.resf.org/apollo/db/models"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+type Access interface {
+	GetAllShortCodes() (models.ShortCodes, error)
+	GetShortCodeByCode(code string) (*models.ShortCode, error)
+	CreateShortCode(code string, mode apollopb.ShortCode_Mode) (*models.ShortCode, error)
+
+	GetProducts() (models.Products, error)
+	GetProductByID(id int64) (*models.Product, error)
+	GetProductByName(name string) (*models.Product, error)
+	CreateProduct(name string, currentFullVersion string, shortCode string, archs pq.StringArray) (*models.Product, error)
+
+	GetAllAdvisories(filters *apollopb.AdvisoryFilters, page int32, limit int32) (models.Advisories, error)
+	GetAdvisoryByCodeAndYearAndNum(code string, year int, num int) (*models.Advisory, error)
+	GetLastPublishedAt() (*time.Time, error)
+	CreateAdvisory(advisory *models.Advisory) (*models.Advisory, error)
+	UpdateAdvisory(advisory *models.Advisory) (*models.Advisory, error)
+	GetRPMsForAdvisory(advisoryId int64) (models.AdvisoryRPMs, error)
+	AddAdvisoryFix(advisoryId int64, fixId int64) error
+	AddAdvisoryCVE(advisoryId int64, cveId string) error
+	AddAdvisoryReference(advisoryId int64, url string) error
+	AddAdvisoryRPM(advisoryId int64, name string, productId int64) error
+
+	GetCVEByID(id string) (*models.CVE, error)
+	CreateCVE(cve *models.CVE) (*models.CVE, error)
+	GetAllCVEsFixedInAdvisory(advisoryId int64) (models.CVEs, error)
+
+	GetAffectedProductsByCVE(cveId string) (models.AffectedProducts, error)
+	GetAffectedProductsByAdvisory(advisory string) (models.AffectedProducts, error)
+	CreateAffectedProduct(productId int64, cveId string, state apollopb.AffectedProduct_State, version string, pkg string, advisory *string) (*models.AffectedProduct, error)
+	UpdateAffectedProductStateAndAdvisory(id int64, state apollopb.AffectedProduct_State, advisory *string) error
+
+	CreateFix(ticket string, sourceBy string, sourceLink string, description string) (int64, error)
+	GetAllFixesForAdvisory(advisoryId int64) (models.Fixes, error)
+
+	Begin() (utils.Tx, error)
+	UseTransaction(tx utils.Tx) Access
+}
@@ -0,0 +1,153 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+type Advisory struct {
+	ID        int64     `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	Year            int                        `json:"year" db:"year"`
+	Num             int                        `json:"num" db:"num"`
+	Synopsis        string                     `json:"synopsis" db:"synopsis"`
+	Topic           string                     `json:"topic" db:"topic"`
+	Severity        apollopb.Advisory_Severity `json:"severity" db:"severity"`
+	Type            apollopb.Advisory_Type     `json:"type" db:"type"`
+	Description     string                     `json:"description" db:"description"`
+	Solution        sql.NullString             `json:"solution" db:"solution"`
+	ShortCodeCode   string                     `json:"shortCodeCode" db:"short_code_code"`
+	RebootSuggested bool                       `json:"rebootSuggested" db:"reboot_suggested"`
+	PublishedAt     sql.NullTime               `json:"publishedAt" db:"published_at"`
+
+	AffectedProducts pq.StringArray `json:"affectedProducts" db:"affected_products"`
+	Cves             pq.StringArray `json:"cves" db:"cves"`
+	References       pq.StringArray `json:"references" db:"references"`
+
+	// Only useful for select queries
+	Total int64 `json:"total" db:"total"`
+}
+
+type Advisories []Advisory
+
+type AdvisoryRPM struct {
+	Name        string `json:"name" db:"name"`
+	ProductName string `json:"productName" db:"product_name"`
+}
+
+type AdvisoryRPMs []AdvisoryRPM
+
+// AdvisoryTypeSuffix returns the errata suffix for given advisory type.
+// For example RLSA-2021:0001 is a security advisory with the "RL" short code.
+func AdvisoryTypeSuffix(advisoryType apollopb.Advisory_Type) string {
+	switch advisoryType {
+	case apollopb.Advisory_TYPE_SECURITY:
+		return "SA"
+	case apollopb.Advisory_TYPE_BUGFIX:
+		return "BA"
+	case apollopb.Advisory_TYPE_ENHANCEMENT:
+		return "EA"
+	default:
+		return "XA"
+	}
+}
+
+// AdvisoryTypeFromSuffix is the reverse of AdvisoryTypeSuffix
+func AdvisoryTypeFromSuffix(suffix string) apollopb.Advisory_Type {
+	switch suffix {
+	case "SA":
+		return apollopb.Advisory_TYPE_SECURITY
+	case "BA":
+		return apollopb.Advisory_TYPE_BUGFIX
+	case "EA":
+		return apollopb.Advisory_TYPE_ENHANCEMENT
+	default:
+		return apollopb.Advisory_TYPE_UNKNOWN
+	}
+}
+
+// Name returns the full errata name of the advisory
+func (a *Advisory) Name() string {
+	return fmt.Sprintf("%s%s-%d:%04d", a.ShortCodeCode, AdvisoryTypeSuffix(a.Type), a.Year, a.Num)
+}
+
+// ToProto returns the advisory without related CVE details, fixes and RPMs.
+// CVEs are only populated with their name.
+func (a *Advisory) ToProto() *apollopb.Advisory {
+	var cves []*apollopb.CVE
+	for _, cve := range a.Cves {
+		cves = append(cves, &apollopb.CVE{
+			Name: cve,
+		})
+	}
+
+	var publishedAt *timestamppb.Timestamp
+	if a.PublishedAt.Valid {
+		publishedAt = timestamppb.New(a.PublishedAt.Time)
+	}
+
+	return &apollopb.Advisory{
+		Type:             a.Type,
+		ShortCode:        a.ShortCodeCode + AdvisoryTypeSuffix(a.Type),
+		Name:             a.Name(),
+		Synopsis:         a.Synopsis,
+		Severity:         a.Severity,
+		Topic:            a.Topic,
+		Description:      a.Description,
+		Solution:         utils.NullStringValueP(a.Solution),
+		AffectedProducts: a.AffectedProducts,
+		Cves:             cves,
+		References:       a.References,
+		PublishedAt:      publishedAt,
+		RebootSuggested:  a.RebootSuggested,
+	}
+}
+
+// ToProtoRPMs groups RPMs by product in the format Advisory.rpms expects
+func (r AdvisoryRPMs) ToProtoRPMs() map[string]*apollopb.RPMs {
+	ret := map[string]*apollopb.RPMs{}
+	for _, rpm := range r {
+		if ret[rpm.ProductName] == nil {
+			ret[rpm.ProductName] = &apollopb.RPMs{}
+		}
+		ret[rpm.ProductName].Nvras = append(ret[rpm.ProductName].Nvras, rpm.Name)
+	}
+
+	return ret
+}
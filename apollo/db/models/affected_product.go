@@ -0,0 +1,71 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package models
+
+import (
+	"database/sql"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+type AffectedProduct struct {
+	ID        int64     `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	ProductID int64                          `json:"productId" db:"product_id"`
+	CveID     sql.NullString                 `json:"cveId" db:"cve_id"`
+	State     apollopb.AffectedProduct_State `json:"state" db:"state"`
+	Version   string                         `json:"version" db:"version"`
+	Package   string                         `json:"package" db:"package"`
+	Advisory  sql.NullString                 `json:"advisory" db:"advisory"`
+}
+
+type AffectedProducts []AffectedProduct
+
+func (a *AffectedProduct) ToProto() *apollopb.AffectedProduct {
+	return &apollopb.AffectedProduct{
+		ProductId: a.ProductID,
+		CveId:     utils.NullStringValueP(a.CveID),
+		Version:   a.Version,
+		State:     a.State,
+		Package:   a.Package,
+		Advisory:  utils.NullStringValueP(a.Advisory),
+	}
+}
+
+func (a AffectedProducts) ToProto() (ret []*apollopb.AffectedProduct) {
+	for _, v := range a {
+		ret = append(ret, v.ToProto())
+	}
+
+	return ret
+}
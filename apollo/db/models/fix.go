@@ -0,0 +1,67 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package models
+
+import (
+	"database/sql"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+type Fix struct {
+	ID        int64     `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	Ticket      sql.NullString `json:"ticket" db:"ticket"`
+	SourceBy    sql.NullString `json:"sourceBy" db:"source_by"`
+	SourceLink  sql.NullString `json:"sourceLink" db:"source_link"`
+	Description sql.NullString `json:"description" db:"description"`
+}
+
+type Fixes []Fix
+
+func (f *Fix) ToProto() *apollopb.Fix {
+	return &apollopb.Fix{
+		Ticket:      utils.NullStringValueP(f.Ticket),
+		SourceBy:    utils.NullStringValueP(f.SourceBy),
+		SourceLink:  utils.NullStringValueP(f.SourceLink),
+		Description: utils.NullStringValueP(f.Description),
+	}
+}
+
+func (f Fixes) ToProto() (ret []*apollopb.Fix) {
+	for _, v := range f {
+		ret = append(ret, v.ToProto())
+	}
+
+	return ret
+}
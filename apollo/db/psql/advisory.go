@@ -0,0 +1,310 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollopsql
+
+import (
+	"database/sql"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"peridot.resf.org/apollo/db/models"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+func timestampP(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+
+	t := ts.AsTime()
+	return &t
+}
+
+func (a *Access) GetAllAdvisories(filters *apollopb.AdvisoryFilters, page int32, limit int32) (models.Advisories, error) {
+	if filters == nil {
+		filters = &apollopb.AdvisoryFilters{}
+	}
+
+	var ret models.Advisories
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			a.id,
+			a.created_at,
+			a.year,
+			a.num,
+			a.synopsis,
+			a.topic,
+			a.severity,
+			a.type,
+			a.description,
+			a.solution,
+			a.short_code_code,
+			a.reboot_suggested,
+			a.published_at,
+			array(
+				select p.name
+				from products p
+				where
+					p.id in (select ar.product_id from advisory_rpms ar where ar.advisory_id = a.id)
+					or p.id in (select ap.product_id from affected_products ap where ap.advisory = advisory_name(a.short_code_code, a.type, a.year, a.num))
+				order by p.name
+			) as affected_products,
+			array(select ac.cve_id from advisory_cves ac where ac.advisory_id = a.id order by ac.cve_id) as cves,
+			array(select r.url from advisory_references r where r.advisory_id = a.id order by r.id) as "references",
+			count(a.*) over() as total
+		from advisories a
+		where
+			($1 :: text is null or exists (
+				select 1
+				from products p
+				where
+					p.name = $1 :: text
+					and (
+						p.id in (select ar.product_id from advisory_rpms ar where ar.advisory_id = a.id)
+						or p.id in (select ap.product_id from affected_products ap where ap.advisory = advisory_name(a.short_code_code, a.type, a.year, a.num))
+					)
+			))
+			and ($2 :: timestamptz is null or a.published_at < $2 :: timestamptz)
+			and ($3 :: timestamptz is null or a.published_at > $3 :: timestamptz)
+			and (coalesce($4 :: bool, false) = true or a.published_at is not null)
+			and ($5 :: text is null or exists (
+				select 1
+				from advisory_cves ac
+				where
+					ac.advisory_id = a.id
+					and ac.cve_id ilike '%' || $5 :: text || '%'
+			))
+			and ($6 :: text is null or a.synopsis ilike '%' || $6 :: text || '%')
+			and ($7 :: text is null or (
+				a.synopsis ilike '%' || $7 :: text || '%'
+				or a.topic ilike '%' || $7 :: text || '%'
+				or a.description ilike '%' || $7 :: text || '%'
+				or advisory_name(a.short_code_code, a.type, a.year, a.num) ilike '%' || $7 :: text || '%'
+				or exists (
+					select 1
+					from advisory_cves ac
+					where
+						ac.advisory_id = a.id
+						and ac.cve_id ilike '%' || $7 :: text || '%'
+				)
+			))
+			and ($8 :: numeric = 0 or a.severity = $8 :: numeric)
+			and ($9 :: numeric = 0 or a.type = $9 :: numeric)
+		order by a.published_at desc nulls last, a.id desc
+		limit $10 offset $11
+		`,
+		utils.StringValueP(filters.Product),
+		timestampP(filters.Before),
+		timestampP(filters.After),
+		utils.BoolValueP(filters.IncludeUnpublished),
+		utils.StringValueP(filters.Cve),
+		utils.StringValueP(filters.Synopsis),
+		utils.StringValueP(filters.Keyword),
+		int32(filters.Severity),
+		int32(filters.Type),
+		utils.UnlimitedLimit(limit),
+		utils.GetOffset(page, limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) GetAdvisoryByCodeAndYearAndNum(code string, year int, num int) (*models.Advisory, error) {
+	var ret models.Advisory
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			a.id,
+			a.created_at,
+			a.year,
+			a.num,
+			a.synopsis,
+			a.topic,
+			a.severity,
+			a.type,
+			a.description,
+			a.solution,
+			a.short_code_code,
+			a.reboot_suggested,
+			a.published_at,
+			array(
+				select p.name
+				from products p
+				where
+					p.id in (select ar.product_id from advisory_rpms ar where ar.advisory_id = a.id)
+					or p.id in (select ap.product_id from affected_products ap where ap.advisory = advisory_name(a.short_code_code, a.type, a.year, a.num))
+				order by p.name
+			) as affected_products,
+			array(select ac.cve_id from advisory_cves ac where ac.advisory_id = a.id order by ac.cve_id) as cves,
+			array(select r.url from advisory_references r where r.advisory_id = a.id order by r.id) as "references"
+		from advisories a
+		where
+			a.short_code_code = $1
+			and a.year = $2
+			and a.num = $3
+		`,
+		code,
+		year,
+		num,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// GetLastPublishedAt returns the time the latest advisory was published at.
+// Returns nil if no advisory has been published yet.
+func (a *Access) GetLastPublishedAt() (*time.Time, error) {
+	var ret sql.NullTime
+	err := a.query.Get(&ret, "select max(published_at) from advisories where published_at is not null")
+	if err != nil {
+		return nil, err
+	}
+	if !ret.Valid {
+		return nil, nil
+	}
+
+	return &ret.Time, nil
+}
+
+func (a *Access) CreateAdvisory(advisory *models.Advisory) (*models.Advisory, error) {
+	ret := *advisory
+	err := a.query.Get(
+		&ret,
+		`
+		insert into advisories
+		(year, num, synopsis, topic, severity, type, description, solution, short_code_code, reboot_suggested, published_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		returning id, created_at
+		`,
+		advisory.Year,
+		advisory.Num,
+		advisory.Synopsis,
+		advisory.Topic,
+		advisory.Severity,
+		advisory.Type,
+		advisory.Description,
+		advisory.Solution,
+		advisory.ShortCodeCode,
+		advisory.RebootSuggested,
+		advisory.PublishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) UpdateAdvisory(advisory *models.Advisory) (*models.Advisory, error) {
+	ret := *advisory
+	err := a.query.Get(
+		&ret,
+		`
+		update advisories
+		set
+			synopsis = $2,
+			topic = $3,
+			severity = $4,
+			type = $5,
+			description = $6,
+			solution = $7,
+			reboot_suggested = $8,
+			published_at = $9
+		where id = $1
+		returning id, created_at
+		`,
+		advisory.ID,
+		advisory.Synopsis,
+		advisory.Topic,
+		advisory.Severity,
+		advisory.Type,
+		advisory.Description,
+		advisory.Solution,
+		advisory.RebootSuggested,
+		advisory.PublishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) GetRPMsForAdvisory(advisoryId int64) (models.AdvisoryRPMs, error) {
+	var ret models.AdvisoryRPMs
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			ar.name,
+			p.name as product_name
+		from advisory_rpms ar
+		inner join products p on p.id = ar.product_id
+		where ar.advisory_id = $1
+		order by p.name, ar.name
+		`,
+		advisoryId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) AddAdvisoryFix(advisoryId int64, fixId int64) error {
+	_, err := a.query.Exec("insert into advisory_fixes (advisory_id, fix_id) values ($1, $2) on conflict do nothing", advisoryId, fixId)
+	return err
+}
+
+func (a *Access) AddAdvisoryCVE(advisoryId int64, cveId string) error {
+	_, err := a.query.Exec("insert into advisory_cves (advisory_id, cve_id) values ($1, $2) on conflict do nothing", advisoryId, cveId)
+	return err
+}
+
+func (a *Access) AddAdvisoryReference(advisoryId int64, url string) error {
+	_, err := a.query.Exec("insert into advisory_references (advisory_id, url) values ($1, $2) on conflict do nothing", advisoryId, url)
+	return err
+}
+
+func (a *Access) AddAdvisoryRPM(advisoryId int64, name string, productId int64) error {
+	_, err := a.query.Exec("insert into advisory_rpms (advisory_id, name, product_id) values ($1, $2, $3) on conflict do nothing", advisoryId, name, productId)
+	return err
+}
@@ -0,0 +1,121 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollopsql
+
+import (
+	"peridot.resf.org/apollo/db/models"
+	apollopb "peridot.resf.org/apollo/pb"
+)
+
+func (a *Access) GetAffectedProductsByCVE(cveId string) (models.AffectedProducts, error) {
+	var ret models.AffectedProducts
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			product_id,
+			cve_id,
+			state,
+			version,
+			package,
+			advisory
+		from affected_products
+		where cve_id = $1
+		`,
+		cveId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) GetAffectedProductsByAdvisory(advisory string) (models.AffectedProducts, error) {
+	var ret models.AffectedProducts
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			product_id,
+			cve_id,
+			state,
+			version,
+			package,
+			advisory
+		from affected_products
+		where advisory = $1
+		`,
+		advisory,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) CreateAffectedProduct(productId int64, cveId string, state apollopb.AffectedProduct_State, version string, pkg string, advisory *string) (*models.AffectedProduct, error) {
+	var ret models.AffectedProduct
+	err := a.query.Get(
+		&ret,
+		`
+		insert into affected_products (product_id, cve_id, state, version, package, advisory)
+		values ($1, $2, $3, $4, $5, $6)
+		returning id, created_at, product_id, cve_id, state, version, package, advisory
+		`,
+		productId,
+		cveId,
+		state,
+		version,
+		pkg,
+		advisory,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) UpdateAffectedProductStateAndAdvisory(id int64, state apollopb.AffectedProduct_State, advisory *string) error {
+	_, err := a.query.Exec(
+		"update affected_products set state = $2, advisory = $3 where id = $1",
+		id,
+		state,
+		advisory,
+	)
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollopsql
+
+import (
+	"peridot.resf.org/apollo/db/models"
+)
+
+func (a *Access) GetCVEByID(id string) (*models.CVE, error) {
+	var ret models.CVE
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			short_code_code,
+			source_by,
+			source_link,
+			cvss3_scoring_vector,
+			cvss3_base_score,
+			cwe
+		from cves
+		where id = $1
+		`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) CreateCVE(cve *models.CVE) (*models.CVE, error) {
+	ret := *cve
+	err := a.query.Get(
+		&ret,
+		`
+		insert into cves (id, short_code_code, source_by, source_link, cvss3_scoring_vector, cvss3_base_score, cwe)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning created_at
+		`,
+		cve.ID,
+		cve.ShortCode,
+		cve.SourceBy,
+		cve.SourceLink,
+		cve.Cvss3ScoringVector,
+		cve.Cvss3BaseScore,
+		cve.Cwe,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) GetAllCVEsFixedInAdvisory(advisoryId int64) (models.CVEs, error) {
+	var ret models.CVEs
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			c.id,
+			c.created_at,
+			c.short_code_code,
+			c.source_by,
+			c.source_link,
+			c.cvss3_scoring_vector,
+			c.cvss3_base_score,
+			c.cwe
+		from cves c
+		inner join advisory_cves ac on ac.cve_id = c.id
+		where ac.advisory_id = $1
+		order by c.id asc
+		`,
+		advisoryId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollopsql
+
+import (
+	"peridot.resf.org/apollo/db/models"
+)
+
+func (a *Access) CreateFix(ticket string, sourceBy string, sourceLink string, description string) (int64, error) {
+	var id int64
+	err := a.query.Get(
+		&id,
+		`
+		insert into fixes (ticket, source_by, source_link, description)
+		values ($1, $2, $3, $4)
+		returning id
+		`,
+		ticket,
+		sourceBy,
+		sourceLink,
+		description,
+	)
+	return id, err
+}
+
+func (a *Access) GetAllFixesForAdvisory(advisoryId int64) (models.Fixes, error) {
+	var ret models.Fixes
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			f.id,
+			f.created_at,
+			f.ticket,
+			f.source_by,
+			f.source_link,
+			f.description
+		from fixes f
+		inner join advisory_fixes af on af.fix_id = f.id
+		where af.advisory_id = $1
+		order by f.id asc
+		`,
+		advisoryId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollopsql
+
+import (
+	"github.com/lib/pq"
+	"peridot.resf.org/apollo/db/models"
+)
+
+func (a *Access) GetProducts() (models.Products, error) {
+	var ret models.Products
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			name,
+			current_full_version,
+			short_code_code,
+			archs
+		from products
+		order by name asc
+		`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) GetProductByID(id int64) (*models.Product, error) {
+	var ret models.Product
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			name,
+			current_full_version,
+			short_code_code,
+			archs
+		from products
+		where id = $1
+		`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) GetProductByName(name string) (*models.Product, error) {
+	var ret models.Product
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			name,
+			current_full_version,
+			short_code_code,
+			archs
+		from products
+		where name = $1
+		`,
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) CreateProduct(name string, currentFullVersion string, shortCode string, archs pq.StringArray) (*models.Product, error) {
+	if archs == nil {
+		archs = pq.StringArray{}
+	}
+
+	ret := models.Product{
+		Name:               name,
+		CurrentFullVersion: currentFullVersion,
+		ShortCode:          shortCode,
+		Archs:              archs,
+	}
+	err := a.query.Get(
+		&ret,
+		`
+		insert into products (name, current_full_version, short_code_code, archs)
+		values ($1, $2, $3, $4)
+		returning id, created_at
+		`,
+		name,
+		currentFullVersion,
+		shortCode,
+		archs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,213 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apolloimplv1
+
+import (
+	"context"
+	"database/sql"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"peridot.resf.org/apollo/db/models"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/apollo/rpmutils"
+	"peridot.resf.org/utils"
+	"strconv"
+)
+
+// rssLimit is the amount of advisories returned in the RSS feed
+const rssLimit = 25
+
+// fetchRelated populates CVE details, fixes and (optionally) RPMs
+// for the given advisory
+func (s *Server) fetchRelated(advisory *models.Advisory, includeRpms bool) (*apollopb.Advisory, error) {
+	ret := advisory.ToProto()
+
+	cves, err := s.db.GetAllCVEsFixedInAdvisory(advisory.ID)
+	if err != nil {
+		s.log.Errorf("could not get cves for advisory %s: %v", advisory.Name(), err)
+		return nil, utils.InternalError
+	}
+	ret.Cves = cves.ToProto()
+
+	fixes, err := s.db.GetAllFixesForAdvisory(advisory.ID)
+	if err != nil {
+		s.log.Errorf("could not get fixes for advisory %s: %v", advisory.Name(), err)
+		return nil, utils.InternalError
+	}
+	ret.Fixes = fixes.ToProto()
+
+	if includeRpms {
+		rpms, err := s.db.GetRPMsForAdvisory(advisory.ID)
+		if err != nil {
+			s.log.Errorf("could not get rpms for advisory %s: %v", advisory.Name(), err)
+			return nil, utils.InternalError
+		}
+		ret.Rpms = rpms.ToProtoRPMs()
+	}
+
+	return ret, nil
+}
+
+// publicFilters returns filters that are safe to pass to the database
+// from a public endpoint
+func publicFilters(filters *apollopb.AdvisoryFilters) *apollopb.AdvisoryFilters {
+	if filters == nil {
+		filters = &apollopb.AdvisoryFilters{}
+	}
+	// Unpublished advisories are never exposed through the public API
+	filters.IncludeUnpublished = wrapperspb.Bool(false)
+
+	return filters
+}
+
+func (s *Server) ListAdvisories(_ context.Context, req *apollopb.ListAdvisoriesRequest) (*apollopb.ListAdvisoriesResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+
+	filters := publicFilters(req.Filters)
+	page := utils.MinPage(req.Page)
+	limit := utils.MinLimit(req.Limit)
+
+	advisories, err := s.db.GetAllAdvisories(filters, page, limit)
+	if err != nil {
+		s.log.Errorf("could not list advisories: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+
+	fetchRelated := filters.FetchRelated == nil || filters.FetchRelated.Value
+	includeRpms := filters.IncludeRpms != nil && filters.IncludeRpms.Value
+
+	var total int64
+	var ret []*apollopb.Advisory
+	for i := range advisories {
+		advisory := &advisories[i]
+		total = advisory.Total
+
+		if !fetchRelated && !includeRpms {
+			ret = append(ret, advisory.ToProto())
+			continue
+		}
+
+		advisoryPb, err := s.fetchRelated(advisory, includeRpms)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, advisoryPb)
+	}
+
+	var lastUpdated *timestamppb.Timestamp
+	lastPublishedAt, err := s.db.GetLastPublishedAt()
+	if err != nil {
+		s.log.Errorf("could not get last published at: %v", err)
+		return nil, utils.InternalError
+	}
+	if lastPublishedAt != nil {
+		lastUpdated = timestamppb.New(*lastPublishedAt)
+	}
+
+	return &apollopb.ListAdvisoriesResponse{
+		Advisories:  ret,
+		Total:       total,
+		Size:        limit,
+		Page:        page,
+		LastUpdated: lastUpdated,
+	}, nil
+}
+
+func (s *Server) ListAdvisoriesRSS(_ context.Context, req *apollopb.ListAdvisoriesRSSRequest) (*httpbody.HttpBody, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+
+	filters := publicFilters(req.Filters)
+	advisories, err := s.db.GetAllAdvisories(filters, 0, rssLimit)
+	if err != nil {
+		s.log.Errorf("could not list advisories for rss: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+
+	feed, err := renderRSS(advisories)
+	if err != nil {
+		s.log.Errorf("could not render rss feed: %v", err)
+		return nil, utils.InternalError
+	}
+
+	return &httpbody.HttpBody{
+		ContentType: "application/rss+xml",
+		Data:        feed,
+	}, nil
+}
+
+func (s *Server) GetAdvisory(_ context.Context, req *apollopb.GetAdvisoryRequest) (*apollopb.GetAdvisoryResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+
+	match := rpmutils.AdvisoryId().FindStringSubmatch(req.Id)
+	if len(match) != 5 {
+		return nil, status.Error(codes.InvalidArgument, "invalid advisory id")
+	}
+	year, err := strconv.Atoi(match[3])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid advisory year")
+	}
+	num, err := strconv.Atoi(match[4])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid advisory number")
+	}
+
+	advisory, err := s.db.GetAdvisoryByCodeAndYearAndNum(match[1], year, num)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "advisory not found")
+		}
+		s.log.Errorf("could not get advisory %s: %v", req.Id, err)
+		return nil, utils.CouldNotRetrieveObject
+	}
+	// The numbering is shared between advisory types, so make sure the
+	// type in the requested ID matches the advisory as well
+	if !advisory.PublishedAt.Valid || models.AdvisoryTypeSuffix(advisory.Type) != match[2] {
+		return nil, status.Error(codes.NotFound, "advisory not found")
+	}
+
+	advisoryPb, err := s.fetchRelated(advisory, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apollopb.GetAdvisoryResponse{
+		Advisory: advisoryPb,
+	}, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apolloimplv1
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/spf13/viper"
+	"peridot.resf.org/apollo/db/models"
+	"strings"
+	"time"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Guid        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+// renderRSS returns an RSS 2.0 feed for given advisories.
+// Advisories are expected to be sorted by newest first.
+func renderRSS(advisories models.Advisories) ([]byte, error) {
+	homepage := strings.TrimSuffix(viper.GetString("homepage"), "/")
+
+	channel := rssChannel{
+		Title:       "Errata Feed",
+		Link:        homepage,
+		Description: "Advisories issued by the errata team",
+	}
+
+	for _, advisory := range advisories {
+		name := advisory.Name()
+		link := fmt.Sprintf("%s/%s", homepage, name)
+
+		item := rssItem{
+			Title:       fmt.Sprintf("%s: %s", name, advisory.Synopsis),
+			Link:        link,
+			Description: advisory.Topic,
+			Guid:        link,
+			Categories:  advisory.AffectedProducts,
+		}
+		if advisory.PublishedAt.Valid {
+			item.PubDate = advisory.PublishedAt.Time.Format(time.RFC1123Z)
+			if channel.LastBuildDate == "" {
+				channel.LastBuildDate = item.PubDate
+			}
+		}
+
+		channel.Items = append(channel.Items, item)
+	}
+
+	out, err := xml.MarshalIndent(&rss{
+		Version: "2.0",
+		Channel: channel,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+ * Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+ * Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * 3. Neither the name of the copyright holder nor the names of its contributors
+ * may be used to endorse or promote products derived from this software without
+ * specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+create table advisories
+(
+    id               bigserial primary key,
+    created_at       timestamptz default current_timestamp not null,
+
+    year             int                                   not null,
+    num              int                                   not null,
+    synopsis         text                                  not null,
+    topic            text                                  not null,
+    severity         numeric                               not null,
+    type             numeric                               not null,
+    description      text                                  not null,
+    solution         text,
+    short_code_code  text references short_codes (code)    not null,
+    reboot_suggested bool        default false             not null,
+    published_at     timestamptz,
+
+    unique (short_code_code, year, num)
+);
+
+create index advisories_published_at_idx on advisories (published_at);
+
+-- Returns the full errata name of an advisory, for example RLSA-2021:0001
+create or replace function advisory_name(short_code text, type numeric, year int, num int) returns text as
+$$
+select short_code ||
+       (case type when 1 then 'SA' when 2 then 'BA' when 3 then 'EA' else 'XA' end) ||
+       '-' || year || ':' || lpad(num :: text, 4, '0');
+$$ language sql immutable;
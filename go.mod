@@ -7,6 +7,7 @@ require (
 	alexejk.io/go-xmlrpc v0.2.0
 	bazel.build/protobuf v0.0.0-00010101000000-000000000000
 	cirello.io/dynamolock v1.4.0
+	cloud.google.com/go/storage v1.43.0
 	github.com/ProtonMail/go-crypto v1.0.0
 	github.com/ProtonMail/gopenpgp/v2 v2.7.5
 	github.com/authzed/authzed-go v0.3.0
@@ -48,6 +49,7 @@ require (
 	k8s.io/apimachinery v0.29.2
 	k8s.io/client-go v0.29.2
 	openapi.peridot.resf.org/peridotopenapi v0.0.0-00010101000000-000000000000
+	peridot.resf.org/apollo/pb v0.0.0-00010101000000-000000000000
 	peridot.resf.org/common v0.0.0-00010101000000-000000000000
 	peridot.resf.org/obsidian/pb v0.0.0-00010101000000-000000000000
 	peridot.resf.org/peridot/admin/pb v0.0.0-00010101000000-000000000000
@@ -62,7 +64,6 @@ require (
 	cloud.google.com/go/auth/oauth2adapt v0.2.3 // indirect
 	cloud.google.com/go/compute/metadata v0.5.0 // indirect
 	cloud.google.com/go/iam v1.1.11 // indirect
-	cloud.google.com/go/storage v1.43.0 // indirect
 	dario.cat/mergo v1.0.0 // indirect
 	github.com/Microsoft/go-winio v0.6.2 // indirect
 	github.com/ProtonMail/go-mime v0.0.0-20230322103455-7d82a3887f2f // indirect
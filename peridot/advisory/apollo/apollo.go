@@ -0,0 +1,78 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package apollo
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	apollopb "peridot.resf.org/apollo/pb"
+)
+
+// pageSize is the maximum limit accepted by ListAdvisories
+const pageSize = 100
+
+// Source fetches advisories from an Apollo instance
+type Source struct {
+	client apollopb.ApolloServiceClient
+}
+
+func New(conn grpc.ClientConnInterface) *Source {
+	return &Source{
+		client: apollopb.NewApolloServiceClient(conn),
+	}
+}
+
+func (s *Source) ListAdvisories(ctx context.Context, productName string) ([]*apollopb.Advisory, error) {
+	var ret []*apollopb.Advisory
+
+	for page := int32(0); ; page++ {
+		res, err := s.client.ListAdvisories(ctx, &apollopb.ListAdvisoriesRequest{
+			Filters: &apollopb.AdvisoryFilters{
+				Product:      wrapperspb.String(productName),
+				IncludeRpms:  wrapperspb.Bool(true),
+				FetchRelated: wrapperspb.Bool(true),
+			},
+			Page:  page,
+			Limit: pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, res.Advisories...)
+		if len(res.Advisories) < pageSize || int64(len(ret)) >= res.Total {
+			break
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	apollopb "peridot.resf.org/apollo/pb"
+	"sort"
+	"strings"
+)
+
+// Source reads advisories from a local directory.
+// Every file in the directory (.yaml, .yml or .json) contains a single advisory
+// in the same format as apollopb.Advisory.
+type Source struct {
+	dir string
+}
+
+func New(dir string) *Source {
+	return &Source{
+		dir: dir,
+	}
+}
+
+func (s *Source) readAdvisory(path string) (*apollopb.Advisory, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc interface{}
+		err = yaml.Unmarshal(content, &doc)
+		if err != nil {
+			return nil, err
+		}
+		content, err = json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var advisory apollopb.Advisory
+	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(content, &advisory)
+	if err != nil {
+		return nil, err
+	}
+
+	return &advisory, nil
+}
+
+func (s *Source) ListAdvisories(_ context.Context, productName string) ([]*apollopb.Advisory, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read advisory directory: %v", err)
+	}
+
+	var ret []*apollopb.Advisory
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		advisory, err := s.readAdvisory(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read advisory %s: %v", entry.Name(), err)
+		}
+		// Unpublished advisories are skipped, same as Apollo does
+		if advisory.PublishedAt == nil || advisory.Rpms[productName] == nil {
+			continue
+		}
+
+		ret = append(ret, advisory)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].PublishedAt.AsTime().After(ret[j].PublishedAt.AsTime())
+	})
+
+	return ret, nil
+}
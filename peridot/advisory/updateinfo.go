@@ -0,0 +1,220 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package advisory
+
+import (
+	"fmt"
+	"path/filepath"
+	apollopb "peridot.resf.org/apollo/pb"
+	"peridot.resf.org/apollo/rpmutils"
+	"peridot.resf.org/peridot/yummeta"
+	"strings"
+	"time"
+)
+
+// UpdateInfoFrom is set as the "from" attribute of generated updates
+const UpdateInfoFrom = "releng@rockylinux.org"
+
+var (
+	advisoryTypes = map[apollopb.Advisory_Type]string{
+		apollopb.Advisory_TYPE_SECURITY:    "security",
+		apollopb.Advisory_TYPE_BUGFIX:      "bugfix",
+		apollopb.Advisory_TYPE_ENHANCEMENT: "enhancement",
+	}
+	advisorySeverities = map[apollopb.Advisory_Severity]string{
+		apollopb.Advisory_SEVERITY_LOW:       "Low",
+		apollopb.Advisory_SEVERITY_MODERATE:  "Moderate",
+		apollopb.Advisory_SEVERITY_IMPORTANT: "Important",
+		apollopb.Advisory_SEVERITY_CRITICAL:  "Critical",
+	}
+)
+
+// nvraKey returns the lookup key for a package without the epoch
+func nvraKey(name string, version string, release string, arch string) string {
+	return fmt.Sprintf("%s-%s-%s.%s", name, version, release, arch)
+}
+
+// parseAdvisoryRPM splits an advisory RPM (for example bash-0:5.1.8-6.el9.x86_64.rpm)
+// into the epoch-less NVRA lookup key and the epoch, if any.
+func parseAdvisoryRPM(rpm string) (string, string, bool) {
+	rpm = strings.TrimSuffix(rpm, ".rpm")
+
+	var epoch string
+	if match := rpmutils.Epoch().FindStringSubmatch(rpm); len(match) == 2 {
+		epoch = match[1]
+		rpm = rpmutils.Epoch().ReplaceAllString(rpm, "")
+	}
+
+	match := rpmutils.NVR().FindStringSubmatch(rpm)
+	if len(match) != 5 || match[4] == "" {
+		return "", "", false
+	}
+
+	return nvraKey(match[1], match[2], match[3], match[4]), epoch, true
+}
+
+// NewUpdateInfo builds an updateinfo document for given product.
+// Only packages present in the given primary.xml are included, and advisories
+// without any matching packages are skipped entirely.
+func NewUpdateInfo(advisories []*apollopb.Advisory, productName string, primary *yummeta.PrimaryRoot) *yummeta.UpdatesRoot {
+	packages := map[string]*yummeta.PrimaryPackage{}
+	for _, pkg := range primary.Packages {
+		if pkg.Version == nil {
+			continue
+		}
+		packages[nvraKey(pkg.Name, pkg.Version.Ver, pkg.Version.Rel, pkg.Arch)] = pkg
+	}
+
+	ret := &yummeta.UpdatesRoot{}
+	for _, advisory := range advisories {
+		rpms := advisory.Rpms[productName]
+		if rpms == nil {
+			continue
+		}
+
+		var updatePackages []*yummeta.UpdatePackage
+		seen := map[string]bool{}
+		for _, nvra := range rpms.Nvras {
+			key, epoch, ok := parseAdvisoryRPM(nvra)
+			if !ok || seen[key] {
+				continue
+			}
+			pkg := packages[key]
+			if pkg == nil {
+				continue
+			}
+			if epoch != "" && epoch != pkg.Version.Epoch {
+				continue
+			}
+			seen[key] = true
+
+			updatePackage := &yummeta.UpdatePackage{
+				Name:    pkg.Name,
+				Version: pkg.Version.Ver,
+				Release: pkg.Version.Rel,
+				Epoch:   pkg.Version.Epoch,
+				Arch:    pkg.Arch,
+			}
+			if pkg.Location != nil {
+				updatePackage.Filename = filepath.Base(pkg.Location.Href)
+			}
+			if pkg.Format != nil {
+				updatePackage.Src = pkg.Format.RpmSourceRpm
+			}
+			if pkg.Checksum != nil {
+				updatePackage.Sum = []*yummeta.UpdatePackageSum{
+					{
+						Type:  pkg.Checksum.Type,
+						Value: pkg.Checksum.Value,
+					},
+				}
+			}
+			updatePackages = append(updatePackages, updatePackage)
+		}
+		if len(updatePackages) == 0 {
+			continue
+		}
+
+		var references []*yummeta.UpdateReference
+		for _, fix := range advisory.Fixes {
+			references = append(references, &yummeta.UpdateReference{
+				Href:  fix.SourceLink.GetValue(),
+				ID:    fix.Ticket.GetValue(),
+				Type:  "bugzilla",
+				Title: fix.Description.GetValue(),
+			})
+		}
+		for _, cve := range advisory.Cves {
+			references = append(references, &yummeta.UpdateReference{
+				Href:  cve.SourceLink.GetValue(),
+				ID:    cve.Name,
+				Type:  "cve",
+				Title: cve.Name,
+			})
+		}
+		for _, reference := range advisory.References {
+			references = append(references, &yummeta.UpdateReference{
+				Href: reference,
+				Type: "other",
+			})
+		}
+
+		var issued *yummeta.UpdateDate
+		year := time.Now().Year()
+		if advisory.PublishedAt != nil {
+			publishedAt := advisory.PublishedAt.AsTime().UTC()
+			issued = &yummeta.UpdateDate{
+				Date: publishedAt.Format(yummeta.TimeFormat),
+			}
+			year = publishedAt.Year()
+		}
+
+		advisoryType := advisoryTypes[advisory.Type]
+		if advisoryType == "" {
+			advisoryType = "bugfix"
+		}
+		severity := advisorySeverities[advisory.Severity]
+		if severity == "" {
+			severity = "None"
+		}
+
+		ret.Updates = append(ret.Updates, &yummeta.Update{
+			From:        UpdateInfoFrom,
+			Status:      "final",
+			Type:        advisoryType,
+			Version:     "2",
+			ID:          advisory.Name,
+			Title:       advisory.Synopsis,
+			Issued:      issued,
+			Updated:     issued,
+			Rights:      fmt.Sprintf("Copyright (C) %d Rocky Enterprise Software Foundation", year),
+			Release:     productName,
+			PushCount:   "1",
+			Severity:    severity,
+			Summary:     advisory.Topic,
+			Description: advisory.Description,
+			References: &yummeta.UpdateReferenceRoot{
+				References: references,
+			},
+			PkgList: &yummeta.UpdateCollectionRoot{
+				Collections: []*yummeta.UpdateCollection{
+					{
+						Short:    strings.ToLower(strings.ReplaceAll(productName, " ", "-")),
+						Name:     productName,
+						Packages: updatePackages,
+					},
+				},
+			},
+		})
+	}
+
+	return ret
+}
@@ -31,16 +31,18 @@
 package builderv1
 
 import (
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/sirupsen/logrus"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
+	"peridot.resf.org/peridot/advisory"
 	"peridot.resf.org/peridot/builder/v1/workflow"
 	serverdb "peridot.resf.org/peridot/db"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
-	"peridot.resf.org/peridot/lookaside/s3"
+	"peridot.resf.org/peridot/lock"
+	"peridot.resf.org/peridot/lookaside/backend"
 	"peridot.resf.org/peridot/plugin"
+	"peridot.resf.org/peridot/provisioner"
 	"time"
 )
 
@@ -55,12 +57,14 @@ type Worker struct {
 
 type ExtraReq struct {
 	KeykeeperClient keykeeperpb.KeykeeperServiceClient
-	DynamoDB        *dynamodb.DynamoDB
+	Locker          lock.Locker
+	AdvisorySource  advisory.Source
+	Provisioner     provisioner.Provisioner
 }
 
 func NewWorker(db serverdb.Access, c client.Client, taskQueue string, extraReq *ExtraReq, plugins ...plugin.Plugin) (*Worker, error) {
 	log := logrus.New()
-	storage, err := s3.New(osfs.New("/"))
+	storage, err := backend.New(osfs.New("/"))
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +76,7 @@ func NewWorker(db serverdb.Access, c client.Client, taskQueue string, extraReq *
 	return &Worker{
 		Client:             c,
 		TaskQueue:          taskQueue,
-		WorkflowController: workflow.NewController(c, db, storage, taskQueue, extraReq.KeykeeperClient, extraReq.DynamoDB, plugins...),
+		WorkflowController: workflow.NewController(c, db, storage, taskQueue, extraReq.KeykeeperClient, extraReq.Locker, extraReq.AdvisorySource, extraReq.Provisioner, plugins...),
 		Worker: worker.New(c, taskQueue, worker.Options{
 			DeadlockDetectionTimeout: 15 * time.Minute,
 		}),
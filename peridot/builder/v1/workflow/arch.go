@@ -33,7 +33,9 @@ package workflow
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -44,12 +46,15 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"peridot.resf.org/apollo/rpmutils"
 	"peridot.resf.org/peridot/db/models"
@@ -211,28 +216,63 @@ func getRepoUrl(arch string, nRepoUrl string) string {
 	return repoUrl
 }
 
-func (c *Controller) repos(projectId string, arch string, extraOptions *peridotpb.ExtraBuildOptions) (map[string]string, error) {
-	ret := map[string]string{}
+// yumrepofsRepos returns the yumrepofs repositories that should be available
+// in the buildroot. The "all" repository is always included
+func yumrepofsRepos(extraOptions *peridotpb.ExtraBuildOptions) []*peridotpb.ExtraYumrepofsRepo {
 	extraRepos := extraOptions.ExtraYumrepofsRepos
 
-	yumrepofsRepos := extraRepos
-	hasAll := false
-	for _, repo := range yumrepofsRepos {
+	for _, repo := range extraRepos {
 		if repo.Name == "all" {
-			hasAll = true
+			return extraRepos
 		}
 	}
-	if !hasAll {
-		yumrepofsRepos = []*peridotpb.ExtraYumrepofsRepo{
-			{
-				Name: "all",
-			},
+
+	return append([]*peridotpb.ExtraYumrepofsRepo{
+		{
+			Name: "all",
+		},
+	}, extraRepos...)
+}
+
+// buildrootRevisions returns the yumrepofs revisions that are currently served
+// to a buildroot for the given arch
+func (c *Controller) buildrootRevisions(projectId string, arch string, extraOptions *peridotpb.ExtraBuildOptions) ([]*peridotpb.BuildrootRepositoryRevision, error) {
+	var ret []*peridotpb.BuildrootRepositoryRevision
+	for _, repo := range yumrepofsRepos(extraOptions) {
+		var revision *models.RepositoryRevision
+		var err error
+		if extraOptions.YumrepofsSnapshot != "" {
+			revision, err = c.db.GetSnapshotRepositoryRevisionByProjectIdAndNameAndArch(projectId, extraOptions.YumrepofsSnapshot, repo.Name, arch)
+		} else {
+			revision, err = c.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(projectId, repo.Name, arch)
+		}
+		if err != nil {
+			// Repositories are configured with skip_if_unavailable
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return nil, err
 		}
-		yumrepofsRepos = append(yumrepofsRepos, extraRepos...)
+
+		ret = append(ret, &peridotpb.BuildrootRepositoryRevision{
+			Repository: repo.Name,
+			Arch:       arch,
+			RevisionId: revision.ID.String(),
+		})
 	}
 
-	for i, repo := range yumrepofsRepos {
-		repoUrl := getRepoUrl(arch, servicecatalog.YumrepofsRepo(projectId, repo.Name, "$arch"))
+	return ret, nil
+}
+
+func (c *Controller) repos(projectId string, arch string, extraOptions *peridotpb.ExtraBuildOptions) (map[string]string, error) {
+	ret := map[string]string{}
+
+	for i, repo := range yumrepofsRepos(extraOptions) {
+		nRepoUrl := servicecatalog.YumrepofsRepo(projectId, repo.Name, "$arch")
+		if extraOptions.YumrepofsSnapshot != "" {
+			nRepoUrl = servicecatalog.YumrepofsSnapshotRepo(projectId, extraOptions.YumrepofsSnapshot, repo.Name, "$arch")
+		}
+		repoUrl := getRepoUrl(arch, nRepoUrl)
 
 		yumrepofsConfig := `[yumrepofs_{i}]
 name=Peridot Internal - Yumrepofs {i}
@@ -452,16 +492,23 @@ func (c *Controller) chrootPkgs(project *models.Project, pkgGroup []string) []st
 	return chrootPkgs
 }
 
+// mockMacros returns the build macros including overrides from extra build options
+func (c *Controller) mockMacros(project *models.Project, packageVersion *models.PackageVersion, extra *peridotpb.ExtraBuildOptions) map[string]string {
+	buildMacros := c.buildMacros(project, packageVersion)
+	if extra != nil && extra.ForceDist != "" {
+		buildMacros["%dist"] = "." + extra.ForceDist
+	}
+
+	return buildMacros
+}
+
 func (c *Controller) mockConfig(project *models.Project, packageVersion *models.PackageVersion, extra *peridotpb.ExtraBuildOptions, arch string, hostArch string, pkgGroup []string) (string, error) {
 	// If we're building for i686 then force host arch to i686 even if we're building on x86_64
 	if arch == "i686" {
 		hostArch = "i686"
 	}
 
-	buildMacros := c.buildMacros(project, packageVersion)
-	if extra != nil && extra.ForceDist != "" {
-		buildMacros["%dist"] = "." + extra.ForceDist
-	}
+	buildMacros := c.mockMacros(project, packageVersion, extra)
 
 	mockConfig := `
 config_opts['root'] = '{additionalVendor}-{majorVersion}-{hostArch}'
@@ -484,6 +531,8 @@ config_opts['plugin_conf']['rpmautospec_opts'] = {
 config_opts['plugin_conf']['ccache_enable'] = False
 config_opts['plugin_conf']['root_cache_enable'] = False
 config_opts['plugin_conf']['yum_cache_enable'] = False
+config_opts['plugin_conf']['package_state_enable'] = True
+config_opts['plugin_conf']['package_state_opts'] = {'available_pkgs': False, 'installed_pkgs': True}
 config_opts['rpmbuild_networking'] = {rpmbuildNetworking}
 config_opts['use_host_resolv'] = {rpmbuildNetworking}
 config_opts['print_main_output'] = True
@@ -602,6 +651,61 @@ func (c *Controller) writeMockConfig(project *models.Project, packageVersion *mo
 	return ioutil.WriteFile("/var/peridot/mock.cfg", []byte(mockConfig), 0644)
 }
 
+// recordBuildroot completes the lockfile with the installed packages and the mock
+// configuration, sets it as the response of the task and uploads it as an artifact
+func (c *Controller) recordBuildroot(lockfile *peridotpb.BuildrootLockfile, task *models.Task) error {
+	mockConfig, err := os.ReadFile("/var/peridot/mock.cfg")
+	if err != nil {
+		return fmt.Errorf("could not read mock config: %v", err)
+	}
+	lockfile.MockConfig = string(mockConfig)
+
+	// Written by the mock package_state plugin once the buildroot is installed
+	// Format: "nevra buildtime size pkgid installtime"
+	installedPkgs, err := os.ReadFile(filepath.Join(rpmbuild.GetCloneDirectory(), "RPMS", "installed_pkgs.log"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read installed packages: %v", err)
+	}
+	for _, line := range strings.Split(string(installedPkgs), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		lockfile.InstalledPackages = append(lockfile.InstalledPackages, fields[0])
+	}
+	sort.Strings(lockfile.InstalledPackages)
+
+	externalRepos, err := c.db.GetExternalRepositoriesForProject(task.ProjectId.String)
+	if err != nil {
+		return fmt.Errorf("could not get external repositories: %v", err)
+	}
+	for _, repo := range externalRepos {
+		lockfile.ExternalRepositories = append(lockfile.ExternalRepositories, repo.Url)
+	}
+
+	lockfileAny, err := anypb.New(lockfile)
+	if err != nil {
+		return err
+	}
+	err = c.db.SetTaskResponse(task.ID.String(), lockfileAny)
+	if err != nil {
+		return fmt.Errorf("could not set task response: %v", err)
+	}
+
+	lockfileBytes, err := protojson.MarshalOptions{Multiline: true}.Marshal(lockfile)
+	if err != nil {
+		return err
+	}
+	objectName := filepath.Join(task.ID.String(), "buildroot.json")
+	_, err = c.storage.PutObjectBytes(objectName, lockfileBytes)
+	if err != nil {
+		return fmt.Errorf("could not upload lockfile: %v", err)
+	}
+	hash := sha256.Sum256(lockfileBytes)
+
+	return c.db.AttachArtifactToTask(objectName, hex.EncodeToString(hash[:]), lockfile.Arch, nil, task.ID.String())
+}
+
 // BuildArchActivity builds a package for a given arch
 // 26.04.2022: This activity had a huge rework with shelling out and chroot
 // Previously it only used Go calls, but architectures like i686
@@ -708,6 +812,17 @@ func (c *Controller) BuildArchActivity(ctx context.Context, projectId string, pa
 	if err != nil {
 		return fmt.Errorf("could not write mock config: %v", err)
 	}
+
+	// Noarch packages are built against the repositories of the builder
+	revisionArch := arch
+	if arch == "noarch" {
+		revisionArch = hostArch
+	}
+	buildrootRevisions, err := c.buildrootRevisions(project.ID.String(), revisionArch, extraOptions)
+	if err != nil {
+		return fmt.Errorf("could not get buildroot revisions: %v", err)
+	}
+
 	args := []string{
 		"mock",
 		"--isolation=simple",
@@ -735,6 +850,21 @@ func (c *Controller) BuildArchActivity(ctx context.Context, projectId string, pa
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err = cmd.Run()
+
+	// Record the buildroot even if the build failed, as failed builds
+	// are usually the ones that need to be debugged
+	lockfile := &peridotpb.BuildrootLockfile{
+		Arch:         arch,
+		HostArch:     hostArch,
+		BuilderImage: os.Getenv("BUILDER_IMAGE"),
+		Repositories: buildrootRevisions,
+		Snapshot:     extraOptions.YumrepofsSnapshot,
+		Macros:       c.mockMacros(&project, packageVersion, extraOptions),
+	}
+	if lockErr := c.recordBuildroot(lockfile, task); lockErr != nil {
+		c.log.Errorf("could not record buildroot: %v", lockErr)
+	}
+
 	if err != nil {
 		return fmt.Errorf("could not mock build: %v", err)
 	}
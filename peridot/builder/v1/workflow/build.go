@@ -340,6 +340,12 @@ func (c *Controller) BuildWorkflow(ctx workflow.Context, req *peridotpb.SubmitBu
 		return nil, err
 	}
 
+	// A pinned buildroot snapshot is created for this build only,
+	// the lockfiles record the pinned revisions
+	if snapshot := extraOptions.GetYumrepofsSnapshot(); snapshot != "" && snapshot == BuildrootSnapshotName(task.ID.String()) {
+		defer c.deleteSnapshot(ctx, req.ProjectId, snapshot)
+	}
+
 	filters := &peridotpb.PackageFilters{}
 	switch p := req.Package.(type) {
 	case *peridotpb.SubmitBuildRequest_PackageId:
@@ -640,6 +646,12 @@ func (c *Controller) BuildWorkflow(ctx workflow.Context, req *peridotpb.SubmitBu
 
 	// Invoke build for all archs
 	var artifacts []*peridotpb.TaskArtifact
+	var archTaskIds []string
+	resources, buildTimeout := c.packageResources(ctx, project.ID.String(), pkg.Name)
+	if buildTimeout == 0 {
+		buildTimeout = 48 * time.Hour
+	}
+
 	archChannel := workflow.NewChannel(ctx)
 	for _, archTop := range arches {
 		archGoCtx := workflow.WithValue(ctx, "arch", archTop)
@@ -663,35 +675,32 @@ func (c *Controller) BuildWorkflow(ctx workflow.Context, req *peridotpb.SubmitBu
 			}
 
 			workerReq := &ProvisionWorkerRequest{
-				TaskId:       archTask.ID.String(),
 				ParentTaskId: sql.NullString{String: taskID, Valid: true},
 				Purpose:      "b-" + arch,
 				Arch:         arch,
 				ProjectId:    req.ProjectId,
 				HighResource: true,
 				Privileged:   true,
+				Resources:    resources,
 			}
-			archTaskQueue, cleanupArch, err := c.provisionWorker(ctx, workerReq)
-			if err != nil {
-				ret.err = fmt.Errorf("failed to provision worker: %s", err)
-				return
-			}
-			defer cleanupArch()
-
-			archCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-				ScheduleToStartTimeout: 12 * time.Hour,
-				StartToCloseTimeout:    48 * time.Hour,
-				HeartbeatTimeout:       12 * time.Hour,
-				TaskQueue:              archTaskQueue,
-				RetryPolicy: &temporal.RetryPolicy{
-					MaximumAttempts: 1,
-				},
+			archTaskQueue, attemptTask, cleanupArch, err := c.buildOnArchWorker(ctx, workerReq, &archTask, buildTimeout, func(ctx workflow.Context, queue string, task *models.Task, timeout time.Duration) error {
+				archCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+					ScheduleToStartTimeout: 12 * time.Hour,
+					StartToCloseTimeout:    timeout,
+					HeartbeatTimeout:       12 * time.Hour,
+					TaskQueue:              queue,
+					RetryPolicy: &temporal.RetryPolicy{
+						MaximumAttempts: 1,
+					},
+				})
+				return workflow.ExecuteActivity(archCtx, c.BuildArchActivity, project.ID.String(), pkg.Name, req.DisableChecks, packageVersion, uploadSRPMResult, task, arch, extraOptions).Get(archCtx, nil)
 			})
-			err = workflow.ExecuteActivity(archCtx, c.BuildArchActivity, project.ID.String(), pkg.Name, req.DisableChecks, packageVersion, uploadSRPMResult, archTask, arch, extraOptions).Get(archCtx, nil)
 			if err != nil {
 				ret.err = fmt.Errorf("failed to build arch %s: %s", arch, err)
 				return
 			}
+			defer cleanupArch()
+			archTaskIds = append(archTaskIds, attemptTask.ID.String())
 
 			uploadArchCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 				ScheduleToStartTimeout: 12 * time.Hour,
@@ -818,6 +827,19 @@ func (c *Controller) BuildWorkflow(ctx workflow.Context, req *peridotpb.SubmitBu
 		return nil, retErr
 	}
 
+	sbomCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    30 * time.Minute,
+		TaskQueue:              c.mainQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 3,
+		},
+	})
+	err = workflow.ExecuteActivity(sbomCtx, c.GenerateBuildSbomActivity, project.ID.String(), buildID, importRevision.ToProto(), srpmTask.ID.String(), archTaskIds, task).Get(ctx, nil)
+	if err != nil {
+		c.log.Errorf("could not generate sbom for build %s: %v", buildID, err)
+	}
+
 	submitBuildTask = peridotpb.SubmitBuildTask{
 		BuildId:        buildID,
 		BuildTaskId:    task.ID.String(),
@@ -867,6 +889,24 @@ func (c *Controller) BuildWorkflow(ctx workflow.Context, req *peridotpb.SubmitBu
 		submitBuildTask.RepoChanges = updateRepoTask
 	}
 
+	// Provenance is generated after the repo update, as the signed artifacts
+	// are only available once the artifacts have been signed for the repositories.
+	// The activity needs keykeeper, so it runs on the yumrepofs worker.
+	// The packages are already published at this point, so a failure
+	// to generate provenance doesn't fail the build
+	provenanceCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    30 * time.Minute,
+		TaskQueue:              "yumrepofs",
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 3,
+		},
+	})
+	err = workflow.ExecuteActivity(provenanceCtx, c.GenerateProvenanceActivity, project.ID.String(), buildID, pkg.Name, importRevision.ToProto(), srpmTask.ID.String(), archTaskIds, task).Get(ctx, nil)
+	if err != nil {
+		c.log.Errorf("could not generate provenance for build %s: %v", buildID, err)
+	}
+
 	// Lock NVR only once
 	effectCallNVRA := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
 		err = c.db.LockNVRA(nvr)
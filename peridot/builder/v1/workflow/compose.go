@@ -0,0 +1,323 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"peridot.resf.org/peridot/compose"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+// composeStatusFile is uploaded last, so a compose is only considered
+// complete (and its ID taken) once the file exists in storage
+const composeStatusFile = "STATUS"
+
+func (c *Controller) ComposeWorkflow(ctx workflow.Context, req *peridotpb.CreateComposeRequest, task *models.Task) (*peridotpb.CreateComposeTask, error) {
+	ret := peridotpb.CreateComposeTask{}
+
+	deferTask, errorDetails, err := c.commonCreateTask(task, &ret)
+	defer deferTask()
+	if err != nil {
+		return nil, err
+	}
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_FAILED
+
+	// Let's provision an ephemeral worker
+	taskQueue, cleanupWorker, err := c.provisionWorker(ctx, &ProvisionWorkerRequest{
+		TaskId:       task.ID.String(),
+		ParentTaskId: task.ParentTaskId,
+		Purpose:      "sync",
+		Arch:         "noarch",
+		ProjectId:    req.ProjectId.Value,
+	})
+	if err != nil {
+		setInternalError(errorDetails, err)
+		return nil, err
+	}
+	defer cleanupWorker()
+
+	composeCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    12 * time.Hour,
+		HeartbeatTimeout:       2 * time.Minute,
+		TaskQueue:              taskQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	})
+	err = workflow.ExecuteActivity(composeCtx, c.ComposeActivity, req).Get(ctx, &ret)
+	if err != nil {
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
+
+	return &ret, nil
+}
+
+func (c *Controller) ComposeActivity(ctx context.Context, req *peridotpb.CreateComposeRequest) (*peridotpb.CreateComposeTask, error) {
+	stopChan := makeHeartbeat(ctx, 10*time.Second)
+	defer func() { stopChan <- true }()
+
+	project, err := c.getSingleProject(req.ProjectId.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := c.db.GetProjectComposeConfiguration(project.ID.String())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("project %s has no compose definition", project.ID.String())
+		}
+		return nil, fmt.Errorf("could not get compose configuration: %v", err)
+	}
+
+	snapshot, err := c.db.GetSnapshotByName(project.ID.String(), req.Snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("could not get snapshot %s: %v", req.Snapshot, err)
+	}
+	snapshotRevisions, err := c.db.GetSnapshotRevisions(snapshot.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not get snapshot revisions: %v", err)
+	}
+
+	// Only load repositories that are part of a variant
+	var repoNames []string
+	for _, variant := range config.Variant {
+		if len(variant.Repository) == 0 {
+			repoNames = append(repoNames, variant.Id)
+			continue
+		}
+		repoNames = append(repoNames, variant.Repository...)
+	}
+
+	projectRepos, err := c.db.FindRepositoriesForProject(project.ID.String(), nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not list repositories: %v", err)
+	}
+	repoById := map[string]*models.Repository{}
+	for i := range projectRepos {
+		repoById[projectRepos[i].ID.String()] = &projectRepos[i]
+	}
+
+	var repos []*compose.Repository
+	for _, snapshotRevision := range snapshotRevisions {
+		if !utils.StrContains(snapshotRevision.ProjectRepoName, repoNames) {
+			continue
+		}
+		repo := repoById[snapshotRevision.ProjectRepoId]
+		if repo == nil {
+			return nil, fmt.Errorf("repository %s not found", snapshotRevision.ProjectRepoId)
+		}
+
+		revision, err := c.db.GetRepositoryRevision(snapshotRevision.ProjectRepoRevisionId)
+		if err != nil {
+			return nil, fmt.Errorf("could not get revision %s: %v", snapshotRevision.ProjectRepoRevisionId, err)
+		}
+
+		composeRepo, err := composeRepositoryFromRevision(repo, revision)
+		if err != nil {
+			return nil, fmt.Errorf("could not load revision %s of %s/%s: %v", revision.ID.String(), repo.Name, revision.Arch, err)
+		}
+		repos = append(repos, composeRepo)
+	}
+
+	// Pick the first free respin for today
+	info := &compose.Info{
+		Date:  time.Now().Format("20060102"),
+		Type:  req.ComposeType,
+		Label: req.Label,
+	}
+	for {
+		exists, err := c.storage.Exists(filepath.Join("composes", compose.ID(config, info), composeStatusFile))
+		if err != nil {
+			return nil, fmt.Errorf("could not check for existing compose: %v", err)
+		}
+		if !exists {
+			break
+		}
+		info.Respin++
+	}
+
+	cmp, err := compose.New(config, info, project.Archs, repos)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve compose: %v", err)
+	}
+	c.log.Infof("creating compose %s from snapshot %s", cmp.ID, snapshot.Name)
+
+	tmpDir, err := os.MkdirTemp("", "compose")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary directory: %v", err)
+	}
+	defer func() {
+		err := os.RemoveAll(tmpDir)
+		if err != nil {
+			c.log.Errorf("could not remove temporary directory: %v", err)
+		}
+	}()
+
+	err = cmp.Write(tmpDir, c.storage)
+	if err != nil {
+		return nil, fmt.Errorf("could not write compose: %v", err)
+	}
+
+	location := filepath.Join("composes", cmp.ID)
+	err = filepath.WalkDir(tmpDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path == filepath.Join(tmpDir, composeStatusFile) {
+			return nil
+		}
+		rel, err := filepath.Rel(tmpDir, path)
+		if err != nil {
+			return err
+		}
+		_, err = c.storage.PutObject(filepath.Join(location, rel), path)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not upload compose: %v", err)
+	}
+	_, err = c.storage.PutObject(filepath.Join(location, composeStatusFile), filepath.Join(tmpDir, composeStatusFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not upload compose status: %v", err)
+	}
+
+	return &peridotpb.CreateComposeTask{
+		ComposeId: cmp.ID,
+		Location:  location,
+		Variants:  cmp.VariantIDs(),
+	}, nil
+}
+
+// composeRepositoryFromRevision decodes the stored metadata of a repository revision
+func composeRepositoryFromRevision(repo *models.Repository, revision *models.RepositoryRevision) (*compose.Repository, error) {
+	ret := &compose.Repository{
+		Name:                  repo.Name,
+		Arch:                  revision.Arch,
+		Primary:               &yummeta.PrimaryRoot{},
+		Filelists:             &yummeta.FilelistsRoot{},
+		Other:                 &yummeta.OtherRoot{},
+		UrlMappings:           map[string]string{},
+		Multilib:              repo.Multilib,
+		AdditionalMultilib:    repo.AdditionalMultilib,
+		ExcludeMultilibFilter: repo.ExcludeMultilibFilter,
+		IncludeFilter:         repo.IncludeFilter,
+	}
+
+	primaryXml, err := decodeRevisionContent(revision.PrimaryXml)
+	if err != nil {
+		return nil, fmt.Errorf("decode primary xml: %w", err)
+	}
+	if primaryXml != nil {
+		err = yummeta.UnmarshalPrimary(primaryXml, ret.Primary)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal primary xml: %w", err)
+		}
+	}
+
+	filelistsXml, err := decodeRevisionContent(revision.FilelistsXml)
+	if err != nil {
+		return nil, fmt.Errorf("decode filelists xml: %w", err)
+	}
+	if filelistsXml != nil {
+		err = xml.Unmarshal(filelistsXml, ret.Filelists)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal filelists xml: %w", err)
+		}
+	}
+
+	otherXml, err := decodeRevisionContent(revision.OtherXml)
+	if err != nil {
+		return nil, fmt.Errorf("decode other xml: %w", err)
+	}
+	if otherXml != nil {
+		err = xml.Unmarshal(otherXml, ret.Other)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal other xml: %w", err)
+		}
+	}
+
+	ret.Modules, err = decodeRevisionContent(revision.ModulesYaml)
+	if err != nil {
+		return nil, fmt.Errorf("decode modules yaml: %w", err)
+	}
+	ret.Groups, err = decodeRevisionContent(revision.GroupsXml)
+	if err != nil {
+		return nil, fmt.Errorf("decode groups xml: %w", err)
+	}
+
+	if len(revision.UrlMappings) > 0 {
+		err = json.Unmarshal(revision.UrlMappings, &ret.UrlMappings)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal url mappings: %w", err)
+		}
+	}
+
+	return ret, nil
+}
+
+// decodeRevisionContent decodes base64 encoded and gzipped revision content,
+// returning nil for empty content
+func decodeRevisionContent(content string) ([]byte, error) {
+	if content == "" {
+		return nil, nil
+	}
+
+	var gz []byte
+	err := b64Decode(content, &gz)
+	if err != nil {
+		return nil, err
+	}
+	var ret []byte
+	err = decompressWithGz(gz, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -240,6 +240,15 @@ func (c *Controller) CreateHashedRepositoriesActivity(req *peridotpb.CreateHashe
 			var filelistsXmlName string
 			var otherXmlName string
 			var groupsXmlName string
+			// sqlite databases are regenerated by sqliterepo_c for the re-written primary
+			var repomdData []*yummeta.RepoMdData
+			for _, data := range repomdRoot.Data {
+				if strings.HasSuffix(data.Type, "_db") {
+					continue
+				}
+				repomdData = append(repomdData, data)
+			}
+			repomdRoot.Data = repomdData
 			for _, data := range repomdRoot.Data {
 				if data.Type == "primary" {
 					data.OpenChecksum.Value = newChecksums[0]
@@ -41,8 +41,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	http2 "net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -64,7 +62,6 @@ import (
 	"github.com/rocky-linux/srpmproc/pkg/srpmproc"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"github.com/xanzy/go-gitlab"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 	"google.golang.org/grpc/codes"
@@ -73,6 +70,10 @@ import (
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"peridot.resf.org/apollo/rpmutils"
 	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/peridot/forge"
+	giteaforge "peridot.resf.org/peridot/forge/gitea"
+	githubforge "peridot.resf.org/peridot/forge/github"
+	gitlabforge "peridot.resf.org/peridot/forge/gitlab"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/peridot/rpmbuild"
 	"peridot.resf.org/utils"
@@ -251,14 +252,29 @@ func (c *Controller) getAuthenticator(projectId string) (transport.AuthMethod, e
 	return authenticator, nil
 }
 
-func (c *Controller) getGitlabClient(project *models.Project) (*gitlab.Client, error) {
+// getForge returns the forge the import targets of given project are pushed to
+func (c *Controller) getForge(project *models.Project) (forge.Forge, error) {
 	// Retrieve keys for the project
 	projectKeys, err := c.db.GetProjectKeys(project.ID.String())
 	if err != nil {
 		return nil, err
 	}
 
-	return gitlab.NewClient(projectKeys.GitlabSecret, gitlab.WithBaseURL(fmt.Sprintf("%s/api/v4", project.TargetGitlabHost)))
+	switch project.TargetForge {
+	case "", forge.KindGitlab:
+		return gitlabforge.New(project.TargetGitlabHost, projectKeys.GitlabSecret)
+	case forge.KindGitea, forge.KindForgejo:
+		return giteaforge.New(project.TargetGitlabHost, projectKeys.GitlabSecret), nil
+	case forge.KindGithub:
+		return githubforge.New(project.TargetGitlabHost, projectKeys.GitlabSecret), nil
+	default:
+		return nil, fmt.Errorf("unsupported forge %s", project.TargetForge)
+	}
+}
+
+// getTargetNamespace returns the forge namespace of given section
+func getTargetNamespace(project *models.Project, section OpenPatchSection) string {
+	return strings.Trim(fmt.Sprintf("%s/%s", project.TargetPrefix, section), "/")
 }
 
 func (c *Controller) createProjectOrMakePublic(project *models.Project, packageName string, section OpenPatchSection) error {
@@ -267,33 +283,20 @@ func (c *Controller) createProjectOrMakePublic(project *models.Project, packageN
 	}
 	packageName = gitlabify(packageName)
 
-	gitlabClient, err := c.getGitlabClient(project)
-	if err != nil {
-		return err
-	}
-
-	name := url.QueryEscape(fmt.Sprintf("%s/%s", project.TargetPrefix, section))
-	ns, _, err := gitlabClient.Namespaces.GetNamespace(name)
+	f, err := c.getForge(project)
 	if err != nil {
 		return err
 	}
 
-	_, resp, err := gitlabClient.Projects.CreateProject(&gitlab.CreateProjectOptions{
-		Name:        &packageName,
-		NamespaceID: &ns.ID,
-		Visibility:  gitlab.Visibility(gitlab.PublicVisibility),
-	})
+	namespace := getTargetNamespace(project, section)
+	err = f.CreateRepository(namespace, packageName, forge.VisibilityPublic)
 	if err != nil {
-		if resp.StatusCode != http2.StatusBadRequest {
+		if err != forge.ErrRepositoryExists {
+			return err
+		}
+		err = f.SetVisibility(namespace, packageName, forge.VisibilityPublic)
+		if err != nil {
 			return err
-		} else {
-			projectName := fmt.Sprintf("%s/%s/%s", project.TargetPrefix, section, packageName)
-			_, _, err = gitlabClient.Projects.EditProject(projectName, &gitlab.EditProjectOptions{
-				Visibility: gitlab.Visibility(gitlab.PublicVisibility),
-			})
-			if err != nil {
-				return err
-			}
 		}
 	}
 
@@ -1219,23 +1222,23 @@ func (c *Controller) UpstreamDistGitActivity(ctx context.Context, greq *Upstream
 	}, nil
 }
 
-// ImportSetGitlabStatusActivity sets a successful import status on the commit for traceability
+// ImportSetCommitStatusActivity sets a successful import status on the commit for traceability
 // Later a build will also set a success/failed status on the commit (if it's queued for build)
-func (c *Controller) ImportSetGitlabStatusActivity(packageName string, project *models.Project, task *models.Task, section OpenPatchSection, shas []string, state gitlab.BuildStateValue) error {
-	gitlabClient, err := c.getGitlabClient(project)
+func (c *Controller) ImportSetCommitStatusActivity(packageName string, project *models.Project, task *models.Task, section OpenPatchSection, shas []string, state forge.CommitState) error {
+	f, err := c.getForge(project)
 	if err != nil {
 		return err
 	}
 
-	projectName := fmt.Sprintf("%s/%s/%s", project.TargetPrefix, section, packageName)
+	namespace := getTargetNamespace(project, section)
 
 	for _, sha := range shas {
-		_, _, err := gitlabClient.Commits.SetCommitStatus(projectName, sha, &gitlab.SetCommitStatusOptions{
+		err := f.SetCommitStatus(namespace, packageName, sha, &forge.CommitStatus{
 			State: state,
-			Name:  gitlab.String("peridot-import"),
+			Name:  "peridot-import",
 			// todo(mustafa): Do not hardcode rockylinux.org here
-			TargetURL:   gitlab.String(fmt.Sprintf("https://peridot.rockylinux.org/%s/tasks/%s", project.ID.String(), task.ID.String())),
-			Description: gitlab.String("Peridot Import"),
+			TargetURL:   fmt.Sprintf("https://peridot.rockylinux.org/%s/tasks/%s", project.ID.String(), task.ID.String()),
+			Description: "Peridot Import",
 		})
 		if err != nil {
 			return err
@@ -50,14 +50,9 @@ import (
 	"go.temporal.io/sdk/workflow"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
-	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	"peridot.resf.org/peridot/db/models"
 	peridotpb "peridot.resf.org/peridot/pb"
-	"peridot.resf.org/utils"
+	"peridot.resf.org/peridot/provisioner"
 )
 
 type ProvisionWorkerRequest struct {
@@ -70,6 +65,10 @@ type ProvisionWorkerRequest struct {
 	ProjectId     string         `json:"projectId"`
 	HighResource  bool           `json:"highResource"`
 	Privileged    bool           `json:"privileged"`
+	// Resources overrides the defaults of high resource workers
+	Resources *provisioner.Resources `json:"resources"`
+	// Queue the worker was provisioned from, set by provisionWorker
+	Queue string `json:"queue"`
 }
 
 func archToGoArch(arch string) string {
@@ -182,6 +181,7 @@ func (c *Controller) provisionWorker(ctx workflow.Context, req *ProvisionWorkerR
 		TaskQueue: queue,
 	})
 	req.ImageArch = imageArch
+	req.Queue = queue
 	if project.BuildPoolType.Valid {
 		req.BuildPoolType = project.BuildPoolType.String
 	} else {
@@ -263,7 +263,7 @@ func (c *Controller) ProvisionWorkerWorkflow(ctx workflow.Context, req *Provisio
 	ctx = workflow.WithActivityOptions(ctx, options)
 
 	var podName string
-	if err := workflow.ExecuteActivity(ctx, c.CreateK8sPodActivity, req, task).Get(ctx, &podName); err != nil {
+	if err := workflow.ExecuteActivity(ctx, c.CreateWorkerActivity, req, task).Get(ctx, &podName); err != nil {
 		return "", err
 	}
 
@@ -317,58 +317,37 @@ func (c *Controller) DestroyWorkerWorkflow(ctx workflow.Context, req *ProvisionW
 		HeartbeatTimeout:       10 * time.Second,
 		TaskQueue:              c.mainQueue,
 	})
-	_ = workflow.ExecuteActivity(ctx, c.DeleteK8sPodActivity, req, task).Get(ctx, nil)
+	_ = workflow.ExecuteActivity(ctx, c.DeleteWorkerActivity, req, task).Get(ctx, nil)
 
 	task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
 
 	return nil
 }
 
-// DeleteK8sPodActivity deletes the pod that hosts the ephemeral worker
-func (c *Controller) DeleteK8sPodActivity(ctx context.Context, req *ProvisionWorkerRequest, task *models.Task) error {
+// DeleteWorkerActivity deletes the ephemeral worker
+func (c *Controller) DeleteWorkerActivity(ctx context.Context, req *ProvisionWorkerRequest, task *models.Task) error {
 	stopChan := makeHeartbeat(ctx, 3*time.Second)
 	defer func() { stopChan <- true }()
 
-	// creates the in-cluster config
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return err
-	}
-	clientSet, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return err
+	if c.provisioner == nil {
+		return errors.New("no provisioner configured")
 	}
 
-	kctx := context.TODO()
 	name := c.genNameWorker(req.TaskId, req.Purpose)
 
 	_ = c.logToMon(
-		[]string{fmt.Sprintf("Deleting pod %s", name)},
+		[]string{fmt.Sprintf("Deleting worker %s", name)},
 		task.ID.String(),
 		req.ParentTaskId.String,
 	)
 
-	podInterface := clientSet.CoreV1().Pods(utils.GetKubeNS())
-	err = podInterface.Delete(kctx, name, metav1.DeleteOptions{})
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			_ = c.logToMon(
-				[]string{fmt.Sprintf("Pod %s already deleted, skipping", name)},
-				task.ID.String(),
-				req.ParentTaskId.String,
-			)
-			return nil
-		}
-		return err
-	}
-
-	return nil
+	return c.provisioner.Delete(ctx, name)
 }
 
 // IngestLogsActivity ingests the logs of the ephemeral worker
 // This is NOT running on the ephemeral worker itself, but the peridotephemeral service
 func (c *Controller) IngestLogsActivity(ctx context.Context, podName string, taskId string, parentTaskId sql.NullString) error {
-	var sinceTime metav1.Time
+	var sinceTime time.Time
 	go func() {
 		for {
 			activity.RecordHeartbeat(ctx, sinceTime)
@@ -379,18 +358,9 @@ func (c *Controller) IngestLogsActivity(ctx context.Context, podName string, tas
 		_ = activity.GetHeartbeatDetails(ctx, &sinceTime)
 	}
 
-	// creates the in-cluster config
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		logrus.Errorf("failed to create in-cluster config: %v", err)
-		return err
+	if c.provisioner == nil {
+		return errors.New("no provisioner configured")
 	}
-	clientSet, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		logrus.Errorf("failed to create k8s client: %v", err)
-	}
-
-	podInterface := clientSet.CoreV1().Pods(utils.GetKubeNS())
 
 	var parentTask string
 	if parentTaskId.Valid {
@@ -400,24 +370,18 @@ func (c *Controller) IngestLogsActivity(ctx context.Context, podName string, tas
 	}
 
 	for {
-		_, err := podInterface.Get(context.TODO(), podName, metav1.GetOptions{})
+		exists, err := c.provisioner.Exists(ctx, podName)
 		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				break
-			}
 			time.Sleep(time.Second)
 			continue
 		}
+		if !exists {
+			break
+		}
 
-		logReq := podInterface.GetLogs(podName, &v1.PodLogOptions{
-			Container: "builder",
-			Follow:    true,
-			SinceTime: &sinceTime,
-		})
-		kctx := context.TODO()
-		podLogs, err := logReq.Stream(kctx)
+		podLogs, err := c.provisioner.Logs(ctx, podName, sinceTime)
 		if err != nil {
-			logrus.Errorf("failed to get logs for pod %s: %v", podName, err)
+			logrus.Errorf("failed to get logs for worker %s: %v", podName, err)
 			return err
 		}
 
@@ -447,33 +411,61 @@ func (c *Controller) IngestLogsActivity(ctx context.Context, podName string, tas
 				c.log.Errorf("could not log to mon: %v", err)
 			}
 		}
-		sinceTime = metav1.Time{Time: time.Now()}
+		sinceTime = time.Now()
 
 		err = podLogs.Close()
 		if err != nil {
-			logrus.Errorf("failed to close pod logs: %v", err)
+			logrus.Errorf("failed to close worker logs: %v", err)
 		}
 	}
 
 	return nil
 }
 
-// CreateK8sPodActivity creates a new pod in the same namespace
-// with the specified container (the container has to contain peridotbuilder)
-func (c *Controller) CreateK8sPodActivity(ctx context.Context, req *ProvisionWorkerRequest, task *models.Task) (string, error) {
+// WatchWorkerActivity returns the reason the provisioner terminated the ephemeral worker for.
+// If follow is set, the activity polls until the worker is terminated or the activity is cancelled.
+// An empty reason is returned if the provisioner can't report termination reasons
+func (c *Controller) WatchWorkerActivity(ctx context.Context, req *ProvisionWorkerRequest, follow bool) (string, error) {
 	stopChan := makeHeartbeat(ctx, 3*time.Second)
 	defer func() { stopChan <- true }()
 
-	task.Status = peridotpb.TaskStatus_TASK_STATUS_FAILED
+	reasoner, ok := c.provisioner.(provisioner.TerminationReasoner)
+	if !ok {
+		if follow {
+			<-ctx.Done()
+		}
+		return "", nil
+	}
 
-	// creates the in-cluster config
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return "", err
+	name := c.genNameWorker(req.TaskId, req.Purpose)
+	for {
+		reason, err := reasoner.TerminationReason(ctx, name)
+		if err != nil {
+			if !follow {
+				return "", err
+			}
+			c.log.Errorf("could not get termination reason for worker %s: %v", name, err)
+		} else if reason != "" || !follow {
+			return reason, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
 	}
-	clientSet, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return "", err
+}
+
+// CreateWorkerActivity creates a new ephemeral worker using the configured provisioner
+func (c *Controller) CreateWorkerActivity(ctx context.Context, req *ProvisionWorkerRequest, task *models.Task) (string, error) {
+	stopChan := makeHeartbeat(ctx, 3*time.Second)
+	defer func() { stopChan <- true }()
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_FAILED
+
+	if c.provisioner == nil {
+		return "", errors.New("no provisioner configured")
 	}
 
 	production := "false"
@@ -491,17 +483,9 @@ func (c *Controller) CreateK8sPodActivity(ctx context.Context, req *ProvisionWor
 		fmt.Sprintf("Go arch: %s", goArch),
 	}, task.ID.String(), req.ParentTaskId.String)
 
+	// Not all provisioners use images, so the provisioner validates the image
 	image := viper.GetString(fmt.Sprintf("builder-oci-image-%s", imageArch))
-	if image == "" {
-		return "", fmt.Errorf("could not find builder-oci-image for arch %s", imageArch)
-	}
 
-	imagePullPolicy := v1.PullIfNotPresent
-	if !viper.GetBool("production") {
-		imagePullPolicy = v1.PullAlways
-	}
-
-	podInterface := clientSet.CoreV1().Pods(utils.GetKubeNS())
 	name := c.genNameWorker(req.TaskId, req.Purpose)
 
 	args := []string{
@@ -541,212 +525,26 @@ func (c *Controller) CreateK8sPodActivity(ctx context.Context, req *ProvisionWor
 		args = append(args, "S3_FORCE_PATH_STYLE=true")
 	}
 
-	var imagePullSecrets []v1.LocalObjectReference
-	if secret := os.Getenv("IMAGE_PULL_SECRET"); secret != "" {
-		imagePullSecrets = []v1.LocalObjectReference{
-			{
-				Name: secret,
-			},
-		}
+	if storageBackend := viper.GetString("storage-backend"); storageBackend != "" {
+		args = append(args, "STORAGE_BACKEND="+storageBackend, "STORAGE_PATH="+viper.GetString("storage-path"))
 	}
 
-	memoryQuantity, err := resource.ParseQuantity("4Gi")
-	if err != nil {
-		return "", err
-	}
-	ephemeralQuantity, err := resource.ParseQuantity("10Gi")
-	if err != nil {
-		return "", err
-	}
-	cpuQuantity, err := resource.ParseQuantity("1")
-	if err != nil {
-		return "", err
-	}
-	resourceRequirements := v1.ResourceRequirements{
-		Requests: v1.ResourceList{
-			v1.ResourceCPU:              cpuQuantity,
-			v1.ResourceMemory:           memoryQuantity,
-			v1.ResourceEphemeralStorage: ephemeralQuantity,
-		},
-	}
-
-	// For now disable resource requirements in dev
-	if os.Getenv("LOCALSTACK_ENDPOINT") != "" || !req.HighResource {
-		resourceRequirements = v1.ResourceRequirements{}
-	}
-
-	command := fmt.Sprintf("/bundle/peridotbuilder_%s", goArch)
-	podConfig := &v1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: utils.GetKubeNS(),
-			Labels: map[string]string{
-				"peridot.rockylinux.org/managed-by":              "peridotephemeral",
-				"peridot.rockylinux.org/task-id":                 req.TaskId,
-				"peridot.rockylinux.org/name":                    name,
-				"peridot.rockylinux.org/workflow-tolerates-arch": nodePoolArch,
-				// todo(mustafa): Implement janitor (cron workflow?)
-				"janitor.peridot.rockylinux.org/allow-cleanup":   "yes",
-				"janitor.peridot.rockylinux.org/cleanup-timeout": "864000s",
-			},
-			Annotations: map[string]string{
-				"cluster-autoscaler.kubernetes.io/safe-to-evict": "false",
-			},
-		},
-		Spec: v1.PodSpec{
-			ServiceAccountName: os.Getenv("RESF_SERVICE_ACCOUNT"),
-			ImagePullSecrets:   imagePullSecrets,
-			Containers: []v1.Container{
-				{
-					Name:  "builder",
-					Image: image,
-					Args:  []string{command},
-					Env: []v1.EnvVar{
-						{
-							Name:  "RESF_ENV",
-							Value: os.Getenv("RESF_ENV"),
-						},
-						{
-							Name:  "RESF_NS",
-							Value: os.Getenv("RESF_NS"),
-						},
-						{
-							Name:  "RESF_FORCE_NS",
-							Value: os.Getenv("RESF_FORCE_NS"),
-						},
-						{
-							Name:  "LOCALSTACK_ENDPOINT",
-							Value: os.Getenv("LOCALSTACK_ENDPOINT"),
-						},
-						{
-							Name:  "REAL_BUILD_ARCH",
-							Value: imageArch,
-						},
-						{
-							Name:  "TEMPORAL_NAMESPACE",
-							Value: viper.GetString("temporal.namespace"),
-						},
-						{
-							Name:  "KEYKEEPER_GRPC_ENDPOINT_OVERRIDE",
-							Value: os.Getenv("KEYKEEPER_GRPC_ENDPOINT_OVERRIDE"),
-						},
-						{
-							Name:  "YUMREPOFS_HTTP_ENDPOINT_OVERRIDE",
-							Value: os.Getenv("YUMREPOFS_HTTP_ENDPOINT_OVERRIDE"),
-						},
-					},
-					// todo(mustafa): Figure out good limitations
-					// todo(mustafa): We will probably generate analysis based upper limits
-					// todo(mustafa): Defaulting to 1cpu/4GiB for packages without metrics
-					Resources:       resourceRequirements,
-					ImagePullPolicy: imagePullPolicy,
-					VolumeMounts: []v1.VolumeMount{
-						{
-							Name:      "urandom",
-							MountPath: "/dev/random",
-						},
-						{
-							Name:      "security-limits",
-							MountPath: "/etc/security/limits.conf",
-						},
-					},
-				},
-			},
-			Volumes: []v1.Volume{
-				{
-					Name: "urandom",
-					VolumeSource: v1.VolumeSource{
-						HostPath: &v1.HostPathVolumeSource{
-							Path: "/dev/urandom",
-						},
-					},
-				},
-				{
-					Name: "security-limits",
-					VolumeSource: v1.VolumeSource{
-						HostPath: &v1.HostPathVolumeSource{
-							Path: "/etc/security/limits.conf",
-						},
-					},
-				},
-			},
-			Tolerations: []v1.Toleration{
-				{
-					Key:      "peridot.rockylinux.org/workflow-tolerates-arch",
-					Operator: v1.TolerationOpEqual,
-					Value:    nodePoolArch,
-					Effect:   v1.TaintEffectNoSchedule,
-				},
-			},
-			RestartPolicy: v1.RestartPolicyOnFailure,
-		},
+	env := []string{
+		"RESF_ENV=" + os.Getenv("RESF_ENV"),
+		"RESF_NS=" + os.Getenv("RESF_NS"),
+		"RESF_FORCE_NS=" + os.Getenv("RESF_FORCE_NS"),
+		"LOCALSTACK_ENDPOINT=" + os.Getenv("LOCALSTACK_ENDPOINT"),
+		"REAL_BUILD_ARCH=" + imageArch,
+		"BUILDER_IMAGE=" + image,
+		"TEMPORAL_NAMESPACE=" + viper.GetString("temporal.namespace"),
+		"KEYKEEPER_GRPC_ENDPOINT_OVERRIDE=" + os.Getenv("KEYKEEPER_GRPC_ENDPOINT_OVERRIDE"),
+		"YUMREPOFS_HTTP_ENDPOINT_OVERRIDE=" + os.Getenv("YUMREPOFS_HTTP_ENDPOINT_OVERRIDE"),
 	}
 	for _, arg := range args {
-		envSplit := strings.SplitN(arg, "=", 2)
-		if len(envSplit) != 2 {
-			continue
-		}
-		podConfig.Spec.Containers[0].Env = append(podConfig.Spec.Containers[0].Env, v1.EnvVar{
-			Name:  fmt.Sprintf("PERIDOTBUILDER_%s", envSplit[0]),
-			Value: envSplit[1],
-		})
+		env = append(env, fmt.Sprintf("PERIDOTBUILDER_%s", arg))
 	}
 	if aki := os.Getenv("AWS_ACCESS_KEY_ID"); aki != "" {
-		podConfig.Spec.Containers[0].Env = append(podConfig.Spec.Containers[0].Env, v1.EnvVar{
-			Name:  "AWS_ACCESS_KEY_ID",
-			Value: aki,
-		})
-		podConfig.Spec.Containers[0].Env = append(podConfig.Spec.Containers[0].Env, v1.EnvVar{
-			Name:  "AWS_SECRET_ACCESS_KEY",
-			Value: os.Getenv("AWS_SECRET_ACCESS_KEY"),
-		})
-	}
-	if os.Getenv("PERIDOT_SITE") == "extarches" {
-		podConfig.Spec.DNSPolicy = v1.DNSNone
-		podConfig.Spec.DNSConfig = &v1.PodDNSConfig{
-			Nameservers: []string{
-				"1.1.1.1",
-				"1.0.0.1",
-			},
-			Searches: []string{
-				"peridotephemeral.svc.cluster.local",
-			},
-		}
-	}
-
-	// Build containers may require a privileged container
-	// to do necessary mounting within the chroot.
-	// The privileges are dropped soon after
-	if req.Privileged {
-		podConfig.Spec.Containers[0].SecurityContext = &v1.SecurityContext{
-			RunAsUser:                utils.Pointer[int64](0),
-			RunAsGroup:               utils.Pointer[int64](0),
-			RunAsNonRoot:             utils.Pointer[bool](false),
-			ReadOnlyRootFilesystem:   utils.Pointer[bool](false),
-			AllowPrivilegeEscalation: utils.Pointer[bool](true),
-			Privileged:               utils.Pointer[bool](true),
-		}
-	}
-
-	// Set node toleration to the specific arch
-	if !viper.GetBool("k8s-supports-cross-platform-no-affinity") {
-		podConfig.Spec.Affinity = &v1.Affinity{
-			NodeAffinity: &v1.NodeAffinity{
-				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
-					NodeSelectorTerms: []v1.NodeSelectorTerm{
-						{
-							MatchExpressions: []v1.NodeSelectorRequirement{
-								{
-									Key:      "peridot.rockylinux.org/workflow-tolerates-arch",
-									Operator: "In",
-									Values:   []string{nodePoolArch},
-								},
-							},
-						},
-					},
-				},
-			},
-		}
+		env = append(env, "AWS_ACCESS_KEY_ID="+aki, "AWS_SECRET_ACCESS_KEY="+os.Getenv("AWS_SECRET_ACCESS_KEY"))
 	}
 
 	metadata := &peridotpb.ProvisionWorkerMetadata{
@@ -754,6 +552,14 @@ func (c *Controller) CreateK8sPodActivity(ctx context.Context, req *ProvisionWor
 		Purpose: req.Purpose,
 		TaskId:  req.TaskId,
 	}
+	if req.Resources != nil {
+		metadata.Resources = &peridotpb.ResourceProfile{
+			Cpu:              req.Resources.CPU,
+			Memory:           req.Resources.Memory,
+			EphemeralStorage: req.Resources.EphemeralStorage,
+			NodeSelector:     req.Resources.NodeSelector,
+		}
+	}
 	metadataAny, err := anypb.New(metadata)
 	if err != nil {
 		return "", fmt.Errorf("could not create metadata any: %v", err)
@@ -763,48 +569,31 @@ func (c *Controller) CreateK8sPodActivity(ctx context.Context, req *ProvisionWor
 		return "", fmt.Errorf("could not set task metadata: %v", err)
 	}
 
-	pod, err := podInterface.Create(ctx, podConfig, metav1.CreateOptions{})
+	err = c.provisioner.Create(ctx, &provisioner.Worker{
+		Name:         name,
+		Purpose:      req.Purpose,
+		TaskId:       req.TaskId,
+		Arch:         imageArch,
+		GoArch:       goArch,
+		PoolArch:     nodePoolArch,
+		Image:        image,
+		Env:          env,
+		HighResource: req.HighResource,
+		Privileged:   req.Privileged,
+		Resources:    req.Resources,
+	})
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
-			return name, nil
+		if errors.Is(err, provisioner.ErrWorkerFailed) {
+			return "", temporal.NewNonRetryableApplicationError("pod failed", "Failed pod", nil, name)
 		}
-		return "", fmt.Errorf("could not create pod: %v", err)
-	}
-
-	runningCount := 0
-	for {
-		if runningCount >= 3 {
-			break
-		}
-		pod, err := podInterface.Get(ctx, pod.Name, metav1.GetOptions{})
-		if err != nil {
-			return "", fmt.Errorf("could not get pod: %v", err)
-		}
-
-		if pod.Status.Phase == v1.PodFailed {
-			return "", temporal.NewNonRetryableApplicationError("pod failed", "Failed pod", nil, pod.Name)
-		}
-		if pod.Status.Phase == v1.PodRunning {
-			runningCount += 1
-			break
-		}
-
-		time.Sleep(time.Second)
-	}
-
-	pod, err = podInterface.Get(ctx, pod.Name, metav1.GetOptions{})
-	if err != nil {
-		return "", fmt.Errorf("could not get pod: %v", err)
-	}
-	if pod.Status.Phase == v1.PodFailed {
-		return "", fmt.Errorf("pod failed")
+		return "", fmt.Errorf("could not create worker: %v", err)
 	}
 
 	_ = c.logToMon(
-		[]string{fmt.Sprintf("Created worker %s", pod.Name)},
+		[]string{fmt.Sprintf("Created worker %s", name)},
 		task.ID.String(),
 		req.ParentTaskId.String,
 	)
 
-	return pod.Name, nil
+	return name, nil
 }
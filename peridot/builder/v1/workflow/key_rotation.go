@@ -0,0 +1,131 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	yumrepofspb "peridot.resf.org/peridot/yumrepofs/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+// KeyRotationWorkflow re-signs all builds that are active in the project's repositories.
+// The new key has already been made the default key when the workflow starts,
+// so RepoUpdaterWorkflow signs the artifacts with the new key and points
+// the repositories at the re-signed artifacts.
+// Artifacts already signed with the new key are not signed again.
+func (c *Controller) KeyRotationWorkflow(ctx workflow.Context, req *peridotpb.RotateProjectKeyRequest, previousKeyName string, task *models.Task) (*peridotpb.RotateProjectKeyTask, error) {
+	ret := peridotpb.RotateProjectKeyTask{
+		KeyName:         req.KeyName,
+		PreviousKeyName: previousKeyName,
+	}
+	deferTask, errorDetails, err := c.commonCreateTask(task, &ret)
+	defer deferTask()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SkipResign {
+		task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
+		return &ret, nil
+	}
+
+	var batches [][]string
+	activeBuildsCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    60 * time.Minute,
+		TaskQueue:              c.mainQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	})
+	err = workflow.ExecuteActivity(activeBuildsCtx, c.ActiveBuildBatchesActivity, req.ProjectId.Value, task).Get(ctx, &batches)
+	if err != nil {
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+
+	for _, batch := range batches {
+		yumrepoCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			TaskQueue: "yumrepofs",
+		})
+		taskID := task.ID.String()
+		updateRepoRequest := &UpdateRepoRequest{
+			ProjectID:        req.ProjectId.Value,
+			BuildIDs:         batch,
+			Delete:           false,
+			TaskID:           &taskID,
+			NoDeletePrevious: true,
+		}
+		updateRepoTask := &yumrepofspb.UpdateRepoTask{}
+		err = workflow.ExecuteChildWorkflow(yumrepoCtx, c.RepoUpdaterWorkflow, updateRepoRequest).Get(yumrepoCtx, updateRepoTask)
+		if err != nil {
+			setActivityError(errorDetails, err)
+			return nil, err
+		}
+		ret.ResignedBuildIds = append(ret.ResignedBuildIds, batch...)
+	}
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
+
+	return &ret, nil
+}
+
+// ActiveBuildBatchesActivity returns the builds that are active in the project's
+// repositories, in batches of 200 builds
+func (c *Controller) ActiveBuildBatchesActivity(ctx context.Context, projectId string, task *models.Task) ([][]string, error) {
+	builds, err := c.db.GetActiveBuildIdsForProject(projectId)
+	if err != nil {
+		return nil, fmt.Errorf("could not list active builds: %v", err)
+	}
+
+	var batches [][]string
+	for i := 0; i < len(builds); i += 200 {
+		end := i + 200
+		if end > len(builds) {
+			end = len(builds)
+		}
+		batches = append(batches, builds[i:end])
+	}
+
+	_ = c.logToMon(
+		[]string{fmt.Sprintf("Re-signing %d active builds in %d batches", len(builds), len(batches))},
+		task.ID.String(),
+		utils.NullStringToEmptyString(task.ParentTaskId),
+	)
+
+	return batches, nil
+}
@@ -0,0 +1,613 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"peridot.resf.org/apollo/rpmutils"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+	"peridot.resf.org/utils"
+)
+
+// MassRebuildPlan is the build order computed for a mass rebuild
+type MassRebuildPlan struct {
+	Waves [][]string `json:"waves"`
+	// Dependencies maps packages to the packages of the rebuild they build require
+	Dependencies map[string][]string `json:"dependencies"`
+	Cycles       [][]string          `json:"cycles"`
+	// Broken maps packages to build requires nothing provides
+	Broken map[string][]string `json:"broken"`
+	// Unknown packages have no source metadata in the project repositories
+	Unknown []string `json:"unknown"`
+}
+
+// MassRebuildWaveResult lists the packages built by MassRebuildWaveWorkflow by outcome
+type MassRebuildWaveResult struct {
+	Succeeded []string `json:"succeeded"`
+	Failed    []string `json:"failed"`
+}
+
+// massRebuildChunkSize is the number of builds a MassRebuildWaveWorkflow starts.
+// Every build adds a few events to the history of the workflow that started it,
+// so waves are split to stay well below the history limit of Temporal
+const massRebuildChunkSize = 500
+
+// richDependencyKeywords are the operators of rich (boolean) dependencies
+var richDependencyKeywords = map[string]bool{
+	"and":     true,
+	"or":      true,
+	"if":      true,
+	"else":    true,
+	"with":    true,
+	"without": true,
+	"unless":  true,
+}
+
+// richDependency is a parsed rich (boolean) dependency such as "(foo >= 1.0 or (bar and baz))".
+// Plain capabilities only have a name, the version is dropped
+type richDependency struct {
+	name     string
+	operator string
+	// For if and unless, the operands are the dependency, the condition and the else branch
+	operands []*richDependency
+}
+
+// parseDependency parses a requirement, which is a plain capability unless it starts with "("
+func parseDependency(requirement string) (*richDependency, error) {
+	if !strings.HasPrefix(requirement, "(") {
+		return &richDependency{name: requirement}, nil
+	}
+
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(requirement))
+	dep, pos, err := parseRichDependency(tokens, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("unexpected %s in %s", tokens[pos], requirement)
+	}
+
+	return dep, nil
+}
+
+// parseRichDependency parses an operand starting at tokens[pos] and returns the position after it
+func parseRichDependency(tokens []string, pos int) (*richDependency, int, error) {
+	if pos >= len(tokens) {
+		return nil, pos, errors.New("unexpected end of dependency")
+	}
+	if tokens[pos] != "(" {
+		if tokens[pos] == ")" || richDependencyKeywords[tokens[pos]] {
+			return nil, pos, fmt.Errorf("unexpected %s", tokens[pos])
+		}
+		dep := &richDependency{name: tokens[pos]}
+		pos++
+		// Skip the version comparison
+		if pos+1 < len(tokens) && strings.ContainsAny(tokens[pos][:1], "<>=") {
+			pos += 2
+		}
+		return dep, pos, nil
+	}
+
+	operand, pos, err := parseRichDependency(tokens, pos+1)
+	if err != nil {
+		return nil, pos, err
+	}
+	dep := &richDependency{
+		operands: []*richDependency{operand},
+	}
+	for pos < len(tokens) && tokens[pos] != ")" {
+		keyword := tokens[pos]
+		if !richDependencyKeywords[keyword] {
+			return nil, pos, fmt.Errorf("unexpected %s", keyword)
+		}
+		if dep.operator == "" {
+			dep.operator = keyword
+		} else if keyword != dep.operator && !(keyword == "else" && (dep.operator == "if" || dep.operator == "unless")) {
+			return nil, pos, fmt.Errorf("cannot mix %s and %s", dep.operator, keyword)
+		}
+		operand, pos, err = parseRichDependency(tokens, pos+1)
+		if err != nil {
+			return nil, pos, err
+		}
+		dep.operands = append(dep.operands, operand)
+	}
+	if pos >= len(tokens) {
+		return nil, pos, errors.New("missing )")
+	}
+
+	// "(foo)" is the same as foo
+	if dep.operator == "" {
+		return operand, pos + 1, nil
+	}
+
+	return dep, pos + 1, nil
+}
+
+// sourcePackageName returns the name of the source package a binary package was built from
+func sourcePackageName(pkg *yummeta.PrimaryPackage) string {
+	if pkg.Format == nil || pkg.Format.RpmSourceRpm == "" {
+		return pkg.Name
+	}
+	nvr := rpmutils.NVR().FindStringSubmatch(strings.TrimSuffix(pkg.Format.RpmSourceRpm, ".src.rpm"))
+	if nvr == nil {
+		return pkg.Name
+	}
+
+	return nvr[1]
+}
+
+// providerIndex maps capabilities (package names, provides and files)
+// to the source packages providing them
+type providerIndex map[string]map[string]bool
+
+func (p providerIndex) add(capability string, source string) {
+	if p[capability] == nil {
+		p[capability] = map[string]bool{}
+	}
+	p[capability][source] = true
+}
+
+// resolve returns the source packages a dependency needs, and whether it can be satisfied.
+// All operands of and/with are needed, while only the first satisfiable alternative
+// of or/if/unless is used
+func (p providerIndex) resolve(dep *richDependency) (map[string]bool, bool) {
+	switch dep.operator {
+	case "":
+		sources, ok := p[dep.name]
+		return sources, ok
+	case "and", "with":
+		ret := map[string]bool{}
+		for _, operand := range dep.operands {
+			sources, ok := p.resolve(operand)
+			if !ok {
+				return nil, false
+			}
+			for source := range sources {
+				ret[source] = true
+			}
+		}
+		return ret, true
+	case "or":
+		for _, operand := range dep.operands {
+			if sources, ok := p.resolve(operand); ok {
+				return sources, true
+			}
+		}
+		return nil, false
+	case "if", "unless":
+		// The condition can't be evaluated before the buildroot exists,
+		// so the dependency is preferred over the else branch
+		if sources, ok := p.resolve(dep.operands[0]); ok {
+			return sources, true
+		}
+		if len(dep.operands) > 2 {
+			return p.resolve(dep.operands[2])
+		}
+		// Without an else branch the dependency may not be needed at all
+		return nil, true
+	case "without":
+		return p.resolve(dep.operands[0])
+	}
+
+	return nil, false
+}
+
+// buildOrder condenses the dependency graph into strongly connected components
+// (Tarjan's algorithm) and groups the components into waves.
+// A component is placed in the wave after the last wave one of its dependencies is in.
+// Returns the waves and every component with more than one package (cycles)
+func buildOrder(packages []string, dependencies map[string][]string) ([][]string, [][]string) {
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	component := map[string]int{}
+	var components [][]string
+
+	var strongConnect func(name string)
+	strongConnect = func(name string) {
+		index[name] = len(index)
+		lowLink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range dependencies[name] {
+			if _, visited := index[dep]; !visited {
+				strongConnect(dep)
+				if lowLink[dep] < lowLink[name] {
+					lowLink[name] = lowLink[dep]
+				}
+			} else if onStack[dep] && index[dep] < lowLink[name] {
+				lowLink[name] = index[dep]
+			}
+		}
+
+		if lowLink[name] == index[name] {
+			var members []string
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				component[member] = len(components)
+				members = append(members, member)
+				if member == name {
+					break
+				}
+			}
+			sort.Strings(members)
+			components = append(components, members)
+		}
+	}
+	for _, name := range packages {
+		if _, visited := index[name]; !visited {
+			strongConnect(name)
+		}
+	}
+
+	// Tarjan's algorithm emits components in reverse topological order,
+	// so dependencies of a component always have their wave assigned already
+	componentWave := make([]int, len(components))
+	var waves [][]string
+	var cycles [][]string
+	for i, members := range components {
+		wave := 0
+		for _, member := range members {
+			for _, dep := range dependencies[member] {
+				if component[dep] != i && componentWave[component[dep]]+1 > wave {
+					wave = componentWave[component[dep]] + 1
+				}
+			}
+		}
+		componentWave[i] = wave
+
+		for len(waves) <= wave {
+			waves = append(waves, []string{})
+		}
+		waves[wave] = append(waves[wave], members...)
+		if len(members) > 1 {
+			cycles = append(cycles, members)
+		}
+	}
+	for _, wave := range waves {
+		sort.Strings(wave)
+	}
+
+	return waves, cycles
+}
+
+// activePrimary returns the primary metadata of the active revision of a repository.
+// Returns nil if the repository doesn't have a revision for the arch
+func (c *Controller) activePrimary(repoId string, arch string) (*yummeta.PrimaryRoot, error) {
+	revision, err := c.db.GetLatestActiveRepositoryRevision(repoId, arch)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if revision.PrimaryXml == "" {
+		return nil, nil
+	}
+
+	var primaryXmlGz []byte
+	var primaryXml []byte
+	var primaryRoot yummeta.PrimaryRoot
+	err = multiErrorCheck(
+		b64Decode(revision.PrimaryXml, &primaryXmlGz),
+		decompressWithGz(primaryXmlGz, &primaryXml),
+		yummeta.UnmarshalPrimary(primaryXml, &primaryRoot),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &primaryRoot, nil
+}
+
+// PlanMassRebuildActivity reads the BuildRequires of the given packages from the source
+// repositories of the project, resolves the providers against the binary repositories
+// and computes the order the packages have to be built in
+func (c *Controller) PlanMassRebuildActivity(ctx context.Context, projectId string, packageNames []string) (*MassRebuildPlan, error) {
+	stopChan := makeHeartbeat(ctx, 3*time.Second)
+	defer func() { stopChan <- true }()
+
+	projects, err := c.db.ListProjects(&peridotpb.ProjectFilters{
+		Id: wrapperspb.String(projectId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list projects: %v", err)
+	}
+	if len(projects) != 1 {
+		return nil, fmt.Errorf("project %s not found", projectId)
+	}
+	project := projects[0]
+	if len(project.Archs) == 0 {
+		return nil, fmt.Errorf("project %s has no architectures", projectId)
+	}
+
+	repos, err := c.db.FindRepositoriesForProject(projectId, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not list repositories: %v", err)
+	}
+
+	// The SRPM entries of the source repositories list the BuildRequires.
+	// Providers are resolved using the primary architecture of the project,
+	// which also contains all noarch packages
+	buildRequires := map[string]map[string]bool{}
+	providers := providerIndex{}
+	for _, repo := range repos {
+		for _, arch := range []string{"src", project.Archs[0]} {
+			primary, err := c.activePrimary(repo.ID.String(), arch)
+			if err != nil {
+				return nil, fmt.Errorf("could not get primary metadata for %s/%s: %v", repo.Name, arch, err)
+			}
+			if primary == nil {
+				continue
+			}
+
+			for _, pkg := range primary.Packages {
+				if arch == "src" {
+					if buildRequires[pkg.Name] == nil {
+						buildRequires[pkg.Name] = map[string]bool{}
+					}
+					if pkg.Format != nil && pkg.Format.RpmRequires != nil {
+						for _, entry := range pkg.Format.RpmRequires.RpmEntries {
+							if !strings.HasPrefix(entry.Name, "rpmlib(") {
+								buildRequires[pkg.Name][entry.Name] = true
+							}
+						}
+					}
+					continue
+				}
+
+				source := sourcePackageName(pkg)
+				providers.add(pkg.Name, source)
+				if pkg.Format == nil {
+					continue
+				}
+				if pkg.Format.RpmProvides != nil {
+					for _, entry := range pkg.Format.RpmProvides.RpmEntries {
+						providers.add(entry.Name, source)
+					}
+				}
+				for _, file := range pkg.Format.File {
+					providers.add(file.Value, source)
+				}
+			}
+		}
+	}
+
+	rebuild := map[string]bool{}
+	for _, name := range packageNames {
+		rebuild[name] = true
+	}
+
+	plan := &MassRebuildPlan{
+		Dependencies: map[string][]string{},
+		Broken:       map[string][]string{},
+	}
+	for _, name := range packageNames {
+		requirements, ok := buildRequires[name]
+		if !ok {
+			plan.Unknown = append(plan.Unknown, name)
+			continue
+		}
+
+		deps := map[string]bool{}
+		for requirement := range requirements {
+			dep, err := parseDependency(requirement)
+			if err != nil {
+				plan.Broken[name] = append(plan.Broken[name], requirement)
+				continue
+			}
+			sources, resolved := providers.resolve(dep)
+			for source := range sources {
+				if source != name && rebuild[source] {
+					deps[source] = true
+				}
+			}
+			// Primary metadata only lists a subset of all files,
+			// so unresolved file requirements may be provided anyway
+			if !resolved && !strings.HasPrefix(requirement, "/") {
+				plan.Broken[name] = append(plan.Broken[name], requirement)
+			}
+		}
+		sort.Strings(plan.Broken[name])
+
+		for dep := range deps {
+			plan.Dependencies[name] = append(plan.Dependencies[name], dep)
+		}
+		sort.Strings(plan.Dependencies[name])
+	}
+
+	sortedNames := append([]string{}, packageNames...)
+	sort.Strings(sortedNames)
+	plan.Waves, plan.Cycles = buildOrder(sortedNames, plan.Dependencies)
+
+	return plan, nil
+}
+
+// MassRebuildWorkflow rebuilds packages in the order of their build requirements.
+// A wave only starts once all builds of the previous wave finished. As every build
+// updates the repositories with RepoUpdaterWorkflow before finishing, each wave builds
+// against the packages of the waves before it.
+// The builds of a wave are started by MassRebuildWaveWorkflow children, at most
+// massRebuildChunkSize each, so the history of this workflow only grows with the waves
+func (c *Controller) MassRebuildWorkflow(ctx workflow.Context, req *peridotpb.SubmitMassRebuildRequest, packageNames []string, buildBatchId string, task *models.Task, user *utils.ContextUser) (*peridotpb.MassRebuildTask, error) {
+	ret := &peridotpb.MassRebuildTask{
+		BuildBatchId: buildBatchId,
+	}
+
+	deferTask, errorDetails, err := c.commonCreateTask(task, ret)
+	defer deferTask()
+	if err != nil {
+		return nil, err
+	}
+
+	planCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    2 * time.Hour,
+		HeartbeatTimeout:       time.Minute,
+		TaskQueue:              c.mainQueue,
+	})
+	var plan MassRebuildPlan
+	err = workflow.ExecuteActivity(planCtx, c.PlanMassRebuildActivity, req.ProjectId, packageNames).Get(ctx, &plan)
+	if err != nil {
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+
+	waveOf := map[string]int{}
+	for i, wave := range plan.Waves {
+		for _, name := range wave {
+			waveOf[name] = i
+		}
+		ret.Waves = append(ret.Waves, &peridotpb.MassRebuildWave{
+			PackageNames: wave,
+		})
+	}
+	for _, cycle := range plan.Cycles {
+		ret.Cycles = append(ret.Cycles, &peridotpb.MassRebuildCycle{
+			PackageNames: cycle,
+		})
+	}
+	ret.Unknown = plan.Unknown
+
+	// Packages that won't have a new build in the repositories
+	unavailable := map[string]bool{}
+	for i, wave := range plan.Waves {
+		var names []string
+		for _, name := range wave {
+			if unresolved := plan.Broken[name]; len(unresolved) > 0 {
+				ret.Broken = append(ret.Broken, &peridotpb.MassRebuildBrokenPackage{
+					PackageName: name,
+					Unresolved:  unresolved,
+				})
+				unavailable[name] = true
+				continue
+			}
+
+			// Packages of the same cycle build against the existing builds,
+			// so only dependencies from earlier waves can block
+			var blockedBy []string
+			for _, dep := range plan.Dependencies[name] {
+				if unavailable[dep] && waveOf[dep] < i {
+					blockedBy = append(blockedBy, dep)
+				}
+			}
+			if len(blockedBy) > 0 {
+				ret.Blocked = append(ret.Blocked, &peridotpb.MassRebuildBlockedPackage{
+					PackageName: name,
+					BlockedBy:   blockedBy,
+				})
+				unavailable[name] = true
+				continue
+			}
+
+			names = append(names, name)
+		}
+
+		var chunks [][]string
+		var futures []workflow.Future
+		for start := 0; start < len(names); start += massRebuildChunkSize {
+			end := start + massRebuildChunkSize
+			if end > len(names) {
+				end = len(names)
+			}
+			waveCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+				TaskQueue:           c.mainQueue,
+				WorkflowTaskTimeout: 3 * time.Hour,
+			})
+			chunks = append(chunks, names[start:end])
+			futures = append(futures, workflow.ExecuteChildWorkflow(waveCtx, c.MassRebuildWaveWorkflow, req, names[start:end], buildBatchId, user))
+		}
+
+		for j, future := range futures {
+			var result MassRebuildWaveResult
+			if err := future.Get(ctx, &result); err != nil {
+				c.log.Errorf("mass rebuild of wave %d failed: %v", i, err)
+				result = MassRebuildWaveResult{Failed: chunks[j]}
+			}
+			for _, name := range result.Failed {
+				unavailable[name] = true
+			}
+			ret.Succeeded = append(ret.Succeeded, result.Succeeded...)
+			ret.Failed = append(ret.Failed, result.Failed...)
+		}
+	}
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
+
+	return ret, nil
+}
+
+// MassRebuildWaveWorkflow builds packages of a mass rebuild wave in parallel
+// and returns once all builds finished
+func (c *Controller) MassRebuildWaveWorkflow(ctx workflow.Context, req *peridotpb.SubmitMassRebuildRequest, packageNames []string, buildBatchId string, user *utils.ContextUser) (*MassRebuildWaveResult, error) {
+	var futures []workflow.Future
+	for _, name := range packageNames {
+		buildReq := &peridotpb.SubmitBuildRequest{
+			ProjectId: req.ProjectId,
+			Package: &peridotpb.SubmitBuildRequest_PackageName{
+				PackageName: wrapperspb.String(name),
+			},
+			DisableChecks: req.DisableChecks,
+		}
+		triggerCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			TaskQueue:           c.mainQueue,
+			WorkflowTaskTimeout: 3 * time.Hour,
+		})
+		futures = append(futures, workflow.ExecuteChildWorkflow(triggerCtx, c.TriggerBuildFromBatchWorkflow, buildReq, buildBatchId, false, user))
+	}
+
+	ret := &MassRebuildWaveResult{}
+	for i, future := range futures {
+		if err := future.Get(ctx, nil); err != nil {
+			c.log.Errorf("mass rebuild of %s failed: %v", packageNames[i], err)
+			ret.Failed = append(ret.Failed, packageNames[i])
+			continue
+		}
+		ret.Succeeded = append(ret.Succeeded, packageNames[i])
+	}
+
+	return ret, nil
+}
@@ -31,8 +31,6 @@
 package workflow
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/go-git/go-billy/v5/memfs"
@@ -125,7 +123,13 @@ type ArtifactIndex struct {
 //   - %_module_name -> the module name
 //   - %_module_stream -> the module stream
 //   - %_module_version -> generated version (as describe above)
-//   - %_module_context -> generated context (calculate sha1 of the buildrequires section) # todo(mustafa): Currently the yaml content is used to calculate the context
+//   - %_module_context -> generated context (see ExpandModuleStream)
+//
+// Contexts are computed the same way MBS does, a sha1 of the expanded buildrequire
+// streams combined with a sha1 of the runtime require streams.
+// Every combination of buildrequired streams is built as its own context.
+// Packager v3 documents are expanded into one context per configuration instead,
+// using the static context declared by the configuration.
 //
 // The macros above will be written to the following file: /etc/rpm/macros.zz-module
 // This is to ensure that the macros are applied last.
@@ -314,103 +318,68 @@ func (c *Controller) BuildModuleWorkflow(ctx workflow.Context, req *peridotpb.Su
 			return nil, newErr
 		}
 
-		var moduleMd *modulemd.ModuleMd
-		if moduleMdNotBackwardsCompatible.V2 != nil {
-			moduleMd = moduleMdNotBackwardsCompatible.V2
-		} else if moduleMdNotBackwardsCompatible.V3 != nil {
-			v3 := moduleMdNotBackwardsCompatible.V3
-			moduleMd = &modulemd.ModuleMd{
-				Document: "modulemd",
-				Version:  2,
-				Data: &modulemd.Data{
-					Name:          v3.Data.Name,
-					Stream:        v3.Data.Stream,
-					Summary:       v3.Data.Summary,
-					Description:   v3.Data.Description,
-					ServiceLevels: nil,
-					License: &modulemd.License{
-						Module: v3.Data.License,
-					},
-					Xmd:        v3.Data.Xmd,
-					References: v3.Data.References,
-					Profiles:   v3.Data.Profiles,
-					Profile:    v3.Data.Profile,
-					API:        v3.Data.API,
-					Filter:     v3.Data.Filter,
-					BuildOpts:  nil,
-					Components: v3.Data.Components,
-					Artifacts:  nil,
-				},
-			}
-			if len(v3.Data.Configurations) > 0 {
-				cfg := v3.Data.Configurations[0]
-				if cfg.BuildOpts != nil {
-					moduleMd.Data.BuildOpts = &modulemd.BuildOpts{
-						Rpms:   cfg.BuildOpts.Rpms,
-						Arches: cfg.BuildOpts.Arches,
-					}
-					moduleMd.Data.Dependencies = []*modulemd.Dependencies{
-						{
-							BuildRequires: cfg.BuildRequires,
-							Requires:      cfg.Requires,
-						},
-					}
-				}
+		// Expand the stream into the contexts to build, v3 documents
+		// may declare multiple configurations for the same stream
+		expansions, err := ExpandModuleStream(moduleMdNotBackwardsCompatible, conf.Platform)
+		if err != nil {
+			if err == ErrInvalidModule {
+				setActivityError(errorDetails, ErrInvalidModule)
+				errorDetails.ErrorInfo.Metadata["module"] = pkg.Name
+				return nil, ErrInvalidModule
 			}
-		}
-		if moduleMd.Data.Name == "" {
-			moduleMd.Data.Name = pkg.Name
-		}
-
-		// Invalid modulemd in repo
-		if moduleMd.Data == nil || moduleMd.Data.Components == nil {
-			setActivityError(errorDetails, ErrInvalidModule)
-			errorDetails.ErrorInfo.Metadata["module"] = pkg.Name
-			return nil, ErrInvalidModule
+			newErr := fmt.Errorf("could not expand module stream in branch %s: %v", revision.ScmBranchName, err)
+			setActivityError(errorDetails, newErr)
+			return nil, newErr
 		}
 
 		moduleVersion := fmt.Sprintf("%d0%d0%d%s", conf.Platform.Major, conf.Platform.Minor, conf.Platform.Patch, time.Now().Format("20060102150405"))
 
-		hasher := sha1.New()
-		_, err = hasher.Write(yamlContent)
-		if err != nil {
-			newErr := fmt.Errorf("could not hash yaml file from modules repo in branch %s: %v", revision.ScmBranchName, err)
-			setActivityError(errorDetails, newErr)
-			return nil, newErr
-		}
-		context := hex.EncodeToString(hasher.Sum(nil))[:8]
-		// todo(mustafa): Evaluate whether we should do `module_` instead of `module+`
-		// Currently RHEL uses `+` but Fedora uses `_` so we'll use `+` for now
-		dist := fmt.Sprintf("module+el%d.%d.%d+%d+%s", conf.Platform.Major, conf.Platform.Minor, conf.Platform.Patch, increment, context)
+		for _, expansion := range expansions {
+			moduleMd := expansion.Document
+			if moduleMd.Data.Name == "" {
+				moduleMd.Data.Name = pkg.Name
+			}
 
-		build, err := c.db.CreateBuild(pkg.ID.String(), revision.PackageVersionId, task.ID.String(), req.ProjectId)
-		if err != nil {
-			err = fmt.Errorf("failed to create build: %v", err)
-			setInternalError(errorDetails, err)
-			return nil, err
-		}
+			// Invalid modulemd in repo
+			if moduleMd.Data.Components == nil {
+				setActivityError(errorDetails, ErrInvalidModule)
+				errorDetails.ErrorInfo.Metadata["module"] = pkg.Name
+				return nil, ErrInvalidModule
+			}
 
-		for name, component := range moduleMd.Data.Components.Rpms {
-			component.Name = name
-		}
+			// todo(mustafa): Evaluate whether we should do `module_` instead of `module+`
+			// Currently RHEL uses `+` but Fedora uses `_` so we'll use `+` for now
+			dist := fmt.Sprintf("module+%s+%d+%s", platformStream(conf.Platform), increment, expansion.Context)
 
-		options := &ModuleStreamBuildOptions{
-			Dist:           dist,
-			Increment:      increment,
-			Name:           moduleMd.Data.Name,
-			Stream:         moduleMd.Data.Stream,
-			Version:        moduleVersion,
-			Context:        context,
-			ImportRevision: revision.ToProto(),
-			Document:       moduleMd,
-			Configuration:  conf,
-			Project:        &*&project,
-			BuildId:        build.ID.String(),
-			BuildBatchId:   extraBuildOptions.BuildBatchId,
-			PackageId:      pkg.ID.String(),
-		}
+			build, err := c.db.CreateBuild(pkg.ID.String(), revision.PackageVersionId, task.ID.String(), req.ProjectId)
+			if err != nil {
+				err = fmt.Errorf("failed to create build: %v", err)
+				setInternalError(errorDetails, err)
+				return nil, err
+			}
+
+			for name, component := range moduleMd.Data.Components.Rpms {
+				component.Name = name
+			}
+
+			options := &ModuleStreamBuildOptions{
+				Dist:           dist,
+				Increment:      increment,
+				Name:           moduleMd.Data.Name,
+				Stream:         moduleMd.Data.Stream,
+				Version:        moduleVersion,
+				Context:        expansion.Context,
+				ImportRevision: revision.ToProto(),
+				Document:       moduleMd,
+				Configuration:  conf,
+				Project:        &*&project,
+				BuildId:        build.ID.String(),
+				BuildBatchId:   extraBuildOptions.BuildBatchId,
+				PackageId:      pkg.ID.String(),
+			}
 
-		streamBuildOptions = append(streamBuildOptions, options)
+			streamBuildOptions = append(streamBuildOptions, options)
+		}
 	}
 
 	var futures []FutureContext
@@ -965,7 +934,7 @@ func fillInRpmArtifactsForModuleMd(md *modulemd.ModuleMd, streamBuildOptions *Mo
 	// Set buildrequires platform to the one used
 	didSetPlatform := false
 	platform := streamBuildOptions.Configuration.Platform
-	platformReq := fmt.Sprintf("el%d.%d.%d", platform.Major, platform.Minor, platform.Patch)
+	platformReq := platformStream(platform)
 	for _, dep := range newMd.Data.Dependencies {
 		if dep.BuildRequires["platform"] != nil {
 			dep.BuildRequires["platform"] = []string{platformReq}
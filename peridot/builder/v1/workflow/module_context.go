@@ -0,0 +1,417 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/rocky-linux/srpmproc/modulemd"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"sort"
+	"strings"
+)
+
+// platformModule is the base module every module stream is built against
+const platformModule = "platform"
+
+// ModuleStreamExpansion is a single buildable context of a module stream.
+// MBS expands every combination of buildrequired streams into its own context,
+// while modulemd-packager v3 documents declare one context per configuration.
+type ModuleStreamExpansion struct {
+	// Document is a modulemd v2 document with the buildrequires expanded to single streams
+	Document *modulemd.ModuleMd
+	Context  string
+}
+
+// platformStream returns the stream of the platform module for given configuration
+func platformStream(platform *peridotpb.ModulePlatform) string {
+	return fmt.Sprintf("el%d.%d.%d", platform.Major, platform.Minor, platform.Patch)
+}
+
+// platformSatisfies returns whether the project platform satisfies a platform stream
+// requirement, either directly or through one of its virtual streams
+func platformSatisfies(platform *peridotpb.ModulePlatform, streams []string) bool {
+	provided := append([]string{platformStream(platform)}, platform.Provides...)
+
+	var positive []string
+	for _, stream := range streams {
+		if strings.HasPrefix(stream, "-") {
+			for _, p := range provided {
+				if p == stream[1:] {
+					return false
+				}
+			}
+			continue
+		}
+		positive = append(positive, stream)
+	}
+	if len(positive) == 0 {
+		return true
+	}
+	for _, stream := range positive {
+		for _, p := range provided {
+			if p == stream {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ExpandModuleStream expands a modulemd document into the contexts that should be built
+// for the project platform. Packager v3 documents produce one expansion per configuration
+// with a static context, v2 documents produce one expansion per combination of buildrequired
+// streams with the context computed the same way MBS does.
+func ExpandModuleStream(md *modulemd.NotBackwardsCompatibleModuleMd, platform *peridotpb.ModulePlatform) ([]*ModuleStreamExpansion, error) {
+	if md.V3 != nil {
+		return expandPackagerV3(md.V3, platform)
+	}
+	if md.V2 == nil || md.V2.Data == nil {
+		return nil, ErrInvalidModule
+	}
+
+	return expandModuleStreamV2(md.V2, platform)
+}
+
+func expandPackagerV3(v3 *modulemd.V3, platform *peridotpb.ModulePlatform) ([]*ModuleStreamExpansion, error) {
+	if v3.Data == nil {
+		return nil, ErrInvalidModule
+	}
+	if len(v3.Data.Configurations) == 0 {
+		return nil, fmt.Errorf("module %s has no configurations", v3.Data.Name)
+	}
+
+	var ret []*ModuleStreamExpansion
+	seenContexts := map[string]bool{}
+	for _, cfg := range v3.Data.Configurations {
+		if cfg.Context == "" {
+			return nil, fmt.Errorf("configuration of module %s is missing a context", v3.Data.Name)
+		}
+		if seenContexts[cfg.Context] {
+			return nil, fmt.Errorf("duplicate context %s in module %s", cfg.Context, v3.Data.Name)
+		}
+		seenContexts[cfg.Context] = true
+
+		// Configurations for other platforms are built by other projects
+		if !platformSatisfies(platform, []string{cfg.Platform}) {
+			continue
+		}
+
+		md := &modulemd.ModuleMd{
+			Document: "modulemd",
+			Version:  2,
+			Data: &modulemd.Data{
+				Name:          v3.Data.Name,
+				Stream:        v3.Data.Stream,
+				StaticContext: true,
+				Context:       cfg.Context,
+				Summary:       v3.Data.Summary,
+				Description:   v3.Data.Description,
+				License: &modulemd.License{
+					Module: v3.Data.License,
+				},
+				Xmd:        v3.Data.Xmd,
+				References: v3.Data.References,
+				Profiles:   v3.Data.Profiles,
+				Profile:    v3.Data.Profile,
+				API:        v3.Data.API,
+				Filter:     v3.Data.Filter,
+				Components: v3.Data.Components,
+			},
+		}
+		if cfg.BuildOpts != nil {
+			md.Data.BuildOpts = &modulemd.BuildOpts{
+				Rpms:   cfg.BuildOpts.Rpms,
+				Arches: cfg.BuildOpts.Arches,
+			}
+		}
+
+		dep := &modulemd.Dependencies{
+			BuildRequires: map[string][]string{
+				platformModule: {platformStream(platform)},
+			},
+			Requires: map[string][]string{
+				platformModule: {cfg.Platform},
+			},
+		}
+		for name, streams := range cfg.BuildRequires {
+			if len(streams) != 1 {
+				return nil, fmt.Errorf("buildrequire %s of module %s context %s must have exactly one stream", name, v3.Data.Name, cfg.Context)
+			}
+			dep.BuildRequires[name] = []string{streams[0]}
+		}
+		for name, streams := range cfg.Requires {
+			dep.Requires[name] = streams
+		}
+		md.Data.Dependencies = []*modulemd.Dependencies{dep}
+
+		ret = append(ret, &ModuleStreamExpansion{
+			Document: md,
+			Context:  cfg.Context,
+		})
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("module %s has no configuration for platform %s", v3.Data.Name, platformStream(platform))
+	}
+
+	return ret, nil
+}
+
+func expandModuleStreamV2(md *modulemd.ModuleMd, platform *peridotpb.ModulePlatform) ([]*ModuleStreamExpansion, error) {
+	deps := md.Data.Dependencies
+	if len(deps) == 0 {
+		deps = []*modulemd.Dependencies{{}}
+	}
+
+	var ret []*ModuleStreamExpansion
+	seenContexts := map[string]bool{}
+	for _, dep := range deps {
+		combinations, err := expandBuildRequires(md.Data.Name, dep.BuildRequires, platform)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, buildRequires := range combinations {
+			newMd := copyModuleMd(*md)
+			newDep := expandDependencies(dep, buildRequires)
+			newMd.Data.Dependencies = []*modulemd.Dependencies{newDep}
+
+			context := moduleContext(moduleBuildContext(buildRequires), moduleRuntimeContext(newMd.Data.Dependencies))
+			if md.Data.StaticContext {
+				context = md.Data.Context
+			}
+			if seenContexts[context] {
+				if md.Data.StaticContext {
+					return nil, fmt.Errorf("module %s has a static context but expands into multiple contexts", md.Data.Name)
+				}
+				continue
+			}
+			seenContexts[context] = true
+			newMd.Data.Context = context
+
+			ret = append(ret, &ModuleStreamExpansion{
+				Document: newMd,
+				Context:  context,
+			})
+		}
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("module %s can not be built for platform %s", md.Data.Name, platformStream(platform))
+	}
+
+	return ret, nil
+}
+
+// expandBuildRequires returns every combination of buildrequired streams.
+// The platform is always resolved to the project platform, while other modules
+// need to list the streams explicitly, as Peridot has no module stream index to
+// resolve empty or negated stream lists against.
+func expandBuildRequires(moduleName string, buildRequires map[string][]string, platform *peridotpb.ModulePlatform) ([]map[string]string, error) {
+	if !platformSatisfies(platform, buildRequires[platformModule]) {
+		return nil, nil
+	}
+
+	var names []string
+	for name := range buildRequires {
+		if name == platformModule {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combinations := []map[string]string{
+		{platformModule: platformStream(platform)},
+	}
+	for _, name := range names {
+		streams := buildRequires[name]
+		if len(streams) == 0 {
+			return nil, fmt.Errorf("buildrequire %s of module %s must list its streams", name, moduleName)
+		}
+
+		var next []map[string]string
+		for _, stream := range streams {
+			if strings.HasPrefix(stream, "-") {
+				return nil, fmt.Errorf("buildrequire %s of module %s uses an unsupported stream exclusion", name, moduleName)
+			}
+			for _, combination := range combinations {
+				newCombination := map[string]string{}
+				for k, v := range combination {
+					newCombination[k] = v
+				}
+				newCombination[name] = stream
+				next = append(next, newCombination)
+			}
+		}
+		combinations = next
+	}
+
+	return combinations, nil
+}
+
+// expandDependencies replaces buildrequires with the streams used for an expansion.
+// Runtime requirements listing the same streams as the buildrequirement are narrowed
+// down to the stream used, other runtime requirements (and the platform) are kept as is.
+func expandDependencies(dep *modulemd.Dependencies, buildRequires map[string]string) *modulemd.Dependencies {
+	ret := &modulemd.Dependencies{
+		BuildRequires: map[string][]string{},
+		Requires:      map[string][]string{},
+	}
+	for name, stream := range buildRequires {
+		ret.BuildRequires[name] = []string{stream}
+	}
+	for name, streams := range dep.Requires {
+		stream, ok := buildRequires[name]
+		if ok && name != platformModule && sameStreams(streams, dep.BuildRequires[name]) {
+			ret.Requires[name] = []string{stream}
+			continue
+		}
+		ret.Requires[name] = append([]string{}, streams...)
+	}
+
+	return ret
+}
+
+func sameStreams(a []string, b []string) bool {
+	set := map[string]bool{}
+	for _, s := range a {
+		set[s] = true
+	}
+	other := map[string]bool{}
+	for _, s := range b {
+		if !set[s] {
+			return false
+		}
+		other[s] = true
+	}
+
+	return len(set) == len(other)
+}
+
+// moduleBuildContext hashes the expanded buildrequire streams (MBS build_context)
+func moduleBuildContext(buildRequires map[string]string) string {
+	var names []string
+	for name := range buildRequires {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items []string
+	for _, name := range names {
+		items = append(items, pythonJSONString(name)+": "+pythonJSONString(buildRequires[name]))
+	}
+
+	return sha1Hex("{" + strings.Join(items, ", ") + "}")
+}
+
+// moduleRuntimeContext hashes the sorted runtime require streams (MBS runtime_context)
+func moduleRuntimeContext(deps []*modulemd.Dependencies) string {
+	requires := map[string][]string{}
+	for _, dep := range deps {
+		for name, streams := range dep.Requires {
+			requires[name] = streams
+		}
+	}
+
+	var names []string
+	for name := range requires {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items []string
+	for _, name := range names {
+		streams := append([]string{}, requires[name]...)
+		sort.Strings(streams)
+
+		var quoted []string
+		for _, stream := range streams {
+			quoted = append(quoted, pythonJSONString(stream))
+		}
+		items = append(items, pythonJSONString(name)+": ["+strings.Join(quoted, ", ")+"]")
+	}
+
+	return sha1Hex("{" + strings.Join(items, ", ") + "}")
+}
+
+// moduleContext combines the build and runtime context into the module context
+func moduleContext(buildContext string, runtimeContext string) string {
+	return sha1Hex(buildContext + ":" + runtimeContext)[:8]
+}
+
+func sha1Hex(content string) string {
+	hasher := sha1.New()
+	_, _ = hasher.Write([]byte(content))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// pythonJSONString encodes a string the same way Python's json.dumps does by default.
+// MBS hashes the output of json.dumps, so the encoding has to match byte for byte.
+func pythonJSONString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 || r > 0x7e {
+				if r > 0xffff {
+					r -= 0x10000
+					sb.WriteString(fmt.Sprintf(`\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff)))
+				} else {
+					sb.WriteString(fmt.Sprintf(`\u%04x`, r))
+				}
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
+}
@@ -0,0 +1,266 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"peridot.resf.org/peridot/db/models"
+	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/servicecatalog"
+)
+
+const (
+	IntotoStatementType     = "https://in-toto.io/Statement/v1"
+	SlsaProvenancePredicate = "https://slsa.dev/provenance/v1"
+	provenanceBuildType     = "https://peridot.resf.org/buildtypes/rpm/v1"
+	provenanceBuilderId     = "https://peridot.resf.org/builder/v1"
+)
+
+// ProvenanceObjectName returns the object name the signed provenance of a build
+// is stored as. There is one envelope per build, covering every arch and the SRPM
+func ProvenanceObjectName(buildId string) string {
+	return filepath.Join("provenance", fmt.Sprintf("%s.intoto.jsonl", buildId))
+}
+
+type resourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	Uri         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type provenanceArch struct {
+	MockConfig string            `json:"mockConfig,omitempty"`
+	Macros     map[string]string `json:"macros,omitempty"`
+	Snapshot   string            `json:"snapshot,omitempty"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition struct {
+		BuildType          string `json:"buildType"`
+		ExternalParameters struct {
+			Package   string `json:"package"`
+			Source    string `json:"source"`
+			Revision  string `json:"revision"`
+			Branch    string `json:"branch"`
+			ProjectId string `json:"projectId"`
+		} `json:"externalParameters"`
+		InternalParameters struct {
+			Arches map[string]*provenanceArch `json:"arches"`
+		} `json:"internalParameters"`
+		ResolvedDependencies []*resourceDescriptor `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			Id                  string                `json:"id"`
+			BuilderDependencies []*resourceDescriptor `json:"builderDependencies,omitempty"`
+		} `json:"builder"`
+		Metadata struct {
+			InvocationId string    `json:"invocationId"`
+			StartedOn    time.Time `json:"startedOn"`
+			FinishedOn   time.Time `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+type intotoStatement struct {
+	Type          string                `json:"_type"`
+	Subject       []*resourceDescriptor `json:"subject"`
+	PredicateType string                `json:"predicateType"`
+	Predicate     *slsaProvenance       `json:"predicate"`
+}
+
+// GenerateProvenanceActivity generates a SLSA provenance statement for a successful build
+// and lets keykeeper sign it with the project key. The signed envelope is attached to the build task.
+// Projects without a signing key don't get provenance.
+func (c *Controller) GenerateProvenanceActivity(ctx context.Context, projectId string, buildId string, packageName string, importRevision *peridotpb.ImportRevision, srpmTaskId string, archTaskIds []string, task *models.Task) error {
+	key, err := c.db.GetDefaultKeyForProject(projectId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.log.Infof("project %s has no signing key, skipping provenance for build %s", projectId, buildId)
+			return nil
+		}
+		return fmt.Errorf("could not get default key for project: %v", err)
+	}
+
+	artifacts, err := c.db.GetArtifactsForBuild(buildId)
+	if err != nil {
+		return fmt.Errorf("could not get artifacts: %v", err)
+	}
+	var subjects []*resourceDescriptor
+	for _, artifact := range artifacts {
+		if filepath.Ext(artifact.Name) != ".rpm" {
+			continue
+		}
+		base := filepath.Base(artifact.Name)
+		subjects = append(subjects, &resourceDescriptor{
+			Name:   base,
+			Digest: map[string]string{"sha256": artifact.HashSha256},
+		})
+
+		signedHash, err := c.db.GetTaskArtifactSignatureHash(artifact.Name, key.ID.String())
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return fmt.Errorf("could not get signature hash for %s: %v", artifact.Name, err)
+		}
+		subjects = append(subjects, &resourceDescriptor{
+			Name:   fmt.Sprintf("%s/%s", key.GpgId, base),
+			Digest: map[string]string{"sha256": signedHash},
+		})
+	}
+	if len(subjects) == 0 {
+		return fmt.Errorf("build %s has no RPM artifacts", buildId)
+	}
+
+	sources, lockfiles, err := c.buildInputs(projectId, srpmTaskId, archTaskIds)
+	if err != nil {
+		return err
+	}
+
+	provenance := &slsaProvenance{}
+	definition := &provenance.BuildDefinition
+	definition.BuildType = provenanceBuildType
+	definition.ExternalParameters.ProjectId = projectId
+	definition.ExternalParameters.Package = packageName
+	definition.ExternalParameters.Source = importRevision.ScmUrl
+	definition.ExternalParameters.Revision = importRevision.ScmHash
+	definition.ExternalParameters.Branch = importRevision.ScmBranchName
+	definition.InternalParameters.Arches = map[string]*provenanceArch{}
+
+	definition.ResolvedDependencies = append(definition.ResolvedDependencies, &resourceDescriptor{
+		Uri:    fmt.Sprintf("git+%s@%s", importRevision.ScmUrl, importRevision.ScmBranchName),
+		Digest: map[string]string{"gitCommit": importRevision.ScmHash},
+	})
+	for _, source := range sources.Sources {
+		definition.ResolvedDependencies = append(definition.ResolvedDependencies, &resourceDescriptor{
+			Name:   source.Path,
+			Digest: map[string]string{source.HashAlgorithm: source.Hash},
+		})
+	}
+
+	builderImages := map[string]bool{}
+	repos := map[string]*resourceDescriptor{}
+	for _, lockfile := range lockfiles {
+		definition.InternalParameters.Arches[lockfile.Arch] = &provenanceArch{
+			MockConfig: lockfile.MockConfig,
+			Macros:     lockfile.Macros,
+			Snapshot:   lockfile.Snapshot,
+		}
+
+		for _, repo := range lockfile.Repositories {
+			uri := servicecatalog.YumrepofsRepo(projectId, repo.Repository, repo.Arch)
+			if repos[uri] != nil {
+				continue
+			}
+			repos[uri] = &resourceDescriptor{
+				Name: repo.Repository,
+				Uri:  uri,
+				Annotations: map[string]string{
+					"revisionId": repo.RevisionId,
+				},
+			}
+		}
+		for _, externalRepo := range lockfile.ExternalRepositories {
+			if repos[externalRepo] != nil {
+				continue
+			}
+			repos[externalRepo] = &resourceDescriptor{Uri: externalRepo}
+		}
+
+		if lockfile.BuilderImage != "" {
+			builderImages[lockfile.BuilderImage] = true
+		}
+	}
+	var repoUris []string
+	for uri := range repos {
+		repoUris = append(repoUris, uri)
+	}
+	sort.Strings(repoUris)
+	for _, uri := range repoUris {
+		definition.ResolvedDependencies = append(definition.ResolvedDependencies, repos[uri])
+	}
+
+	runDetails := &provenance.RunDetails
+	runDetails.Builder.Id = provenanceBuilderId
+	var images []string
+	for image := range builderImages {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	for _, image := range images {
+		descriptor := &resourceDescriptor{Uri: fmt.Sprintf("oci://%s", image)}
+		// Images pinned by digest can be verified
+		if idx := strings.Index(image, "@sha256:"); idx != -1 {
+			descriptor.Digest = map[string]string{"sha256": image[idx+len("@sha256:"):]}
+		}
+		runDetails.Builder.BuilderDependencies = append(runDetails.Builder.BuilderDependencies, descriptor)
+	}
+	runDetails.Metadata.InvocationId = task.ID.String()
+	runDetails.Metadata.StartedOn = task.CreatedAt.UTC()
+	runDetails.Metadata.FinishedOn = time.Now().UTC()
+
+	statement, err := json.Marshal(&intotoStatement{
+		Type:          IntotoStatementType,
+		Subject:       subjects,
+		PredicateType: SlsaProvenancePredicate,
+		Predicate:     provenance,
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal provenance: %v", err)
+	}
+
+	res, err := c.keykeeper.SignProvenance(ctx, &keykeeperpb.SignProvenanceRequest{
+		BuildId:   buildId,
+		KeyName:   key.Name,
+		Statement: statement,
+	})
+	if err != nil {
+		return fmt.Errorf("could not sign provenance: %v", err)
+	}
+
+	err = c.db.AttachArtifactToTask(res.ObjectName, res.HashSha256, "noarch", nil, task.ID.String())
+	if err != nil {
+		return fmt.Errorf("could not attach provenance to task: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,352 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/provisioner"
+)
+
+// workerTerminatedError is returned if the provisioner terminated a worker
+// while a build was running on it
+type workerTerminatedError struct {
+	reason string
+}
+
+func (e *workerTerminatedError) Error() string {
+	return fmt.Sprintf("worker was terminated: %s", e.reason)
+}
+
+// archBuildFunc runs a build on the worker listening on queue.
+// The build should be recorded under task and take at most timeout
+type archBuildFunc func(ctx workflow.Context, queue string, task *models.Task, timeout time.Duration) error
+
+// validateResourceProfile checks that the quantities and timeout of a profile can be parsed
+func validateResourceProfile(profile *peridotpb.ResourceProfile) error {
+	quantities := map[string]string{
+		"cpu":               profile.Cpu,
+		"memory":            profile.Memory,
+		"ephemeral_storage": profile.EphemeralStorage,
+	}
+	for name, quantity := range quantities {
+		if quantity == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(quantity); err != nil {
+			return fmt.Errorf("invalid %s %s: %v", name, quantity, err)
+		}
+	}
+	if profile.Timeout != "" {
+		timeout, err := time.ParseDuration(profile.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %s: %v", profile.Timeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout has to be positive")
+		}
+	}
+
+	return nil
+}
+
+// resolveResourceProfile returns the package profile merged on top of the project default.
+// Returns nil if neither exist
+func (c *Controller) resolveResourceProfile(projectId string, packageName string) (*peridotpb.ResourceProfile, error) {
+	var ret *peridotpb.ResourceProfile
+	for _, name := range []string{"", packageName} {
+		profile, err := c.db.GetResourceProfile(projectId, name)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return nil, err
+		}
+		if ret == nil {
+			ret = &peridotpb.ResourceProfile{}
+		}
+		proto.Merge(ret, profile)
+	}
+
+	return ret, nil
+}
+
+// packageResources resolves the resources builders of the package should request,
+// and the maximum time a build may take (zero if unset)
+func (c *Controller) packageResources(ctx workflow.Context, projectId string, packageName string) (*provisioner.Resources, time.Duration) {
+	var profile *peridotpb.ResourceProfile
+	profileEffect := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		profile, err := c.resolveResourceProfile(projectId, packageName)
+		if err != nil {
+			c.log.Errorf("could not get resource profile for %s, using defaults: %v", packageName, err)
+			return (*peridotpb.ResourceProfile)(nil)
+		}
+		return profile
+	})
+	if err := profileEffect.Get(&profile); err != nil || profile == nil {
+		return nil, 0
+	}
+
+	var timeout time.Duration
+	if profile.Timeout != "" {
+		timeout, _ = time.ParseDuration(profile.Timeout)
+	}
+
+	return &provisioner.Resources{
+		CPU:              profile.Cpu,
+		Memory:           profile.Memory,
+		EphemeralStorage: profile.EphemeralStorage,
+		NodeSelector:     profile.NodeSelector,
+	}, timeout
+}
+
+// doubleQuantity returns twice the given quantity, starting from fallback if empty
+func doubleQuantity(quantity string, fallback string) string {
+	if quantity == "" {
+		quantity = fallback
+	}
+	q, err := resource.ParseQuantity(quantity)
+	if err != nil {
+		return quantity
+	}
+	q.Add(q.DeepCopy())
+
+	return q.String()
+}
+
+// escalateResources returns the next larger resource profile for a worker that was
+// terminated for the given reason. Only the exhausted resource is increased
+func escalateResources(resources *provisioner.Resources, timeout time.Duration, reason string) (*provisioner.Resources, time.Duration) {
+	ret := &provisioner.Resources{}
+	if resources != nil {
+		*ret = *resources
+	}
+
+	switch reason {
+	case provisioner.ReasonOOMKilled:
+		ret.Memory = doubleQuantity(ret.Memory, provisioner.DefaultMemory)
+	case provisioner.ReasonEvicted:
+		ret.EphemeralStorage = doubleQuantity(ret.EphemeralStorage, provisioner.DefaultEphemeralStorage)
+	case provisioner.ReasonDeadlineExceeded:
+		ret.CPU = doubleQuantity(ret.CPU, provisioner.DefaultCPU)
+		timeout *= 2
+	}
+
+	return ret, timeout
+}
+
+// watchWorker runs build while watching the worker it runs on.
+// If the provisioner terminates the worker, the build is cancelled
+// and a workerTerminatedError is returned
+func (c *Controller) watchWorker(ctx workflow.Context, req *ProvisionWorkerRequest, build func(ctx workflow.Context) error) error {
+	watchCtx, cancelWatch := workflow.WithCancel(ctx)
+	defer cancelWatch()
+	watchCtx = workflow.WithActivityOptions(watchCtx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    96 * time.Hour,
+		HeartbeatTimeout:       time.Minute,
+		TaskQueue:              req.Queue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	})
+	buildCtx, cancelBuild := workflow.WithCancel(ctx)
+	defer cancelBuild()
+
+	buildFuture, buildSettable := workflow.NewFuture(ctx)
+	workflow.Go(buildCtx, func(ctx workflow.Context) {
+		buildSettable.Set(nil, build(ctx))
+	})
+
+	var reason string
+	var buildErr error
+	buildDone := false
+	selector := workflow.NewSelector(ctx)
+	selector.AddFuture(buildFuture, func(f workflow.Future) {
+		buildDone = true
+		buildErr = f.Get(ctx, nil)
+	})
+	selector.AddFuture(workflow.ExecuteActivity(watchCtx, c.WatchWorkerActivity, req, true), func(f workflow.Future) {
+		_ = f.Get(ctx, &reason)
+	})
+	selector.Select(ctx)
+	// The watcher may give up without a reason, in that case just wait for the build
+	if !buildDone && reason == "" {
+		selector.Select(ctx)
+	}
+
+	if reason != "" {
+		return &workerTerminatedError{reason: reason}
+	}
+	if buildErr == nil || temporal.IsCanceledError(buildErr) {
+		return buildErr
+	}
+
+	var timeoutErr *temporal.TimeoutError
+	if errors.As(buildErr, &timeoutErr) && timeoutErr.TimeoutType() == enums.TIMEOUT_TYPE_START_TO_CLOSE {
+		return &workerTerminatedError{reason: provisioner.ReasonDeadlineExceeded}
+	}
+
+	// The build may have failed before the watcher noticed the worker was terminated
+	checkCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    5 * time.Minute,
+		TaskQueue:              req.Queue,
+	})
+	if err := workflow.ExecuteActivity(checkCtx, c.WatchWorkerActivity, req, false).Get(ctx, &reason); err == nil && reason != "" {
+		return &workerTerminatedError{reason: reason}
+	}
+
+	return buildErr
+}
+
+// RecordWorkerTerminationActivity fails the task of a terminated attempt with the termination reason.
+// If the attempt already recorded its buildroot lockfile, the termination is added to the lockfile
+// so the buildroot stays available to later builds
+func (c *Controller) RecordWorkerTerminationActivity(ctx context.Context, taskId string, reason string, resources *provisioner.Resources, timeout time.Duration) error {
+	errorDetails := &peridotpb.TaskErrorDetails{}
+	setWorkerTerminatedError(errorDetails, reason, resources, timeout)
+
+	tasks, err := c.db.GetTask(taskId, nil)
+	if err != nil {
+		return fmt.Errorf("could not get task: %v", err)
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("task %s not found", taskId)
+	}
+
+	var response proto.Message = errorDetails
+	if tasks[0].Response.Valid {
+		anyResponse := &anypb.Any{}
+		if err := protojson.Unmarshal(tasks[0].Response.JSONText, anyResponse); err == nil {
+			lockfile := &peridotpb.BuildrootLockfile{}
+			if err := anyResponse.UnmarshalTo(lockfile); err == nil {
+				lockfile.WorkerTermination = errorDetails
+				response = lockfile
+			}
+		}
+	}
+
+	responseAny, err := anypb.New(response)
+	if err != nil {
+		return fmt.Errorf("could not create anypb for task response: %v", err)
+	}
+	err = c.db.SetTaskResponse(taskId, responseAny)
+	if err != nil {
+		return fmt.Errorf("could not set task response: %v", err)
+	}
+
+	return c.db.SetTaskStatus(taskId, peridotpb.TaskStatus_TASK_STATUS_FAILED)
+}
+
+// buildOnArchWorker provisions a worker for archTask and runs build on it.
+// If the provisioner terminates the worker because it ran out of memory, storage or time,
+// the build is retried as a subtask of archTask on a worker with the next larger
+// resource profile, at most resource-retry-limit times.
+// Returns the queue of the worker the build succeeded on, the task of the successful
+// attempt and a function that destroys the worker
+func (c *Controller) buildOnArchWorker(ctx workflow.Context, workerReq *ProvisionWorkerRequest, archTask *models.Task, timeout time.Duration, build archBuildFunc) (string, *models.Task, func(), error) {
+	var retryLimit int
+	retryLimitEffect := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return viper.GetInt("resource-retry-limit")
+	})
+	if err := retryLimitEffect.Get(&retryLimit); err != nil {
+		return "", nil, nil, err
+	}
+
+	task := archTask
+	resources := workerReq.Resources
+	for attempt := 0; ; attempt++ {
+		req := *workerReq
+		req.TaskId = task.ID.String()
+		req.Resources = resources
+		queue, cleanup, err := c.provisionWorker(ctx, &req)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to provision worker: %v", err)
+		}
+
+		err = c.watchWorker(ctx, &req, func(ctx workflow.Context) error {
+			return build(ctx, queue, task, timeout)
+		})
+		if err == nil {
+			return queue, task, cleanup, nil
+		}
+		cleanup()
+
+		var terminatedErr *workerTerminatedError
+		if !errors.As(err, &terminatedErr) {
+			return "", nil, nil, err
+		}
+		recordCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			ScheduleToStartTimeout: 25 * time.Minute,
+			StartToCloseTimeout:    time.Minute,
+			TaskQueue:              c.mainQueue,
+		})
+		if recordErr := workflow.ExecuteActivity(recordCtx, c.RecordWorkerTerminationActivity, task.ID.String(), terminatedErr.reason, resources, timeout).Get(ctx, nil); recordErr != nil {
+			c.log.Errorf("could not record termination of task %s: %v", task.ID.String(), recordErr)
+		}
+		if attempt >= retryLimit {
+			return "", nil, nil, fmt.Errorf("%v (gave up after %d attempts)", err, attempt+1)
+		}
+
+		resources, timeout = escalateResources(resources, timeout, terminatedErr.reason)
+		c.log.Infof("retrying %s of task %s with larger resources after worker was terminated: %s", req.Arch, archTask.ID.String(), terminatedErr.reason)
+
+		var retryTask models.Task
+		retryTaskEffect := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+			parentTaskId := archTask.ID.String()
+			newTask, err := c.db.CreateTask(nil, req.Arch, archTask.Type, &req.ProjectId, &parentTaskId)
+			if err != nil {
+				return &models.Task{}
+			}
+			return newTask
+		})
+		err = retryTaskEffect.Get(&retryTask)
+		if err != nil || !retryTask.ProjectId.Valid {
+			return "", nil, nil, fmt.Errorf("failed to create retry task: %v", err)
+		}
+		task = &retryTask
+	}
+}
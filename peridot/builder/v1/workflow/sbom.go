@@ -0,0 +1,826 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+)
+
+const (
+	spdxNoAssertion     = "NOASSERTION"
+	spdxNamespacePrefix = "https://peridot.resf.org/spdx"
+	sbomToolName        = "peridot"
+)
+
+var spdxIdSanitizer = regexp.MustCompile("[^a-zA-Z0-9.-]")
+
+// SbomObjectName returns the object name an SBOM document of a build is stored as
+func SbomObjectName(buildTaskId string, format peridotpb.SbomFormat) string {
+	switch format {
+	case peridotpb.SbomFormat_SBOM_FORMAT_CYCLONEDX:
+		return filepath.Join(buildTaskId, "sbom.cdx.json")
+	default:
+		return filepath.Join(buildTaskId, "sbom.spdx.json")
+	}
+}
+
+// SbomContentType returns the media type of an SBOM document
+func SbomContentType(format peridotpb.SbomFormat) string {
+	switch format {
+	case peridotpb.SbomFormat_SBOM_FORMAT_CYCLONEDX:
+		return "application/vnd.cyclonedx+json"
+	default:
+		return "application/spdx+json"
+	}
+}
+
+type sbomPackage struct {
+	Name    string
+	Epoch   string
+	Version string
+	Release string
+	Arch    string
+	Sha256  string
+	License string
+	Summary string
+	Url     string
+
+	// Buildroot architectures the package was installed in
+	BuildrootArches []string
+}
+
+func (p *sbomPackage) nevra() string {
+	epoch := ""
+	if p.Epoch != "" && p.Epoch != "0" {
+		epoch = p.Epoch + ":"
+	}
+	return fmt.Sprintf("%s-%s%s-%s.%s", p.Name, epoch, p.Version, p.Release, p.Arch)
+}
+
+func (p *sbomPackage) evr() string {
+	if p.Epoch != "" && p.Epoch != "0" {
+		return fmt.Sprintf("%s:%s-%s", p.Epoch, p.Version, p.Release)
+	}
+	return fmt.Sprintf("%s-%s", p.Version, p.Release)
+}
+
+// purl returns the package URL of the package
+// Spec: https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#rpm
+func (p *sbomPackage) purl(namespace string) string {
+	purl := fmt.Sprintf("pkg:rpm/%s/%s@%s", url.PathEscape(namespace), url.QueryEscape(p.Name), url.QueryEscape(p.Version+"-"+p.Release))
+	purl += "?arch=" + url.QueryEscape(p.Arch)
+	if p.Epoch != "" && p.Epoch != "0" {
+		purl += "&epoch=" + url.QueryEscape(p.Epoch)
+	}
+
+	return purl
+}
+
+// parseNevra parses NEVRA strings as written by rpm (name-[epoch:]version-release.arch)
+func parseNevra(nevra string) (*sbomPackage, error) {
+	nevra = strings.TrimSuffix(nevra, ".rpm")
+	invalid := fmt.Errorf("invalid nevra %s", nevra)
+
+	archIdx := strings.LastIndex(nevra, ".")
+	if archIdx == -1 {
+		return nil, invalid
+	}
+	nevr := nevra[:archIdx]
+	relIdx := strings.LastIndex(nevr, "-")
+	if relIdx == -1 {
+		return nil, invalid
+	}
+	nev := nevr[:relIdx]
+	verIdx := strings.LastIndex(nev, "-")
+	if verIdx == -1 {
+		return nil, invalid
+	}
+
+	pkg := &sbomPackage{
+		Name:    nev[:verIdx],
+		Version: nev[verIdx+1:],
+		Release: nevr[relIdx+1:],
+		Arch:    nevra[archIdx+1:],
+	}
+	if epochIdx := strings.Index(pkg.Version, ":"); epochIdx != -1 {
+		pkg.Epoch = pkg.Version[:epochIdx]
+		pkg.Version = pkg.Version[epochIdx+1:]
+	}
+
+	return pkg, nil
+}
+
+// sbomPackageFromArtifact uses the primary metadata of an RPM artifact
+// and falls back to the file name if the artifact has no metadata
+func sbomPackageFromArtifact(artifact *models.TaskArtifact) (*sbomPackage, error) {
+	if artifact.Metadata.Valid {
+		anyMetadata := &anypb.Any{}
+		err := protojson.Unmarshal(artifact.Metadata.JSONText, anyMetadata)
+		if err != nil {
+			return nil, err
+		}
+		rpmMetadata := &peridotpb.RpmArtifactMetadata{}
+		err = anyMetadata.UnmarshalTo(rpmMetadata)
+		if err != nil {
+			return nil, err
+		}
+		var primary yummeta.PrimaryRoot
+		err = yummeta.UnmarshalPrimary(rpmMetadata.Primary, &primary)
+		if err != nil {
+			return nil, err
+		}
+		if len(primary.Packages) == 1 && primary.Packages[0].Version != nil {
+			primaryPkg := primary.Packages[0]
+			pkg := &sbomPackage{
+				Name:    primaryPkg.Name,
+				Epoch:   primaryPkg.Version.Epoch,
+				Version: primaryPkg.Version.Ver,
+				Release: primaryPkg.Version.Rel,
+				Arch:    primaryPkg.Arch,
+				Sha256:  artifact.HashSha256,
+				Summary: primaryPkg.Summary,
+				Url:     primaryPkg.Url,
+			}
+			if primaryPkg.Format != nil {
+				pkg.License = primaryPkg.Format.RpmLicense
+			}
+			return pkg, nil
+		}
+	}
+
+	pkg, err := parseNevra(filepath.Base(artifact.Name))
+	if err != nil {
+		return nil, err
+	}
+	pkg.Sha256 = artifact.HashSha256
+
+	return pkg, nil
+}
+
+// lookasideSources parses the srpmproc metadata file in a dist-git checkout.
+// Format: "hash path"
+func lookasideSources(cloneDir string) (*peridotpb.SrpmSources, error) {
+	metadataFiles, err := filepath.Glob(filepath.Join(cloneDir, "*.metadata"))
+	if err != nil {
+		return nil, err
+	}
+	hiddenMetadataFiles, err := filepath.Glob(filepath.Join(cloneDir, ".*.metadata"))
+	if err != nil {
+		return nil, err
+	}
+	metadataFiles = append(metadataFiles, hiddenMetadataFiles...)
+
+	ret := &peridotpb.SrpmSources{}
+	for _, metadataFile := range metadataFiles {
+		content, err := os.ReadFile(metadataFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+
+			var hashAlgorithm string
+			switch len(fields[0]) {
+			case 40:
+				hashAlgorithm = "sha1"
+			case 64:
+				hashAlgorithm = "sha256"
+			case 128:
+				hashAlgorithm = "sha512"
+			default:
+				return nil, fmt.Errorf("unknown hash %s for %s", fields[0], fields[1])
+			}
+
+			ret.Sources = append(ret.Sources, &peridotpb.LookasideSource{
+				Path:          fields[1],
+				Hash:          fields[0],
+				HashAlgorithm: hashAlgorithm,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+// buildSbom is the format independent representation of the SBOM of a build
+type buildSbom struct {
+	DocumentId uuid.UUID
+	Created    time.Time
+	ProjectId  string
+	BuildId    string
+	Vendor     string
+	Namespace  string
+
+	ImportRevision *peridotpb.ImportRevision
+
+	Srpm      *sbomPackage
+	Rpms      []*sbomPackage
+	Buildroot []*sbomPackage
+	Sources   []*peridotpb.LookasideSource
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID                string            `json:"SPDXID"`
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	PackageFileName       string            `json:"packageFileName,omitempty"`
+	Supplier              string            `json:"supplier,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	Homepage              string            `json:"homepage,omitempty"`
+	SourceInfo            string            `json:"sourceInfo,omitempty"`
+	LicenseConcluded      string            `json:"licenseConcluded"`
+	LicenseDeclared       string            `json:"licenseDeclared"`
+	CopyrightText         string            `json:"copyrightText"`
+	Summary               string            `json:"summary,omitempty"`
+	Comment               string            `json:"comment,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxRelationship struct {
+	SpdxElementId      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	SpdxVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+func spdxRpmPackage(id string, pkg *sbomPackage, namespace string, supplier string) spdxPackage {
+	ret := spdxPackage{
+		SPDXID:           id,
+		Name:             pkg.Name,
+		VersionInfo:      pkg.evr(),
+		PackageFileName:  fmt.Sprintf("%s-%s-%s.%s.rpm", pkg.Name, pkg.Version, pkg.Release, pkg.Arch),
+		Supplier:         supplier,
+		DownloadLocation: spdxNoAssertion,
+		Homepage:         pkg.Url,
+		LicenseConcluded: spdxNoAssertion,
+		// RPM license tags are not guaranteed to be valid SPDX expressions
+		LicenseDeclared: spdxNoAssertion,
+		CopyrightText:   spdxNoAssertion,
+		Summary:         pkg.Summary,
+		ExternalRefs: []spdxExternalRef{
+			{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  pkg.purl(namespace),
+			},
+		},
+	}
+	if pkg.Sha256 != "" {
+		ret.Checksums = []spdxChecksum{
+			{
+				Algorithm:     "SHA256",
+				ChecksumValue: pkg.Sha256,
+			},
+		}
+	}
+	if pkg.License != "" {
+		ret.Comment = "RPM License: " + pkg.License
+	}
+	if len(pkg.BuildrootArches) > 0 {
+		ret.Comment = "Installed in buildroot for " + strings.Join(pkg.BuildrootArches, ", ")
+	}
+
+	return ret
+}
+
+// spdx renders the SBOM as an SPDX 2.3 JSON document
+func (b *buildSbom) spdx() ([]byte, error) {
+	supplier := "NOASSERTION"
+	if b.Vendor != "" {
+		supplier = "Organization: " + b.Vendor
+	}
+
+	doc := &spdxDocument{
+		SpdxVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              fmt.Sprintf("%s-%s", b.Srpm.Name, b.Srpm.evr()),
+		DocumentNamespace: fmt.Sprintf("%s/%s/%s-%s", spdxNamespacePrefix, b.ProjectId, b.BuildId, b.DocumentId.String()),
+		CreationInfo: spdxCreationInfo{
+			Created:  b.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomToolName},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	if b.Vendor != "" {
+		doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, "Organization: "+b.Vendor)
+	}
+
+	relate := func(element string, relationshipType string, related string) {
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SpdxElementId:      element,
+			RelationshipType:   relationshipType,
+			RelatedSpdxElement: related,
+		})
+	}
+
+	srpmId := "SPDXRef-SRPM"
+	srpm := spdxRpmPackage(srpmId, b.Srpm, b.Namespace, supplier)
+	srpm.PrimaryPackagePurpose = "SOURCE"
+	if b.ImportRevision != nil && b.ImportRevision.ScmUrl != "" {
+		srpm.DownloadLocation = fmt.Sprintf("git+%s@%s", b.ImportRevision.ScmUrl, b.ImportRevision.ScmHash)
+		srpm.SourceInfo = fmt.Sprintf("Imported from %s at %s (branch %s)", b.ImportRevision.ScmUrl, b.ImportRevision.ScmHash, b.ImportRevision.ScmBranchName)
+	}
+	doc.Packages = append(doc.Packages, srpm)
+	relate(doc.SPDXID, "DESCRIBES", srpmId)
+
+	for _, rpm := range b.Rpms {
+		id := "SPDXRef-RPM-" + spdxIdSanitizer.ReplaceAllString(rpm.nevra(), "-")
+		pkg := spdxRpmPackage(id, rpm, b.Namespace, supplier)
+		pkg.PrimaryPackagePurpose = "INSTALL"
+		doc.Packages = append(doc.Packages, pkg)
+		relate(doc.SPDXID, "DESCRIBES", id)
+		relate(id, "GENERATED_FROM", srpmId)
+	}
+
+	for i, source := range b.Sources {
+		id := fmt.Sprintf("SPDXRef-Source-%d", i)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:           id,
+			Name:             filepath.Base(source.Path),
+			PackageFileName:  source.Path,
+			DownloadLocation: spdxNoAssertion,
+			Checksums: []spdxChecksum{
+				{
+					Algorithm:     strings.ToUpper(source.HashAlgorithm),
+					ChecksumValue: source.Hash,
+				},
+			},
+			LicenseConcluded:      spdxNoAssertion,
+			LicenseDeclared:       spdxNoAssertion,
+			CopyrightText:         spdxNoAssertion,
+			PrimaryPackagePurpose: "SOURCE",
+			Comment:               "Fetched from lookaside",
+		})
+		relate(srpmId, "CONTAINS", id)
+	}
+
+	for _, buildrootPkg := range b.Buildroot {
+		id := "SPDXRef-Buildroot-" + spdxIdSanitizer.ReplaceAllString(buildrootPkg.nevra(), "-")
+		doc.Packages = append(doc.Packages, spdxRpmPackage(id, buildrootPkg, b.Namespace, supplier))
+		relate(id, "BUILD_DEPENDENCY_OF", srpmId)
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	Name string `json:"name"`
+}
+
+type cdxLicenseChoice struct {
+	License cdxLicense `json:"license"`
+}
+
+type cdxExternalReference struct {
+	Type    string `json:"type"`
+	Url     string `json:"url"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxComponent struct {
+	BomRef             string                 `json:"bom-ref,omitempty"`
+	Type               string                 `json:"type"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	Description        string                 `json:"description,omitempty"`
+	Scope              string                 `json:"scope,omitempty"`
+	Hashes             []cdxHash              `json:"hashes,omitempty"`
+	Licenses           []cdxLicenseChoice     `json:"licenses,omitempty"`
+	Purl               string                 `json:"purl,omitempty"`
+	ExternalReferences []cdxExternalReference `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty          `json:"properties,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp"`
+	Tools     cdxTools      `json:"tools"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxBom struct {
+	BomFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+var cdxHashAlgorithms = map[string]string{
+	"sha1":   "SHA-1",
+	"sha256": "SHA-256",
+	"sha512": "SHA-512",
+}
+
+func cdxRpmComponent(pkg *sbomPackage, namespace string) cdxComponent {
+	purl := pkg.purl(namespace)
+	ret := cdxComponent{
+		BomRef:      purl,
+		Type:        "library",
+		Name:        pkg.Name,
+		Version:     pkg.evr(),
+		Description: pkg.Summary,
+		Purl:        purl,
+	}
+	if pkg.Sha256 != "" {
+		ret.Hashes = []cdxHash{
+			{
+				Alg:     "SHA-256",
+				Content: pkg.Sha256,
+			},
+		}
+	}
+	if pkg.License != "" {
+		ret.Licenses = []cdxLicenseChoice{
+			{
+				License: cdxLicense{Name: pkg.License},
+			},
+		}
+	}
+	if pkg.Url != "" {
+		ret.ExternalReferences = []cdxExternalReference{
+			{
+				Type: "website",
+				Url:  pkg.Url,
+			},
+		}
+	}
+
+	return ret
+}
+
+// cycloneDx renders the SBOM as a CycloneDX 1.5 JSON document
+func (b *buildSbom) cycloneDx() ([]byte, error) {
+	srpm := cdxRpmComponent(b.Srpm, b.Namespace)
+	srpm.Type = "application"
+	srpm.Properties = []cdxProperty{
+		{Name: "peridot:build_id", Value: b.BuildId},
+		{Name: "peridot:project_id", Value: b.ProjectId},
+	}
+	if b.ImportRevision != nil && b.ImportRevision.ScmUrl != "" {
+		srpm.ExternalReferences = append(srpm.ExternalReferences, cdxExternalReference{
+			Type:    "vcs",
+			Url:     b.ImportRevision.ScmUrl,
+			Comment: b.ImportRevision.ScmHash,
+		})
+		srpm.Properties = append(srpm.Properties,
+			cdxProperty{Name: "peridot:scm_hash", Value: b.ImportRevision.ScmHash},
+			cdxProperty{Name: "peridot:scm_branch", Value: b.ImportRevision.ScmBranchName},
+		)
+	}
+
+	bom := &cdxBom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + b.DocumentId.String(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: b.Created.UTC().Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{
+					{
+						Type: "application",
+						Name: sbomToolName,
+					},
+				},
+			},
+			Component: &srpm,
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+
+	srpmDependency := cdxDependency{
+		Ref:       srpm.BomRef,
+		DependsOn: []string{},
+	}
+	seen := map[string]bool{
+		srpm.BomRef: true,
+	}
+
+	for _, rpm := range b.Rpms {
+		component := cdxRpmComponent(rpm, b.Namespace)
+		component.Scope = "required"
+		component.Properties = []cdxProperty{
+			{Name: "peridot:source_rpm", Value: srpm.BomRef},
+		}
+		seen[component.BomRef] = true
+		bom.Components = append(bom.Components, component)
+	}
+
+	for _, source := range b.Sources {
+		ref := "source:" + source.Path
+		srpmDependency.DependsOn = append(srpmDependency.DependsOn, ref)
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		bom.Components = append(bom.Components, cdxComponent{
+			BomRef: ref,
+			Type:   "file",
+			Name:   source.Path,
+			Hashes: []cdxHash{
+				{
+					Alg:     cdxHashAlgorithms[source.HashAlgorithm],
+					Content: source.Hash,
+				},
+			},
+			Properties: []cdxProperty{
+				{Name: "peridot:lookaside_hash", Value: source.Hash},
+			},
+		})
+	}
+
+	for _, buildrootPkg := range b.Buildroot {
+		component := cdxRpmComponent(buildrootPkg, b.Namespace)
+		srpmDependency.DependsOn = append(srpmDependency.DependsOn, component.BomRef)
+		if seen[component.BomRef] {
+			continue
+		}
+		seen[component.BomRef] = true
+		component.Scope = "excluded"
+		for _, arch := range buildrootPkg.BuildrootArches {
+			component.Properties = append(component.Properties, cdxProperty{Name: "peridot:buildroot_arch", Value: arch})
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	bom.Dependencies = append(bom.Dependencies, srpmDependency)
+
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+func unmarshalTaskResponse(task *models.Task, m proto.Message) error {
+	anyResponse := &anypb.Any{}
+	err := protojson.Unmarshal(task.Response.JSONText, anyResponse)
+	if err != nil {
+		return err
+	}
+
+	return anyResponse.UnmarshalTo(m)
+}
+
+// buildInputs returns the lookaside sources and buildroot lockfiles recorded
+// by the SRPM and arch tasks of a build
+func (c *Controller) buildInputs(projectId string, srpmTaskId string, archTaskIds []string) (*peridotpb.SrpmSources, []*peridotpb.BuildrootLockfile, error) {
+	getTask := func(id string) (*models.Task, error) {
+		tasks, err := c.db.GetTask(id, &projectId)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			if t.ID.String() == id {
+				return &t, nil
+			}
+		}
+		return nil, fmt.Errorf("task %s not found", id)
+	}
+
+	sources := &peridotpb.SrpmSources{}
+	srpmTask, err := getTask(srpmTaskId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get srpm task: %v", err)
+	}
+	if srpmTask.Response.Valid {
+		err = unmarshalTaskResponse(srpmTask, sources)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read srpm sources: %v", err)
+		}
+	}
+
+	var lockfiles []*peridotpb.BuildrootLockfile
+	for _, archTaskId := range archTaskIds {
+		archTask, err := getTask(archTaskId)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not get arch task: %v", err)
+		}
+		if !archTask.Response.Valid {
+			continue
+		}
+		lockfile := &peridotpb.BuildrootLockfile{}
+		err = unmarshalTaskResponse(archTask, lockfile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read buildroot lockfile: %v", err)
+		}
+		lockfiles = append(lockfiles, lockfile)
+	}
+	sort.Slice(lockfiles, func(i, j int) bool {
+		return lockfiles[i].Arch < lockfiles[j].Arch
+	})
+
+	return sources, lockfiles, nil
+}
+
+// GenerateBuildSbomActivity generates SPDX and CycloneDX documents for a build
+// and attaches them as artifacts to the build task.
+// The SRPM and arch tasks are passed explicitly since module component builds
+// share a parent task.
+func (c *Controller) GenerateBuildSbomActivity(ctx context.Context, projectId string, buildId string, importRevision *peridotpb.ImportRevision, srpmTaskId string, archTaskIds []string, task *models.Task) error {
+	projects, err := c.db.ListProjects(&peridotpb.ProjectFilters{Id: wrapperspb.String(projectId)})
+	if err != nil {
+		return fmt.Errorf("could not list projects: %v", err)
+	}
+	if len(projects) != 1 {
+		return errors.New("project not found")
+	}
+	project := projects[0]
+
+	namespace := strings.ToLower(project.AdditionalVendor)
+	if namespace == "" {
+		namespace = strings.ToLower(spdxIdSanitizer.ReplaceAllString(project.TargetVendor, "-"))
+	}
+	sbom := &buildSbom{
+		DocumentId:     uuid.New(),
+		Created:        time.Now(),
+		ProjectId:      projectId,
+		BuildId:        buildId,
+		Vendor:         project.TargetVendor,
+		Namespace:      namespace,
+		ImportRevision: importRevision,
+	}
+
+	artifacts, err := c.db.GetArtifactsForBuild(buildId)
+	if err != nil {
+		return fmt.Errorf("could not get artifacts: %v", err)
+	}
+	for _, artifact := range artifacts {
+		if filepath.Ext(artifact.Name) != ".rpm" {
+			continue
+		}
+		pkg, err := sbomPackageFromArtifact(&artifact)
+		if err != nil {
+			return fmt.Errorf("could not parse artifact %s: %v", artifact.Name, err)
+		}
+		if artifact.Arch == "src" {
+			sbom.Srpm = pkg
+		} else {
+			sbom.Rpms = append(sbom.Rpms, pkg)
+		}
+	}
+	if sbom.Srpm == nil {
+		return errors.New("build has no source RPM")
+	}
+	sort.Slice(sbom.Rpms, func(i, j int) bool {
+		return sbom.Rpms[i].nevra() < sbom.Rpms[j].nevra()
+	})
+
+	sources, lockfiles, err := c.buildInputs(projectId, srpmTaskId, archTaskIds)
+	if err != nil {
+		return err
+	}
+	sbom.Sources = sources.Sources
+
+	buildroot := map[string]*sbomPackage{}
+	for _, lockfile := range lockfiles {
+		for _, nevra := range lockfile.InstalledPackages {
+			pkg, ok := buildroot[nevra]
+			if !ok {
+				pkg, err = parseNevra(nevra)
+				if err != nil {
+					c.log.Warnf("skipping buildroot package: %v", err)
+					continue
+				}
+				buildroot[nevra] = pkg
+				sbom.Buildroot = append(sbom.Buildroot, pkg)
+			}
+			pkg.BuildrootArches = append(pkg.BuildrootArches, lockfile.Arch)
+		}
+	}
+	sort.Slice(sbom.Buildroot, func(i, j int) bool {
+		return sbom.Buildroot[i].nevra() < sbom.Buildroot[j].nevra()
+	})
+
+	spdxDoc, err := sbom.spdx()
+	if err != nil {
+		return fmt.Errorf("could not generate SPDX document: %v", err)
+	}
+	cdxDoc, err := sbom.cycloneDx()
+	if err != nil {
+		return fmt.Errorf("could not generate CycloneDX document: %v", err)
+	}
+
+	documents := []struct {
+		format  peridotpb.SbomFormat
+		content []byte
+	}{
+		{peridotpb.SbomFormat_SBOM_FORMAT_SPDX, spdxDoc},
+		{peridotpb.SbomFormat_SBOM_FORMAT_CYCLONEDX, cdxDoc},
+	}
+	for _, document := range documents {
+		objectName := SbomObjectName(task.ID.String(), document.format)
+		_, err = c.storage.PutObjectBytes(objectName, document.content)
+		if err != nil {
+			return fmt.Errorf("could not upload %s: %v", objectName, err)
+		}
+
+		hash := sha256.Sum256(document.content)
+		err = c.db.AttachArtifactToTask(objectName, hex.EncodeToString(hash[:]), "noarch", nil, task.ID.String())
+		if err != nil {
+			return fmt.Errorf("could not attach %s to task: %v", objectName, err)
+		}
+	}
+
+	return nil
+}
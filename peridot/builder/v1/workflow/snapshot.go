@@ -0,0 +1,133 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go.temporal.io/sdk/workflow"
+	serverdb "peridot.resf.org/peridot/db"
+	"peridot.resf.org/peridot/db/models"
+	"time"
+)
+
+// InternalSnapshotPrefix prefixes the names of snapshots Peridot creates for builds.
+// Snapshot names from users have to start with a letter or digit, so they can't collide
+const InternalSnapshotPrefix = "_"
+
+// BuildrootSnapshotName returns the name of the snapshot pinning the buildroot of a build task
+func BuildrootSnapshotName(taskId string) string {
+	return fmt.Sprintf("%sbuildroot-%s", InternalSnapshotPrefix, taskId)
+}
+
+// CreateRevisionSnapshot creates a snapshot pinning every repository of the project,
+// for every architecture yumrepofs creates revisions for, to the revision returned
+// by revisionFor. Repositories revisionFor returns sql.ErrNoRows for are skipped
+func CreateRevisionSnapshot(tx serverdb.Access, project *models.Project, name string, revisionFor func(repo *models.Repository, arch string) (string, error)) (*models.Snapshot, error) {
+	projectId := project.ID.String()
+	repos, err := tx.FindRepositoriesForProject(projectId, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not list repositories: %v", err)
+	}
+
+	allArches := append([]string{}, project.Archs...)
+	allArches = append(allArches, "src")
+	for _, arch := range allArches {
+		allArches = append(allArches, arch+"-debug")
+	}
+
+	snapshot, err := tx.CreateSnapshot(projectId, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range repos {
+		repo := &repos[i]
+		for _, arch := range allArches {
+			revisionId, err := revisionFor(repo, arch)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					continue
+				}
+				return nil, fmt.Errorf("could not get revision for %s/%s: %v", repo.Name, arch, err)
+			}
+
+			err = tx.AddRevisionToSnapshot(snapshot.ID.String(), repo.ID.String(), arch, revisionId)
+			if err != nil {
+				return nil, fmt.Errorf("could not pin %s/%s: %v", repo.Name, arch, err)
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// DeleteSnapshotActivity deletes a snapshot that was created for a single task.
+// Deleting a snapshot that doesn't exist is not an error, so the activity can be retried
+func (c *Controller) DeleteSnapshotActivity(ctx context.Context, projectId string, name string) error {
+	snapshot, err := c.db.GetSnapshotByName(projectId, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("could not get snapshot %s: %v", name, err)
+	}
+
+	beginTx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+	tx := c.db.UseTransaction(beginTx)
+
+	err = tx.DeleteSnapshot(snapshot.ID.String())
+	if err != nil {
+		_ = beginTx.Rollback()
+		return fmt.Errorf("could not delete snapshot %s: %v", name, err)
+	}
+
+	return beginTx.Commit()
+}
+
+// deleteSnapshot deletes a task snapshot once the workflow no longer needs it.
+// Failures are only logged, a lingering snapshot doesn't affect the task result
+func (c *Controller) deleteSnapshot(ctx workflow.Context, projectId string, name string) {
+	// the workflow context may already be cancelled at this point
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    5 * time.Minute,
+		TaskQueue:              c.mainQueue,
+	})
+	err := workflow.ExecuteActivity(ctx, c.DeleteSnapshotActivity, projectId, name).Get(ctx, nil)
+	if err != nil {
+		c.log.Errorf("could not delete snapshot %s: %v", name, err)
+	}
+}
@@ -401,6 +401,20 @@ func (c *Controller) BuildSRPMActivity(ctx context.Context, upstreamPrefix strin
 		return fmt.Errorf("could not import using srpmproc: %v", err)
 	}
 
+	// Record the lookaside sources for the SBOM of the build
+	sources, err := lookasideSources(cloneDir)
+	if err != nil {
+		return fmt.Errorf("could not parse lookaside sources: %v", err)
+	}
+	sourcesAny, err := anypb.New(sources)
+	if err != nil {
+		return err
+	}
+	err = c.db.SetTaskResponse(task.ID.String(), sourcesAny)
+	if err != nil {
+		return fmt.Errorf("could not set task response: %v", err)
+	}
+
 	// The SOURCES dir should always be available. Some packages don't have that
 	// and Mock complains. Loudly. About that
 	_ = os.MkdirAll(filepath.Join(cloneDir, "SOURCES"), 0755)
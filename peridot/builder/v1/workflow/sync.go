@@ -583,6 +583,18 @@ func kindCatalogExtraOptions(tx peridotdb.Access, req *peridotpb.SyncCatalogRequ
 	ret := &peridotpb.KindCatalogExtraOptions{}
 
 	for _, extraOption := range extraOptions {
+		if extraOption.DefaultResources != nil {
+			err := validateResourceProfile(extraOption.DefaultResources)
+			if err != nil {
+				return nil, fmt.Errorf("invalid default resource profile: %w", err)
+			}
+			err = tx.SetResourceProfile(req.ProjectId.Value, "", extraOption.DefaultResources)
+			if err != nil {
+				return nil, fmt.Errorf("failed to set default resource profile: %w", err)
+			}
+			ret.ModifiedResourceProfiles = append(ret.ModifiedResourceProfiles, "default")
+		}
+
 		for _, pkg := range extraOption.PackageOptions {
 			pkgs, err := tx.GetPackagesInProject(&peridotpb.PackageFilters{NameExact: wrapperspb.String(pkg.Name)}, req.ProjectId.Value, 0, 1)
 			if err != nil {
@@ -595,7 +607,65 @@ func kindCatalogExtraOptions(tx peridotdb.Access, req *peridotpb.SyncCatalogRequ
 			if err != nil {
 				return nil, fmt.Errorf("failed to set extra options for package %s", pkg.Name)
 			}
+
+			// Packages listed without resources go back to the project default
+			if pkg.Resources != nil {
+				err = validateResourceProfile(pkg.Resources)
+				if err != nil {
+					return nil, fmt.Errorf("invalid resource profile for package %s: %w", pkg.Name, err)
+				}
+				err = tx.SetResourceProfile(req.ProjectId.Value, pkg.Name, pkg.Resources)
+				if err != nil {
+					return nil, fmt.Errorf("failed to set resource profile for package %s: %w", pkg.Name, err)
+				}
+				ret.ModifiedResourceProfiles = append(ret.ModifiedResourceProfiles, pkg.Name)
+			} else {
+				err = tx.DeleteResourceProfile(req.ProjectId.Value, pkg.Name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to remove resource profile for package %s: %w", pkg.Name, err)
+				}
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func kindCatalogCompose(tx peridotdb.Access, req *peridotpb.SyncCatalogRequest, composes []*peridotpb.CatalogCompose) (*peridotpb.KindCatalogCompose, error) {
+	ret := &peridotpb.KindCatalogCompose{}
+	if len(composes) == 0 {
+		return ret, nil
+	}
+	if len(composes) > 1 {
+		return nil, fmt.Errorf("only one compose definition is allowed per catalog")
+	}
+
+	compose := composes[0]
+	err := compose.ValidateAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, variant := range compose.Variant {
+		if utils.StrContains(variant.Id, ret.Variants) {
+			return nil, fmt.Errorf("duplicate variant %s", variant.Id)
+		}
+		repos := variant.Repository
+		if len(repos) == 0 {
+			repos = []string{variant.Id}
+		}
+		for _, repo := range repos {
+			_, err := tx.GetRepository(nil, &repo, &req.ProjectId.Value)
+			if err != nil {
+				return nil, fmt.Errorf("repository %s of variant %s does not exist", repo, variant.Id)
+			}
 		}
+		ret.Variants = append(ret.Variants, variant.Id)
+	}
+
+	err = tx.CreateProjectComposeConfiguration(req.ProjectId.Value, compose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create project compose configuration: %w", err)
 	}
 
 	return ret, nil
@@ -834,6 +904,7 @@ func (c *Controller) SyncCatalogActivity(req *peridotpb.SyncCatalogRequest) (*pe
 	var catalogs []*peridotpb.CatalogSync
 	var extraOptions []*peridotpb.CatalogExtraOptions
 	var groupInstallOptions []*peridotpb.CatalogGroupInstallOptions
+	var composes []*peridotpb.CatalogCompose
 
 	files, err := recursiveSearchBillyFs(w.Filesystem, ".", ".cfg")
 	if err != nil {
@@ -882,6 +953,13 @@ func (c *Controller) SyncCatalogActivity(req *peridotpb.SyncCatalogRequest) (*pe
 				return nil, fmt.Errorf("failed to parse kind resf.peridot.v1.CatalogGroupInstallOptions: %w", err)
 			}
 			groupInstallOptions = append(groupInstallOptions, cg1)
+		case "resf.peridot.v1.CatalogCompose":
+			cc1 := &peridotpb.CatalogCompose{}
+			err = prototext.Unmarshal(bts, cc1)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse kind resf.peridot.v1.CatalogCompose: %w", err)
+			}
+			composes = append(composes, cc1)
 		default:
 			return nil, fmt.Errorf("unknown format %s", format)
 		}
@@ -919,6 +997,12 @@ func (c *Controller) SyncCatalogActivity(req *peridotpb.SyncCatalogRequest) (*pe
 	}
 	ret.GroupInstallOptions = resKindCatalogGroupInstallOptions
 
+	resKindCatalogCompose, err := kindCatalogCompose(tx, req, composes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process kind CatalogCompose: %w", err)
+	}
+	ret.Compose = resKindCatalogCompose
+
 	var buildIDs []string
 	var newBuildPackages []string
 	for _, newPackage := range ret.CatalogSync.NewPackages {
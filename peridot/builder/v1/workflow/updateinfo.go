@@ -32,24 +32,22 @@ package workflow
 
 import (
 	"bytes"
-	"cirello.io/dynamolock"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/spf13/viper"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 	"google.golang.org/protobuf/types/known/wrapperspb"
-	"io"
-	"net/http"
-	"net/url"
 	adminpb "peridot.resf.org/peridot/admin/pb"
+	"peridot.resf.org/peridot/advisory"
 	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/peridot/lock"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/peridot/yummeta"
 	"strings"
@@ -60,46 +58,28 @@ func (c *Controller) SetUpdateInfoActivity(ctx context.Context, req *adminpb.Add
 	stopChan := makeHeartbeat(ctx, 10*time.Second)
 	defer func() { stopChan <- true }()
 
-	lock, err := dynamolock.New(
-		c.dynamodb,
-		viper.GetString("dynamodb-table"),
-		dynamolock.WithLeaseDuration(10*time.Second),
-		dynamolock.WithHeartbeatPeriod(3*time.Second),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create dynamolock: %v", err)
+	if c.locker == nil {
+		return nil, fmt.Errorf("no lock backend configured")
 	}
-	defer lock.Close()
 
-	var lockedItem *dynamolock.Lock
+	var projectLock lock.Lock
+	var err error
 	for {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
-		lockedItem, err = lock.AcquireLock(
-			req.ProjectId,
-		)
+		projectLock, err = c.locker.AcquireLock(ctx, req.ProjectId)
 		if err != nil {
 			c.log.Errorf("failed to acquire lock: %v", err)
 			continue
 		}
 		break
 	}
-	didRelease := false
-	releaseLock := func() error {
-		if didRelease {
-			return nil
-		}
-		lockSuccess, err := lock.ReleaseLock(lockedItem)
-		if err != nil {
-			return fmt.Errorf("error releasing lock: %v", err)
-		}
-		if !lockSuccess {
-			return fmt.Errorf("lost lock before release")
+	defer func() {
+		if err := projectLock.Release(); err != nil {
+			c.log.Errorf("error releasing lock: %v", err)
 		}
-		return nil
-	}
-	defer releaseLock()
+	}()
 
 	projects, err := c.db.ListProjects(&peridotpb.ProjectFilters{
 		Id: wrapperspb.String(req.ProjectId),
@@ -117,42 +97,65 @@ func (c *Controller) SetUpdateInfoActivity(ctx context.Context, req *adminpb.Add
 		return nil, fmt.Errorf("failed to list repositories: %v", err)
 	}
 
-	apiBase := "https://apollo.build.resf.org/api/v3/updateinfo"
+	if c.advisories == nil {
+		return nil, fmt.Errorf("no advisory source configured")
+	}
 
-	for _, repo := range repositories {
-		if repo.Name == "all" {
-			continue
+	for _, arch := range project.Archs {
+		realProductName := strings.ReplaceAll(req.ProductName, "$arch", arch)
+		c.log.Infof("Getting advisories for %s", realProductName)
+		advisories, err := c.advisories.ListAdvisories(ctx, realProductName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list advisories: %v", err)
 		}
 
-		for _, arch := range project.Archs {
-			realProductName := strings.ReplaceAll(req.ProductName, "$arch", arch)
-			apiURL := fmt.Sprintf(
-				"%s/%s/%s/updateinfo.xml",
-				apiBase,
-				url.PathEscape(realProductName),
-				repo.Name,
-			)
-			c.log.Infof("Getting updateinfo %s", apiURL)
-			resp, err := http.Get(apiURL)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get updateinfo: %v", err)
+		for _, repo := range repositories {
+			if repo.Name == "all" {
+				continue
 			}
 
-			if resp.StatusCode != http.StatusOK {
-				if resp.StatusCode == http.StatusNotFound {
-					c.log.Warnf("no updateinfo found for %s/%s", realProductName, repo.Name)
+			latestRevision, err := c.db.GetLatestActiveRepositoryRevision(repo.ID.String(), arch)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					c.log.Warnf("no revision found for %s/%s", repo.Name, arch)
 					continue
-				} else {
-					return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 				}
+				return nil, fmt.Errorf("failed to get latest active repository revision: %v", err)
 			}
 
-			c.log.Infof("Got updateinfo for %s/%s", realProductName, repo.Name)
+			// Only advisories for packages actually present in this
+			// revision should end up in updateinfo.xml
+			var primaryRoot yummeta.PrimaryRoot
+			if latestRevision.PrimaryXml != "" {
+				var primaryXmlGz []byte
+				var primaryXml []byte
+				err := multiErrorCheck(
+					b64Decode(latestRevision.PrimaryXml, &primaryXmlGz),
+					decompressWithGz(primaryXmlGz, &primaryXml),
+				)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode primary.xml: %v", err)
+				}
+
+				err = yummeta.UnmarshalPrimary(primaryXml, &primaryRoot)
+				if err != nil {
+					return nil, fmt.Errorf("failed to unmarshal primary.xml: %v", err)
+				}
+			}
+
+			updateInfo := advisory.NewUpdateInfo(advisories, realProductName, &primaryRoot)
+			if len(updateInfo.Updates) == 0 {
+				c.log.Warnf("no updateinfo found for %s/%s", realProductName, repo.Name)
+				continue
+			}
 
-			xmlBytes, err := io.ReadAll(resp.Body)
+			c.log.Infof("Generated updateinfo for %s/%s with %d advisories", realProductName, repo.Name, len(updateInfo.Updates))
+
+			xmlBytes, err := xml.Marshal(updateInfo)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read updateinfo: %v", err)
+				return nil, fmt.Errorf("failed to marshal updateinfo: %v", err)
 			}
+			xmlBytes = append([]byte(xml.Header), xmlBytes...)
 
 			hasher := sha256.New()
 
@@ -203,11 +206,6 @@ func (c *Controller) SetUpdateInfoActivity(ctx context.Context, req *adminpb.Add
 				OpenSize:  openSize,
 			}
 
-			latestRevision, err := c.db.GetLatestActiveRepositoryRevision(repo.ID.String(), arch)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get latest active repository revision: %v", err)
-			}
-
 			// Get the existing repomd.xml
 			// If updateinfo already exists, replace it
 			// Else append it
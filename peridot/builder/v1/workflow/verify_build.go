@@ -0,0 +1,731 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/cavaliergopher/rpm"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+	"io"
+	"os"
+	"path/filepath"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/utils"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	verifySectionArtifact = "artifact"
+	verifySectionHeader   = "header"
+	verifySectionFile     = "file"
+	verifySectionPayload  = "payload"
+)
+
+// Header tags that are expected to differ between two builds of the same
+// SRPM and are ignored when verifying a build.
+// Source: https://github.com/rpm-software-management/rpm/blob/82dafa39a2dfd3e24858681ca75f467c1e1b3635/lib/rpmtag.h
+var verifyIgnoredTags = map[int]bool{
+	62:   true, // HEADERSIGNATURES
+	63:   true, // HEADERIMMUTABLE (region trailer, offsets change with BUILDHOST)
+	1006: true, // BUILDTIME
+	1007: true, // BUILDHOST
+	1094: true, // COOKIE (build host and time)
+	1096: true, // FILEINODES (inode numbers on the build host)
+	5092: true, // PAYLOADDIGEST (compared as payload)
+	5097: true, // PAYLOADDIGESTALT (compared as payload)
+}
+
+// Header tags that make up the file list. These are compared per file
+// instead of as whole tags to keep the report readable.
+var verifyFileTags = map[int]bool{
+	1028: true, // FILESIZES
+	1030: true, // FILEMODES
+	1034: true, // FILEMTIMES
+	1035: true, // FILEDIGESTS
+	1036: true, // FILELINKTOS
+	1037: true, // FILEFLAGS
+	1039: true, // FILEUSERNAME
+	1040: true, // FILEGROUPNAME
+	1116: true, // DIRINDEXES
+	1117: true, // BASENAMES
+	1118: true, // DIRNAMES
+}
+
+var verifyTagNames = map[int]string{
+	1000: "NAME",
+	1001: "VERSION",
+	1002: "RELEASE",
+	1003: "EPOCH",
+	1004: "SUMMARY",
+	1005: "DESCRIPTION",
+	1009: "SIZE",
+	1010: "DISTRIBUTION",
+	1011: "VENDOR",
+	1014: "LICENSE",
+	1015: "PACKAGER",
+	1022: "ARCH",
+	1023: "PREIN",
+	1024: "POSTIN",
+	1025: "PREUN",
+	1026: "POSTUN",
+	1044: "SOURCERPM",
+	1046: "ARCHIVESIZE",
+	1047: "PROVIDENAME",
+	1048: "REQUIREFLAGS",
+	1049: "REQUIRENAME",
+	1050: "REQUIREVERSION",
+	1064: "RPMVERSION",
+	1080: "CHANGELOGTIME",
+	1081: "CHANGELOGNAME",
+	1082: "CHANGELOGTEXT",
+	1112: "PROVIDEFLAGS",
+	1113: "PROVIDEVERSION",
+	1122: "OPTFLAGS",
+	1124: "PAYLOADFORMAT",
+	1125: "PAYLOADCOMPRESSOR",
+	1126: "PAYLOADFLAGS",
+	1132: "PLATFORM",
+	5011: "FILEDIGESTALGO",
+}
+
+// VerifyBuildStep1 contains everything needed to rebuild a build
+// the same way it was originally built
+type VerifyBuildStep1 struct {
+	Snapshot       string
+	PackageName    string
+	ChecksDisabled bool
+	PackageVersion *models.PackageVersion
+	SRPM           *UploadActivityResult
+	Originals      []string
+	Arches         []string
+}
+
+type channelVerifyArch struct {
+	err     error
+	results []*peridotpb.VerifyBuildArtifactResult
+}
+
+func verifyBuildSnapshotName(taskId string) string {
+	return fmt.Sprintf("%sverify-%s", InternalSnapshotPrefix, taskId)
+}
+
+func verifyTagName(id int) string {
+	if name, ok := verifyTagNames[id]; ok {
+		return fmt.Sprintf("%s (%d)", name, id)
+	}
+	return fmt.Sprintf("%d", id)
+}
+
+func formatVerifyValue(value interface{}) string {
+	var ret string
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		ret = hex.EncodeToString(v)
+	case []string:
+		ret = strings.Join(v, ", ")
+	default:
+		ret = fmt.Sprint(v)
+	}
+
+	// Keep the report readable for large tags such as changelogs
+	if len(ret) > 512 {
+		ret = ret[:512] + "..."
+	}
+	return ret
+}
+
+// compareRpms compares the headers and payload of two RPMs, ignoring
+// signatures, build host and build time
+func compareRpms(original []byte, rebuilt []byte) ([]*peridotpb.VerifyBuildDifference, error) {
+	originalReader := bytes.NewReader(original)
+	originalPkg, err := rpm.Read(originalReader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read original rpm: %v", err)
+	}
+	rebuiltReader := bytes.NewReader(rebuilt)
+	rebuiltPkg, err := rpm.Read(rebuiltReader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rebuilt rpm: %v", err)
+	}
+
+	var differences []*peridotpb.VerifyBuildDifference
+
+	tagSet := map[int]bool{}
+	for id := range originalPkg.Header.Tags {
+		tagSet[id] = true
+	}
+	for id := range rebuiltPkg.Header.Tags {
+		tagSet[id] = true
+	}
+	var tagIds []int
+	for id := range tagSet {
+		if verifyIgnoredTags[id] || verifyFileTags[id] {
+			continue
+		}
+		tagIds = append(tagIds, id)
+	}
+	sort.Ints(tagIds)
+
+	for _, id := range tagIds {
+		var originalValue, rebuiltValue interface{}
+		if tag := originalPkg.Header.GetTag(id); tag != nil {
+			originalValue = tag.Value
+		}
+		if tag := rebuiltPkg.Header.GetTag(id); tag != nil {
+			rebuiltValue = tag.Value
+		}
+		if reflect.DeepEqual(originalValue, rebuiltValue) {
+			continue
+		}
+		differences = append(differences, &peridotpb.VerifyBuildDifference{
+			Section:  verifySectionHeader,
+			Key:      verifyTagName(id),
+			Original: formatVerifyValue(originalValue),
+			Rebuilt:  formatVerifyValue(rebuiltValue),
+		})
+	}
+
+	originalFiles := map[string]rpm.FileInfo{}
+	for _, file := range originalPkg.Files() {
+		originalFiles[file.Name()] = file
+	}
+	rebuiltFiles := map[string]rpm.FileInfo{}
+	for _, file := range rebuiltPkg.Files() {
+		rebuiltFiles[file.Name()] = file
+	}
+	fileSet := map[string]bool{}
+	for name := range originalFiles {
+		fileSet[name] = true
+	}
+	for name := range rebuiltFiles {
+		fileSet[name] = true
+	}
+	var fileNames []string
+	for name := range fileSet {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+
+	for _, name := range fileNames {
+		originalFile, inOriginal := originalFiles[name]
+		rebuiltFile, inRebuilt := rebuiltFiles[name]
+		if !inOriginal || !inRebuilt {
+			diff := &peridotpb.VerifyBuildDifference{
+				Section: verifySectionFile,
+				Key:     name,
+			}
+			if inOriginal {
+				diff.Original = "present"
+				diff.Rebuilt = "missing"
+			} else {
+				diff.Original = "missing"
+				diff.Rebuilt = "present"
+			}
+			differences = append(differences, diff)
+			continue
+		}
+
+		attrs := []struct {
+			name     string
+			original interface{}
+			rebuilt  interface{}
+		}{
+			{"digest", originalFile.Digest(), rebuiltFile.Digest()},
+			{"size", originalFile.Size(), rebuiltFile.Size()},
+			{"mode", originalFile.Mode(), rebuiltFile.Mode()},
+			{"mtime", originalFile.ModTime().Unix(), rebuiltFile.ModTime().Unix()},
+			{"flags", originalFile.Flags(), rebuiltFile.Flags()},
+			{"owner", originalFile.Owner(), rebuiltFile.Owner()},
+			{"group", originalFile.Group(), rebuiltFile.Group()},
+			{"linkto", originalFile.Linkname(), rebuiltFile.Linkname()},
+		}
+		for _, attr := range attrs {
+			if attr.original == attr.rebuilt {
+				continue
+			}
+			differences = append(differences, &peridotpb.VerifyBuildDifference{
+				Section:  verifySectionFile,
+				Key:      fmt.Sprintf("%s:%s", name, attr.name),
+				Original: formatVerifyValue(attr.original),
+				Rebuilt:  formatVerifyValue(attr.rebuilt),
+			})
+		}
+	}
+
+	// Both readers are now positioned at the start of the payload
+	originalHash := sha256.New()
+	if _, err := io.Copy(originalHash, originalReader); err != nil {
+		return nil, fmt.Errorf("could not hash original payload: %v", err)
+	}
+	rebuiltHash := sha256.New()
+	if _, err := io.Copy(rebuiltHash, rebuiltReader); err != nil {
+		return nil, fmt.Errorf("could not hash rebuilt payload: %v", err)
+	}
+	originalPayload := hex.EncodeToString(originalHash.Sum(nil))
+	rebuiltPayload := hex.EncodeToString(rebuiltHash.Sum(nil))
+	if originalPayload != rebuiltPayload {
+		differences = append(differences, &peridotpb.VerifyBuildDifference{
+			Section:  verifySectionPayload,
+			Key:      "sha256",
+			Original: originalPayload,
+			Rebuilt:  rebuiltPayload,
+		})
+	}
+
+	return differences, nil
+}
+
+// VerifyBuildWorkflow rebuilds an existing build with the same SRPM, mock configuration
+// and buildroot repository revisions, and compares the resulting RPMs with the
+// original artifacts. Artifacts are never uploaded or attached to the original build,
+// only a diff report is stored as an artifact of the verification task.
+func (c *Controller) VerifyBuildWorkflow(ctx workflow.Context, req *peridotpb.VerifyBuildRequest, task *models.Task) (*peridotpb.VerifyBuildTask, error) {
+	ret := peridotpb.VerifyBuildTask{
+		BuildId: req.BuildId,
+	}
+
+	deferTask, errorDetails, err := c.commonCreateTask(task, &ret)
+	defer deferTask()
+	if err != nil {
+		return nil, err
+	}
+
+	var step1 VerifyBuildStep1
+	prepareCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    30 * time.Minute,
+		TaskQueue:              c.mainQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	})
+	err = workflow.ExecuteActivity(prepareCtx, c.PrepareVerifyBuildActivity, req, task).Get(ctx, &step1)
+	if err != nil {
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+	// The snapshot is only needed for the rebuild
+	defer c.deleteSnapshot(ctx, req.ProjectId, step1.Snapshot)
+	ret.Snapshot = step1.Snapshot
+
+	taskID := task.ID.String()
+	extraOptions := &peridotpb.ExtraBuildOptions{
+		YumrepofsSnapshot: step1.Snapshot,
+	}
+
+	resources, buildTimeout := c.packageResources(ctx, req.ProjectId, step1.PackageName)
+	if buildTimeout == 0 {
+		buildTimeout = 48 * time.Hour
+	}
+
+	archChannel := workflow.NewChannel(ctx)
+	for _, archTop := range step1.Arches {
+		archGoCtx := workflow.WithValue(ctx, "arch", archTop)
+		workflow.Go(archGoCtx, func(ctx workflow.Context) {
+			arch := ctx.Value("arch").(string)
+			res := &channelVerifyArch{}
+			defer archChannel.Send(ctx, res)
+
+			var archTask models.Task
+			archTaskEffect := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+				newTask, err := c.db.CreateTask(nil, arch, peridotpb.TaskType_TASK_TYPE_BUILD_ARCH, &req.ProjectId, &taskID)
+				if err != nil {
+					return &models.Task{}
+				}
+				return newTask
+			})
+			err := archTaskEffect.Get(&archTask)
+			if err != nil || !archTask.ProjectId.Valid {
+				res.err = fmt.Errorf("failed to create arch task: %v", err)
+				return
+			}
+
+			workerReq := &ProvisionWorkerRequest{
+				ParentTaskId: sql.NullString{String: taskID, Valid: true},
+				Purpose:      "v-" + arch,
+				Arch:         arch,
+				ProjectId:    req.ProjectId,
+				HighResource: true,
+				Privileged:   true,
+				Resources:    resources,
+			}
+			archTaskQueue, _, cleanupArch, err := c.buildOnArchWorker(ctx, workerReq, &archTask, buildTimeout, func(ctx workflow.Context, queue string, task *models.Task, timeout time.Duration) error {
+				archCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+					ScheduleToStartTimeout: 12 * time.Hour,
+					StartToCloseTimeout:    timeout,
+					HeartbeatTimeout:       12 * time.Hour,
+					TaskQueue:              queue,
+					RetryPolicy: &temporal.RetryPolicy{
+						MaximumAttempts: 1,
+					},
+				})
+				return workflow.ExecuteActivity(archCtx, c.BuildArchActivity, req.ProjectId, step1.PackageName, step1.ChecksDisabled, step1.PackageVersion, step1.SRPM, task, arch, extraOptions).Get(archCtx, nil)
+			})
+			if err != nil {
+				res.err = fmt.Errorf("failed to build arch %s: %v", arch, err)
+				return
+			}
+			defer cleanupArch()
+
+			verifyArchCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+				ScheduleToStartTimeout: 12 * time.Hour,
+				StartToCloseTimeout:    2 * time.Hour,
+				HeartbeatTimeout:       2 * time.Minute,
+				TaskQueue:              archTaskQueue,
+			})
+			err = workflow.ExecuteActivity(verifyArchCtx, c.VerifyArchActivity, step1.Originals, arch).Get(ctx, &res.results)
+			if err != nil {
+				res.err = fmt.Errorf("failed to verify arch %s: %v", arch, err)
+				return
+			}
+		})
+	}
+
+	var archErrs []string
+	rebuiltNames := map[string]bool{}
+	for i := 0; i < len(step1.Arches); i++ {
+		var res channelVerifyArch
+		archChannel.Receive(ctx, &res)
+		if res.err != nil {
+			archErrs = append(archErrs, res.err.Error())
+			continue
+		}
+		for _, result := range res.results {
+			rebuiltNames[result.Name] = true
+			ret.Artifacts = append(ret.Artifacts, result)
+		}
+	}
+	if len(archErrs) > 0 {
+		err = errors.New(strings.Join(archErrs, "; "))
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+
+	// Original artifacts that none of the architectures produced again
+	for _, original := range step1.Originals {
+		name := filepath.Base(original)
+		if rebuiltNames[name] {
+			continue
+		}
+		ret.Artifacts = append(ret.Artifacts, &peridotpb.VerifyBuildArtifactResult{
+			Name:         name,
+			Reproducible: false,
+			Differences: []*peridotpb.VerifyBuildDifference{
+				{
+					Section:  verifySectionArtifact,
+					Key:      name,
+					Original: "present",
+					Rebuilt:  "missing",
+				},
+			},
+		})
+	}
+	sort.SliceStable(ret.Artifacts, func(i, j int) bool {
+		if ret.Artifacts[i].Name == ret.Artifacts[j].Name {
+			return ret.Artifacts[i].BuildArch < ret.Artifacts[j].BuildArch
+		}
+		return ret.Artifacts[i].Name < ret.Artifacts[j].Name
+	})
+
+	ret.Reproducible = true
+	for _, artifact := range ret.Artifacts {
+		if !artifact.Reproducible {
+			ret.Reproducible = false
+			break
+		}
+	}
+
+	reportCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    10 * time.Minute,
+		TaskQueue:              c.mainQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 3,
+		},
+	})
+	err = workflow.ExecuteActivity(reportCtx, c.UploadVerifyBuildReportActivity, &ret, task).Get(ctx, &ret.Report)
+	if err != nil {
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
+
+	return &ret, nil
+}
+
+// PrepareVerifyBuildActivity collects the SRPM and artifacts of the original build
+// and pins the buildroot repositories to the revisions that were active when the
+// original build started building architectures
+func (c *Controller) PrepareVerifyBuildActivity(ctx context.Context, req *peridotpb.VerifyBuildRequest, task *models.Task) (*VerifyBuildStep1, error) {
+	project, err := c.getSingleProject(req.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	build, err := c.db.GetBuild(req.ProjectId, req.BuildId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get build %s: %v", req.BuildId, err)
+	}
+	if build.TaskStatus != peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED {
+		return nil, fmt.Errorf("build %s has not succeeded", req.BuildId)
+	}
+	if !build.TaskResponse.Valid {
+		return nil, fmt.Errorf("build %s has no response", req.BuildId)
+	}
+	anyResponse := &anypb.Any{}
+	err = protojson.Unmarshal(build.TaskResponse.JSONText, anyResponse)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal build response: %v", err)
+	}
+	submitBuildTask := &peridotpb.SubmitBuildTask{}
+	err = anyResponse.UnmarshalTo(submitBuildTask)
+	if err != nil {
+		return nil, fmt.Errorf("build %s is not a package build", req.BuildId)
+	}
+	// Module components are built with options derived from the module stream
+	// (dist, enabled modules, side repositories) that are not stored with the build
+	if submitBuildTask.Modular {
+		return nil, fmt.Errorf("verifying module component builds is not supported")
+	}
+
+	packageVersion, err := c.db.GetPackageVersion(build.PackageVersionId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get package version: %v", err)
+	}
+
+	artifacts, err := c.db.GetArtifactsForBuild(build.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not get artifacts for build: %v", err)
+	}
+	var srpm *models.TaskArtifact
+	for i, artifact := range artifacts {
+		if strings.HasSuffix(artifact.Name, ".src.rpm") {
+			srpm = &artifacts[i]
+			break
+		}
+	}
+	if srpm == nil {
+		return nil, fmt.Errorf("build %s has no srpm artifact", req.BuildId)
+	}
+
+	metadata := &anypb.Any{}
+	err = protojson.Unmarshal(srpm.Metadata.JSONText, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal srpm metadata: %v", err)
+	}
+	arches, err := getSrpmBuildArches(project, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Arches) > 0 {
+		var filtered []string
+		for _, arch := range arches {
+			if utils.StrContains(arch, req.Arches) {
+				filtered = append(filtered, arch)
+			}
+		}
+		if len(filtered) == 0 {
+			return nil, fmt.Errorf("build %s was not built for any of %s", req.BuildId, strings.Join(req.Arches, ", "))
+		}
+		arches = filtered
+	}
+
+	// Only compare against the artifacts of the architectures that are rebuilt
+	var originals []string
+	for _, artifact := range artifacts {
+		if strings.HasSuffix(artifact.Name, ".src.rpm") || !strings.HasSuffix(artifact.Name, ".rpm") {
+			continue
+		}
+		if artifact.Arch != "noarch" && !utils.StrContains(artifact.Arch, arches) {
+			continue
+		}
+		if utils.StrContains(artifact.Name, originals) {
+			continue
+		}
+		originals = append(originals, artifact.Name)
+	}
+	if len(originals) == 0 {
+		return nil, fmt.Errorf("build %s has no binary artifacts for %s", req.BuildId, strings.Join(arches, ", "))
+	}
+	sort.Strings(originals)
+
+	// The buildroot was resolved when the first architecture started building
+	buildrootAt := build.CreatedAt
+	tasks, err := c.db.GetTask(build.TaskId, &req.ProjectId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get build task: %v", err)
+	}
+	first := true
+	for _, subtask := range tasks {
+		if subtask.Type != peridotpb.TaskType_TASK_TYPE_BUILD_ARCH {
+			continue
+		}
+		if first || subtask.CreatedAt.Before(buildrootAt) {
+			buildrootAt = subtask.CreatedAt
+			first = false
+		}
+	}
+
+	beginTx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %v", err)
+	}
+	tx := c.db.UseTransaction(beginTx)
+
+	snapshotName := verifyBuildSnapshotName(task.ID.String())
+	_, err = CreateRevisionSnapshot(tx, project, snapshotName, func(repo *models.Repository, arch string) (string, error) {
+		revision, err := tx.GetRepositoryRevisionActiveAt(repo.ID.String(), arch, buildrootAt)
+		if err != nil {
+			return "", err
+		}
+		return revision.ID.String(), nil
+	})
+	if err != nil {
+		_ = beginTx.Rollback()
+		return nil, fmt.Errorf("could not create snapshot %s: %v", snapshotName, err)
+	}
+	err = beginTx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not commit snapshot: %v", err)
+	}
+
+	return &VerifyBuildStep1{
+		Snapshot:       snapshotName,
+		PackageName:    build.PackageName,
+		ChecksDisabled: submitBuildTask.ChecksDisabled,
+		PackageVersion: packageVersion,
+		SRPM: &UploadActivityResult{
+			ObjectName: srpm.Name,
+			HashSha256: srpm.HashSha256,
+			Arch:       srpm.Arch,
+		},
+		Originals: originals,
+		Arches:    arches,
+	}, nil
+}
+
+// VerifyArchActivity compares the RPMs produced by BuildArchActivity
+// with the artifacts of the original build
+func (c *Controller) VerifyArchActivity(ctx context.Context, originals []string, arch string) ([]*peridotpb.VerifyBuildArtifactResult, error) {
+	stopChan := makeHeartbeat(ctx, 10*time.Second)
+	defer func() { stopChan <- true }()
+
+	rpms, err := findRpms()
+	if err != nil {
+		return nil, err
+	}
+
+	originalByName := map[string]string{}
+	for _, original := range originals {
+		originalByName[filepath.Base(original)] = original
+	}
+
+	var ret []*peridotpb.VerifyBuildArtifactResult
+	for _, rpmPath := range rpms {
+		name := filepath.Base(rpmPath)
+		result := &peridotpb.VerifyBuildArtifactResult{
+			Name:      name,
+			BuildArch: arch,
+		}
+		ret = append(ret, result)
+
+		original, ok := originalByName[name]
+		if !ok {
+			result.Differences = append(result.Differences, &peridotpb.VerifyBuildDifference{
+				Section:  verifySectionArtifact,
+				Key:      name,
+				Original: "missing",
+				Rebuilt:  "present",
+			})
+			continue
+		}
+
+		originalBytes, err := c.storage.ReadObject(original)
+		if err != nil {
+			return nil, fmt.Errorf("could not read original artifact %s: %v", original, err)
+		}
+		rebuiltBytes, err := os.ReadFile(rpmPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read rebuilt artifact %s: %v", rpmPath, err)
+		}
+
+		differences, err := compareRpms(originalBytes, rebuiltBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not compare %s: %v", name, err)
+		}
+		result.Differences = differences
+		result.Reproducible = len(differences) == 0
+	}
+
+	return ret, nil
+}
+
+// UploadVerifyBuildReportActivity stores the verification result as a JSON
+// report and attaches it as an artifact of the verification task
+func (c *Controller) UploadVerifyBuildReportActivity(ctx context.Context, report *peridotpb.VerifyBuildTask, task *models.Task) (string, error) {
+	reportBytes, err := protojson.MarshalOptions{
+		Multiline:       true,
+		EmitUnpopulated: true,
+	}.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal report: %v", err)
+	}
+
+	objectName := filepath.Join(task.ID.String(), "verify-report.json")
+	_, err = c.storage.PutObjectBytes(objectName, reportBytes)
+	if err != nil {
+		return "", fmt.Errorf("could not upload report: %v", err)
+	}
+
+	hash := sha256.Sum256(reportBytes)
+	err = c.db.AttachArtifactToTask(objectName, hex.EncodeToString(hash[:]), "noarch", nil, task.ID.String())
+	if err != nil {
+		return "", fmt.Errorf("could not attach report to task: %v", err)
+	}
+
+	return objectName, nil
+}
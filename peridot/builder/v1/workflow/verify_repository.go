@@ -0,0 +1,334 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"io"
+	"path/filepath"
+	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/peridot/lookaside"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+	"peridot.resf.org/utils"
+	"strings"
+	"time"
+)
+
+// VerifyRepositoryWorkflow audits a repository revision, see VerifyRepositoryActivity
+func (c *Controller) VerifyRepositoryWorkflow(ctx workflow.Context, req *peridotpb.VerifyRepositoryRequest, revisionId string, task *models.Task) (*peridotpb.VerifyRepositoryTask, error) {
+	ret := peridotpb.VerifyRepositoryTask{
+		RevisionId: revisionId,
+	}
+	deferTask, errorDetails, err := c.commonCreateTask(task, &ret)
+	defer deferTask()
+	if err != nil {
+		return nil, err
+	}
+
+	verifyCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: 25 * time.Minute,
+		StartToCloseTimeout:    12 * time.Hour,
+		HeartbeatTimeout:       2 * time.Minute,
+		TaskQueue:              c.mainQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	})
+	var res peridotpb.VerifyRepositoryTask
+	err = workflow.ExecuteActivity(verifyCtx, c.VerifyRepositoryActivity, req.ProjectId.Value, revisionId, task).Get(ctx, &res)
+	if err != nil {
+		setActivityError(errorDetails, err)
+		return nil, err
+	}
+	ret.KeyName = res.KeyName
+	ret.CheckedPackages = res.CheckedPackages
+	ret.MissingPackages = res.MissingPackages
+	ret.MismatchedPackages = res.MismatchedPackages
+	ret.UnsignedPackages = res.UnsignedPackages
+	ret.RepoMdSignature = res.RepoMdSignature
+	ret.RepoMdSignatureError = res.RepoMdSignatureError
+	ret.Valid = res.Valid
+
+	task.Status = peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED
+
+	return &ret, nil
+}
+
+// VerifyRepositoryActivity checks every package in the primary.xml of given revision.
+// A package has to exist in lookaside with the published sha256, and a signature
+// with one of the published keys of the project has to be recorded for the published object.
+// The detached repomd.xml signature has to verify with one of the published keys.
+func (c *Controller) VerifyRepositoryActivity(ctx context.Context, projectId string, revisionId string, task *models.Task) (*peridotpb.VerifyRepositoryTask, error) {
+	stopChan := makeHeartbeat(ctx, 3*time.Second)
+	defer func() { stopChan <- true }()
+
+	revision, err := c.db.GetRepositoryRevision(revisionId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revision: %v", err)
+	}
+
+	primaryXml, err := decodeRevisionContent(revision.PrimaryXml)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode primary.xml: %v", err)
+	}
+	var primary yummeta.PrimaryRoot
+	if len(primaryXml) > 0 {
+		err = yummeta.UnmarshalPrimary(primaryXml, &primary)
+		if err != nil {
+			return nil, fmt.Errorf("could not unmarshal primary.xml: %v", err)
+		}
+	}
+
+	urlMappings := map[string]string{}
+	if len(revision.UrlMappings) > 0 {
+		err = json.Unmarshal(revision.UrlMappings, &urlMappings)
+		if err != nil {
+			return nil, fmt.Errorf("could not unmarshal url mappings: %v", err)
+		}
+	}
+
+	ret := &peridotpb.VerifyRepositoryTask{
+		RevisionId: revisionId,
+	}
+
+	key, err := c.db.GetDefaultKeyForProject(projectId)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("could not get default key for project: %v", err)
+		}
+		key = nil
+		_ = c.logToMon(
+			[]string{"Project has no default key, skipping package signature checks"},
+			task.ID.String(),
+			utils.NullStringToEmptyString(task.ParentTaskId),
+		)
+	} else {
+		ret.KeyName = key.Name
+	}
+
+	// Packages signed with a retiring key are still valid until the key expires
+	publishedKeys := map[string]*models.Key{}
+	if key != nil {
+		keys, err := c.db.ListKeysForProject(projectId)
+		if err != nil {
+			return nil, fmt.Errorf("could not list keys: %v", err)
+		}
+		now := time.Now()
+		for i := range keys {
+			if keys[i].Published(now) {
+				publishedKeys[keys[i].GpgId] = &keys[i]
+			}
+		}
+	}
+
+	for _, pkg := range primary.Packages {
+		if pkg.Location == nil {
+			continue
+		}
+		ret.CheckedPackages++
+
+		href := pkg.Location.Href
+		objectName := strings.TrimPrefix(filepath.Clean(href), "Packages/")
+		if mapped, ok := urlMappings[objectName]; ok {
+			objectName = mapped
+		}
+		result := &peridotpb.VerifyRepositoryPackage{
+			Href:       href,
+			ObjectName: objectName,
+		}
+		if pkg.Checksum != nil {
+			result.ExpectedSha256 = pkg.Checksum.Value
+		}
+
+		exists, err := c.storage.Exists(objectName)
+		if err != nil {
+			return nil, fmt.Errorf("could not check if %s exists: %v", objectName, err)
+		}
+		if !exists {
+			result.Reason = "object does not exist"
+			ret.MissingPackages = append(ret.MissingPackages, result)
+			continue
+		}
+
+		result.ActualSha256, err = objectSha256(c.storage, objectName)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash %s: %v", objectName, err)
+		}
+		if pkg.Checksum == nil || pkg.Checksum.Type != "sha256" {
+			result.Reason = "primary.xml has no sha256 checksum"
+			ret.MismatchedPackages = append(ret.MismatchedPackages, result)
+			continue
+		}
+		if result.ActualSha256 != result.ExpectedSha256 {
+			result.Reason = "checksum does not match primary.xml"
+			ret.MismatchedPackages = append(ret.MismatchedPackages, result)
+			continue
+		}
+
+		if key == nil {
+			continue
+		}
+		// Signed RPMs are stored as {dir}/{gpgId}/{file}, while signatures
+		// are recorded for the unsigned artifact {dir}/{file}
+		parts := strings.Split(objectName, "/")
+		if len(parts) != 3 {
+			result.Reason = "object is not signed"
+			ret.UnsignedPackages = append(ret.UnsignedPackages, result)
+			continue
+		}
+		signingKey := publishedKeys[parts[1]]
+		if signingKey == nil {
+			result.Reason = fmt.Sprintf("object is signed with key %s, which is not published for the project", parts[1])
+			ret.UnsignedPackages = append(ret.UnsignedPackages, result)
+			continue
+		}
+		signedHash, err := c.db.GetTaskArtifactSignatureHash(filepath.Join(parts[0], parts[2]), signingKey.ID.String())
+		if err != nil {
+			if err == sql.ErrNoRows {
+				result.Reason = "no signature has been recorded for the artifact"
+				ret.UnsignedPackages = append(ret.UnsignedPackages, result)
+				continue
+			}
+			return nil, fmt.Errorf("could not get signature hash for %s: %v", objectName, err)
+		}
+		if signedHash != result.ExpectedSha256 {
+			result.ActualSha256 = signedHash
+			result.Reason = "recorded signature is for a different object"
+			ret.UnsignedPackages = append(ret.UnsignedPackages, result)
+		}
+	}
+
+	ret.RepoMdSignature, err = c.verifyRepoMdSignature(projectId, revision)
+	if err != nil {
+		ret.RepoMdSignatureError = err.Error()
+	}
+
+	ret.Valid = len(ret.MissingPackages) == 0 &&
+		len(ret.MismatchedPackages) == 0 &&
+		len(ret.UnsignedPackages) == 0 &&
+		ret.RepoMdSignature == peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_VALID
+
+	_ = c.logToMon(
+		[]string{fmt.Sprintf(
+			"Verified %d packages: %d missing, %d mismatched, %d unsigned",
+			ret.CheckedPackages,
+			len(ret.MissingPackages),
+			len(ret.MismatchedPackages),
+			len(ret.UnsignedPackages),
+		)},
+		task.ID.String(),
+		utils.NullStringToEmptyString(task.ParentTaskId),
+	)
+
+	return ret, nil
+}
+
+// verifyRepoMdSignature verifies the detached signature of repomd.xml stored
+// by the hashed repositories workflow against the published keys of the project
+func (c *Controller) verifyRepoMdSignature(projectId string, revision *models.RepositoryRevision) (peridotpb.RepoMdSignatureStatus, error) {
+	signatureName := filepath.Join("repo-signatures", revision.ID.String()+".xml.asc")
+	exists, err := c.storage.Exists(signatureName)
+	if err != nil {
+		return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_UNSPECIFIED, fmt.Errorf("could not check if %s exists: %v", signatureName, err)
+	}
+	if !exists {
+		return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_MISSING, nil
+	}
+	asc, err := c.storage.ReadObject(signatureName)
+	if err != nil {
+		return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_UNSPECIFIED, fmt.Errorf("could not read %s: %v", signatureName, err)
+	}
+
+	var repomd []byte
+	err = b64Decode(revision.RepomdXml, &repomd)
+	if err != nil {
+		return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_UNSPECIFIED, fmt.Errorf("could not decode repomd.xml: %v", err)
+	}
+
+	keys, err := c.db.ListKeysForProject(projectId)
+	if err != nil {
+		return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_UNSPECIFIED, fmt.Errorf("could not list keys: %v", err)
+	}
+	var keyring openpgp.EntityList
+	now := time.Now()
+	for _, key := range keys {
+		if !key.Published(now) {
+			continue
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key.PublicKey))
+		if err != nil {
+			return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_UNSPECIFIED, fmt.Errorf("could not read public key %s: %v", key.Name, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(repomd), bytes.NewReader(asc), nil)
+	if err != nil {
+		return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_INVALID, err
+	}
+
+	return peridotpb.RepoMdSignatureStatus_REPO_MD_SIGNATURE_STATUS_VALID, nil
+}
+
+// objectSha256 returns the hex encoded sha256 of an object,
+// streaming it if the storage backend supports it
+func objectSha256(storage lookaside.Storage, objectName string) (string, error) {
+	hasher := sha256.New()
+	if opener, ok := storage.(lookaside.ObjectOpener); ok {
+		r, err := opener.OpenObject(objectName)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		_, err = io.Copy(hasher, r)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		content, err := storage.ReadObject(objectName)
+		if err != nil {
+			return "", err
+		}
+		hasher.Write(content)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -33,7 +33,6 @@ package workflow
 import (
 	"context"
 	"fmt"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/sirupsen/logrus"
 	"go.temporal.io/sdk/activity"
@@ -41,12 +40,15 @@ import (
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
+	"peridot.resf.org/peridot/advisory"
 	serverdb "peridot.resf.org/peridot/db"
 	"peridot.resf.org/peridot/db/models"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	"peridot.resf.org/peridot/lock"
 	"peridot.resf.org/peridot/lookaside"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/peridot/plugin"
+	"peridot.resf.org/peridot/provisioner"
 	"peridot.resf.org/peridot/rpmbuild"
 	"peridot.resf.org/utils"
 	"time"
@@ -60,21 +62,25 @@ const (
 	ErrorReasonInternalError       = "internal-error"
 	ErrorReasonCouldNotFindPackage = "could not find specified package"
 	ErrorReasonActivityFailed      = "activity failed in asynctask"
+	ErrorReasonWorkerTerminated    = "worker terminated by provisioner"
 )
 
 type Controller struct {
 	internalMonBuffer []string
 
-	temporal  client.Client
-	db        serverdb.Access
-	storage   lookaside.Storage
-	log       *logrus.Logger
-	rpmbuild  rpmbuild.Access
-	plugins   []plugin.Plugin
-	mainQueue string
-	keykeeper keykeeperpb.KeykeeperServiceClient
-	dynamodb  *dynamodb.DynamoDB
-	unshared  bool
+	temporal   client.Client
+	db         serverdb.Access
+	storage    lookaside.Storage
+	log        *logrus.Logger
+	rpmbuild   rpmbuild.Access
+	plugins    []plugin.Plugin
+	mainQueue  string
+	keykeeper  keykeeperpb.KeykeeperServiceClient
+	locker     lock.Locker
+	advisories advisory.Source
+	// provisioner is only set for the service provisioning ephemeral workers
+	provisioner provisioner.Provisioner
+	unshared    bool
 }
 
 type MonLogger struct {
@@ -83,17 +89,19 @@ type MonLogger struct {
 	Controller   *Controller
 }
 
-func NewController(c client.Client, db serverdb.Access, storage lookaside.Storage, mainQueue string, keykeeperClient keykeeperpb.KeykeeperServiceClient, ddb *dynamodb.DynamoDB, plugins ...plugin.Plugin) *Controller {
+func NewController(c client.Client, db serverdb.Access, storage lookaside.Storage, mainQueue string, keykeeperClient keykeeperpb.KeykeeperServiceClient, locker lock.Locker, advisories advisory.Source, workerProvisioner provisioner.Provisioner, plugins ...plugin.Plugin) *Controller {
 	return &Controller{
-		temporal:  c,
-		db:        db,
-		storage:   storage,
-		log:       logrus.New(),
-		rpmbuild:  rpmbuild.New(osfs.New(".")),
-		plugins:   plugins,
-		mainQueue: mainQueue,
-		keykeeper: keykeeperClient,
-		dynamodb:  ddb,
+		temporal:    c,
+		db:          db,
+		storage:     storage,
+		log:         logrus.New(),
+		rpmbuild:    rpmbuild.New(osfs.New(".")),
+		plugins:     plugins,
+		mainQueue:   mainQueue,
+		keykeeper:   keykeeperClient,
+		locker:      locker,
+		advisories:  advisories,
+		provisioner: workerProvisioner,
 	}
 }
 
@@ -140,6 +148,44 @@ func setActivityError(errorDetails *peridotpb.TaskErrorDetails, err error) {
 	}
 }
 
+func setWorkerTerminatedError(errorDetails *peridotpb.TaskErrorDetails, reason string, resources *provisioner.Resources, timeout time.Duration) {
+	if resources == nil {
+		resources = &provisioner.Resources{}
+	}
+
+	var violation *errdetails.QuotaFailure_Violation
+	switch reason {
+	case provisioner.ReasonOOMKilled:
+		violation = &errdetails.QuotaFailure_Violation{
+			Subject:     "memory",
+			Description: fmt.Sprintf("Worker ran out of memory (requested %s)", resources.Memory),
+		}
+	case provisioner.ReasonEvicted:
+		violation = &errdetails.QuotaFailure_Violation{
+			Subject:     "ephemeral_storage",
+			Description: fmt.Sprintf("Worker was evicted, likely for exceeding ephemeral storage (requested %s)", resources.EphemeralStorage),
+		}
+	default:
+		violation = &errdetails.QuotaFailure_Violation{
+			Subject:     "timeout",
+			Description: fmt.Sprintf("Worker exceeded its deadline of %s", timeout),
+		}
+	}
+
+	errorDetails.ErrorInfo = &errdetails.ErrorInfo{
+		Reason: ErrorReasonWorkerTerminated,
+		Domain: ErrorDomainBuildsPeridot,
+		Metadata: map[string]string{
+			"termination_reason": reason,
+		},
+	}
+	errorDetails.ErrorType = &peridotpb.TaskErrorDetails_QuotaFailure{
+		QuotaFailure: &errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{violation},
+		},
+	}
+}
+
 func (c *Controller) commonCreateTask(task *models.Task, taskResponse proto.Message) (func(), *peridotpb.TaskErrorDetails, error) {
 	errorDetails := peridotpb.TaskErrorDetails{}
 
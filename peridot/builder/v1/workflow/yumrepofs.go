@@ -32,7 +32,6 @@ package workflow
 
 import (
 	"bytes"
-	"cirello.io/dynamolock"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
@@ -45,7 +44,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/rocky-linux/srpmproc/modulemd"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -61,6 +59,7 @@ import (
 	peridotdb "peridot.resf.org/peridot/db"
 	"peridot.resf.org/peridot/db/models"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	"peridot.resf.org/peridot/lock"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/peridot/yummeta"
 	yumrepofspb "peridot.resf.org/peridot/yumrepofs/pb"
@@ -518,46 +517,27 @@ func (c *Controller) UpdateRepoActivity(ctx context.Context, req *UpdateRepoRequ
 	// can set it to SUCCEEDED
 	task.Status = peridotpb.TaskStatus_TASK_STATUS_FAILED
 
-	lock, err := dynamolock.New(
-		c.dynamodb,
-		viper.GetString("dynamodb-table"),
-		dynamolock.WithLeaseDuration(10*time.Second),
-		dynamolock.WithHeartbeatPeriod(3*time.Second),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create dynamolock: %v", err)
+	if c.locker == nil {
+		return nil, fmt.Errorf("no lock backend configured")
 	}
-	defer lock.Close()
 
-	var lockedItem *dynamolock.Lock
+	var projectLock lock.Lock
 	for {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
-		lockedItem, err = lock.AcquireLock(
-			req.ProjectID,
-		)
+		projectLock, err = c.locker.AcquireLock(ctx, req.ProjectID)
 		if err != nil {
 			c.log.Errorf("failed to acquire lock: %v", err)
 			continue
 		}
 		break
 	}
-	didRelease := false
-	releaseLock := func() error {
-		if didRelease {
-			return nil
-		}
-		lockSuccess, err := lock.ReleaseLock(lockedItem)
-		if err != nil {
-			return fmt.Errorf("error releasing lock: %v", err)
-		}
-		if !lockSuccess {
-			return fmt.Errorf("lost lock before release")
+	defer func() {
+		if err := projectLock.Release(); err != nil {
+			c.log.Errorf("error releasing lock: %v", err)
 		}
-		return nil
-	}
-	defer releaseLock()
+	}()
 
 	beginTx, err := c.db.Begin()
 	if err != nil {
@@ -787,10 +767,84 @@ func (c *Controller) UpdateRepoActivity(ctx context.Context, req *UpdateRepoRequ
 			ext := "xml"
 			if blob == "MODULES" {
 				ext = "yaml"
+			} else if strings.HasSuffix(blob, "_DB") {
+				ext = "sqlite"
 			}
 			return fmt.Sprintf("repodata/%s-%s.%s.gz", newRevision.String(), blob, ext)
 		}
 
+		// Generate sqlite databases from the same metadata.
+		// Those are too large to store with the revision, so they're uploaded
+		// to storage and served from there.
+		var sqliteData []*yummeta.RepoMdData
+		sqliteDbs := []struct {
+			Type     string
+			Blob     string
+			Generate func() ([]byte, error)
+		}{
+			{
+				Type: "primary_db",
+				Blob: "PRIMARY_DB",
+				Generate: func() ([]byte, error) {
+					return yummeta.PrimarySqlite(primaryRoot, newGzChecksums[0])
+				},
+			},
+			{
+				Type: "filelists_db",
+				Blob: "FILELISTS_DB",
+				Generate: func() ([]byte, error) {
+					return yummeta.FilelistsSqlite(filelistsRoot, newGzChecksums[1])
+				},
+			},
+			{
+				Type: "other_db",
+				Blob: "OTHER_DB",
+				Generate: func() ([]byte, error) {
+					return yummeta.OtherSqlite(otherRoot, newGzChecksums[2])
+				},
+			},
+		}
+		for _, sqliteDb := range sqliteDbs {
+			db, err := sqliteDb.Generate()
+			if err != nil {
+				return nil, fmt.Errorf("could not generate %s: %v", sqliteDb.Type, err)
+			}
+			var dbGz []byte
+			err = compressWithGz(db, &dbGz)
+			if err != nil {
+				return nil, err
+			}
+			dbChecksums, err := getChecksums(db, dbGz)
+			if err != nil {
+				return nil, err
+			}
+
+			href := blobHref(sqliteDb.Blob)
+			_, err = c.storage.PutObjectBytes(fmt.Sprintf("sqlite-files/%s", filepath.Base(href)), dbGz)
+			if err != nil {
+				return nil, fmt.Errorf("could not upload %s: %v", sqliteDb.Type, err)
+			}
+
+			sqliteData = append(sqliteData, &yummeta.RepoMdData{
+				Type: sqliteDb.Type,
+				Checksum: &yummeta.RepoMdDataChecksum{
+					Type:  "sha256",
+					Value: dbChecksums[1],
+				},
+				OpenChecksum: &yummeta.RepoMdDataChecksum{
+					Type:  "sha256",
+					Value: dbChecksums[0],
+				},
+				Location: &yummeta.RepoMdDataLocation{
+					Href: href,
+				},
+				Timestamp:       time.Now().Unix(),
+				Size:            len(dbGz),
+				OpenSize:        len(db),
+				DatabaseVersion: yummeta.SqliteDatabaseVersion,
+			})
+		}
+
 		now := time.Now()
 
 		// Add primary
@@ -850,6 +904,9 @@ func (c *Controller) UpdateRepoActivity(ctx context.Context, req *UpdateRepoRequ
 			OpenSize:  len(newOther),
 		})
 
+		// Add sqlite databases
+		repomdRoot.Data = append(repomdRoot.Data, sqliteData...)
+
 		// Add modules if any entries
 		if len(modulesRoot) > 0 {
 			repomdRoot.Data = append(repomdRoot.Data, &yummeta.RepoMdData{
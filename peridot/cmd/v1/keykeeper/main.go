@@ -57,6 +57,7 @@ func init() {
 
 	peridotcommon.AddFlags(root.PersistentFlags())
 	root.PersistentFlags().String("awssm-prefix", "", "prefix for aws ssm entries")
+	keykeeperv1.AddBackendFlags(root.PersistentFlags())
 	utils.AddFlags(root.PersistentFlags(), cnf)
 }
 
@@ -51,6 +51,9 @@ var (
 	moduleVariant bool
 	sideNvrs      []string
 	setInactive   bool
+
+	buildrootRevisionIds []string
+	buildrootFromBuildId string
 )
 
 func init() {
@@ -60,6 +63,8 @@ func init() {
 	buildPackage.Flags().BoolVar(&moduleVariant, "module-variant", false, "Build a module variant")
 	buildPackage.Flags().StringSliceVar(&sideNvrs, "side-nvrs", []string{}, "Side NVRs to include")
 	buildPackage.Flags().BoolVar(&setInactive, "set-inactive", false, "Set build as inactive")
+	buildPackage.Flags().StringSliceVar(&buildrootRevisionIds, "buildroot-revision", []string{}, "Repository revisions to pin the buildroot to")
+	buildPackage.Flags().StringVar(&buildrootFromBuildId, "buildroot-from-build", "", "Pin the buildroot to the one recorded for an earlier build")
 }
 
 func buildPackageMn(_ *cobra.Command, args []string) {
@@ -78,6 +83,12 @@ func buildPackageMn(_ *cobra.Command, args []string) {
 	if scmHash != "" {
 		body.ScmHash = &scmHash
 	}
+	if len(buildrootRevisionIds) > 0 {
+		body.BuildrootRevisionIds = &buildrootRevisionIds
+	}
+	if buildrootFromBuildId != "" {
+		body.BuildrootFromBuildId = &buildrootFromBuildId
+	}
 	req := buildCl.SubmitBuild(getContext(), projectId).Body(body)
 	buildRes, _, err := req.Execute()
 	errFatal(err)
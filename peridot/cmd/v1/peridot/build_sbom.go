@@ -0,0 +1,81 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"openapi.peridot.resf.org/peridotopenapi"
+)
+
+var buildSbom = &cobra.Command{
+	Use:  "sbom [build-id]",
+	Args: cobra.ExactArgs(1),
+	Run:  buildSbomMn,
+}
+
+var (
+	buildSbomFormat string
+	buildSbomOutput string
+)
+
+func init() {
+	buildSbom.Flags().StringVar(&buildSbomFormat, "format", "spdx", "SBOM format (spdx or cyclonedx)")
+	buildSbom.Flags().StringVarP(&buildSbomOutput, "output", "o", "", "Write the SBOM to a file instead of stdout")
+}
+
+func buildSbomMn(_ *cobra.Command, args []string) {
+	projectID := mustGetProjectID()
+
+	var format peridotopenapi.V1SbomFormat
+	switch strings.ToLower(buildSbomFormat) {
+	case "spdx":
+		format = peridotopenapi.SPDX
+	case "cyclonedx", "cdx":
+		format = peridotopenapi.CYCLONEDX
+	default:
+		log.Fatalf("unknown SBOM format %s", buildSbomFormat)
+	}
+
+	cl := getClient(serviceBuild).(peridotopenapi.BuildServiceApi)
+	res, _, err := cl.GetBuildSbom(getContext(), projectID, args[0]).Format(string(format)).Execute()
+	errFatal(err)
+
+	if buildSbomOutput == "" {
+		fmt.Println(res.GetDocument())
+		return
+	}
+	errFatal(os.WriteFile(buildSbomOutput, []byte(res.GetDocument()), 0644))
+}
@@ -57,15 +57,33 @@ func init() {
 	root.AddCommand(build)
 	build.AddCommand(buildRpmImport)
 	build.AddCommand(buildPackage)
+	build.AddCommand(buildVerify)
+	build.AddCommand(buildSbom)
+	build.AddCommand(buildMassRebuild)
 
 	root.AddCommand(project)
 	project.AddCommand(projectInfo)
 	project.AddCommand(projectList)
 	project.AddCommand(projectCreateHashedRepos)
 	project.AddCommand(projectCatalogSync)
+	project.AddCommand(projectRevisions)
+	projectRevisions.AddCommand(projectRevisionsList)
+	projectRevisions.AddCommand(projectRevisionsDiff)
+	projectRevisions.AddCommand(projectRevisionsActivate)
+	projectRevisions.AddCommand(projectRevisionsVerify)
+	project.AddCommand(projectCompose)
+	project.AddCommand(projectSnapshots)
+	projectSnapshots.AddCommand(projectSnapshotsCreate)
+	projectSnapshots.AddCommand(projectSnapshotsList)
+	project.AddCommand(projectKeys)
+	projectKeys.AddCommand(projectKeysList)
+	projectKeys.AddCommand(projectKeysRotate)
+	projectKeys.AddCommand(projectKeysRevoke)
 
 	root.AddCommand(impCmd)
 
+	root.AddCommand(dev)
+
 	viper.SetEnvPrefix("PERIDOT")
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
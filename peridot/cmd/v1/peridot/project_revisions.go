@@ -0,0 +1,70 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"openapi.peridot.resf.org/peridotopenapi"
+)
+
+var projectRevisions = &cobra.Command{
+	Use:   "revisions",
+	Short: "Inspect and roll back repository revisions",
+}
+
+var (
+	revisionsRepository string
+	revisionsArch       string
+)
+
+func init() {
+	projectRevisions.PersistentFlags().StringVar(&revisionsRepository, "repository", "", "Repository name or ID")
+	projectRevisions.PersistentFlags().StringVar(&revisionsArch, "arch", "", "Repository architecture")
+	_ = projectRevisions.MarkPersistentFlagRequired("repository")
+}
+
+// mustGetRepositoryID resolves the --repository flag to a repository ID
+func mustGetRepositoryID(projectID string) string {
+	cl := getClient(serviceProject).(peridotopenapi.ProjectServiceApi)
+
+	res, _, err := cl.ListRepositories(getContext(), projectID).Execute()
+	errFatal(err)
+	for _, repo := range res.GetRepositories() {
+		if repo.GetId() == revisionsRepository || repo.GetName() == revisionsRepository {
+			return repo.GetId()
+		}
+	}
+
+	log.Fatalf("repository %s not found", revisionsRepository)
+	return ""
+}
@@ -0,0 +1,81 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"openapi.peridot.resf.org/peridotopenapi"
+)
+
+var projectRevisionsDiff = &cobra.Command{
+	Use:  "diff [from-revision] [to-revision]",
+	Args: cobra.ExactArgs(2),
+	Run:  projectRevisionsDiffMn,
+}
+
+func projectRevisionsDiffMn(_ *cobra.Command, args []string) {
+	projectID := mustGetProjectID()
+	repositoryID := mustGetRepositoryID(projectID)
+
+	cl := getClient(serviceProject).(peridotopenapi.ProjectServiceApi)
+	res, _, err := cl.DiffRepositoryRevisions(getContext(), projectID, repositoryID).
+		FromRevisionId(args[0]).
+		ToRevisionId(args[1]).
+		Execute()
+	errFatal(err)
+
+	for _, nevra := range res.GetAddedPackages() {
+		fmt.Printf("+ %s\n", nevra)
+	}
+	for _, nevra := range res.GetRemovedPackages() {
+		fmt.Printf("- %s\n", nevra)
+	}
+	for _, change := range res.GetUpgradedPackages() {
+		fmt.Printf("↑ %s -> %s\n", change.GetFrom(), change.GetTo())
+	}
+	for _, change := range res.GetDowngradedPackages() {
+		fmt.Printf("↓ %s -> %s\n", change.GetFrom(), change.GetTo())
+	}
+	for _, nsvca := range res.GetAddedModules() {
+		fmt.Printf("+ module:%s\n", nsvca)
+	}
+	for _, nsvca := range res.GetRemovedModules() {
+		fmt.Printf("- module:%s\n", nsvca)
+	}
+	for _, change := range res.GetUpgradedModules() {
+		fmt.Printf("↑ module:%s -> module:%s\n", change.GetFrom(), change.GetTo())
+	}
+	for _, change := range res.GetDowngradedModules() {
+		fmt.Printf("↓ module:%s -> module:%s\n", change.GetFrom(), change.GetTo())
+	}
+}
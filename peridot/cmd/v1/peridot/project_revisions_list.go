@@ -0,0 +1,71 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"openapi.peridot.resf.org/peridotopenapi"
+)
+
+var projectRevisionsList = &cobra.Command{
+	Use: "list",
+	Run: projectRevisionsListMn,
+}
+
+var (
+	revisionsPage  int32
+	revisionsLimit int32
+)
+
+func init() {
+	projectRevisionsList.Flags().Int32Var(&revisionsPage, "page", 0, "Page")
+	projectRevisionsList.Flags().Int32Var(&revisionsLimit, "limit", 20, "Revisions per page")
+	_ = projectRevisionsList.MarkFlagRequired("arch")
+}
+
+func projectRevisionsListMn(_ *cobra.Command, _ []string) {
+	projectID := mustGetProjectID()
+	repositoryID := mustGetRepositoryID(projectID)
+
+	cl := getClient(serviceProject).(peridotopenapi.ProjectServiceApi)
+	res, _, err := cl.ListRepositoryRevisions(getContext(), projectID, repositoryID).
+		Arch(revisionsArch).
+		Page(revisionsPage).
+		Limit(revisionsLimit).
+		Execute()
+	errFatal(err)
+
+	data, err := res.MarshalJSON()
+	errFatal(err)
+	fmt.Println(string(data))
+}
@@ -142,6 +142,7 @@ func mn(_ *cobra.Command, _ []string) {
 	w.Worker.RegisterActivity(w.WorkflowController.UploadSRPMActivity)
 	w.Worker.RegisterActivity(w.WorkflowController.BuildArchActivity)
 	w.Worker.RegisterActivity(w.WorkflowController.UploadArchActivity)
+	w.Worker.RegisterActivity(w.WorkflowController.VerifyArchActivity)
 
 	// Import
 	w.Worker.RegisterWorkflow(w.WorkflowController.ImportPackageWorkflow)
@@ -154,7 +155,7 @@ func mn(_ *cobra.Command, _ []string) {
 
 	// Worker
 	w.Worker.RegisterWorkflow(w.WorkflowController.DestroyWorkerWorkflow)
-	w.Worker.RegisterActivity(w.WorkflowController.DeleteK8sPodActivity)
+	w.Worker.RegisterActivity(w.WorkflowController.DeleteWorkerActivity)
 
 	// RPM Import
 	w.Worker.RegisterActivity(w.WorkflowController.RpmImportActivity)
@@ -162,6 +163,9 @@ func mn(_ *cobra.Command, _ []string) {
 	// Yumrepofs
 	w.Worker.RegisterActivity(w.WorkflowController.CreateHashedRepositoriesActivity)
 
+	// Compose
+	w.Worker.RegisterActivity(w.WorkflowController.ComposeActivity)
+
 	w.Run()
 }
 
@@ -43,6 +43,7 @@ import (
 	peridotcommon "peridot.resf.org/peridot/common"
 	serverconnector "peridot.resf.org/peridot/db/connector"
 	peridotimplv1 "peridot.resf.org/peridot/impl/v1"
+	provisionerbackend "peridot.resf.org/peridot/provisioner/backend"
 	"peridot.resf.org/temporalutils"
 	"peridot.resf.org/utils"
 	"strings"
@@ -69,6 +70,11 @@ func init() {
 	root.PersistentFlags().String("builder-oci-image-s390x", "", "Builder OCI image to spawn (s390x)")
 
 	root.PersistentFlags().Bool("k8s-supports-cross-platform-no-affinity", false, "All Kubernetes nodes supports cross-platform so no affinity rules required (Ex. M1 Docker Desktop)")
+	root.PersistentFlags().String("provisioner", "kubernetes", "Provisioner used to spawn ephemeral workers (kubernetes, local or podman)")
+	root.PersistentFlags().String("local-builder-binary", "peridotbuilder", "peridotbuilder binary to run if provisioner is local")
+	root.PersistentFlags().String("local-worker-dir", "", "Directory to keep worker logs in if provisioner is local")
+	root.PersistentFlags().String("podman-binary", "podman", "podman binary to use if provisioner is podman")
+	root.PersistentFlags().Int("resource-retry-limit", 2, "How many times an arch build terminated for exhausting its resources is retried with a larger resource profile")
 	root.PersistentFlags().Bool("provision-only", false, "Provision only mode only provisions ephemeral resources. Only used for extarches (s390x and ppc64le)")
 
 	temporalutils.AddFlags(root.PersistentFlags())
@@ -86,7 +92,14 @@ func mn(_ *cobra.Command, _ []string) {
 		peridotimplv1.MainTaskQueue = strings.ToLower(fmt.Sprintf("peridot-provision-only-%s", os.Getenv("PERIDOT_SITE")))
 	}
 
-	w, err := builderv1.NewWorker(serverconnector.MustAuto(), c, peridotimplv1.MainTaskQueue, nil)
+	workerProvisioner, err := provisionerbackend.New()
+	if err != nil {
+		logrus.Fatalf("could not init provisioner: %v", err)
+	}
+
+	w, err := builderv1.NewWorker(serverconnector.MustAuto(), c, peridotimplv1.MainTaskQueue, &builderv1.ExtraReq{
+		Provisioner: workerProvisioner,
+	})
 	if err != nil {
 		logrus.Fatalf("could not init worker: %v", err)
 	}
@@ -106,13 +119,28 @@ func mn(_ *cobra.Command, _ []string) {
 		w.Worker.RegisterWorkflow(w.WorkflowController.RpmImportWorkflow)
 		w.Worker.RegisterWorkflow(w.WorkflowController.RpmLookasideBatchImportWorkflow)
 		w.Worker.RegisterWorkflow(w.WorkflowController.CreateHashedRepositoriesWorkflow)
+		w.Worker.RegisterWorkflow(w.WorkflowController.ComposeWorkflow)
 		w.Worker.RegisterWorkflow(w.WorkflowController.CloneSwapWorkflow)
 		w.Worker.RegisterActivity(w.WorkflowController.CloneSwapActivity)
+		w.Worker.RegisterWorkflow(w.WorkflowController.VerifyBuildWorkflow)
+		w.Worker.RegisterActivity(w.WorkflowController.PrepareVerifyBuildActivity)
+		w.Worker.RegisterActivity(w.WorkflowController.DeleteSnapshotActivity)
+		w.Worker.RegisterActivity(w.WorkflowController.UploadVerifyBuildReportActivity)
+		w.Worker.RegisterActivity(w.WorkflowController.GenerateBuildSbomActivity)
+		w.Worker.RegisterWorkflow(w.WorkflowController.MassRebuildWorkflow)
+		w.Worker.RegisterWorkflow(w.WorkflowController.MassRebuildWaveWorkflow)
+		w.Worker.RegisterActivity(w.WorkflowController.PlanMassRebuildActivity)
+		w.Worker.RegisterWorkflow(w.WorkflowController.KeyRotationWorkflow)
+		w.Worker.RegisterActivity(w.WorkflowController.ActiveBuildBatchesActivity)
+		w.Worker.RegisterWorkflow(w.WorkflowController.VerifyRepositoryWorkflow)
+		w.Worker.RegisterActivity(w.WorkflowController.VerifyRepositoryActivity)
+		w.Worker.RegisterActivity(w.WorkflowController.RecordWorkerTerminationActivity)
 	}
 	w.Worker.RegisterWorkflow(w.WorkflowController.ProvisionWorkerWorkflow)
 	w.Worker.RegisterWorkflow(w.WorkflowController.DestroyWorkerWorkflow)
-	w.Worker.RegisterActivity(w.WorkflowController.CreateK8sPodActivity)
-	w.Worker.RegisterActivity(w.WorkflowController.DeleteK8sPodActivity)
+	w.Worker.RegisterActivity(w.WorkflowController.CreateWorkerActivity)
+	w.Worker.RegisterActivity(w.WorkflowController.DeleteWorkerActivity)
+	w.Worker.RegisterActivity(w.WorkflowController.WatchWorkerActivity)
 
 	// Logs
 	w.Worker.RegisterActivity(w.WorkflowController.IngestLogsActivity)
@@ -38,7 +38,7 @@ import (
 	peridotcommon "peridot.resf.org/peridot/common"
 	serverconnector "peridot.resf.org/peridot/db/connector"
 	peridotimplv1 "peridot.resf.org/peridot/impl/v1"
-	"peridot.resf.org/peridot/lookaside/s3"
+	"peridot.resf.org/peridot/lookaside/backend"
 	"peridot.resf.org/temporalutils"
 	"peridot.resf.org/utils"
 )
@@ -68,9 +68,9 @@ func mn(_ *cobra.Command, _ []string) {
 	}
 	defer c.Close()
 
-	storage, err := s3.New(osfs.New("/"))
+	storage, err := backend.New(osfs.New("/"))
 	if err != nil {
-		logrus.Fatalln("unable to create S3 storage", err)
+		logrus.Fatalln("unable to create storage", err)
 	}
 
 	s, err := peridotimplv1.NewServer(serverconnector.MustAuto(), c, storage)
@@ -0,0 +1,90 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	serverconnector "peridot.resf.org/peridot/db/connector"
+	"peridot.resf.org/peridot/lookaside/backend"
+	yumrepofsv1 "peridot.resf.org/peridot/yumrepofs/v1"
+	"time"
+)
+
+var export = &cobra.Command{
+	Use:   "export",
+	Short: "Export repositories of a project as static yum repositories",
+	Run:   exportMn,
+}
+
+var (
+	exportProjectId string
+	exportOutput    string
+	exportRepos     []string
+	exportArchs     []string
+	exportSync      bool
+	exportInterval  time.Duration
+)
+
+func init() {
+	export.Flags().StringVar(&exportProjectId, "project-id", "", "Project to export")
+	export.Flags().StringVar(&exportOutput, "output", "", "Directory to export repositories to")
+	export.Flags().StringSliceVar(&exportRepos, "repo", nil, "Repositories to export (default: all)")
+	export.Flags().StringSliceVar(&exportArchs, "arch", nil, "Architectures to export (default: all project architectures)")
+	export.Flags().BoolVar(&exportSync, "sync", false, "Keep running and export new revisions as they're created")
+	export.Flags().DurationVar(&exportInterval, "interval", 5*time.Minute, "How often to check for new revisions in sync mode")
+	_ = export.MarkFlagRequired("project-id")
+	_ = export.MarkFlagRequired("output")
+}
+
+func exportMn(_ *cobra.Command, _ []string) {
+	storage, err := backend.New(osfs.New("/"))
+	if err != nil {
+		logrus.Fatalf("could not create storage: %v", err)
+	}
+
+	exporter := yumrepofsv1.NewExporter(serverconnector.MustAuto(), storage)
+	for {
+		err := exporter.ExportProject(exportProjectId, exportRepos, exportArchs, exportOutput)
+		if err != nil {
+			if !exportSync {
+				logrus.Fatal(err)
+			}
+			logrus.Errorf("could not export project: %v", err)
+		}
+		if !exportSync {
+			return
+		}
+
+		time.Sleep(exportInterval)
+	}
+}
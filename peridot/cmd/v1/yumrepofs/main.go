@@ -32,10 +32,13 @@ package main
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	peridotcommon "peridot.resf.org/peridot/common"
 	serverconnector "peridot.resf.org/peridot/db/connector"
+	"peridot.resf.org/peridot/lookaside/backend"
 	yumrepofsv1 "peridot.resf.org/peridot/yumrepofs/v1"
 	"peridot.resf.org/utils"
 )
@@ -56,13 +59,21 @@ func init() {
 
 	peridotcommon.AddFlags(root.PersistentFlags())
 	root.PersistentFlags().String("s3-assume-role", "", "S3 assume role")
+	root.PersistentFlags().String("object-secret", "", "Secret used to sign object URLs for storage backends without presigning support")
 	utils.AddFlags(root.PersistentFlags(), cnf)
+
+	root.AddCommand(export)
 }
 
 func mn(_ *cobra.Command, _ []string) {
-	sess, err := utils.NewAwsSessionNoLocalStack(&aws.Config{})
-	if err != nil {
-		logrus.Fatal(err)
+	// An AWS session is only required for presigning S3 URLs
+	var sess *session.Session
+	if viper.GetString("storage-backend") == backend.S3 {
+		var err error
+		sess, err = utils.NewAwsSessionNoLocalStack(&aws.Config{})
+		if err != nil {
+			logrus.Fatal(err)
+		}
 	}
 
 	s, err := yumrepofsv1.NewServer(serverconnector.MustAuto(), sess)
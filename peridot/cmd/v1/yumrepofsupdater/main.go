@@ -35,14 +35,22 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"go.temporal.io/sdk/client"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	commonpb "peridot.resf.org/common"
+	"peridot.resf.org/peridot/advisory"
+	apolloadvisory "peridot.resf.org/peridot/advisory/apollo"
+	localadvisory "peridot.resf.org/peridot/advisory/local"
 	builderv1 "peridot.resf.org/peridot/builder/v1"
 	peridotcommon "peridot.resf.org/peridot/common"
 	serverconnector "peridot.resf.org/peridot/db/connector"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	"peridot.resf.org/peridot/lock"
+	dynamodblock "peridot.resf.org/peridot/lock/dynamodb"
+	etcdlock "peridot.resf.org/peridot/lock/etcd"
+	postgreslock "peridot.resf.org/peridot/lock/postgres"
 	"peridot.resf.org/servicecatalog"
 	"peridot.resf.org/temporalutils"
 	"peridot.resf.org/utils"
@@ -66,7 +74,11 @@ func init() {
 	cnf.Name = "yumrepofsupdater"
 
 	peridotcommon.AddFlags(root.PersistentFlags())
+	root.PersistentFlags().String("lock-backend", "dynamodb", "Backend used to lock repositories during updates (dynamodb, postgres or etcd)")
 	root.PersistentFlags().String("dynamodb-table", "peridot-repo-revision-lock", "DynamoDB table name")
+	root.PersistentFlags().String("etcd-endpoint", "http://localhost:2379", "etcd endpoint if lock-backend is etcd")
+	root.PersistentFlags().String("advisory-source", "apollo", "Source of advisories for updateinfo (apollo or local)")
+	root.PersistentFlags().String("advisory-dir", "", "Directory containing advisories (YAML or JSON) if advisory-source is local")
 	utils.AddFlags(root.PersistentFlags(), cnf)
 }
 
@@ -76,11 +88,26 @@ func mn(_ *cobra.Command, _ []string) {
 		logrus.Fatalf("could not create temporal client: %v", err)
 	}
 
-	sess, err := utils.NewAwsSession(&aws.Config{})
-	if err != nil {
-		logrus.Fatalf("could not create aws session: %v", err)
+	var locker lock.Locker
+	switch viper.GetString("lock-backend") {
+	case "dynamodb":
+		sess, err := utils.NewAwsSession(&aws.Config{})
+		if err != nil {
+			logrus.Fatalf("could not create aws session: %v", err)
+		}
+		locker, err = dynamodblock.New(dynamodb.New(sess), viper.GetString("dynamodb-table"))
+		if err != nil {
+			logrus.Fatalf("could not create dynamodb locker: %v", err)
+		}
+	case "postgres":
+		// Use a separate pool, locks pin their own connections
+		locker = postgreslock.New(utils.PgInit())
+	case "etcd":
+		locker = etcdlock.New(viper.GetString("etcd-endpoint"))
+	default:
+		logrus.Fatalf("unknown lock backend: %s", viper.GetString("lock-backend"))
 	}
-	ddb := dynamodb.New(sess)
+	defer locker.Close()
 
 	keykeeperConn, err := grpc.Dial(servicecatalog.KeykeeperGrpc(), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
@@ -88,9 +115,28 @@ func mn(_ *cobra.Command, _ []string) {
 	}
 	keykeeperClient := keykeeperpb.NewKeykeeperServiceClient(keykeeperConn)
 
+	var advisorySource advisory.Source
+	switch viper.GetString("advisory-source") {
+	case "apollo":
+		apolloConn, err := grpc.Dial(servicecatalog.ApolloGrpc(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			logrus.Fatalf("could not connect to apollo: %v", err)
+		}
+		advisorySource = apolloadvisory.New(apolloConn)
+	case "local":
+		advisoryDir := viper.GetString("advisory-dir")
+		if advisoryDir == "" {
+			logrus.Fatalf("advisory-dir is required for local advisory source")
+		}
+		advisorySource = localadvisory.New(advisoryDir)
+	default:
+		logrus.Fatalf("unknown advisory source: %s", viper.GetString("advisory-source"))
+	}
+
 	w, err := builderv1.NewWorker(serverconnector.MustAuto(), c, MainTaskQueue, &builderv1.ExtraReq{
 		KeykeeperClient: keykeeperClient,
-		DynamoDB:        ddb,
+		Locker:          locker,
+		AdvisorySource:  advisorySource,
 	})
 	if err != nil {
 		logrus.Fatalf("could not init worker: %v", err)
@@ -101,6 +147,7 @@ func mn(_ *cobra.Command, _ []string) {
 	w.Worker.RegisterWorkflow(w.WorkflowController.RepoUpdaterWorkflow)
 	w.Worker.RegisterActivity(w.WorkflowController.UpdateRepoActivity)
 	w.Worker.RegisterActivity(w.WorkflowController.RequestKeykeeperSignActivity)
+	w.Worker.RegisterActivity(w.WorkflowController.GenerateProvenanceActivity)
 
 	// Updateinfo
 	w.Worker.RegisterWorkflow(w.WorkflowController.UpdateInfoWorkflow)
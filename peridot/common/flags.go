@@ -48,6 +48,10 @@ func AddFlags(pflags *pflag.FlagSet) {
 		defaultBucket = "peridot"
 	}
 
+	pflags.String("storage-backend", "s3", "Storage backend (s3, gcs or filesystem)")
+	pflags.String("storage-path", "/var/lib/peridot/storage", "Root directory if storage-backend is filesystem")
+	pflags.String("gcs-bucket", "", "GCS Bucket")
+
 	pflags.String("s3-endpoint", "", "S3 endpoint")
 	pflags.String("s3-access-key", defaultAccessKey, "S3 Access Key")
 	pflags.String("s3-secret-key", defaultSecretKey, "S3 Secret Key")
@@ -0,0 +1,513 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package compose produces Pungi-style compose trees out of repository
+// revisions. A compose consists of an os, debug and source tree for every
+// variant and architecture, along with productmd metadata describing it.
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cavaliergopher/rpm"
+	"path/filepath"
+	"peridot.resf.org/peridot/composetools"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+	"peridot.resf.org/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CategoryBinary = "binary"
+	CategoryDebug  = "debug"
+	CategorySource = "source"
+)
+
+var ErrNoContent = errors.New("compose has no content")
+
+// composeTypeSuffixes maps productmd compose types to compose ID suffixes
+var composeTypeSuffixes = map[string]string{
+	"production": "",
+	"test":       ".t",
+	"nightly":    ".n",
+	"ci":         ".ci",
+}
+
+// Repository is the content of a single repository revision
+type Repository struct {
+	Name string
+	// Architecture of the revision, for example x86_64, x86_64-debug or src
+	Arch string
+
+	Primary   *yummeta.PrimaryRoot
+	Filelists *yummeta.FilelistsRoot
+	Other     *yummeta.OtherRoot
+	// Uncompressed modules.yaml and comps, empty if not present
+	Modules []byte
+	Groups  []byte
+	// Maps package locations to storage objects, same as GetUrlMappings
+	UrlMappings map[string]string
+
+	// Multilib settings of the repository
+	Multilib              []string
+	AdditionalMultilib    []string
+	ExcludeMultilibFilter []string
+	IncludeFilter         []string
+}
+
+// Info identifies a compose
+type Info struct {
+	// Date in the format YYYYMMDD
+	Date   string
+	Respin int
+	// Compose type as defined by productmd, defaults to production
+	Type  string
+	Label string
+}
+
+// Package is an RPM placed in a compose tree
+type Package struct {
+	Primary   *yummeta.PrimaryPackage
+	Filelists *yummeta.FilelistsPackage
+	Other     *yummeta.OtherPackage
+
+	// Storage object the RPM is downloaded from
+	Object string
+	// NEVRA of the source RPM the package was built from
+	SourceNevra string
+}
+
+// FileName returns the file name of the RPM
+func (p *Package) FileName() string {
+	return filepath.Base(p.Primary.Location.Href)
+}
+
+// Href returns the location of the package relative to the tree,
+// using the same hashed directories as Pungi
+func (p *Package) Href() string {
+	fileName := p.FileName()
+	return filepath.Join("Packages", strings.ToLower(fileName[:1]), fileName)
+}
+
+// Nevra returns the package NEVRA as used by productmd
+func (p *Package) Nevra() string {
+	return composetools.GenNevraPrimaryPkg(p.Primary)
+}
+
+// Tree is a single yum repository in the compose
+type Tree struct {
+	Variant string
+	// Architecture of the tree, src for source trees
+	Arch     string
+	Category string
+	// Path relative to the compose directory, for example BaseOS/x86_64/os
+	Path string
+
+	Packages []*Package
+	Modules  []byte
+	Groups   []byte
+}
+
+// Variant is a resolved compose variant
+type Variant struct {
+	ID     string
+	Name   string
+	Arches []string
+}
+
+type Compose struct {
+	ID        string
+	Info      *Info
+	Config    *peridotpb.CatalogCompose
+	Timestamp time.Time
+
+	Variants []*Variant
+	Trees    []*Tree
+}
+
+// ID returns the compose ID, for example Rocky-9.1-20221017.0
+func ID(config *peridotpb.CatalogCompose, info *Info) string {
+	short := config.ReleaseShort
+	if short == "" {
+		short = strings.ReplaceAll(config.ReleaseName, " ", "")
+	}
+
+	return fmt.Sprintf("%s-%s-%s%s.%d", short, config.ReleaseVersion, info.Date, composeTypeSuffixes[info.Type], info.Respin)
+}
+
+// New resolves the package sets of every variant of given compose definition.
+// Binary packages are filtered for the tree architecture using the composetools
+// multilib rules, only the latest version of non-modular packages is kept,
+// and the source tree only contains sources of the binary packages in the compose.
+func New(config *peridotpb.CatalogCompose, info *Info, projectArches []string, repos []*Repository) (*Compose, error) {
+	if info.Type == "" {
+		info.Type = "production"
+	}
+	if _, ok := composeTypeSuffixes[info.Type]; !ok {
+		return nil, fmt.Errorf("unknown compose type %s", info.Type)
+	}
+
+	c := &Compose{
+		ID:        ID(config, info),
+		Info:      info,
+		Config:    config,
+		Timestamp: time.Now(),
+	}
+
+	repoMap := map[string]*Repository{}
+	for _, repo := range repos {
+		repoMap[repo.Name+"/"+repo.Arch] = repo
+	}
+
+	hasContent := false
+	for _, cv := range config.Variant {
+		variant := &Variant{
+			ID:     cv.Id,
+			Name:   cv.Name,
+			Arches: cv.Arch,
+		}
+		if variant.Name == "" {
+			variant.Name = cv.Id
+		}
+		if len(variant.Arches) == 0 {
+			variant.Arches = projectArches
+		}
+		repoNames := cv.Repository
+		if len(repoNames) == 0 {
+			repoNames = []string{cv.Id}
+		}
+
+		// Source RPM file names referenced by the binary packages of this variant
+		sourceRpms := map[string]bool{}
+		for _, arch := range variant.Arches {
+			compatibleArches, err := composetools.GetCompatibleArches(arch)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %v", cv.Id, err)
+			}
+
+			osTree := &Tree{
+				Variant:  cv.Id,
+				Arch:     arch,
+				Category: CategoryBinary,
+				Path:     filepath.Join(cv.Id, arch, "os"),
+			}
+			debugTree := &Tree{
+				Variant:  cv.Id,
+				Arch:     arch,
+				Category: CategoryDebug,
+				Path:     filepath.Join(cv.Id, arch, "debug", "tree"),
+			}
+
+			// Source RPMs of multilib packages, their debuginfo is included as well
+			multilibSources := map[string]bool{}
+			for _, repoName := range repoNames {
+				repo := repoMap[repoName+"/"+arch]
+				if repo == nil {
+					continue
+				}
+
+				pkgs, err := resolvePackages(repo, arch, compatibleArches)
+				if err != nil {
+					return nil, fmt.Errorf("variant %s: %v", cv.Id, err)
+				}
+				for _, pkg := range pkgs {
+					sourceRpm := pkg.Primary.Format.RpmSourceRpm
+					sourceRpms[sourceRpm] = true
+					if pkg.Primary.Arch != arch && pkg.Primary.Arch != "noarch" {
+						multilibSources[sourceRpm] = true
+					}
+				}
+				osTree.Packages = append(osTree.Packages, pkgs...)
+				osTree.Modules = append(osTree.Modules, repo.Modules...)
+				if len(osTree.Groups) == 0 {
+					osTree.Groups = repo.Groups
+				}
+
+				debugRepo := repoMap[repoName+"/"+arch+"-debug"]
+				if debugRepo == nil {
+					continue
+				}
+				debugPkgs, err := resolvePackages(debugRepo, arch, compatibleArches)
+				if err != nil {
+					return nil, fmt.Errorf("variant %s: %v", cv.Id, err)
+				}
+				for _, pkg := range debugPkgs {
+					if pkg.Primary.Arch != arch && pkg.Primary.Arch != "noarch" && !multilibSources[pkg.Primary.Format.RpmSourceRpm] {
+						continue
+					}
+					debugTree.Packages = append(debugTree.Packages, pkg)
+				}
+			}
+
+			osTree.Packages = dedupePackages(osTree.Packages)
+			debugTree.Packages = dedupePackages(debugTree.Packages)
+			if len(osTree.Packages) > 0 {
+				hasContent = true
+			}
+			c.Trees = append(c.Trees, osTree, debugTree)
+		}
+
+		sourceTree := &Tree{
+			Variant:  cv.Id,
+			Arch:     "src",
+			Category: CategorySource,
+			Path:     filepath.Join(cv.Id, "source", "tree"),
+		}
+		for _, repoName := range repoNames {
+			repo := repoMap[repoName+"/src"]
+			if repo == nil {
+				continue
+			}
+			pkgs, err := repositoryPackages(repo)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %v", cv.Id, err)
+			}
+			for _, pkg := range pkgs {
+				if sourceRpms[pkg.FileName()] {
+					sourceTree.Packages = append(sourceTree.Packages, pkg)
+				}
+			}
+		}
+		sourceTree.Packages = dedupePackages(sourceTree.Packages)
+		c.Trees = append(c.Trees, sourceTree)
+
+		c.Variants = append(c.Variants, variant)
+	}
+	if !hasContent {
+		return nil, ErrNoContent
+	}
+
+	// Binary packages refer to their source RPM by file name,
+	// productmd uses the full NEVRA instead
+	sourceNevras := map[string]string{}
+	for _, tree := range c.Trees {
+		if tree.Category != CategorySource {
+			continue
+		}
+		for _, pkg := range tree.Packages {
+			sourceNevras[pkg.FileName()] = pkg.Nevra()
+		}
+	}
+	for _, tree := range c.Trees {
+		for _, pkg := range tree.Packages {
+			if tree.Category == CategorySource {
+				pkg.SourceNevra = pkg.Nevra()
+				continue
+			}
+			sourceRpm := pkg.Primary.Format.RpmSourceRpm
+			if nevra, ok := sourceNevras[sourceRpm]; ok {
+				pkg.SourceNevra = nevra
+			} else {
+				pkg.SourceNevra = sourceNevraFromFileName(sourceRpm, pkg.Primary.Version.Epoch)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// repositoryPackages returns all packages of a repository with their
+// file lists, changelogs and storage objects
+func repositoryPackages(repo *Repository) ([]*Package, error) {
+	filelists := map[string]*yummeta.FilelistsPackage{}
+	if repo.Filelists != nil {
+		for _, pkg := range repo.Filelists.Packages {
+			filelists[pkg.PkgId] = pkg
+		}
+	}
+	other := map[string]*yummeta.OtherPackage{}
+	if repo.Other != nil {
+		for _, pkg := range repo.Other.Packages {
+			other[pkg.PkgId] = pkg
+		}
+	}
+
+	var ret []*Package
+	if repo.Primary == nil {
+		return ret, nil
+	}
+	for _, pkg := range repo.Primary.Packages {
+		if pkg.Location == nil || pkg.Checksum == nil || pkg.Version == nil {
+			return nil, fmt.Errorf("package %s in %s/%s has incomplete metadata", pkg.Name, repo.Name, repo.Arch)
+		}
+		if pkg.Format == nil {
+			pkg.Format = &yummeta.PrimaryPackageFormat{}
+		}
+
+		href := filepath.Clean(pkg.Location.Href)
+		if !strings.HasPrefix(href, "Packages/") {
+			return nil, fmt.Errorf("unexpected package location %s", pkg.Location.Href)
+		}
+		object := strings.TrimPrefix(href, "Packages/")
+		if mapped, ok := repo.UrlMappings[object]; ok {
+			object = mapped
+		}
+
+		ret = append(ret, &Package{
+			Primary:   pkg,
+			Filelists: filelists[pkg.Checksum.Value],
+			Other:     other[pkg.Checksum.Value],
+			Object:    object,
+		})
+	}
+
+	return ret, nil
+}
+
+// resolvePackages returns the packages of a repository that belong in a tree
+// for given architecture. Packages for other architectures are only included
+// if multilib is enabled for the architecture and the package is a multilib package
+func resolvePackages(repo *Repository, arch string, compatibleArches []string) ([]*Package, error) {
+	pkgs, err := repositoryPackages(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	multilibArches, err := composetools.GetMultilibArches(arch)
+	if err != nil {
+		return nil, err
+	}
+	multilibEnabled := utils.StrContains(arch, repo.Multilib)
+
+	var ret []*Package
+	for _, pkg := range pkgs {
+		pkgArch := pkg.Primary.Arch
+		if !utils.StrContains(pkgArch, compatibleArches) {
+			continue
+		}
+		if utils.StrContains(pkgArch, multilibArches) {
+			if !multilibEnabled {
+				continue
+			}
+
+			// Debug packages follow the package they were built with
+			if !composetools.IsDebugPackage(pkg.Primary.Name) {
+				var files []*yummeta.FilelistsFile
+				if pkg.Filelists != nil {
+					files = pkg.Filelists.Files
+				}
+				isDevel, err := composetools.DevelMultilib(pkg.Primary, files, repo.ExcludeMultilibFilter, repo.AdditionalMultilib)
+				if err != nil {
+					return nil, fmt.Errorf("could not determine if %s is a devel multilib package: %v", pkg.Nevra(), err)
+				}
+				isRuntime, err := composetools.RuntimeMultilib(pkg.Primary, files, repo.ExcludeMultilibFilter, repo.AdditionalMultilib)
+				if err != nil {
+					return nil, fmt.Errorf("could not determine if %s is a runtime multilib package: %v", pkg.Nevra(), err)
+				}
+				if !isDevel && !isRuntime && !utils.StrContains(fmt.Sprintf("%s.%s", pkg.Primary.Name, pkgArch), repo.IncludeFilter) {
+					continue
+				}
+			}
+		}
+
+		ret = append(ret, pkg)
+	}
+
+	return ret, nil
+}
+
+// dedupePackages removes packages that are present multiple times, and
+// older versions of non-modular packages. Modular packages are kept as
+// different streams can ship the same package name
+func dedupePackages(pkgs []*Package) []*Package {
+	seen := map[string]bool{}
+	latest := map[string]*Package{}
+	var ret []*Package
+	for _, pkg := range pkgs {
+		nevra := pkg.Nevra()
+		if seen[nevra] {
+			continue
+		}
+		seen[nevra] = true
+
+		if strings.Contains(pkg.Primary.Version.Rel, ".module+") {
+			ret = append(ret, pkg)
+			continue
+		}
+
+		key := pkg.Primary.Name + "." + pkg.Primary.Arch
+		if existing, ok := latest[key]; !ok || rpm.Compare(packageVersion(pkg), packageVersion(existing)) > 0 {
+			latest[key] = pkg
+		}
+	}
+	for _, pkg := range latest {
+		ret = append(ret, pkg)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].FileName() < ret[j].FileName()
+	})
+
+	return ret
+}
+
+// sourceNevraFromFileName converts a source RPM file name to a NEVRA,
+// assuming the source RPM has the same epoch as the binary package
+func sourceNevraFromFileName(fileName string, epoch string) string {
+	nvr := strings.TrimSuffix(fileName, ".src.rpm")
+	if epoch == "" {
+		epoch = "0"
+	}
+
+	// Name may contain dashes, version and release may not
+	releaseIdx := strings.LastIndex(nvr, "-")
+	if releaseIdx == -1 {
+		return nvr + ".src"
+	}
+	versionIdx := strings.LastIndex(nvr[:releaseIdx], "-")
+	if versionIdx == -1 {
+		return nvr + ".src"
+	}
+
+	return fmt.Sprintf("%s-%s:%s.src", nvr[:versionIdx], epoch, nvr[versionIdx+1:])
+}
+
+type packageEVR struct {
+	epoch   int
+	version string
+	release string
+}
+
+func (v *packageEVR) Epoch() int      { return v.epoch }
+func (v *packageEVR) Version() string { return v.version }
+func (v *packageEVR) Release() string { return v.release }
+
+func packageVersion(pkg *Package) rpm.Version {
+	epoch, _ := strconv.Atoi(pkg.Primary.Version.Epoch)
+	return &packageEVR{
+		epoch:   epoch,
+		version: pkg.Primary.Version.Ver,
+		release: pkg.Primary.Version.Rel,
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// productmd metadata format version written by this package
+const productmdVersion = "1.2"
+
+type productmdHeader struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+type productmdCompose struct {
+	ID     string `json:"id"`
+	Date   string `json:"date"`
+	Respin int    `json:"respin"`
+	Type   string `json:"type"`
+	Label  string `json:"label,omitempty"`
+}
+
+type productmdRelease struct {
+	Name      string `json:"name"`
+	Short     string `json:"short"`
+	Version   string `json:"version"`
+	Type      string `json:"type"`
+	IsLayered bool   `json:"is_layered"`
+	Internal  bool   `json:"internal"`
+}
+
+type productmdVariant struct {
+	ID     string                       `json:"id"`
+	UID    string                       `json:"uid"`
+	Name   string                       `json:"name"`
+	Type   string                       `json:"type"`
+	Arches []string                     `json:"arches"`
+	Paths  map[string]map[string]string `json:"paths"`
+}
+
+type composeInfo struct {
+	Header  productmdHeader `json:"header"`
+	Payload struct {
+		Compose  productmdCompose             `json:"compose"`
+		Release  productmdRelease             `json:"release"`
+		Variants map[string]*productmdVariant `json:"variants"`
+	} `json:"payload"`
+}
+
+type rpmsEntry struct {
+	Category string  `json:"category"`
+	Path     string  `json:"path"`
+	Sigkey   *string `json:"sigkey"`
+}
+
+type rpmsInfo struct {
+	Header  productmdHeader `json:"header"`
+	Payload struct {
+		Compose productmdCompose `json:"compose"`
+		// variant -> arch -> source NEVRA -> NEVRA
+		Rpms map[string]map[string]map[string]map[string]*rpmsEntry `json:"rpms"`
+	} `json:"payload"`
+}
+
+func (c *Compose) productmdCompose() productmdCompose {
+	return productmdCompose{
+		ID:     c.ID,
+		Date:   c.Info.Date,
+		Respin: c.Info.Respin,
+		Type:   c.Info.Type,
+		Label:  c.Info.Label,
+	}
+}
+
+func (c *Compose) productmdRelease() productmdRelease {
+	releaseType := c.Config.ReleaseType
+	if releaseType == "" {
+		releaseType = "ga"
+	}
+	short := c.Config.ReleaseShort
+	if short == "" {
+		short = strings.ReplaceAll(c.Config.ReleaseName, " ", "")
+	}
+
+	return productmdRelease{
+		Name:    c.Config.ReleaseName,
+		Short:   short,
+		Version: c.Config.ReleaseVersion,
+		Type:    releaseType,
+	}
+}
+
+// ComposeInfo returns metadata/composeinfo.json
+func (c *Compose) ComposeInfo() ([]byte, error) {
+	var info composeInfo
+	info.Header = productmdHeader{
+		Type:    "productmd.composeinfo",
+		Version: productmdVersion,
+	}
+	info.Payload.Compose = c.productmdCompose()
+	info.Payload.Release = c.productmdRelease()
+	info.Payload.Variants = map[string]*productmdVariant{}
+
+	for _, variant := range c.Variants {
+		v := &productmdVariant{
+			ID:     variant.ID,
+			UID:    variant.ID,
+			Name:   variant.Name,
+			Type:   "variant",
+			Arches: variant.Arches,
+			Paths:  map[string]map[string]string{},
+		}
+		setPath := func(category string, arch string, path string) {
+			if v.Paths[category] == nil {
+				v.Paths[category] = map[string]string{}
+			}
+			v.Paths[category][arch] = path
+		}
+
+		for _, tree := range c.Trees {
+			if tree.Variant != variant.ID {
+				continue
+			}
+			switch tree.Category {
+			case CategoryBinary:
+				setPath("os_tree", tree.Arch, tree.Path)
+				setPath("repository", tree.Arch, tree.Path)
+				setPath("packages", tree.Arch, tree.Path+"/Packages")
+			case CategoryDebug:
+				setPath("debug_tree", tree.Arch, tree.Path)
+				setPath("debug_repository", tree.Arch, tree.Path)
+				setPath("debug_packages", tree.Arch, tree.Path+"/Packages")
+			case CategorySource:
+				// Source trees are listed under every binary architecture
+				for _, arch := range variant.Arches {
+					setPath("source_tree", arch, tree.Path)
+					setPath("source_repository", arch, tree.Path)
+					setPath("source_packages", arch, tree.Path+"/Packages")
+				}
+			}
+		}
+
+		info.Payload.Variants[variant.ID] = v
+	}
+
+	return json.MarshalIndent(info, "", "    ")
+}
+
+// Rpms returns metadata/rpms.json
+func (c *Compose) Rpms() ([]byte, error) {
+	var info rpmsInfo
+	info.Header = productmdHeader{
+		Type:    "productmd.rpms",
+		Version: productmdVersion,
+	}
+	info.Payload.Compose = c.productmdCompose()
+	info.Payload.Rpms = map[string]map[string]map[string]map[string]*rpmsEntry{}
+
+	for _, variant := range c.Variants {
+		arches := map[string]map[string]map[string]*rpmsEntry{}
+		add := func(arch string, pkg *Package, category string, path string) {
+			if arches[arch] == nil {
+				arches[arch] = map[string]map[string]*rpmsEntry{}
+			}
+			if arches[arch][pkg.SourceNevra] == nil {
+				arches[arch][pkg.SourceNevra] = map[string]*rpmsEntry{}
+			}
+			arches[arch][pkg.SourceNevra][pkg.Nevra()] = &rpmsEntry{
+				Category: category,
+				Path:     path,
+			}
+		}
+
+		// Source packages are listed under every architecture that ships binaries built from them
+		sourcePackages := map[string]*Package{}
+		var sourceTree *Tree
+		for _, tree := range c.Trees {
+			if tree.Variant == variant.ID && tree.Category == CategorySource {
+				sourceTree = tree
+				for _, pkg := range tree.Packages {
+					sourcePackages[pkg.SourceNevra] = pkg
+				}
+			}
+		}
+
+		for _, tree := range c.Trees {
+			if tree.Variant != variant.ID || tree.Category == CategorySource {
+				continue
+			}
+			for _, pkg := range tree.Packages {
+				add(tree.Arch, pkg, tree.Category, tree.Path+"/"+pkg.Href())
+				if source, ok := sourcePackages[pkg.SourceNevra]; ok {
+					add(tree.Arch, source, CategorySource, sourceTree.Path+"/"+source.Href())
+				}
+			}
+		}
+
+		info.Payload.Rpms[variant.ID] = arches
+	}
+
+	return json.MarshalIndent(info, "", "    ")
+}
+
+// TreeInfo returns the .treeinfo file for a tree
+func (c *Compose) TreeInfo(tree *Tree) []byte {
+	release := c.productmdRelease()
+	variant := c.variant(tree.Variant)
+	timestamp := c.Timestamp.Unix()
+
+	var b strings.Builder
+	section := func(name string, values [][2]string) {
+		_, _ = fmt.Fprintf(&b, "[%s]\n", name)
+		for _, value := range values {
+			_, _ = fmt.Fprintf(&b, "%s = %s\n", value[0], value[1])
+		}
+		b.WriteString("\n")
+	}
+
+	section("general", [][2]string{
+		{"arch", tree.Arch},
+		{"family", release.Name},
+		{"name", fmt.Sprintf("%s %s", release.Name, release.Version)},
+		{"packagedir", "Packages"},
+		{"platforms", tree.Arch},
+		{"repository", "."},
+		{"timestamp", fmt.Sprintf("%d", timestamp)},
+		{"variant", variant.ID},
+		{"variants", variant.ID},
+		{"version", release.Version},
+	})
+	section("header", [][2]string{
+		{"type", "productmd.treeinfo"},
+		{"version", productmdVersion},
+	})
+	section("release", [][2]string{
+		{"name", release.Name},
+		{"short", release.Short},
+		{"version", release.Version},
+	})
+	section("tree", [][2]string{
+		{"arch", tree.Arch},
+		{"build_timestamp", fmt.Sprintf("%d", timestamp)},
+		{"platforms", tree.Arch},
+		{"variants", variant.ID},
+	})
+	section("variant-"+variant.ID, [][2]string{
+		{"id", variant.ID},
+		{"name", variant.Name},
+		{"packages", "Packages"},
+		{"repository", "."},
+		{"type", "variant"},
+		{"uid", variant.ID},
+	})
+
+	return []byte(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// DiscInfo returns the .discinfo file for a tree
+func (c *Compose) DiscInfo(tree *Tree) []byte {
+	release := c.productmdRelease()
+	return []byte(fmt.Sprintf("%d.000000\n%s %s\n%s\nALL\n", c.Timestamp.Unix(), release.Name, release.Version, tree.Arch))
+}
+
+func (c *Compose) variant(id string) *Variant {
+	for _, variant := range c.Variants {
+		if variant.ID == id {
+			return variant
+		}
+	}
+	return nil
+}
+
+// VariantIDs returns the IDs of all variants in the compose
+func (c *Compose) VariantIDs() []string {
+	var ret []string
+	for _, variant := range c.Variants {
+		ret = append(ret, variant.ID)
+	}
+	sort.Strings(ret)
+	return ret
+}
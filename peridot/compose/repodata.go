@@ -0,0 +1,282 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package compose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"peridot.resf.org/peridot/yummeta"
+)
+
+// repodataFile is a metadata file listed in repomd.xml
+type repodataFile struct {
+	dataType string
+	name     string
+	content  []byte
+	// Gzip compressed content, nil if the file is not compressed
+	compressed      []byte
+	databaseVersion int
+}
+
+// writeRepodata generates the yum metadata for a tree in <treeDir>/repodata.
+// File names are prefixed with their checksum like createrepo does
+func writeRepodata(treeDir string, tree *Tree, timestamp int64) error {
+	primary := &yummeta.PrimaryRoot{
+		Rpm:      "http://linux.duke.edu/metadata/rpm",
+		XmlnsRpm: "http://linux.duke.edu/metadata/rpm",
+		Xmlns:    "http://linux.duke.edu/metadata/common",
+	}
+	filelists := &yummeta.FilelistsRoot{
+		Xmlns: "http://linux.duke.edu/metadata/filelists",
+	}
+	other := &yummeta.OtherRoot{
+		Xmlns: "http://linux.duke.edu/metadata/other",
+	}
+
+	for _, pkg := range tree.Packages {
+		// Copy so the location in the source revision is left intact
+		primaryPkg := *pkg.Primary
+		primaryPkg.Location = &yummeta.PrimaryPackageLocation{
+			Href: pkg.Href(),
+		}
+		primary.Packages = append(primary.Packages, &primaryPkg)
+
+		filelistsPkg := pkg.Filelists
+		if filelistsPkg == nil {
+			filelistsPkg = &yummeta.FilelistsPackage{
+				PkgId: pkg.Primary.Checksum.Value,
+				Name:  pkg.Primary.Name,
+				Arch:  pkg.Primary.Arch,
+				Version: &yummeta.FilelistsVersion{
+					Epoch: pkg.Primary.Version.Epoch,
+					Ver:   pkg.Primary.Version.Ver,
+					Rel:   pkg.Primary.Version.Rel,
+				},
+			}
+		}
+		filelists.Packages = append(filelists.Packages, filelistsPkg)
+
+		otherPkg := pkg.Other
+		if otherPkg == nil {
+			otherPkg = &yummeta.OtherPackage{
+				PkgId: pkg.Primary.Checksum.Value,
+				Name:  pkg.Primary.Name,
+				Arch:  pkg.Primary.Arch,
+				Version: &yummeta.OtherPackageVersion{
+					Epoch: pkg.Primary.Version.Epoch,
+					Ver:   pkg.Primary.Version.Ver,
+					Rel:   pkg.Primary.Version.Rel,
+				},
+			}
+		}
+		other.Packages = append(other.Packages, otherPkg)
+	}
+	primary.PackageCount = len(primary.Packages)
+	filelists.PackageCount = len(filelists.Packages)
+	other.PackageCount = len(other.Packages)
+
+	primaryXml, err := yummeta.MarshalPrimary(primary)
+	if err != nil {
+		return fmt.Errorf("could not marshal primary.xml: %v", err)
+	}
+	filelistsXml, err := xml.Marshal(filelists)
+	if err != nil {
+		return fmt.Errorf("could not marshal filelists.xml: %v", err)
+	}
+	otherXml, err := xml.Marshal(other)
+	if err != nil {
+		return fmt.Errorf("could not marshal other.xml: %v", err)
+	}
+
+	primaryXml = append([]byte(xml.Header), primaryXml...)
+	filelistsXml = append([]byte(xml.Header), filelistsXml...)
+	otherXml = append([]byte(xml.Header), otherXml...)
+
+	var files []*repodataFile
+	addFile := func(dataType string, name string, content []byte, compress bool, databaseVersion int) error {
+		file := &repodataFile{
+			dataType:        dataType,
+			name:            name,
+			content:         content,
+			databaseVersion: databaseVersion,
+		}
+		if compress {
+			var err error
+			file.compressed, err = gzipContent(content)
+			if err != nil {
+				return err
+			}
+		}
+		files = append(files, file)
+		return nil
+	}
+
+	err = multiErrorCheck(
+		addFile("primary", "primary.xml", primaryXml, true, 0),
+		addFile("filelists", "filelists.xml", filelistsXml, true, 0),
+		addFile("other", "other.xml", otherXml, true, 0),
+	)
+	if err != nil {
+		return err
+	}
+
+	// The sqlite databases refer to the checksum of the metadata they were generated from
+	primaryDb, err := yummeta.PrimarySqlite(primary, checksum(files[0].compressed))
+	if err != nil {
+		return fmt.Errorf("could not generate primary.sqlite: %v", err)
+	}
+	filelistsDb, err := yummeta.FilelistsSqlite(filelists, checksum(files[1].compressed))
+	if err != nil {
+		return fmt.Errorf("could not generate filelists.sqlite: %v", err)
+	}
+	otherDb, err := yummeta.OtherSqlite(other, checksum(files[2].compressed))
+	if err != nil {
+		return fmt.Errorf("could not generate other.sqlite: %v", err)
+	}
+	err = multiErrorCheck(
+		addFile("primary_db", "primary.sqlite", primaryDb, true, yummeta.SqliteDatabaseVersion),
+		addFile("filelists_db", "filelists.sqlite", filelistsDb, true, yummeta.SqliteDatabaseVersion),
+		addFile("other_db", "other.sqlite", otherDb, true, yummeta.SqliteDatabaseVersion),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(tree.Groups) > 0 {
+		err = multiErrorCheck(
+			addFile("group", "comps.xml", tree.Groups, false, 0),
+			addFile("group_gz", "comps.xml", tree.Groups, true, 0),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if len(tree.Modules) > 0 {
+		err = addFile("modules", "modules.yaml", tree.Modules, true, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	repodataDir := filepath.Join(treeDir, "repodata")
+	err = os.MkdirAll(repodataDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	repomd := &yummeta.RepoMdRoot{
+		Rpm:      "http://linux.duke.edu/metadata/rpm",
+		XmlnsRpm: "http://linux.duke.edu/metadata/rpm",
+		Xmlns:    "http://linux.duke.edu/metadata/repo",
+		Revision: fmt.Sprintf("%d", timestamp),
+	}
+	for _, file := range files {
+		content := file.content
+		name := file.name
+		if file.compressed != nil {
+			content = file.compressed
+			name += ".gz"
+		}
+
+		contentChecksum := checksum(content)
+		href := filepath.Join("repodata", fmt.Sprintf("%s-%s", contentChecksum, name))
+		err := os.WriteFile(filepath.Join(treeDir, href), content, 0644)
+		if err != nil {
+			return err
+		}
+
+		data := &yummeta.RepoMdData{
+			Type: file.dataType,
+			Checksum: &yummeta.RepoMdDataChecksum{
+				Type:  "sha256",
+				Value: contentChecksum,
+			},
+			Location: &yummeta.RepoMdDataLocation{
+				Href: href,
+			},
+			Timestamp:       timestamp,
+			Size:            len(content),
+			DatabaseVersion: file.databaseVersion,
+		}
+		if file.compressed != nil {
+			data.OpenChecksum = &yummeta.RepoMdDataChecksum{
+				Type:  "sha256",
+				Value: checksum(file.content),
+			}
+			data.OpenSize = len(file.content)
+		}
+		repomd.Data = append(repomd.Data, data)
+	}
+
+	repomdXml, err := xml.Marshal(repomd)
+	if err != nil {
+		return fmt.Errorf("could not marshal repomd.xml: %v", err)
+	}
+	repomdXml = append([]byte(xml.Header), repomdXml...)
+
+	return os.WriteFile(filepath.Join(repodataDir, "repomd.xml"), repomdXml, 0644)
+}
+
+func checksum(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+func gzipContent(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(content)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func multiErrorCheck(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Fetcher downloads objects from storage, implemented by lookaside.Storage
+type Fetcher interface {
+	DownloadObject(objectName string, path string) error
+}
+
+// Write writes the compose to dir, using the same layout as Pungi:
+//
+//	COMPOSE_ID
+//	STATUS
+//	compose/metadata/composeinfo.json
+//	compose/metadata/rpms.json
+//	compose/{variant}/{arch}/os
+//	compose/{variant}/{arch}/debug/tree
+//	compose/{variant}/source/tree
+func (c *Compose) Write(dir string, fetcher Fetcher) error {
+	composeDir := filepath.Join(dir, "compose")
+	err := os.MkdirAll(filepath.Join(composeDir, "metadata"), 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, tree := range c.Trees {
+		err := c.writeTree(filepath.Join(composeDir, tree.Path), tree, fetcher)
+		if err != nil {
+			return fmt.Errorf("could not write %s: %v", tree.Path, err)
+		}
+	}
+
+	composeInfo, err := c.ComposeInfo()
+	if err != nil {
+		return fmt.Errorf("could not generate composeinfo.json: %v", err)
+	}
+	rpms, err := c.Rpms()
+	if err != nil {
+		return fmt.Errorf("could not generate rpms.json: %v", err)
+	}
+
+	files := map[string][]byte{
+		filepath.Join(composeDir, "metadata", "composeinfo.json"): composeInfo,
+		filepath.Join(composeDir, "metadata", "rpms.json"):        rpms,
+		filepath.Join(dir, "COMPOSE_ID"):                          []byte(c.ID),
+		filepath.Join(dir, "STATUS"):                              []byte("FINISHED\n"),
+	}
+	for path, content := range files {
+		err := os.WriteFile(path, content, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compose) writeTree(treeDir string, tree *Tree, fetcher Fetcher) error {
+	for _, pkg := range tree.Packages {
+		target := filepath.Join(treeDir, pkg.Href())
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = fetcher.DownloadObject(pkg.Object, target)
+		if err != nil {
+			return fmt.Errorf("could not download %s: %v", pkg.Object, err)
+		}
+	}
+
+	err := writeRepodata(treeDir, tree, c.Timestamp.Unix())
+	if err != nil {
+		return err
+	}
+
+	// Installable trees carry productmd tree metadata
+	if tree.Category == CategoryDebug {
+		return nil
+	}
+	err = os.WriteFile(filepath.Join(treeDir, ".treeinfo"), c.TreeInfo(tree), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(treeDir, ".discinfo"), c.DiscInfo(tree), 0644)
+}
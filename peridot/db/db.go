@@ -36,6 +36,7 @@ import (
 	"peridot.resf.org/peridot/db/models"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/utils"
+	"time"
 )
 
 type Access interface {
@@ -43,6 +44,8 @@ type Access interface {
 	GetProjectKeys(projectId string) (*models.ProjectKey, error)
 	GetProjectModuleConfiguration(projectId string) (*peridotpb.ModuleConfiguration, error)
 	CreateProjectModuleConfiguration(projectId string, config *peridotpb.ModuleConfiguration) error
+	GetProjectComposeConfiguration(projectId string) (*peridotpb.CatalogCompose, error)
+	CreateProjectComposeConfiguration(projectId string, config *peridotpb.CatalogCompose) error
 	CreateProject(project *peridotpb.Project) (*models.Project, error)
 	UpdateProject(id string, project *peridotpb.Project) (*models.Project, error)
 	SetProjectKeys(projectId string, username string, password string) error
@@ -50,6 +53,7 @@ type Access interface {
 
 	CreateBuild(packageId string, packageVersionId string, taskId string, projectId string) (*models.Build, error)
 	GetArtifactsForBuild(buildId string) (models.TaskArtifacts, error)
+	GetBuildIdByTaskArtifactName(projectId string, name string) (string, error)
 	GetBuildCount() (int64, error)
 	CreateBuildBatch(projectId string) (string, error)
 	AttachBuildToBatch(buildId string, batchId string) error
@@ -70,6 +74,7 @@ type Access interface {
 	GetLatestBuildIdsByPackageName(name string, projectId *string) ([]string, error)
 	GetBuildIDsByPackageNameAndBranchName(name string, branchName string) ([]string, error)
 	GetActiveBuildIdsByTaskArtifactGlob(taskArtifactGlob string, projectId string) ([]string, error)
+	GetActiveBuildIdsForProject(projectId string) ([]string, error)
 	GetAllBuildIdsByPackageName(name string, projectId string) ([]string, error)
 
 	CreateImport(scmUrl string, taskId string, packageId string, projectId string) (*models.Import, error)
@@ -101,6 +106,9 @@ type Access interface {
 	GetPackageID(name string) (string, error)
 	SetExtraOptionsForPackage(projectId string, packageName string, withFlags pq.StringArray, withoutFlags pq.StringArray) error
 	GetExtraOptionsForPackage(projectId string, packageName string) (*models.ExtraOptions, error)
+	SetResourceProfile(projectId string, packageName string, profile *peridotpb.ResourceProfile) error
+	DeleteResourceProfile(projectId string, packageName string) error
+	GetResourceProfile(projectId string, packageName string) (*peridotpb.ResourceProfile, error)
 	SetGroupInstallOptionsForPackage(projectId string, packageName string, dependsOn pq.StringArray, enableModule pq.StringArray, disableModule pq.StringArray) error
 	SetPackageType(projectId string, packageName string, packageType peridotpb.PackageType) error
 
@@ -132,20 +140,44 @@ type Access interface {
 	GetRepositoryRevision(revisionId string) (*models.RepositoryRevision, error)
 	GetLatestActiveRepositoryRevision(repoId string, arch string) (*models.RepositoryRevision, error)
 	GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(projectId string, name string, arch string) (*models.RepositoryRevision, error)
+	GetRepositoryRevisionActiveAt(repoId string, arch string, at time.Time) (*models.RepositoryRevision, error)
+	ListRepositoryRevisions(repoId string, arch string, page int32, limit int32) (models.RepositoryRevisions, error)
+	RepositoryRevisionCount(repoId string, arch string) (int64, error)
+	ActivateRepositoryRevision(revisionId string) error
 	CreateRevisionForRepository(id string, repoId string, arch string, repomdXml string, primaryXml string, filelistsXml string, otherXml string, updateInfoXml string, moduleDefaultsYaml string, modulesYaml string, groupsXml string, urlMappings string) (*models.RepositoryRevision, error)
 	CreateRepositoryWithPackages(name string, projectId string, internalOnly bool, packages pq.StringArray) (*models.Repository, error)
 	GetRepository(id *string, name *string, projectId *string) (*models.Repository, error)
 	SetRepositoryOptions(id string, packages pq.StringArray, excludeFilter pq.StringArray, includeFilter pq.StringArray, additionalMultilib pq.StringArray, excludeMultilibFilter pq.StringArray, multilib pq.StringArray, globIncludeFilter pq.StringArray) error
 
+	CreateSnapshot(projectId string, name string) (*models.Snapshot, error)
+	GetSnapshotByName(projectId string, name string) (*models.Snapshot, error)
+	ListSnapshots(projectId string, page int32, limit int32) (models.Snapshots, error)
+	SnapshotCount(projectId string) (int64, error)
+	AddRevisionToSnapshot(snapshotId string, repoId string, arch string, revisionId string) error
+	DeleteSnapshot(snapshotId string) error
+	GetSnapshotRevisions(snapshotId string) (models.SnapshotRevisions, error)
+	GetSnapshotRepositoryRevisionByProjectIdAndNameAndArch(projectId string, snapshotName string, name string, arch string) (*models.RepositoryRevision, error)
+
 	CreateKey(id string, name string, email string, gpgId string, encKey string, nonce string, publicKey string, extStoreType string, extStoreId string) (*models.Key, error)
 	AttachKeyToProject(projectId string, keyId string, defaultKey bool) error
 	GetKeyByProjectIdAndId(projectId string, keyId string) (*models.Key, error)
 	GetDefaultKeyForProject(projectId string) (*models.Key, error)
 	GetKeyByName(name string) (*models.Key, error)
+	ListKeysForProject(projectId string) (models.Keys, error)
+	SetDefaultKeyForProject(projectId string, keyId string) error
+	ActivateKey(keyId string) error
+	RetireKey(keyId string, successorId string, expiresAt *time.Time) error
+	RevokeKey(keyId string) error
+	KeyProjectCount(keyId string) (int64, error)
 
 	InsertLogs(lines pq.StringArray, taskId string, parentTaskId string) error
 	GetLogsForTaskIdOrParentTaskId(taskId *string, parentTaskId *string, offset *int64) ([]pq.StringArray, error)
 
+	SearchProjects(query string, projectIds pq.StringArray, limit int32) (models.Projects, error)
+	SearchPackages(query string, projectId string, limit int32) (models.Packages, error)
+	SearchBuilds(query string, projectId string, limit int32) (models.Builds, error)
+	SearchTasks(query string, projectId string, limit int32) (models.Tasks, error)
+
 	Begin() (utils.Tx, error)
 	UseTransaction(tx utils.Tx) Access
 }
@@ -33,9 +33,18 @@ package models
 import (
 	"database/sql"
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	peridotpb "peridot.resf.org/peridot/pb"
 	"time"
 )
 
+const (
+	KeyStateActive   = "active"
+	KeyStateRetiring = "retiring"
+	KeyStateRevoked  = "revoked"
+)
+
 type Key struct {
 	ID         uuid.UUID    `json:"id" db:"id"`
 	CreatedAt  time.Time    `json:"createdAt" db:"created_at"`
@@ -56,4 +65,70 @@ type Key struct {
 	RotExtStoreType sql.NullString `json:"rotExtStoreType" db:"rot_ext_store_type"`
 	ExtStoreId      string         `json:"extStoreId" db:"ext_store_id"`
 	RotExtStoreId   sql.NullString `json:"rotExtStoreId" db:"rot_ext_store_id"`
+
+	State       string         `json:"state" db:"state"`
+	ExpiresAt   sql.NullTime   `json:"expiresAt" db:"expires_at"`
+	SuccessorId sql.NullString `json:"successorId" db:"successor_id"`
+
+	// Only set when listing keys for a project
+	DefaultKey    bool           `json:"defaultKey" db:"default_key"`
+	SuccessorName sql.NullString `json:"successorName" db:"successor_name"`
+}
+
+// Published returns whether yumrepofs should serve the public key to clients.
+// Retiring keys are published until they expire, so clients can still
+// verify artifacts that haven't been re-signed yet
+func (k *Key) Published(now time.Time) bool {
+	switch k.State {
+	case KeyStateActive:
+		return true
+	case KeyStateRetiring:
+		return !k.ExpiresAt.Valid || k.ExpiresAt.Time.After(now)
+	default:
+		return false
+	}
+}
+
+func (k *Key) ToProto() *peridotpb.ProjectKey {
+	var state peridotpb.KeyState
+	switch k.State {
+	case KeyStateActive:
+		state = peridotpb.KeyState_KEY_STATE_ACTIVE
+	case KeyStateRetiring:
+		state = peridotpb.KeyState_KEY_STATE_RETIRING
+	case KeyStateRevoked:
+		state = peridotpb.KeyState_KEY_STATE_REVOKED
+	}
+
+	var expiresAt *timestamppb.Timestamp
+	if k.ExpiresAt.Valid {
+		expiresAt = timestamppb.New(k.ExpiresAt.Time)
+	}
+	var successor *wrapperspb.StringValue
+	if k.SuccessorName.Valid {
+		successor = wrapperspb.String(k.SuccessorName.String)
+	}
+
+	return &peridotpb.ProjectKey{
+		Id:         k.ID.String(),
+		CreatedAt:  timestamppb.New(k.CreatedAt),
+		Name:       k.Name,
+		Email:      k.Email,
+		GpgId:      k.GpgId,
+		State:      state,
+		DefaultKey: k.DefaultKey,
+		ExpiresAt:  expiresAt,
+		Successor:  successor,
+		PublicKey:  k.PublicKey,
+	}
+}
+
+type Keys []Key
+
+func (kk Keys) ToProto() []*peridotpb.ProjectKey {
+	var result []*peridotpb.ProjectKey
+	for _, k := range kk {
+		result = append(result, k.ToProto())
+	}
+	return result
 }
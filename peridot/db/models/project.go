@@ -52,6 +52,7 @@ type Project struct {
 	DistTagOverride sql.NullString `json:"distTagOverride" db:"dist_tag_override"`
 
 	TargetGitlabHost   string         `json:"targetGitlabHost" db:"target_gitlab_host"`
+	TargetForge        string         `json:"targetForge" db:"target_forge"`
 	TargetPrefix       string         `json:"targetPrefix" db:"target_prefix"`
 	TargetBranchPrefix string         `json:"targetBranchPrefix" db:"target_branch_prefix"`
 	SourceGitHost      sql.NullString `json:"sourceGitHost" db:"source_git_host"`
@@ -102,6 +103,7 @@ func (p *Project) ToProto() *peridotpb.Project {
 		Archs:              p.Archs,
 		DistTag:            wrapperspb.String(distTag),
 		TargetGitlabHost:   wrapperspb.String(p.TargetGitlabHost),
+		TargetForge:        p.TargetForge,
 		TargetPrefix:       wrapperspb.String(p.TargetPrefix),
 		TargetBranchPrefix: wrapperspb.String(p.TargetBranchPrefix),
 		SourceGitHost:      utils.NullStringValueP(p.SourceGitHost),
@@ -31,6 +31,7 @@
 package models
 
 import (
+	"database/sql"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx/types"
 	"github.com/lib/pq"
@@ -125,4 +126,35 @@ type RepositoryRevision struct {
 	ModulesYaml        string         `json:"modulesYaml" db:"modules_yaml"`
 	GroupsXml          string         `json:"groupsXml" db:"groups_xml"`
 	UrlMappings        types.JSONText `json:"urlMappings" db:"url_mappings"`
+
+	// Only set when listing revisions
+	ActivatedAt sql.NullTime `json:"activatedAt" db:"activated_at"`
+	Active      bool         `json:"active" db:"active"`
+	Total       int64        `json:"total" db:"total"`
+}
+
+func (r *RepositoryRevision) ToProto() *peridotpb.RepositoryRevision {
+	var activatedAt *timestamppb.Timestamp
+	if r.ActivatedAt.Valid {
+		activatedAt = timestamppb.New(r.ActivatedAt.Time)
+	}
+
+	return &peridotpb.RepositoryRevision{
+		Id:           r.ID.String(),
+		CreatedAt:    timestamppb.New(r.CreatedAt),
+		ActivatedAt:  activatedAt,
+		RepositoryId: r.ProjectRepoId,
+		Arch:         r.Arch,
+		Active:       r.Active,
+	}
+}
+
+type RepositoryRevisions []RepositoryRevision
+
+func (rs RepositoryRevisions) ToProto() []*peridotpb.RepositoryRevision {
+	var result []*peridotpb.RepositoryRevision
+	for _, r := range rs {
+		result = append(result, r.ToProto())
+	}
+	return result
 }
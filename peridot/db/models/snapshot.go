@@ -0,0 +1,94 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package models
+
+import (
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"time"
+)
+
+type Snapshot struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	ProjectId string `json:"projectId" db:"project_id"`
+	Name      string `json:"name" db:"name"`
+
+	// Only set when listing snapshots
+	Total int64 `json:"total" db:"total"`
+}
+
+func (s *Snapshot) ToProto() *peridotpb.Snapshot {
+	return &peridotpb.Snapshot{
+		Id:        s.ID.String(),
+		CreatedAt: timestamppb.New(s.CreatedAt),
+		Name:      s.Name,
+	}
+}
+
+type Snapshots []Snapshot
+
+func (ss Snapshots) ToProto() []*peridotpb.Snapshot {
+	var result []*peridotpb.Snapshot
+	for _, s := range ss {
+		result = append(result, s.ToProto())
+	}
+	return result
+}
+
+type SnapshotRevision struct {
+	ProjectSnapshotId     string `json:"projectSnapshotId" db:"project_snapshot_id"`
+	ProjectRepoId         string `json:"projectRepoId" db:"project_repo_id"`
+	ProjectRepoName       string `json:"projectRepoName" db:"project_repo_name"`
+	Arch                  string `json:"arch" db:"arch"`
+	ProjectRepoRevisionId string `json:"projectRepoRevisionId" db:"project_repo_revision_id"`
+}
+
+func (s *SnapshotRevision) ToProto() *peridotpb.SnapshotRevision {
+	return &peridotpb.SnapshotRevision{
+		RepositoryId:   s.ProjectRepoId,
+		RepositoryName: s.ProjectRepoName,
+		Arch:           s.Arch,
+		RevisionId:     s.ProjectRepoRevisionId,
+	}
+}
+
+type SnapshotRevisions []SnapshotRevision
+
+func (ss SnapshotRevisions) ToProto() []*peridotpb.SnapshotRevision {
+	var result []*peridotpb.SnapshotRevision
+	for _, s := range ss {
+		result = append(result, s.ToProto())
+	}
+	return result
+}
@@ -81,6 +81,31 @@ func (a *Access) GetArtifactsForBuild(buildId string) (ret models.TaskArtifacts,
 	return ret, nil
 }
 
+// GetBuildIdByTaskArtifactName returns the latest build that produced given (unsigned) artifact
+func (a *Access) GetBuildIdByTaskArtifactName(projectId string, name string) (string, error) {
+	var ret string
+	err := a.query.Get(
+		&ret,
+		`
+		select b.id from builds b
+		inner join build_tasks bt on bt.build_id = b.id
+		inner join task_artifacts ta on ta.task_id = bt.task_id
+		where
+			ta.name = $1
+			and b.project_id = $2
+		order by b.created_at desc
+		limit 1
+		`,
+		name,
+		projectId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return ret, nil
+}
+
 func (a *Access) GetBuildCount() (int64, error) {
 	var count int64
 	err := a.query.Get(&count, "select count(id) from builds")
@@ -546,6 +571,32 @@ func (a *Access) GetActiveBuildIdsByTaskArtifactGlob(taskArtifactGlob string, pr
 	return ret, nil
 }
 
+func (a *Access) GetActiveBuildIdsForProject(projectId string) ([]string, error) {
+	var ret []string
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			b.id
+		from builds b
+		inner join tasks t on t.id = b.task_id
+		inner join project_package_versions ppv on ppv.package_version_id = b.package_version_id
+		where
+			b.project_id = $1
+			and t.status = 3
+			and ppv.active_in_repo = true
+			and ppv.project_id = b.project_id
+		order by b.created_at asc
+		`,
+		projectId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
 func (a *Access) GetAllBuildIdsByPackageName(name string, projectId string) ([]string, error) {
 	var ret []string
 	err := a.query.Select(
@@ -33,6 +33,7 @@ package serverpsql
 import (
 	"github.com/google/uuid"
 	"peridot.resf.org/peridot/db/models"
+	"time"
 )
 
 func (a *Access) CreateKey(id string, name string, email string, gpgId string, encKey string, nonce string, publicKey string, extStoreType string, extStoreId string) (*models.Key, error) {
@@ -48,11 +49,12 @@ func (a *Access) CreateKey(id string, name string, email string, gpgId string, e
 		GpgId:        gpgId,
 		EncKey:       encKey,
 		Nonce:        nonce,
+		PublicKey:    publicKey,
 		ExtStoreType: extStoreType,
 		ExtStoreId:   extStoreId,
 	}
 
-	err = a.query.Get(&p, "insert into gpg_keys (id, name, email, gpg_id, enc_key, nonce, public_key, ext_store_type, ext_store_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id, created_at", id, name, email, gpgId, encKey, nonce, publicKey, extStoreType, extStoreId)
+	err = a.query.Get(&p, "insert into gpg_keys (id, name, email, gpg_id, enc_key, nonce, public_key, ext_store_type, ext_store_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id, created_at, state", id, name, email, gpgId, encKey, nonce, publicKey, extStoreType, extStoreId)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +88,10 @@ func (a *Access) GetKeyByProjectIdAndId(projectId string, keyId string) (*models
 			gk.ext_store_type,
 			gk.rot_ext_store_type,
 			gk.ext_store_id,
-			gk.rot_ext_store_id
+			gk.rot_ext_store_id,
+			gk.state,
+			gk.expires_at,
+			gk.successor_id
 		from gpg_keys gk
 		inner join project_gpg_keys pgk on pgk.gpg_key_id = gk.id
 		where
@@ -125,7 +130,10 @@ func (a *Access) GetDefaultKeyForProject(projectId string) (*models.Key, error)
 			gk.ext_store_type,
 			gk.rot_ext_store_type,
 			gk.ext_store_id,
-			gk.rot_ext_store_id
+			gk.rot_ext_store_id,
+			gk.state,
+			gk.expires_at,
+			gk.successor_id
 		from gpg_keys gk
 		inner join project_gpg_keys pgk on pgk.gpg_key_id = gk.id
 		where
@@ -162,7 +170,10 @@ func (a *Access) GetKeyByName(name string) (*models.Key, error) {
 			gk.ext_store_type,
 			gk.rot_ext_store_type,
 			gk.ext_store_id,
-			gk.rot_ext_store_id
+			gk.rot_ext_store_id,
+			gk.state,
+			gk.expires_at,
+			gk.successor_id
 		from gpg_keys gk
 		where
 			gk.name = $1
@@ -175,3 +186,81 @@ func (a *Access) GetKeyByName(name string) (*models.Key, error) {
 
 	return &p, nil
 }
+
+// ListKeysForProject returns all keys attached to the project, including revoked keys.
+// The default key is returned first
+func (a *Access) ListKeysForProject(projectId string) (models.Keys, error) {
+	var ret models.Keys
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			gk.id,
+			gk.created_at,
+			gk.updated_at,
+			gk.breached_at,
+			gk.name,
+			gk.email,
+			gk.gpg_id,
+			gk.enc_key,
+			gk.rot_enc_key,
+			gk.nonce,
+			gk.rot_nonce,
+			gk.public_key,
+			gk.ext_store_type,
+			gk.rot_ext_store_type,
+			gk.ext_store_id,
+			gk.rot_ext_store_id,
+			gk.state,
+			gk.expires_at,
+			gk.successor_id,
+			pgk.default_key,
+			sgk.name as successor_name
+		from gpg_keys gk
+		inner join project_gpg_keys pgk on pgk.gpg_key_id = gk.id
+		left join gpg_keys sgk on sgk.id = gk.successor_id
+		where
+			pgk.project_id = $1
+		order by pgk.default_key desc, gk.created_at desc
+		`,
+		projectId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// SetDefaultKeyForProject makes given key the only default key of the project
+func (a *Access) SetDefaultKeyForProject(projectId string, keyId string) error {
+	_, err := a.query.Exec("update project_gpg_keys set default_key = (gpg_key_id = $2) where project_id = $1", projectId, keyId)
+	return err
+}
+
+// ActivateKey marks the key as active, clearing any expiry and successor from a previous rotation
+func (a *Access) ActivateKey(keyId string) error {
+	_, err := a.query.Exec("update gpg_keys set state = 'active', expires_at = null, successor_id = null, updated_at = now() where id = $1", keyId)
+	return err
+}
+
+func (a *Access) RetireKey(keyId string, successorId string, expiresAt *time.Time) error {
+	_, err := a.query.Exec("update gpg_keys set state = 'retiring', successor_id = $2, expires_at = $3, updated_at = now() where id = $1", keyId, successorId, expiresAt)
+	return err
+}
+
+func (a *Access) RevokeKey(keyId string) error {
+	_, err := a.query.Exec("update gpg_keys set state = 'revoked', updated_at = now() where id = $1", keyId)
+	return err
+}
+
+// KeyProjectCount returns the number of projects the key is attached to
+func (a *Access) KeyProjectCount(keyId string) (int64, error) {
+	var count int64
+	err := a.query.Get(&count, "select count(*) from project_gpg_keys where gpg_key_id = $1", keyId)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
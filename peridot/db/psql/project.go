@@ -63,6 +63,7 @@ func (a *Access) ListProjects(filters *peridotpb.ProjectFilters) (ret models.Pro
 			major_version,
 			dist_tag_override,
 			target_gitlab_host,
+			target_forge,
 			target_prefix,
 			target_branch_prefix,
 			source_git_host,
@@ -181,6 +182,67 @@ func (a *Access) CreateProjectModuleConfiguration(projectId string, config *peri
 	return nil
 }
 
+func (a *Access) GetProjectComposeConfiguration(projectId string) (*peridotpb.CatalogCompose, error) {
+	var ret types.JSONText
+	err := a.query.Get(
+		&ret,
+		`
+        select
+            proto
+        from project_compose_configuration
+        where
+			project_id = $1
+			and active = true
+        `,
+		projectId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	anyPb := &anypb.Any{}
+	err = protojson.Unmarshal(ret, anyPb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal compose configuration (protojson): %v", err)
+	}
+
+	pb := &peridotpb.CatalogCompose{}
+	err = anypb.UnmarshalTo(anyPb, pb, proto.UnmarshalOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal compose configuration: %v", err)
+	}
+
+	return pb, nil
+}
+
+func (a *Access) CreateProjectComposeConfiguration(projectId string, config *peridotpb.CatalogCompose) error {
+	anyPb, err := anypb.New(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compose configuration: %v", err)
+	}
+
+	protoJson, err := protojson.Marshal(anyPb)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compose configuration (protojson): %v", err)
+	}
+
+	_, err = a.query.Exec(
+		`
+        insert into project_compose_configuration (project_id, proto, active)
+        values ($1, $2, true)
+		on conflict (project_id) do update
+			set proto = $2, active = true
+        `,
+		projectId,
+		protoJson,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (a *Access) CreateProject(project *peridotpb.Project) (*models.Project, error) {
 	if err := project.ValidateAll(); err != nil {
 		return nil, err
@@ -207,6 +269,10 @@ func (a *Access) CreateProject(project *peridotpb.Project) (*models.Project, err
 		GitMakePublic:      project.GitMakePublic,
 		VendorMacro:        utils.StringValueToNullString(project.VendorMacro),
 		PackagerMacro:      utils.StringValueToNullString(project.PackagerMacro),
+		TargetForge:        project.TargetForge,
+	}
+	if ret.TargetForge == "" {
+		ret.TargetForge = "gitlab"
 	}
 
 	err := a.query.Get(
@@ -216,8 +282,9 @@ func (a *Access) CreateProject(project *peridotpb.Project) (*models.Project, err
 		(name, major_version, dist_tag_override, target_gitlab_host, target_prefix,
 		target_branch_prefix, source_git_host, source_prefix, source_branch_prefix, cdn_url,
 		stream_mode, target_vendor, additional_vendor, archs, build_pool_type,
-        follow_import_dist, branch_suffix, git_make_public, vendor_macro, packager_macro)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+        follow_import_dist, branch_suffix, git_make_public, vendor_macro, packager_macro,
+		target_forge)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 		returning id, created_at, updated_at
 		`,
 		ret.Name,
@@ -240,6 +307,7 @@ func (a *Access) CreateProject(project *peridotpb.Project) (*models.Project, err
 		ret.GitMakePublic,
 		ret.VendorMacro,
 		ret.PackagerMacro,
+		ret.TargetForge,
 	)
 	if err != nil {
 		return nil, err
@@ -274,6 +342,10 @@ func (a *Access) UpdateProject(id string, project *peridotpb.Project) (*models.P
 		GitMakePublic:      project.GitMakePublic,
 		VendorMacro:        utils.StringValueToNullString(project.VendorMacro),
 		PackagerMacro:      utils.StringValueToNullString(project.PackagerMacro),
+		TargetForge:        project.TargetForge,
+	}
+	if ret.TargetForge == "" {
+		ret.TargetForge = "gitlab"
 	}
 
 	err := a.query.Get(
@@ -300,8 +372,9 @@ func (a *Access) UpdateProject(id string, project *peridotpb.Project) (*models.P
 			git_make_public = $18,
             vendor_macro = $19,
             packager_macro = $20,
+			target_forge = $21,
 			updated_at = now()
-		where id = $21
+		where id = $22
 		returning id, created_at, updated_at
 		`,
 		ret.Name,
@@ -324,6 +397,7 @@ func (a *Access) UpdateProject(id string, project *peridotpb.Project) (*models.P
 		ret.GitMakePublic,
 		ret.VendorMacro,
 		ret.PackagerMacro,
+		ret.TargetForge,
 		id,
 	)
 	if err != nil {
@@ -34,6 +34,8 @@ import (
 	"github.com/jmoiron/sqlx/types"
 	"github.com/lib/pq"
 	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/utils"
+	"time"
 )
 
 func (a *Access) GetExternalRepositoriesForProject(projectId string) (ret models.ExternalRepositories, err error) {
@@ -194,7 +196,7 @@ func (a *Access) GetLatestActiveRepositoryRevision(repoId string, arch string) (
 		where
 			project_repo_id = $1
 			and arch = $2
-		order by created_at desc
+		order by coalesce(activated_at, created_at) desc
 		limit 1
 		`,
 		repoId,
@@ -232,7 +234,7 @@ func (a *Access) GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(proj
 			pr.project_id = $1
 			and pr.name = $2
 			and prr.arch = $3
-		order by prr.created_at desc
+		order by coalesce(prr.activated_at, prr.created_at) desc
         limit 1
 		`,
 		projectId,
@@ -246,6 +248,99 @@ func (a *Access) GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(proj
 	return &ret, nil
 }
 
+// GetRepositoryRevisionActiveAt returns the revision that was active for the
+// given repository and arch at the given time.
+// Re-activating a revision moves its activated_at forward, so this is a best
+// effort reconstruction for revisions that have been rolled back to.
+func (a *Access) GetRepositoryRevisionActiveAt(repoId string, arch string, at time.Time) (*models.RepositoryRevision, error) {
+	var ret models.RepositoryRevision
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			activated_at,
+			project_repo_id,
+			arch
+		from project_repo_revisions
+		where
+			project_repo_id = $1
+			and arch = $2
+			and coalesce(activated_at, created_at) <= $3
+		order by coalesce(activated_at, created_at) desc
+		limit 1
+		`,
+		repoId,
+		arch,
+		at,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *Access) ListRepositoryRevisions(repoId string, arch string, page int32, limit int32) (models.RepositoryRevisions, error) {
+	var ret models.RepositoryRevisions
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			prr.id,
+			prr.created_at,
+			prr.activated_at,
+			prr.project_repo_id,
+			prr.arch,
+			prr.id = (
+				select id from project_repo_revisions
+				where project_repo_id = $1 and arch = $2
+				order by coalesce(activated_at, created_at) desc
+				limit 1
+			) as active,
+			count(prr.*) over() as total
+		from project_repo_revisions prr
+		where
+			prr.project_repo_id = $1
+			and prr.arch = $2
+		order by prr.created_at desc
+		limit $3 offset $4
+		`,
+		repoId,
+		arch,
+		limit,
+		utils.GetOffset(page, limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) RepositoryRevisionCount(repoId string, arch string) (int64, error) {
+	var count int64
+	err := a.query.Get(&count, "select count(*) from project_repo_revisions where project_repo_id = $1 and arch = $2", repoId, arch)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (a *Access) ActivateRepositoryRevision(revisionId string) error {
+	_, err := a.query.Exec(
+		`
+		update project_repo_revisions
+		set activated_at = now()
+		where id = $1
+		`,
+		revisionId,
+	)
+	return err
+}
+
 func (a *Access) CreateRevisionForRepository(id string, repoId string, arch string, repomdXml string, primaryXml string, filelistsXml string, otherXml string, updateInfoXml string, moduleDefaultsYaml string, modulesYaml string, groupsXml string, urlMappings string) (*models.RepositoryRevision, error) {
 	revision := models.RepositoryRevision{
 		ProjectRepoId:      repoId,
@@ -0,0 +1,92 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package serverpsql
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx/types"
+	"google.golang.org/protobuf/encoding/protojson"
+	peridotpb "peridot.resf.org/peridot/pb"
+)
+
+// SetResourceProfile sets the resource profile of a package.
+// An empty package name sets the default profile of the project
+func (a *Access) SetResourceProfile(projectId string, packageName string, profile *peridotpb.ResourceProfile) error {
+	protoJson, err := protojson.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource profile: %v", err)
+	}
+
+	_, err = a.query.Exec(
+		`
+        insert into resource_profiles (project_id, package_name, proto)
+        values ($1, $2, $3)
+        on conflict on constraint resource_profiles_uniq do
+            update set proto = $3, updated_at = now()
+        `,
+		projectId,
+		packageName,
+		types.JSONText(protoJson),
+	)
+	return err
+}
+
+// DeleteResourceProfile removes the resource profile of a package (or the project default)
+func (a *Access) DeleteResourceProfile(projectId string, packageName string) error {
+	_, err := a.query.Exec(
+		"delete from resource_profiles where project_id = $1 and package_name = $2",
+		projectId,
+		packageName,
+	)
+	return err
+}
+
+// GetResourceProfile returns the resource profile of a package (or the project default if the package name is empty)
+func (a *Access) GetResourceProfile(projectId string, packageName string) (*peridotpb.ResourceProfile, error) {
+	var ret types.JSONText
+	err := a.query.Get(
+		&ret,
+		"select proto from resource_profiles where project_id = $1 and package_name = $2",
+		projectId,
+		packageName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &peridotpb.ResourceProfile{}
+	err = protojson.Unmarshal(ret, profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource profile: %v", err)
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,236 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package serverpsql
+
+import (
+	"github.com/lib/pq"
+	"peridot.resf.org/peridot/db/models"
+	"strings"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+// so user input is always matched literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (a *Access) SearchProjects(query string, projectIds pq.StringArray, limit int32) (ret models.Projects, err error) {
+	err = a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			updated_at,
+			name,
+			major_version,
+			dist_tag_override,
+			target_gitlab_host,
+			target_forge,
+			target_prefix,
+			target_branch_prefix,
+			source_git_host,
+			source_prefix,
+			source_branch_prefix,
+			cdn_url,
+			strict_mode,
+			target_vendor,
+			additional_vendor,
+			archs,
+			build_pool_type,
+			follow_import_dist,
+			branch_suffix,
+			git_make_public,
+			vendor_macro,
+			packager_macro,
+			srpm_stage_packages,
+			build_stage_packages
+		from projects
+		where
+			id = any($1 :: uuid[])
+			and name ilike '%' || $2 :: text || '%'
+		order by
+			case
+				when lower(name) = lower($3 :: text) then 0
+				when name ilike $2 :: text || '%' then 1
+				else 2
+			end,
+			length(name),
+			name
+		limit $4
+		`,
+		projectIds,
+		escapeLike(query),
+		query,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) SearchPackages(query string, projectId string, limit int32) (ret models.Packages, err error) {
+	err = a.query.Select(
+		&ret,
+		`
+		select
+			p.id,
+			p.created_at,
+			p.updated_at,
+			p.name,
+			p.package_type,
+			proj_p.package_type_override
+		from packages p
+		inner join project_packages proj_p on proj_p.package_id = p.id
+		where
+			proj_p.project_id = $1
+			and p.name ilike '%' || $2 :: text || '%'
+		order by
+			case
+				when lower(p.name) = lower($3 :: text) then 0
+				when p.name ilike $2 :: text || '%' then 1
+				else 2
+			end,
+			length(p.name),
+			p.name
+		limit $4
+		`,
+		projectId,
+		escapeLike(query),
+		query,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) SearchBuilds(query string, projectId string, limit int32) (ret models.Builds, err error) {
+	err = a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			package_id,
+			package_name,
+			package_version_id,
+			task_id,
+			project_id,
+			task_status,
+			task_response,
+			task_metadata
+		from (
+			select
+				b.id,
+				b.created_at,
+				b.package_id,
+				p.name as package_name,
+				b.package_version_id,
+				b.task_id,
+				b.project_id,
+				t.status as task_status,
+				t.response as task_response,
+				t.metadata as task_metadata,
+				p.name || '-' || pv.version || '-' || pv.release as nvr
+			from builds b
+			inner join tasks t on t.id = b.task_id
+			inner join packages p on p.id = b.package_id
+			inner join package_versions pv on pv.id = b.package_version_id
+			where
+				b.project_id = $1
+		) nb
+		where
+			nvr ilike '%' || $2 :: text || '%'
+		order by
+			case
+				when lower(nvr) = lower($3 :: text) then 0
+				when nvr ilike $2 :: text || '%' then 1
+				else 2
+			end,
+			created_at desc
+		limit $4
+		`,
+		projectId,
+		escapeLike(query),
+		query,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// SearchTasks returns parent tasks with an ID starting with the given query
+func (a *Access) SearchTasks(query string, projectId string, limit int32) (ret models.Tasks, err error) {
+	err = a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			finished_at,
+			arch,
+			type,
+			response,
+			metadata,
+			status,
+			project_id,
+			parent_task_id,
+			submitter_id,
+			submitter_display_name,
+			submitter_email
+		from tasks
+		where
+			parent_task_id is null
+			and project_id = $1
+			and id :: text like lower($2 :: text) || '%'
+		order by created_at desc
+		limit $3
+		`,
+		projectId,
+		escapeLike(query),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
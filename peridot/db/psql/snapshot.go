@@ -0,0 +1,196 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package serverpsql
+
+import (
+	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/utils"
+)
+
+func (a *Access) CreateSnapshot(projectId string, name string) (*models.Snapshot, error) {
+	p := models.Snapshot{
+		ProjectId: projectId,
+		Name:      name,
+	}
+
+	err := a.query.Get(&p, "insert into project_snapshots (project_id, name) values ($1, $2) returning id, created_at", projectId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (a *Access) GetSnapshotByName(projectId string, name string) (*models.Snapshot, error) {
+	var ret models.Snapshot
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			project_id,
+			name
+		from project_snapshots
+		where
+			project_id = $1
+			and name = $2
+		`,
+		projectId,
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// ListSnapshots skips internal snapshots, their names start with an underscore
+func (a *Access) ListSnapshots(projectId string, page int32, limit int32) (models.Snapshots, error) {
+	var ret models.Snapshots
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			id,
+			created_at,
+			project_id,
+			name,
+			count(*) over() as total
+		from project_snapshots
+		where
+			project_id = $1
+			and left(name, 1) <> '_'
+		order by created_at desc
+		limit $2 offset $3
+		`,
+		projectId,
+		limit,
+		utils.GetOffset(page, limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) SnapshotCount(projectId string) (int64, error) {
+	var count int64
+	err := a.query.Get(&count, "select count(*) from project_snapshots where project_id = $1 and left(name, 1) <> '_'", projectId)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (a *Access) AddRevisionToSnapshot(snapshotId string, repoId string, arch string, revisionId string) error {
+	_, err := a.query.Exec("insert into project_snapshot_revisions (project_snapshot_id, project_repo_id, arch, project_repo_revision_id) values ($1, $2, $3, $4)", snapshotId, repoId, arch, revisionId)
+	return err
+}
+
+func (a *Access) DeleteSnapshot(snapshotId string) error {
+	_, err := a.query.Exec("delete from project_snapshot_revisions where project_snapshot_id = $1", snapshotId)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.query.Exec("delete from project_snapshots where id = $1", snapshotId)
+	return err
+}
+
+func (a *Access) GetSnapshotRevisions(snapshotId string) (models.SnapshotRevisions, error) {
+	var ret models.SnapshotRevisions
+	err := a.query.Select(
+		&ret,
+		`
+		select
+			psr.project_snapshot_id,
+			psr.project_repo_id,
+			pr.name as project_repo_name,
+			psr.arch,
+			psr.project_repo_revision_id
+		from project_snapshot_revisions psr
+		inner join project_repos pr on pr.id = psr.project_repo_id
+		where psr.project_snapshot_id = $1
+		order by pr.name, psr.arch
+		`,
+		snapshotId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (a *Access) GetSnapshotRepositoryRevisionByProjectIdAndNameAndArch(projectId string, snapshotName string, name string, arch string) (*models.RepositoryRevision, error) {
+	var ret models.RepositoryRevision
+	err := a.query.Get(
+		&ret,
+		`
+		select
+			prr.id,
+			prr.created_at,
+			prr.project_repo_id,
+			prr.arch,
+			prr.repomd_xml,
+			prr.primary_xml,
+			prr.filelists_xml,
+			prr.other_xml,
+			prr.updateinfo_xml,
+			prr.module_defaults_yaml,
+			prr.modules_yaml,
+			prr.groups_xml,
+			prr.url_mappings
+		from project_snapshot_revisions psr
+		inner join project_snapshots ps on ps.id = psr.project_snapshot_id
+		inner join project_repos pr on pr.id = psr.project_repo_id
+		inner join project_repo_revisions prr on prr.id = psr.project_repo_revision_id
+		where
+			ps.project_id = $1
+			and ps.name = $2
+			and pr.name = $3
+			and psr.arch = $4
+		`,
+		projectId,
+		snapshotName,
+		name,
+		arch,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package forge
+
+import (
+	"errors"
+)
+
+const (
+	KindGitlab  = "gitlab"
+	KindGitea   = "gitea"
+	KindForgejo = "forgejo"
+	KindGithub  = "github"
+)
+
+// ErrRepositoryExists is returned by CreateRepository if the repository already exists
+var ErrRepositoryExists = errors.New("repository already exists")
+
+type Visibility string
+
+const (
+	VisibilityPublic  Visibility = "public"
+	VisibilityPrivate Visibility = "private"
+)
+
+type CommitState string
+
+const (
+	CommitStatePending CommitState = "pending"
+	CommitStateRunning CommitState = "running"
+	CommitStateSuccess CommitState = "success"
+	CommitStateFailed  CommitState = "failed"
+)
+
+// CommitStatus is reported on a commit for traceability
+type CommitStatus struct {
+	State CommitState
+	// Name identifies the status check, for example peridot-import
+	Name        string
+	TargetURL   string
+	Description string
+}
+
+// Forge manages repositories on the git forge import targets are pushed to.
+// Namespace is the path of the group/organization holding the repository,
+// forges without nested groups only accept a single path segment.
+type Forge interface {
+	// CreateRepository creates a repository with given visibility.
+	// ErrRepositoryExists is returned if the repository already exists.
+	CreateRepository(namespace string, name string, visibility Visibility) error
+	// SetVisibility changes the visibility of an existing repository
+	SetVisibility(namespace string, name string, visibility Visibility) error
+	// SetCommitStatus reports a status on given commit
+	SetCommitStatus(namespace string, name string, sha string, status *CommitStatus) error
+}
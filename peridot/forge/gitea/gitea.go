@@ -0,0 +1,179 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package gitea implements forge.Forge for Gitea and Forgejo, which share the same API
+package gitea
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"peridot.resf.org/peridot/forge"
+	"strings"
+	"time"
+)
+
+type Forge struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+type createRepoOption struct {
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+}
+
+type editRepoOption struct {
+	Private *bool `json:"private,omitempty"`
+}
+
+type createStatusOption struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context,omitempty"`
+}
+
+// New returns a Gitea/Forgejo forge for the instance at host, authenticated using an access token
+func New(host string, token string) *Forge {
+	return &Forge{
+		baseURL: fmt.Sprintf("%s/api/v1", strings.TrimSuffix(host, "/")),
+		token:   token,
+		client: &http.Client{
+			Timeout: time.Minute,
+		},
+	}
+}
+
+// owner returns the organization for given namespace.
+// Gitea doesn't support nested organizations, so the namespace has to be a single path segment.
+func owner(namespace string) (string, error) {
+	namespace = strings.Trim(namespace, "/")
+	if namespace == "" || strings.Contains(namespace, "/") {
+		return "", fmt.Errorf("gitea does not support nested namespace %s, leave the target prefix empty", namespace)
+	}
+
+	return namespace, nil
+}
+
+func (f *Forge) do(method string, path string, body interface{}) (int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		bts, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(bts)
+	}
+
+	req, err := http.NewRequest(method, f.baseURL+path, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "token "+f.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, fmt.Errorf("gitea: %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (f *Forge) CreateRepository(namespace string, name string, visibility forge.Visibility) error {
+	org, err := owner(namespace)
+	if err != nil {
+		return err
+	}
+
+	statusCode, err := f.do(http.MethodPost, fmt.Sprintf("/orgs/%s/repos", url.PathEscape(org)), &createRepoOption{
+		Name:    name,
+		Private: visibility == forge.VisibilityPrivate,
+	})
+	if err != nil {
+		if statusCode == http.StatusConflict {
+			return forge.ErrRepositoryExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (f *Forge) SetVisibility(namespace string, name string, visibility forge.Visibility) error {
+	org, err := owner(namespace)
+	if err != nil {
+		return err
+	}
+
+	private := visibility == forge.VisibilityPrivate
+	_, err = f.do(http.MethodPatch, fmt.Sprintf("/repos/%s/%s", url.PathEscape(org), url.PathEscape(name)), &editRepoOption{
+		Private: &private,
+	})
+	return err
+}
+
+func (f *Forge) SetCommitStatus(namespace string, name string, sha string, status *forge.CommitStatus) error {
+	org, err := owner(namespace)
+	if err != nil {
+		return err
+	}
+
+	// Gitea has no running state and calls failures "failure"
+	state := string(status.State)
+	switch status.State {
+	case forge.CommitStateRunning:
+		state = "pending"
+	case forge.CommitStateFailed:
+		state = "failure"
+	}
+
+	_, err = f.do(http.MethodPost, fmt.Sprintf("/repos/%s/%s/statuses/%s", url.PathEscape(org), url.PathEscape(name), url.PathEscape(sha)), &createStatusOption{
+		State:       state,
+		TargetURL:   status.TargetURL,
+		Description: status.Description,
+		Context:     status.Name,
+	})
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package gitlab
+
+import (
+	"fmt"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"net/url"
+	"peridot.resf.org/peridot/forge"
+)
+
+type Forge struct {
+	client *gitlab.Client
+}
+
+// New returns a GitLab forge for the instance at host, authenticated using a personal access token
+func New(host string, token string) (*Forge, error) {
+	client, err := gitlab.NewClient(token, gitlab.WithBaseURL(fmt.Sprintf("%s/api/v4", host)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Forge{
+		client: client,
+	}, nil
+}
+
+func (f *Forge) CreateRepository(namespace string, name string, visibility forge.Visibility) error {
+	ns, _, err := f.client.Namespaces.GetNamespace(url.QueryEscape(namespace))
+	if err != nil {
+		return err
+	}
+
+	_, resp, err := f.client.Projects.CreateProject(&gitlab.CreateProjectOptions{
+		Name:        &name,
+		NamespaceID: &ns.ID,
+		Visibility:  gitlab.Visibility(gitlab.VisibilityValue(visibility)),
+	})
+	if err != nil {
+		// GitLab responds with a bad request if the path is already taken
+		if resp != nil && resp.StatusCode == http.StatusBadRequest {
+			return forge.ErrRepositoryExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (f *Forge) SetVisibility(namespace string, name string, visibility forge.Visibility) error {
+	_, _, err := f.client.Projects.EditProject(fmt.Sprintf("%s/%s", namespace, name), &gitlab.EditProjectOptions{
+		Visibility: gitlab.Visibility(gitlab.VisibilityValue(visibility)),
+	})
+	return err
+}
+
+func (f *Forge) SetCommitStatus(namespace string, name string, sha string, status *forge.CommitStatus) error {
+	_, _, err := f.client.Commits.SetCommitStatus(fmt.Sprintf("%s/%s", namespace, name), sha, &gitlab.SetCommitStatusOptions{
+		State:       gitlab.BuildStateValue(status.State),
+		Name:        gitlab.String(status.Name),
+		TargetURL:   gitlab.String(status.TargetURL),
+		Description: gitlab.String(status.Description),
+	})
+	return err
+}
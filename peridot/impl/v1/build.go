@@ -38,8 +38,11 @@ import (
 	"go.temporal.io/sdk/client"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	"peridot.resf.org/peridot/builder/v1/workflow"
+	peridotdb "peridot.resf.org/peridot/db"
 	"peridot.resf.org/peridot/db/models"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/utils"
@@ -198,6 +201,96 @@ func (s *Server) GetBuildBatch(ctx context.Context, req *peridotpb.GetBuildBatch
 	}, nil
 }
 
+// pinBuildroot creates a snapshot that pins the buildroot repositories to the requested
+// revisions. Repositories and architectures without a requested revision are pinned
+// to their active revision, so the buildroot stays complete.
+// BuildWorkflow deletes the snapshot once the build finishes
+func (s *Server) pinBuildroot(tx peridotdb.Access, project *models.Project, taskId string, req *peridotpb.SubmitBuildRequest) (string, error) {
+	projectId := project.ID.String()
+	revisionIds := append([]string{}, req.BuildrootRevisionIds...)
+
+	if req.BuildrootFromBuildId != "" {
+		build, err := tx.GetBuild(projectId, req.BuildrootFromBuildId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "", status.Errorf(codes.NotFound, "build %s not found", req.BuildrootFromBuildId)
+			}
+			s.log.Errorf("could not get build in pinBuildroot: %v", err)
+			return "", utils.InternalError
+		}
+		tasks, err := tx.GetTask(build.TaskId, &projectId)
+		if err != nil {
+			s.log.Errorf("could not get build task in pinBuildroot: %v", err)
+			return "", utils.InternalError
+		}
+		found := false
+		for _, subtask := range tasks {
+			if subtask.Type != peridotpb.TaskType_TASK_TYPE_BUILD_ARCH || !subtask.Response.Valid {
+				continue
+			}
+			anyResponse := &anypb.Any{}
+			if err := protojson.Unmarshal(subtask.Response.JSONText, anyResponse); err != nil {
+				continue
+			}
+			lockfile := &peridotpb.BuildrootLockfile{}
+			if err := anyResponse.UnmarshalTo(lockfile); err != nil {
+				continue
+			}
+			found = true
+			for _, revision := range lockfile.Repositories {
+				revisionIds = append(revisionIds, revision.RevisionId)
+			}
+		}
+		if !found {
+			return "", status.Errorf(codes.FailedPrecondition, "build %s has no recorded buildroot", req.BuildrootFromBuildId)
+		}
+	}
+
+	// Key = repository ID + arch, value = revision ID
+	pinned := map[string]string{}
+	for _, revisionId := range revisionIds {
+		revision, err := tx.GetRepositoryRevision(revisionId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "", status.Errorf(codes.InvalidArgument, "revision %s not found", revisionId)
+			}
+			s.log.Errorf("could not get revision in pinBuildroot: %v", err)
+			return "", utils.InternalError
+		}
+		_, err = tx.GetRepository(&revision.ProjectRepoId, nil, &projectId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "", status.Errorf(codes.InvalidArgument, "revision %s does not belong to project %s", revisionId, projectId)
+			}
+			s.log.Errorf("could not get repository in pinBuildroot: %v", err)
+			return "", utils.InternalError
+		}
+		key := revision.ProjectRepoId + "/" + revision.Arch
+		if existing, ok := pinned[key]; ok && existing != revisionId {
+			return "", status.Errorf(codes.InvalidArgument, "revisions %s and %s pin the same repository and arch", existing, revisionId)
+		}
+		pinned[key] = revisionId
+	}
+
+	snapshotName := workflow.BuildrootSnapshotName(taskId)
+	_, err := workflow.CreateRevisionSnapshot(tx, project, snapshotName, func(repo *models.Repository, arch string) (string, error) {
+		if revisionId, ok := pinned[repo.ID.String()+"/"+arch]; ok {
+			return revisionId, nil
+		}
+		revision, err := tx.GetLatestActiveRepositoryRevision(repo.ID.String(), arch)
+		if err != nil {
+			return "", err
+		}
+		return revision.ID.String(), nil
+	})
+	if err != nil {
+		s.log.Errorf("could not create snapshot in pinBuildroot: %v", err)
+		return "", utils.InternalError
+	}
+
+	return snapshotName, nil
+}
+
 func (s *Server) SubmitBuild(ctx context.Context, req *peridotpb.SubmitBuildRequest) (*peridotpb.AsyncTask, error) {
 	if err := req.ValidateAll(); err != nil {
 		return nil, err
@@ -333,7 +426,13 @@ func (s *Server) SubmitBuild(ctx context.Context, req *peridotpb.SubmitBuildRequ
 		req.ModuleVariant = true
 	}
 
+	pinBuildroot := len(req.BuildrootRevisionIds) > 0 || req.BuildrootFromBuildId != ""
+
 	if (packageType == peridotpb.PackageType_PACKAGE_TYPE_MODULE_FORK || packageType == peridotpb.PackageType_PACKAGE_TYPE_NORMAL_FORK_MODULE || packageType == peridotpb.PackageType_PACKAGE_TYPE_MODULE_FORK_MODULE_COMPONENT) && req.ModuleVariant {
+		if pinBuildroot {
+			return nil, status.Error(codes.InvalidArgument, "buildroot pinning is not supported for module builds")
+		}
+
 		rollback = false
 		err = beginTx.Commit()
 		if err != nil {
@@ -364,6 +463,16 @@ func (s *Server) SubmitBuild(ctx context.Context, req *peridotpb.SubmitBuildRequ
 			return nil, status.Error(codes.InvalidArgument, "could not create build")
 		}
 
+		extraOptions := &peridotpb.ExtraBuildOptions{
+			ReusableBuildId: build.ID.String(),
+		}
+		if pinBuildroot {
+			extraOptions.YumrepofsSnapshot, err = s.pinBuildroot(tx, &projects[0], task.ID.String(), req)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		rollback = false
 		err = beginTx.Commit()
 		if err != nil {
@@ -380,9 +489,7 @@ func (s *Server) SubmitBuild(ctx context.Context, req *peridotpb.SubmitBuildRequ
 			s.temporalWorker.WorkflowController.BuildWorkflow,
 			req,
 			task,
-			&peridotpb.ExtraBuildOptions{
-				ReusableBuildId: build.ID.String(),
-			},
+			extraOptions,
 		)
 		if err != nil {
 			return nil, err
@@ -645,3 +752,265 @@ func (s *Server) RpmLookasideBatchImport(ctx context.Context, req *peridotpb.Rpm
 		Done:     false,
 	}, nil
 }
+
+func (s *Server) VerifyBuild(ctx context.Context, req *peridotpb.VerifyBuildRequest) (*peridotpb.AsyncTask, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId, PermissionBuild); err != nil {
+		return nil, err
+	}
+	user, err := utils.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	build, err := s.db.GetBuild(req.ProjectId, req.BuildId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.NotFound, "build %s not found", req.BuildId)
+		}
+		s.log.Errorf("could not get build in VerifyBuild: %v", err)
+		return nil, utils.InternalError
+	}
+	if build.TaskStatus != peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED {
+		return nil, status.Error(codes.FailedPrecondition, "only succeeded builds can be verified")
+	}
+
+	rollback := true
+	beginTx, err := s.db.Begin()
+	if err != nil {
+		s.log.Error(err)
+		return nil, utils.InternalError
+	}
+	defer func() {
+		if rollback {
+			_ = beginTx.Rollback()
+		}
+	}()
+	tx := s.db.UseTransaction(beginTx)
+
+	task, err := tx.CreateTask(user, "noarch", peridotpb.TaskType_TASK_TYPE_VERIFY_BUILD, &req.ProjectId, nil)
+	if err != nil {
+		s.log.Errorf("could not create verify build task in VerifyBuild: %v", err)
+		return nil, status.Error(codes.InvalidArgument, "could not create verify build task")
+	}
+
+	metadataAnyPb, err := anypb.New(&peridotpb.PackageOperationMetadata{
+		PackageName: build.PackageName,
+		TaskType:    peridotpb.TaskType_TASK_TYPE_VERIFY_BUILD,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.SetTaskMetadata(task.ID.String(), metadataAnyPb)
+	if err != nil {
+		s.log.Errorf("could not set task metadata in VerifyBuild: %v", err)
+		return nil, status.Error(codes.Internal, "could not set task metadata")
+	}
+
+	taskProto, err := task.ToProto(true)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not marshal task: %v", err)
+	}
+
+	rollback = false
+	err = beginTx.Commit()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "could not save, try again")
+	}
+
+	_, err = s.temporal.ExecuteWorkflow(
+		context.Background(),
+		client.StartWorkflowOptions{
+			ID:        task.ID.String(),
+			TaskQueue: MainTaskQueue,
+		},
+		s.temporalWorker.WorkflowController.VerifyBuildWorkflow,
+		req,
+		task,
+	)
+	if err != nil {
+		s.log.Errorf("could not start verify build workflow in VerifyBuild: %v", err)
+		return nil, status.Error(codes.Internal, "could not start verify build workflow")
+	}
+
+	return &peridotpb.AsyncTask{
+		TaskId:   task.ID.String(),
+		Subtasks: []*peridotpb.Subtask{taskProto},
+		Done:     false,
+	}, nil
+}
+
+func (s *Server) GetBuildSbom(ctx context.Context, req *peridotpb.GetBuildSbomRequest) (*peridotpb.GetBuildSbomResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId, PermissionView); err != nil {
+		return nil, err
+	}
+
+	build, err := s.db.GetBuild(req.ProjectId, req.BuildId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.NotFound, "build %s not found", req.BuildId)
+		}
+		s.log.Errorf("could not get build in GetBuildSbom: %v", err)
+		return nil, utils.InternalError
+	}
+	if build.TaskStatus != peridotpb.TaskStatus_TASK_STATUS_SUCCEEDED {
+		return nil, status.Error(codes.FailedPrecondition, "SBOMs are only available for succeeded builds")
+	}
+
+	document, err := s.storage.ReadObject(workflow.SbomObjectName(build.TaskId, req.Format))
+	if err != nil {
+		s.log.Errorf("could not read SBOM for build %s: %v", req.BuildId, err)
+		return nil, status.Errorf(codes.NotFound, "no SBOM found for build %s", req.BuildId)
+	}
+
+	return &peridotpb.GetBuildSbomResponse{
+		Format:      req.Format,
+		ContentType: workflow.SbomContentType(req.Format),
+		Document:    string(document),
+	}, nil
+}
+
+func (s *Server) SubmitMassRebuild(ctx context.Context, req *peridotpb.SubmitMassRebuildRequest) (*peridotpb.AsyncTask, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId, PermissionBuild); err != nil {
+		return nil, err
+	}
+	user, err := utils.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, err := s.db.ListProjects(&peridotpb.ProjectFilters{
+		Id: wrapperspb.String(req.ProjectId),
+	})
+	if err != nil {
+		s.log.Errorf("could not list projects in SubmitMassRebuild: %v", err)
+		return nil, utils.InternalError
+	}
+	if len(projects) != 1 {
+		return nil, status.Errorf(codes.InvalidArgument, "project %s does not exist", req.ProjectId)
+	}
+
+	pkgs, err := s.db.GetPackagesInProject(&peridotpb.PackageFilters{}, req.ProjectId, 0, -1)
+	if err != nil {
+		s.log.Errorf("could not get packages in SubmitMassRebuild: %v", err)
+		return nil, utils.InternalError
+	}
+
+	// Only normal packages are rebuilt, modules have their own build order
+	normalPackages := map[string]bool{}
+	var allNormalPackages []string
+	for _, pkg := range pkgs {
+		packageType := pkg.PackageType
+		if pkg.PackageTypeOverride.Valid {
+			packageType = peridotpb.PackageType(pkg.PackageTypeOverride.Int32)
+		}
+		switch packageType {
+		case peridotpb.PackageType_PACKAGE_TYPE_MODULE_FORK,
+			peridotpb.PackageType_PACKAGE_TYPE_NORMAL_FORK_MODULE,
+			peridotpb.PackageType_PACKAGE_TYPE_MODULE_FORK_MODULE_COMPONENT,
+			peridotpb.PackageType_PACKAGE_TYPE_MODULE_FORK_COMPONENT:
+			continue
+		}
+		normalPackages[pkg.Name] = true
+		allNormalPackages = append(allNormalPackages, pkg.Name)
+	}
+
+	packageNames := allNormalPackages
+	if len(req.PackageNames) > 0 {
+		packageNames = []string{}
+		seen := map[string]bool{}
+		for _, name := range req.PackageNames {
+			if !normalPackages[name] {
+				return nil, status.Errorf(codes.InvalidArgument, "package %s is not a non-modular package in project %s", name, req.ProjectId)
+			}
+			if !seen[name] {
+				seen[name] = true
+				packageNames = append(packageNames, name)
+			}
+		}
+	}
+	if len(packageNames) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "project has no packages to rebuild")
+	}
+
+	buildBatchId, err := s.db.CreateBuildBatch(req.ProjectId)
+	if err != nil {
+		s.log.Errorf("could not create build batch in SubmitMassRebuild: %v", err)
+		return nil, status.Error(codes.Internal, "could not create build batch")
+	}
+
+	rollback := true
+	beginTx, err := s.db.Begin()
+	if err != nil {
+		s.log.Error(err)
+		return nil, utils.InternalError
+	}
+	defer func() {
+		if rollback {
+			_ = beginTx.Rollback()
+		}
+	}()
+	tx := s.db.UseTransaction(beginTx)
+
+	task, err := tx.CreateTask(user, "noarch", peridotpb.TaskType_TASK_TYPE_MASS_REBUILD, &req.ProjectId, nil)
+	if err != nil {
+		s.log.Errorf("could not create mass rebuild task in SubmitMassRebuild: %v", err)
+		return nil, status.Error(codes.InvalidArgument, "could not create mass rebuild task")
+	}
+
+	metadataAnyPb, err := anypb.New(&peridotpb.MassRebuildMetadata{
+		BuildBatchId: buildBatchId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.SetTaskMetadata(task.ID.String(), metadataAnyPb)
+	if err != nil {
+		s.log.Errorf("could not set task metadata in SubmitMassRebuild: %v", err)
+		return nil, status.Error(codes.Internal, "could not set task metadata")
+	}
+
+	taskProto, err := task.ToProto(true)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not marshal task: %v", err)
+	}
+
+	rollback = false
+	err = beginTx.Commit()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "could not save, try again")
+	}
+
+	_, err = s.temporal.ExecuteWorkflow(
+		context.Background(),
+		client.StartWorkflowOptions{
+			ID:                  task.ID.String(),
+			TaskQueue:           MainTaskQueue,
+			WorkflowTaskTimeout: 3 * time.Hour,
+		},
+		s.temporalWorker.WorkflowController.MassRebuildWorkflow,
+		req,
+		packageNames,
+		buildBatchId,
+		task,
+		user,
+	)
+	if err != nil {
+		s.log.Errorf("could not start mass rebuild workflow in SubmitMassRebuild: %v", err)
+		return nil, status.Error(codes.Internal, "could not start mass rebuild workflow")
+	}
+
+	return &peridotpb.AsyncTask{
+		TaskId:   task.ID.String(),
+		Subtasks: []*peridotpb.Subtask{taskProto},
+		Done:     false,
+	}, nil
+}
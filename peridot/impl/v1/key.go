@@ -0,0 +1,254 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package peridotimplv1
+
+import (
+	"context"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/utils"
+	"time"
+)
+
+func findProjectKey(keys models.Keys, name string) *models.Key {
+	for i := range keys {
+		if keys[i].Name == name {
+			return &keys[i]
+		}
+	}
+
+	return nil
+}
+
+// checkKeyNotShared rejects state changes of keys attached to more than one project.
+// The state of a key is shared by all projects it's attached to, so retiring or
+// revoking it for one project would stop other projects from publishing or signing with it
+func (s *Server) checkKeyNotShared(key *models.Key) error {
+	count, err := s.db.KeyProjectCount(key.ID.String())
+	if err != nil {
+		s.log.Errorf("could not count projects of key: %v", err)
+		return utils.InternalError
+	}
+	if count > 1 {
+		return status.Errorf(codes.FailedPrecondition, "key %s is attached to other projects", key.Name)
+	}
+
+	return nil
+}
+
+func (s *Server) ListProjectKeys(ctx context.Context, req *peridotpb.ListProjectKeysRequest) (*peridotpb.ListProjectKeysResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionView); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.db.ListKeysForProject(req.ProjectId.Value)
+	if err != nil {
+		s.log.Errorf("could not list keys: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+
+	return &peridotpb.ListProjectKeysResponse{
+		Keys: keys.ToProto(),
+	}, nil
+}
+
+func (s *Server) RotateProjectKey(ctx context.Context, req *peridotpb.RotateProjectKeyRequest) (*peridotpb.AsyncTask, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionManage); err != nil {
+		return nil, err
+	}
+	user, err := utils.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.ExpiresAt.AsTime()
+		if !t.After(time.Now()) {
+			return nil, status.Error(codes.InvalidArgument, "expires_at must be in the future")
+		}
+		expiresAt = &t
+	}
+
+	keys, err := s.db.ListKeysForProject(req.ProjectId.Value)
+	if err != nil {
+		s.log.Errorf("could not list keys: %v", err)
+		return nil, utils.InternalError
+	}
+	key := findProjectKey(keys, req.KeyName)
+	if key == nil {
+		return nil, status.Errorf(codes.NotFound, "key %s is not attached to project", req.KeyName)
+	}
+	if key.State == models.KeyStateRevoked {
+		return nil, status.Errorf(codes.FailedPrecondition, "key %s has been revoked", req.KeyName)
+	}
+	if key.DefaultKey {
+		return nil, status.Errorf(codes.FailedPrecondition, "key %s is already the default key", req.KeyName)
+	}
+	var previousKey *models.Key
+	for i := range keys {
+		if keys[i].DefaultKey {
+			previousKey = &keys[i]
+			break
+		}
+	}
+	if key.State != models.KeyStateActive {
+		if err := s.checkKeyNotShared(key); err != nil {
+			return nil, err
+		}
+	}
+	if previousKey != nil {
+		if err := s.checkKeyNotShared(previousKey); err != nil {
+			return nil, err
+		}
+	}
+
+	rollback := true
+	beginTx, err := s.db.Begin()
+	if err != nil {
+		s.log.Error(err)
+		return nil, utils.InternalError
+	}
+	defer func() {
+		if rollback {
+			_ = beginTx.Rollback()
+		}
+	}()
+	tx := s.db.UseTransaction(beginTx)
+
+	// Rotating back to a retiring key is allowed, so the key is always re-activated
+	err = tx.ActivateKey(key.ID.String())
+	if err != nil {
+		s.log.Errorf("could not activate key: %v", err)
+		return nil, utils.InternalError
+	}
+	err = tx.SetDefaultKeyForProject(req.ProjectId.Value, key.ID.String())
+	if err != nil {
+		s.log.Errorf("could not set default key: %v", err)
+		return nil, utils.InternalError
+	}
+	var previousKeyName string
+	if previousKey != nil {
+		previousKeyName = previousKey.Name
+		err = tx.RetireKey(previousKey.ID.String(), key.ID.String(), expiresAt)
+		if err != nil {
+			s.log.Errorf("could not retire key: %v", err)
+			return nil, utils.InternalError
+		}
+	}
+
+	task, err := tx.CreateTask(user, "noarch", peridotpb.TaskType_TASK_TYPE_KEY_ROTATION, &req.ProjectId.Value, nil)
+	if err != nil {
+		s.log.Errorf("could not create task: %v", err)
+		return nil, utils.InternalError
+	}
+
+	taskProto, err := task.ToProto(false)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not marshal task: %v", err)
+	}
+
+	rollback = false
+	err = beginTx.Commit()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "could not save, try again")
+	}
+
+	_, err = s.temporal.ExecuteWorkflow(
+		context.Background(),
+		client.StartWorkflowOptions{
+			ID:        task.ID.String(),
+			TaskQueue: MainTaskQueue,
+		},
+		s.temporalWorker.WorkflowController.KeyRotationWorkflow,
+		req,
+		previousKeyName,
+		task,
+	)
+	if err != nil {
+		s.log.Errorf("could not start workflow: %v", err)
+		_ = s.db.SetTaskStatus(task.ID.String(), peridotpb.TaskStatus_TASK_STATUS_FAILED)
+		return nil, err
+	}
+
+	return &peridotpb.AsyncTask{
+		TaskId:   task.ID.String(),
+		Subtasks: []*peridotpb.Subtask{taskProto},
+		Done:     false,
+	}, nil
+}
+
+func (s *Server) RevokeProjectKey(ctx context.Context, req *peridotpb.RevokeProjectKeyRequest) (*peridotpb.RevokeProjectKeyResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionManage); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.db.ListKeysForProject(req.ProjectId.Value)
+	if err != nil {
+		s.log.Errorf("could not list keys: %v", err)
+		return nil, utils.InternalError
+	}
+	key := findProjectKey(keys, req.KeyName)
+	if key == nil {
+		return nil, status.Errorf(codes.NotFound, "key %s is not attached to project", req.KeyName)
+	}
+	if key.DefaultKey {
+		return nil, status.Errorf(codes.FailedPrecondition, "key %s is the default key, rotate to another key first", req.KeyName)
+	}
+
+	if key.State != models.KeyStateRevoked {
+		if err := s.checkKeyNotShared(key); err != nil {
+			return nil, err
+		}
+		err = s.db.RevokeKey(key.ID.String())
+		if err != nil {
+			s.log.Errorf("could not revoke key: %v", err)
+			return nil, utils.InternalError
+		}
+		key.State = models.KeyStateRevoked
+	}
+
+	return &peridotpb.RevokeProjectKeyResponse{
+		Key: key.ToProto(),
+	}, nil
+}
@@ -0,0 +1,517 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package peridotimplv1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/cavaliergopher/rpm"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+	"peridot.resf.org/utils"
+	"sort"
+	"strconv"
+)
+
+// revisionEntry is a package or module stream in a revision
+type revisionEntry struct {
+	name  string
+	arch  string
+	nevra string
+
+	// Only set for packages
+	evr *revisionVersion
+	// Only set for module streams
+	moduleVersion uint64
+}
+
+func (e *revisionEntry) compare(other *revisionEntry) int {
+	if e.evr != nil {
+		return rpm.Compare(e.evr, other.evr)
+	}
+
+	if e.moduleVersion < other.moduleVersion {
+		return -1
+	} else if e.moduleVersion > other.moduleVersion {
+		return 1
+	}
+	return 0
+}
+
+type revisionVersion struct {
+	epoch   int
+	version string
+	release string
+}
+
+func (v *revisionVersion) Epoch() int      { return v.epoch }
+func (v *revisionVersion) Version() string { return v.version }
+func (v *revisionVersion) Release() string { return v.release }
+
+type revisionModule struct {
+	Document string `yaml:"document"`
+	Data     struct {
+		Name    string `yaml:"name"`
+		Stream  string `yaml:"stream"`
+		Version uint64 `yaml:"version"`
+		Context string `yaml:"context"`
+		Arch    string `yaml:"arch"`
+	} `yaml:"data"`
+}
+
+func decodeRevisionBlob(blob string) ([]byte, error) {
+	if blob == "" {
+		return nil, nil
+	}
+
+	gz, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(gz) == 0 {
+		return nil, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+func revisionPackages(revision *models.RepositoryRevision) ([]*revisionEntry, error) {
+	primaryXml, err := decodeRevisionBlob(revision.PrimaryXml)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode primary: %v", err)
+	}
+	if len(primaryXml) == 0 {
+		return nil, nil
+	}
+	var primary yummeta.PrimaryRoot
+	err = yummeta.UnmarshalPrimary(primaryXml, &primary)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal primary: %v", err)
+	}
+
+	var ret []*revisionEntry
+	for _, pkg := range primary.Packages {
+		version := &yummeta.PrimaryPackageVersion{}
+		if pkg.Version != nil {
+			version = pkg.Version
+		}
+		epoch := version.Epoch
+		if epoch == "" {
+			epoch = "0"
+		}
+
+		epochInt, _ := strconv.Atoi(epoch)
+
+		ret = append(ret, &revisionEntry{
+			name:  pkg.Name,
+			arch:  pkg.Arch,
+			nevra: fmt.Sprintf("%s-%s:%s-%s.%s", pkg.Name, epoch, version.Ver, version.Rel, pkg.Arch),
+			evr: &revisionVersion{
+				epoch:   epochInt,
+				version: version.Ver,
+				release: version.Rel,
+			},
+		})
+	}
+
+	return ret, nil
+}
+
+func revisionModules(revision *models.RepositoryRevision) ([]*revisionEntry, error) {
+	modulesYaml, err := decodeRevisionBlob(revision.ModulesYaml)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode modules: %v", err)
+	}
+
+	var ret []*revisionEntry
+	decoder := yaml.NewDecoder(bytes.NewReader(modulesYaml))
+	for {
+		var doc revisionModule
+		err := decoder.Decode(&doc)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("could not decode module document: %v", err)
+		}
+		if doc.Document != "modulemd" {
+			continue
+		}
+
+		entry := &revisionEntry{
+			name:          fmt.Sprintf("%s:%s", doc.Data.Name, doc.Data.Stream),
+			arch:          doc.Data.Arch,
+			nevra:         fmt.Sprintf("%s:%s:%d:%s:%s", doc.Data.Name, doc.Data.Stream, doc.Data.Version, doc.Data.Context, doc.Data.Arch),
+			moduleVersion: doc.Data.Version,
+		}
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// diffRevisionEntries returns added, removed, upgraded and downgraded entries.
+// For every name and arch present in both revisions, the newest entries are
+// compared and reported as an upgrade or downgrade instead.
+func diffRevisionEntries(from []*revisionEntry, to []*revisionEntry) ([]string, []string, []*peridotpb.RevisionChange, []*peridotpb.RevisionChange) {
+	fromSet := map[string]bool{}
+	toSet := map[string]bool{}
+	fromLatest := map[string]*revisionEntry{}
+	toLatest := map[string]*revisionEntry{}
+	for _, entry := range from {
+		fromSet[entry.nevra] = true
+		key := entry.name + "." + entry.arch
+		if fromLatest[key] == nil || entry.compare(fromLatest[key]) > 0 {
+			fromLatest[key] = entry
+		}
+	}
+	for _, entry := range to {
+		toSet[entry.nevra] = true
+		key := entry.name + "." + entry.arch
+		if toLatest[key] == nil || entry.compare(toLatest[key]) > 0 {
+			toLatest[key] = entry
+		}
+	}
+
+	var upgraded []*peridotpb.RevisionChange
+	var downgraded []*peridotpb.RevisionChange
+	changed := map[string]bool{}
+	for key, fromEntry := range fromLatest {
+		toEntry := toLatest[key]
+		if toEntry == nil || toEntry.nevra == fromEntry.nevra {
+			continue
+		}
+		// Only report a change if the newest entry actually changed
+		if toSet[fromEntry.nevra] && fromSet[toEntry.nevra] {
+			continue
+		}
+
+		change := &peridotpb.RevisionChange{
+			Name: fromEntry.name,
+			Arch: fromEntry.arch,
+			From: fromEntry.nevra,
+			To:   toEntry.nevra,
+		}
+		if toEntry.compare(fromEntry) >= 0 {
+			upgraded = append(upgraded, change)
+		} else {
+			downgraded = append(downgraded, change)
+		}
+		changed[fromEntry.nevra] = true
+		changed[toEntry.nevra] = true
+	}
+
+	var added []string
+	var removed []string
+	for nevra := range toSet {
+		if !fromSet[nevra] && !changed[nevra] {
+			added = append(added, nevra)
+		}
+	}
+	for nevra := range fromSet {
+		if !toSet[nevra] && !changed[nevra] {
+			removed = append(removed, nevra)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Slice(upgraded, func(i, j int) bool {
+		return upgraded[i].From < upgraded[j].From
+	})
+	sort.Slice(downgraded, func(i, j int) bool {
+		return downgraded[i].From < downgraded[j].From
+	})
+
+	return added, removed, upgraded, downgraded
+}
+
+// getRepositoryRevision returns the revision if it belongs to given repository in the project
+func (s *Server) getRepositoryRevision(projectId string, repoId string, revisionId string) (*models.RepositoryRevision, error) {
+	repos, err := s.db.FindRepositoriesForProject(projectId, &repoId, false)
+	if err != nil {
+		s.log.Errorf("could not list repositories: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+	if len(repos) == 0 {
+		return nil, utils.CouldNotFindObject
+	}
+
+	revision, err := s.db.GetRepositoryRevision(revisionId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.CouldNotFindObject
+		}
+		s.log.Errorf("could not get repository revision: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+	if revision.ProjectRepoId != repos[0].ID.String() {
+		return nil, utils.CouldNotFindObject
+	}
+
+	return revision, nil
+}
+
+func (s *Server) ListRepositoryRevisions(ctx context.Context, req *peridotpb.ListRepositoryRevisionsRequest) (*peridotpb.ListRepositoryRevisionsResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionView); err != nil {
+		return nil, err
+	}
+
+	repos, err := s.db.FindRepositoriesForProject(req.ProjectId.Value, &req.RepositoryId.Value, false)
+	if err != nil {
+		s.log.Errorf("could not list repositories: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+	if len(repos) == 0 {
+		return nil, utils.CouldNotFindObject
+	}
+
+	page := utils.MinPage(req.Page)
+	limit := utils.MinLimit(req.Limit)
+	revisions, err := s.db.ListRepositoryRevisions(repos[0].ID.String(), req.Arch.Value, page, limit)
+	if err != nil {
+		s.log.Errorf("could not list repository revisions: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+	var total int64
+	if len(revisions) > 0 {
+		total = revisions[0].Total
+	} else {
+		total, err = s.db.RepositoryRevisionCount(repos[0].ID.String(), req.Arch.Value)
+		if err != nil {
+			s.log.Errorf("could not count repository revisions: %v", err)
+			return nil, utils.CouldNotRetrieveObjects
+		}
+	}
+
+	return &peridotpb.ListRepositoryRevisionsResponse{
+		Revisions: revisions.ToProto(),
+		Total:     total,
+		Size:      limit,
+		Page:      page,
+	}, nil
+}
+
+func (s *Server) DiffRepositoryRevisions(ctx context.Context, req *peridotpb.DiffRepositoryRevisionsRequest) (*peridotpb.DiffRepositoryRevisionsResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionView); err != nil {
+		return nil, err
+	}
+
+	from, err := s.getRepositoryRevision(req.ProjectId.Value, req.RepositoryId.Value, req.FromRevisionId.Value)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getRepositoryRevision(req.ProjectId.Value, req.RepositoryId.Value, req.ToRevisionId.Value)
+	if err != nil {
+		return nil, err
+	}
+	if from.ProjectRepoId != to.ProjectRepoId {
+		return nil, status.Error(codes.InvalidArgument, "revisions belong to different repositories")
+	}
+	if from.Arch != to.Arch {
+		return nil, status.Errorf(codes.InvalidArgument, "revisions are for different architectures (%s and %s)", from.Arch, to.Arch)
+	}
+
+	fromPackages, err := revisionPackages(from)
+	if err != nil {
+		s.log.Errorf("could not parse packages of revision %s: %v", from.ID.String(), err)
+		return nil, utils.InternalError
+	}
+	toPackages, err := revisionPackages(to)
+	if err != nil {
+		s.log.Errorf("could not parse packages of revision %s: %v", to.ID.String(), err)
+		return nil, utils.InternalError
+	}
+	fromModules, err := revisionModules(from)
+	if err != nil {
+		s.log.Errorf("could not parse modules of revision %s: %v", from.ID.String(), err)
+		return nil, utils.InternalError
+	}
+	toModules, err := revisionModules(to)
+	if err != nil {
+		s.log.Errorf("could not parse modules of revision %s: %v", to.ID.String(), err)
+		return nil, utils.InternalError
+	}
+
+	ret := &peridotpb.DiffRepositoryRevisionsResponse{}
+	ret.AddedPackages, ret.RemovedPackages, ret.UpgradedPackages, ret.DowngradedPackages = diffRevisionEntries(fromPackages, toPackages)
+	ret.AddedModules, ret.RemovedModules, ret.UpgradedModules, ret.DowngradedModules = diffRevisionEntries(fromModules, toModules)
+
+	return ret, nil
+}
+
+func (s *Server) ActivateRepositoryRevision(ctx context.Context, req *peridotpb.ActivateRepositoryRevisionRequest) (*peridotpb.ActivateRepositoryRevisionResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionManage); err != nil {
+		return nil, err
+	}
+
+	revision, err := s.getRepositoryRevision(req.ProjectId.Value, req.RepositoryId.Value, req.Id.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.ActivateRepositoryRevision(revision.ID.String())
+	if err != nil {
+		s.log.Errorf("could not activate repository revision: %v", err)
+		return nil, utils.InternalError
+	}
+
+	active, err := s.db.GetLatestActiveRepositoryRevision(revision.ProjectRepoId, revision.Arch)
+	if err != nil {
+		s.log.Errorf("could not get active repository revision: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+	active.Active = true
+
+	return &peridotpb.ActivateRepositoryRevisionResponse{
+		Revision: active.ToProto(),
+	}, nil
+}
+
+func (s *Server) VerifyRepository(ctx context.Context, req *peridotpb.VerifyRepositoryRequest) (*peridotpb.AsyncTask, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionManage); err != nil {
+		return nil, err
+	}
+	user, err := utils.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var revision *models.RepositoryRevision
+	if req.RevisionId != nil {
+		revision, err = s.getRepositoryRevision(req.ProjectId.Value, req.RepositoryId.Value, req.RevisionId.Value)
+		if err != nil {
+			return nil, err
+		}
+		if revision.Arch != req.Arch.Value {
+			return nil, status.Errorf(codes.InvalidArgument, "revision %s is not for arch %s", req.RevisionId.Value, req.Arch.Value)
+		}
+	} else {
+		repos, err := s.db.FindRepositoriesForProject(req.ProjectId.Value, &req.RepositoryId.Value, false)
+		if err != nil {
+			s.log.Errorf("could not list repositories: %v", err)
+			return nil, utils.CouldNotRetrieveObjects
+		}
+		if len(repos) == 0 {
+			return nil, utils.CouldNotFindObject
+		}
+		revision, err = s.db.GetLatestActiveRepositoryRevision(repos[0].ID.String(), req.Arch.Value)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, status.Errorf(codes.NotFound, "no active revision for arch %s", req.Arch.Value)
+			}
+			s.log.Errorf("could not get active repository revision: %v", err)
+			return nil, utils.CouldNotRetrieveObjects
+		}
+	}
+
+	rollback := true
+	beginTx, err := s.db.Begin()
+	if err != nil {
+		s.log.Error(err)
+		return nil, utils.InternalError
+	}
+	defer func() {
+		if rollback {
+			_ = beginTx.Rollback()
+		}
+	}()
+	tx := s.db.UseTransaction(beginTx)
+
+	task, err := tx.CreateTask(user, "noarch", peridotpb.TaskType_TASK_TYPE_VERIFY_REPOSITORY, &req.ProjectId.Value, nil)
+	if err != nil {
+		s.log.Errorf("could not create task: %v", err)
+		return nil, utils.InternalError
+	}
+
+	taskProto, err := task.ToProto(false)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not marshal task: %v", err)
+	}
+
+	rollback = false
+	err = beginTx.Commit()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "could not save, try again")
+	}
+
+	_, err = s.temporal.ExecuteWorkflow(
+		context.Background(),
+		client.StartWorkflowOptions{
+			ID:        task.ID.String(),
+			TaskQueue: MainTaskQueue,
+		},
+		s.temporalWorker.WorkflowController.VerifyRepositoryWorkflow,
+		req,
+		revision.ID.String(),
+		task,
+	)
+	if err != nil {
+		s.log.Errorf("could not start workflow: %v", err)
+		_ = s.db.SetTaskStatus(task.ID.String(), peridotpb.TaskStatus_TASK_STATUS_FAILED)
+		return nil, err
+	}
+
+	return &peridotpb.AsyncTask{
+		TaskId:   task.ID.String(),
+		Subtasks: []*peridotpb.Subtask{taskProto},
+		Done:     false,
+	}, nil
+}
@@ -29,3 +29,169 @@
 // POSSIBILITY OF SUCH DAMAGE.
 
 package peridotimplv1
+
+import (
+	"google.golang.org/protobuf/types/known/anypb"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/utils"
+	"regexp"
+	"strings"
+)
+
+// taskIdPrefixRegex matches queries that could be (a prefix of) a task ID.
+// Shorter queries are too ambiguous to be useful.
+var taskIdPrefixRegex = regexp.MustCompile("^[0-9a-fA-F-]{4,36}$")
+
+func (s *Server) Search(req *peridotpb.SearchRequest, stream peridotpb.SearchService_SearchServer) error {
+	ctx := stream.Context()
+
+	if err := req.ValidateAll(); err != nil {
+		return err
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil
+	}
+	limit := utils.MinLimit(req.Limit)
+
+	var projectIds []string
+	if req.ProjectId != nil {
+		if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionView); err != nil {
+			return err
+		}
+		projectIds = []string{req.ProjectId.Value}
+	} else {
+		resources, err := s.lookupResources(ctx, ObjectProject, PermissionView)
+		if err != nil {
+			return err
+		}
+		projectIds = utils.Take[string](resources, "global")
+	}
+	// Subject doesn't have access to any projects
+	if len(projectIds) == 0 {
+		return nil
+	}
+
+	// Project hits are only relevant for global searches
+	if req.ProjectId == nil {
+		projects, err := s.db.SearchProjects(query, projectIds, limit)
+		if err != nil {
+			s.log.Errorf("could not search projects: %v", err)
+			return utils.InternalError
+		}
+		if len(projects) > 0 {
+			hit, err := anypb.New(&peridotpb.SearchHitProjects{
+				Projects: projects.ToProto(),
+			})
+			if err != nil {
+				return utils.InternalError
+			}
+			err = stream.Send(&peridotpb.SearchResponse{
+				Hits: []*anypb.Any{hit},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, projectId := range projectIds {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		hits, err := s.searchProject(projectId, query, limit)
+		if err != nil {
+			return err
+		}
+		if len(hits) == 0 {
+			continue
+		}
+
+		err = stream.Send(&peridotpb.SearchResponse{
+			Hits: hits,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchProject returns the hits for a single project, ordered by relevance.
+// Task ID matches are the most specific, so they're returned first.
+func (s *Server) searchProject(projectId string, query string, limit int32) ([]*anypb.Any, error) {
+	var hits []*anypb.Any
+
+	if taskIdPrefixRegex.MatchString(query) {
+		tasks, err := s.db.SearchTasks(query, projectId, limit)
+		if err != nil {
+			s.log.Errorf("could not search tasks: %v", err)
+			return nil, utils.InternalError
+		}
+		if len(tasks) > 0 {
+			var asyncTasks []*peridotpb.AsyncTask
+			for _, task := range tasks {
+				taskProto, err := task.ToProto(true)
+				if err != nil {
+					return nil, utils.InternalError
+				}
+				asyncTasks = append(asyncTasks, &peridotpb.AsyncTask{
+					TaskId:   task.ID.String(),
+					Subtasks: []*peridotpb.Subtask{taskProto},
+					Done:     task.FinishedAt.Valid,
+				})
+			}
+
+			hit, err := anypb.New(&peridotpb.SearchHitTasks{
+				Tasks:     asyncTasks,
+				ProjectId: projectId,
+			})
+			if err != nil {
+				return nil, utils.InternalError
+			}
+			hits = append(hits, hit)
+		}
+	}
+
+	packages, err := s.db.SearchPackages(query, projectId, limit)
+	if err != nil {
+		s.log.Errorf("could not search packages: %v", err)
+		return nil, utils.InternalError
+	}
+	if len(packages) > 0 {
+		hit, err := anypb.New(&peridotpb.SearchHitPackages{
+			Packages:  packages.ToProto(),
+			ProjectId: projectId,
+		})
+		if err != nil {
+			return nil, utils.InternalError
+		}
+		hits = append(hits, hit)
+	}
+
+	builds, err := s.db.SearchBuilds(query, projectId, limit)
+	if err != nil {
+		s.log.Errorf("could not search builds: %v", err)
+		return nil, utils.InternalError
+	}
+	if len(builds) > 0 {
+		buildsProto, err := builds.ToProto()
+		if err != nil {
+			s.log.Errorf("could not convert builds: %v", err)
+			return nil, utils.InternalError
+		}
+		hit, err := anypb.New(&peridotpb.SearchHitBuilds{
+			Builds:    buildsProto,
+			ProjectId: projectId,
+		})
+		if err != nil {
+			return nil, utils.InternalError
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package peridotimplv1
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"peridot.resf.org/peridot/builder/v1/workflow"
+	"peridot.resf.org/peridot/db/models"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/utils"
+	"strings"
+)
+
+func (s *Server) CreateSnapshot(ctx context.Context, req *peridotpb.CreateSnapshotRequest) (*peridotpb.CreateSnapshotResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionManage); err != nil {
+		return nil, err
+	}
+
+	projects, err := s.db.ListProjects(&peridotpb.ProjectFilters{
+		Id: req.ProjectId,
+	})
+	if err != nil {
+		s.log.Errorf("could not list projects in CreateSnapshot: %v", err)
+		return nil, utils.InternalError
+	}
+	if len(projects) != 1 {
+		return nil, status.Errorf(codes.InvalidArgument, "project %s does not exist", req.ProjectId.Value)
+	}
+	project := projects[0]
+
+	beginTx, err := s.db.Begin()
+	if err != nil {
+		s.log.Errorf("CreateSnapshot: beginTx: %v", err)
+		return nil, utils.InternalError
+	}
+	tx := s.db.UseTransaction(beginTx)
+
+	snapshot, err := workflow.CreateRevisionSnapshot(tx, &project, req.Name, func(repo *models.Repository, arch string) (string, error) {
+		revision, err := tx.GetLatestActiveRepositoryRevision(repo.ID.String(), arch)
+		if err != nil {
+			return "", err
+		}
+		return revision.ID.String(), nil
+	})
+	if err != nil {
+		_ = beginTx.Rollback()
+		if strings.Contains(err.Error(), "unique") {
+			return nil, status.Errorf(codes.AlreadyExists, "snapshot %s already exists", req.Name)
+		}
+		s.log.Errorf("CreateSnapshot: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create snapshot")
+	}
+
+	revisions, err := tx.GetSnapshotRevisions(snapshot.ID.String())
+	if err != nil {
+		_ = beginTx.Rollback()
+		s.log.Errorf("CreateSnapshot: could not get snapshot revisions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create snapshot")
+	}
+
+	err = beginTx.Commit()
+	if err != nil {
+		s.log.Errorf("CreateSnapshot: commit: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create snapshot")
+	}
+
+	ret := snapshot.ToProto()
+	ret.Revisions = revisions.ToProto()
+
+	return &peridotpb.CreateSnapshotResponse{
+		Snapshot: ret,
+	}, nil
+}
+
+func (s *Server) ListSnapshots(ctx context.Context, req *peridotpb.ListSnapshotsRequest) (*peridotpb.ListSnapshotsResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermission(ctx, ObjectProject, req.ProjectId.Value, PermissionView); err != nil {
+		return nil, err
+	}
+
+	page := utils.MinPage(req.Page)
+	limit := utils.MinLimit(req.Limit)
+	snapshots, err := s.db.ListSnapshots(req.ProjectId.Value, page, limit)
+	if err != nil {
+		s.log.Errorf("could not list snapshots: %v", err)
+		return nil, utils.CouldNotRetrieveObjects
+	}
+	var total int64
+	if len(snapshots) > 0 {
+		total = snapshots[0].Total
+	} else {
+		total, err = s.db.SnapshotCount(req.ProjectId.Value)
+		if err != nil {
+			s.log.Errorf("could not count snapshots: %v", err)
+			return nil, utils.CouldNotRetrieveObjects
+		}
+	}
+
+	ret := snapshots.ToProto()
+	for i, snapshot := range snapshots {
+		revisions, err := s.db.GetSnapshotRevisions(snapshot.ID.String())
+		if err != nil {
+			s.log.Errorf("could not get snapshot revisions: %v", err)
+			return nil, utils.CouldNotRetrieveObjects
+		}
+		ret[i].Revisions = revisions.ToProto()
+	}
+
+	return &peridotpb.ListSnapshotsResponse{
+		Snapshots: ret,
+		Total:     total,
+		Size:      limit,
+		Page:      page,
+	}, nil
+}
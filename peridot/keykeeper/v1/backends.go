@@ -0,0 +1,92 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package keykeeperv1
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
+	"peridot.resf.org/peridot/keykeeper/v1/signer/pkcs11"
+	"peridot.resf.org/peridot/keykeeper/v1/store"
+	"peridot.resf.org/peridot/keykeeper/v1/vault"
+)
+
+// AddBackendFlags adds the flags for key stores and signer backends
+func AddBackendFlags(f *pflag.FlagSet) {
+	f.String("key-store", "awssm", "store for generated and imported keys (awssm or vault-kv)")
+	f.String("vault-addr", "", "address of the Vault server, enables the vault-kv store and the vault-transit signer (RSA keys only)")
+	f.String("vault-token", "", "Vault token")
+	f.String("vault-namespace", "", "Vault namespace")
+	f.String("vault-transit-mount", "transit", "mount path of the Vault Transit secrets engine")
+	f.String("vault-kv-mount", "secret", "mount path of the Vault KV v2 secrets engine")
+	f.String("vault-kv-prefix", "", "prefix for Vault KV entries")
+	f.String("pkcs11-tool", "pkcs11-tool", "path to the OpenSC pkcs11-tool binary (OpenSC 0.21 or newer)")
+	f.String("pkcs11-module", "", "PKCS#11 module to load, enables the pkcs11 signer (RSA keys only)")
+	f.String("pkcs11-token-label", "", "label of the PKCS#11 token holding the keys")
+	f.String("pkcs11-pin", "", "user PIN of the PKCS#11 token")
+}
+
+// newSignerBackends returns the configured signer backends by name.
+// The names are saved as the external store type of registered keys
+func newSignerBackends() (map[string]signer.Backend, error) {
+	backends := map[string]signer.Backend{}
+
+	if viper.GetString("vault-addr") != "" {
+		client, err := vault.New()
+		if err != nil {
+			return nil, err
+		}
+		backends["vault-transit"] = vault.NewTransit(client, viper.GetString("vault-transit-mount"))
+	}
+
+	if viper.GetString("pkcs11-module") != "" {
+		backend, err := pkcs11.New()
+		if err != nil {
+			return nil, err
+		}
+		backends["pkcs11"] = backend
+	}
+
+	return backends, nil
+}
+
+// newVaultStore returns the Vault KV store if Vault is configured
+func newVaultStore() (store.Store, error) {
+	if viper.GetString("vault-addr") == "" {
+		return nil, nil
+	}
+	client, err := vault.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return vault.NewKV(client, viper.GetString("vault-kv-mount")), nil
+}
@@ -37,6 +37,8 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/ProtonMail/gopenpgp/v2/helper"
@@ -44,22 +46,57 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"io"
+	"peridot.resf.org/peridot/db/models"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
 	"peridot.resf.org/utils"
+	"time"
 )
 
-// GenerateKey generates a new key pair.
-// We're trying to do this as securely as possible and while keeping it simple.
-// We're using the gopenpgp library to do this (and ProtonMail is also using it and maintaining it).
-// Best practices are followed. All keys are generated with the RSA algorithm with a key size of 4096 bits.
-// The private key is encrypted with a random passphrase.
-// If the project doesn't have a default key, the generated key is set as the default.
-func (s *Server) GenerateKey(_ context.Context, req *keykeeperpb.GenerateKeyRequest) (*keykeeperpb.GenerateKeyResponse, error) {
-	_, err := s.db.GetKeyByName(req.Name)
-	if err == nil {
-		return nil, status.Error(codes.InvalidArgument, "key with that name already exists")
+// createKey saves the key in the database and attaches it to the given project.
+// If the project doesn't have a default key, the key is set as the default.
+func (s *Server) createKey(projectId string, keyUuid uuid.UUID, name string, email string, gpgId string, encKey string, nonce string, publicKey string, extStoreType string, extStoreId string) (*models.Key, error) {
+	beginTx, err := s.db.Begin()
+	if err != nil {
+		s.log.Errorf("could not start transaction: %v", err)
+		return nil, utils.InternalError
+	}
+	tx := s.db.UseTransaction(beginTx)
+
+	setDefault := false
+	_, err = tx.GetDefaultKeyForProject(projectId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			setDefault = true
+		} else {
+			s.log.Errorf("could not get default key for project: %v", err)
+			return nil, utils.InternalError
+		}
 	}
 
+	k, err := tx.CreateKey(keyUuid.String(), name, email, gpgId, encKey, nonce, publicKey, extStoreType, extStoreId)
+	if err != nil {
+		s.log.Errorf("could not save key: %v", err)
+		return nil, status.Error(codes.Internal, "could not save key")
+	}
+	err = tx.AttachKeyToProject(projectId, keyUuid.String(), setDefault)
+	if err != nil {
+		s.log.Errorf("could not attach key to project: %v", err)
+		return nil, utils.InternalError
+	}
+	err = beginTx.Commit()
+	if err != nil {
+		s.log.Errorf("could not commit transaction: %v", err)
+		return nil, utils.InternalError
+	}
+
+	return k, nil
+}
+
+// saveKey encrypts the armored (and locked) key with a random AES key and stores it in the default store.
+// The AES key and nonce are saved in the database, and the key is attached to the given project.
+// If the project doesn't have a default key, the key is set as the default.
+func (s *Server) saveKey(projectId string, name string, email string, keyUuid uuid.UUID, keyObj *crypto.Key) (*models.Key, error) {
 	encBytes := make([]byte, 32) //generate a random 32 byte key for AES
 	if _, err := rand.Read(encBytes); err != nil {
 		s.log.Errorf("failed to generate random key: %s", err)
@@ -82,6 +119,47 @@ func (s *Server) GenerateKey(_ context.Context, req *keykeeperpb.GenerateKeyRequ
 		return nil, status.Error(codes.Internal, "failed to generate nonce")
 	}
 
+	publicKey, err := keyObj.GetArmoredPublicKeyWithCustomHeaders("Keykeeper", "resf.keykeeper.v1")
+	if err != nil {
+		s.log.Errorf("could not get armored public key: %v", err)
+		return nil, utils.InternalError
+	}
+	armoredKey, err := keyObj.Armor()
+	if err != nil {
+		s.log.Errorf("could not get armored key: %v", err)
+		return nil, utils.InternalError
+	}
+
+	cipherText := gcm.Seal(nil, nonce, []byte(armoredKey), nil)
+	cipherHex := hex.EncodeToString(cipherText)
+
+	store := s.stores[s.defaultStore]
+	err = store.Create(keyUuid.String(), cipherHex)
+	if err != nil {
+		s.log.Errorf("could not store key: %v", err)
+		return nil, status.Error(codes.Internal, "could not store key")
+	}
+
+	k, err := s.createKey(projectId, keyUuid, name, email, keyObj.GetHexKeyID(), hex.EncodeToString(encBytes), hex.EncodeToString(nonce), publicKey, s.defaultStore, keyUuid.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// GenerateKey generates a new key pair.
+// We're trying to do this as securely as possible and while keeping it simple.
+// We're using the gopenpgp library to do this (and ProtonMail is also using it and maintaining it).
+// Best practices are followed. All keys are generated with the RSA algorithm with a key size of 4096 bits.
+// The private key is encrypted with a random passphrase.
+// If the project doesn't have a default key, the generated key is set as the default.
+func (s *Server) GenerateKey(_ context.Context, req *keykeeperpb.GenerateKeyRequest) (*keykeeperpb.GenerateKeyResponse, error) {
+	_, err := s.db.GetKeyByName(req.Name)
+	if err == nil {
+		return nil, status.Error(codes.InvalidArgument, "key with that name already exists")
+	}
+
 	keyUuid := uuid.New()
 
 	rsaKey, err := helper.GenerateKey(req.Name, req.Email, []byte(keyUuid.String()), "rsa", 4096)
@@ -96,76 +174,168 @@ func (s *Server) GenerateKey(_ context.Context, req *keykeeperpb.GenerateKeyRequ
 	}
 	keyObj.GetEntity().Subkeys = []openpgp.Subkey{}
 	fingerprint := keyObj.GetFingerprint()
-	publicKey, err := keyObj.GetArmoredPublicKeyWithCustomHeaders("Keykeeper", "resf.keykeeper.v1")
+
+	k, err := s.saveKey(req.ProjectId, req.Name, req.Email, keyUuid, keyObj)
 	if err != nil {
-		s.log.Errorf("could not get armored public key: %v", err)
-		return nil, utils.InternalError
+		return nil, err
 	}
-	rsaKey, err = keyObj.Armor()
+
+	// Insert into cache
+	_, err = s.WarmGPGKey(req.Name, keyObj, k)
 	if err != nil {
-		s.log.Errorf("could not get armored key: %v", err)
-		return nil, utils.InternalError
+		// We don't have to fail, we can just log the error
+		// and a future request will warm the key
+		s.log.Errorf("could not warm key: %v", err)
 	}
 
-	cipherText := gcm.Seal(nil, nonce, []byte(rsaKey), nil)
-	cipherHex := hex.EncodeToString(cipherText)
+	return &keykeeperpb.GenerateKeyResponse{
+		Name:        req.Name,
+		Email:       req.Email,
+		Fingerprint: fingerprint,
+	}, nil
+}
 
-	store := s.stores[s.defaultStore]
-	err = store.Create(keyUuid.String(), cipherHex)
-	if err != nil {
-		s.log.Errorf("could not store key: %v", err)
-		return nil, status.Error(codes.Internal, "could not store key")
+// ImportKey imports an existing key pair.
+// The private key is unlocked with the given passphrase and locked again with
+// a random passphrase, the same way generated keys are, before it's stored.
+// Subkeys are kept so the public key stays identical to the one already distributed.
+// If the project doesn't have a default key, the imported key is set as the default.
+func (s *Server) ImportKey(_ context.Context, req *keykeeperpb.ImportKeyRequest) (*keykeeperpb.ImportKeyResponse, error) {
+	if req.ProjectId == "" {
+		return nil, status.Error(codes.InvalidArgument, "project_id is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if req.PrivateKey == "" {
+		return nil, status.Error(codes.InvalidArgument, "private_key is required")
 	}
 
-	beginTx, err := s.db.Begin()
+	_, err := s.db.GetKeyByName(req.Name)
+	if err == nil {
+		return nil, status.Error(codes.InvalidArgument, "key with that name already exists")
+	}
+
+	keyObj, err := crypto.NewKeyFromArmored(req.PrivateKey)
 	if err != nil {
-		s.log.Errorf("could not start transaction: %v", err)
-		return nil, utils.InternalError
+		return nil, status.Errorf(codes.InvalidArgument, "could not parse key: %v", err)
+	}
+	if !keyObj.IsPrivate() {
+		return nil, status.Error(codes.InvalidArgument, "key is not a private key")
+	}
+	if keyObj.IsExpired() || keyObj.IsRevoked() {
+		return nil, status.Error(codes.InvalidArgument, "key is expired or revoked")
+	}
+	if !keyObj.GetEntity().PrimaryKey.CanSign() {
+		return nil, status.Error(codes.InvalidArgument, "primary key cannot be used for signing")
 	}
-	tx := s.db.UseTransaction(beginTx)
 
-	setDefault := false
-	_, err = tx.GetDefaultKeyForProject(req.ProjectId)
+	locked, err := keyObj.IsLocked()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			setDefault = true
-		} else {
-			s.log.Errorf("could not get default key for project: %v", err)
-			return nil, utils.InternalError
+		return nil, status.Errorf(codes.InvalidArgument, "could not check if key is locked: %v", err)
+	}
+	if locked {
+		keyObj, err = keyObj.Unlock([]byte(req.Passphrase))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "could not unlock key with given passphrase")
 		}
 	}
 
-	k, err := tx.CreateKey(keyUuid.String(), req.Name, req.Email, keyObj.GetHexKeyID(), hex.EncodeToString(encBytes), hex.EncodeToString(nonce), publicKey, s.defaultStore, keyUuid.String())
-	if err != nil {
-		s.log.Errorf("could not save key: %v", err)
-		return nil, status.Error(codes.Internal, "could not save key")
+	email := req.Email
+	if email == "" {
+		identity := keyObj.GetEntity().PrimaryIdentity()
+		if identity != nil && identity.UserId != nil {
+			email = identity.UserId.Email
+		}
 	}
-	err = tx.AttachKeyToProject(req.ProjectId, keyUuid.String(), setDefault)
+
+	keyUuid := uuid.New()
+
+	keyObj, err = keyObj.Lock([]byte(keyUuid.String()))
 	if err != nil {
-		s.log.Errorf("could not attach key to project: %v", err)
+		s.log.Errorf("could not lock key: %v", err)
 		return nil, utils.InternalError
 	}
-	err = beginTx.Commit()
+	fingerprint := keyObj.GetFingerprint()
+
+	k, err := s.saveKey(req.ProjectId, req.Name, email, keyUuid, keyObj)
 	if err != nil {
-		s.log.Errorf("could not commit transaction: %v", err)
-		return nil, utils.InternalError
+		return nil, err
 	}
 
 	// Insert into cache
-	_, err = s.WarmGPGKey(req.Name, rsaKey, keyObj, k)
+	_, err = s.WarmGPGKey(req.Name, keyObj, k)
 	if err != nil {
 		// We don't have to fail, we can just log the error
 		// and a future request will warm the key
 		s.log.Errorf("could not warm key: %v", err)
 	}
 
-	return &keykeeperpb.GenerateKeyResponse{
+	return &keykeeperpb.ImportKeyResponse{
 		Name:        req.Name,
-		Email:       req.Email,
+		Email:       email,
 		Fingerprint: fingerprint,
 	}, nil
 }
 
+// RegisterKey registers a key held by a signer backend.
+// An OpenPGP key is created around the backend key and its identity is self-signed
+// through the backend. Only the public key is saved, the external store reference
+// points to the backend key instead of an encrypted private key.
+// If the project doesn't have a default key, the registered key is set as the default.
+func (s *Server) RegisterKey(ctx context.Context, req *keykeeperpb.RegisterKeyRequest) (*keykeeperpb.RegisterKeyResponse, error) {
+	if req.ProjectId == "" {
+		return nil, status.Error(codes.InvalidArgument, "project_id is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if req.KeyRef == "" {
+		return nil, status.Error(codes.InvalidArgument, "key_ref is required")
+	}
+	backend := s.signers[req.Backend]
+	if backend == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "signer backend %s is not configured", req.Backend)
+	}
+
+	_, err := s.db.GetKeyByName(req.Name)
+	if err == nil {
+		return nil, status.Error(codes.InvalidArgument, "key with that name already exists")
+	}
+
+	keySigner, err := backend.Signer(ctx, req.KeyRef)
+	if err != nil {
+		if errors.Is(err, signer.ErrUnsupportedKey) {
+			return nil, status.Errorf(codes.InvalidArgument, "key %s is not an RSA key, only RSA keys are supported by signer backends", req.KeyRef)
+		}
+		s.log.Errorf("could not load key %s from %s: %v", req.KeyRef, req.Backend, err)
+		return nil, status.Errorf(codes.InvalidArgument, "could not load key from backend: %v", err)
+	}
+	entity, err := signer.NewEntity(keySigner, req.Name, req.Email, time.Now())
+	if err != nil {
+		s.log.Errorf("could not create key: %v", err)
+		return nil, status.Errorf(codes.Internal, "could not create key: %v", err)
+	}
+	publicKey, err := signer.ArmoredPublicKey(entity)
+	if err != nil {
+		s.log.Errorf("could not get armored public key: %v", err)
+		return nil, utils.InternalError
+	}
+
+	keyUuid := uuid.New()
+	gpgId := fmt.Sprintf("%016x", entity.PrimaryKey.KeyId)
+	_, err = s.createKey(req.ProjectId, keyUuid, req.Name, req.Email, gpgId, "", "", publicKey, req.Backend, req.KeyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keykeeperpb.RegisterKeyResponse{
+		Name:        req.Name,
+		Email:       req.Email,
+		Fingerprint: hex.EncodeToString(entity.PrimaryKey.Fingerprint),
+	}, nil
+}
+
 func (s *Server) GetPublicKey(_ context.Context, req *keykeeperpb.GetPublicKeyRequest) (*keykeeperpb.GetPublicKeyResponse, error) {
 	key, err := s.db.GetKeyByName(req.KeyName)
 	if err != nil {
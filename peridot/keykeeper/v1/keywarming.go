@@ -31,92 +31,75 @@
 package keykeeperv1
 
 import (
-	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/google/uuid"
-	"io/ioutil"
-	"os"
-	"os/exec"
 	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
 	"peridot.resf.org/utils"
-	"strings"
 	"sync"
 )
 
+var ErrKeyRevoked = errors.New("key has been revoked")
+
 // LoadedKey keeps the key and some other information in memory
 // todo(mustafa): Add TTL, rotation check, etc.
 type LoadedKey struct {
 	sync.Mutex
 	keyUuid uuid.UUID
 	gpgId   string
-}
-
-func logCmdRun(cmd *exec.Cmd) (*bytes.Buffer, error) {
-	var outBuf bytes.Buffer
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &outBuf
-	return &outBuf, cmd.Run()
-}
 
-func gpgCmdEnv(cmd *exec.Cmd) *exec.Cmd {
-	cmd.Env = append(cmd.Env, "GNUPGHOME=/keykeeper/gpg")
-	return cmd
-}
-
-func (s *Server) importGpgKey(armoredKey string) error {
-	cmd := gpgCmdEnv(exec.Command("gpg", "--batch", "--yes", "--import", "-"))
-	cmd.Stdin = strings.NewReader(armoredKey)
-	out, err := logCmdRun(cmd)
-	if err != nil {
-		s.log.Errorf("failed to import gpg key: %s", out.String())
-	}
-	return err
-}
-
-func (s *Server) importRpmKey(publicKey string) error {
-	tmpFile, err := ioutil.TempFile("/tmp", "peridot-key-")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tmpFile.Name())
-	_, err = tmpFile.Write([]byte(publicKey))
-	if err != nil {
-		return err
-	}
-	cmd := gpgCmdEnv(exec.Command("rpm", "--import", tmpFile.Name()))
-	out, err := logCmdRun(cmd)
-	if err != nil {
-		s.log.Errorf("failed to import rpm key: %s", out.String())
-	}
-	return err
+	// entity signs with the unlocked private key,
+	// or through the signer backend holding the key
+	entity *openpgp.Entity
 }
 
 // WarmGPGKey warms up a specific GPG key
-// This involves shelling out to GPG to import the key
-func (s *Server) WarmGPGKey(key string, armoredKey string, gpgKey *crypto.Key, db *models.Key) (*LoadedKey, error) {
+// The key is unlocked and kept in memory, so artifacts can be signed without gpg
+func (s *Server) WarmGPGKey(key string, gpgKey *crypto.Key, db *models.Key) (*LoadedKey, error) {
 	cachedKeyAny, ok := s.keys.Load(key)
 	// This means that the key is already loaded
 	if ok {
 		return cachedKeyAny.(*LoadedKey), nil
 	}
 
-	err := s.importGpgKey(armoredKey)
+	// Keys are locked with the key ID as the passphrase
+	unlockedKey, err := gpgKey.Unlock([]byte(db.ID.String()))
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.importRpmKey(db.PublicKey)
+	cachedKey := &LoadedKey{
+		keyUuid: db.ID,
+		gpgId:   gpgKey.GetHexKeyID(),
+		entity:  unlockedKey.GetEntity(),
+	}
+	s.keys.Store(key, cachedKey)
+
+	return cachedKey, nil
+}
+
+// warmExternalKey warms up a key held by a signer backend
+func (s *Server) warmExternalKey(key string, backend signer.Backend, db *models.Key) (*LoadedKey, error) {
+	keySigner, err := backend.Signer(context.Background(), db.ExtStoreId)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := signer.LoadEntity(db.PublicKey, keySigner)
 	if err != nil {
 		return nil, err
 	}
 
 	cachedKey := &LoadedKey{
 		keyUuid: db.ID,
-		gpgId:   gpgKey.GetHexKeyID(),
+		gpgId:   fmt.Sprintf("%016x", entity.PrimaryKey.KeyId),
+		entity:  entity,
 	}
 	s.keys.Store(key, cachedKey)
 
@@ -124,18 +107,29 @@ func (s *Server) WarmGPGKey(key string, armoredKey string, gpgKey *crypto.Key, d
 }
 
 // EnsureGPGKey ensures that the key is loaded
+// Revoked keys are evicted from the cache and never loaded again
 func (s *Server) EnsureGPGKey(key string) (*LoadedKey, error) {
+	// Fetch the encryption key, nonce and external key ID from the database
+	k, err := s.db.GetKeyByName(key)
+	if err != nil {
+		return nil, err
+	}
+	if k.State == models.KeyStateRevoked {
+		s.keys.Delete(key)
+		return nil, ErrKeyRevoked
+	}
+
 	cachedKeyAny, ok := s.keys.Load(key)
 	if ok {
 		return cachedKeyAny.(*LoadedKey), nil
 	}
 
-	// Key not found in cache, fetch from database
-	// Fetch the encryption key, nonce and external key ID from the database
-	k, err := s.db.GetKeyByName(key)
-	if err != nil {
-		return nil, err
+	// Keys held by a signer backend don't have any key material in a store
+	if backend := s.signers[k.ExtStoreType]; backend != nil {
+		return s.warmExternalKey(key, backend, k)
 	}
+
+	// Key not found in cache, decrypt it
 	encBytes, err := hex.DecodeString(k.EncKey)
 	if err != nil {
 		return nil, err
@@ -178,7 +172,7 @@ func (s *Server) EnsureGPGKey(key string) (*LoadedKey, error) {
 		return nil, utils.InternalError
 	}
 
-	loadedKey, err := s.WarmGPGKey(key, string(armoredKey), keyObj, k)
+	loadedKey, err := s.WarmGPGKey(key, keyObj, k)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,163 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package keykeeperv1
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	peridotworkflow "peridot.resf.org/peridot/builder/v1/workflow"
+	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+)
+
+// dssePayloadType is the DSSE payload type for in-toto statements
+const dssePayloadType = "application/vnd.in-toto+json"
+
+type dsseSignature struct {
+	KeyId string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+// Only the parts of the statement that keykeeper verifies
+type intotoStatement struct {
+	Type    string `json:"_type"`
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// dssePae returns the DSSE pre-authentication encoding of a payload.
+// Spec: https://github.com/secure-systems-lab/dsse/blob/master/protocol.md
+func dssePae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// SignProvenance signs the provenance statement of a build as a DSSE envelope.
+// The signature is a detached binary OpenPGP signature over the DSSE PAE,
+// so the envelope can be verified with the public key of the project.
+// Only statements that exclusively describe artifacts of the build (signed or unsigned)
+// are signed, to avoid keykeeper signing arbitrary content.
+func (s *Server) SignProvenance(ctx context.Context, req *keykeeperpb.SignProvenanceRequest) (*keykeeperpb.SignProvenanceResponse, error) {
+	var statement intotoStatement
+	err := json.Unmarshal(req.Statement, &statement)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid statement: %v", err)
+	}
+	if statement.Type != peridotworkflow.IntotoStatementType {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported statement type %s", statement.Type)
+	}
+	if len(statement.Subject) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "statement has no subjects")
+	}
+
+	key, err := s.EnsureGPGKey(req.KeyName)
+	if err != nil {
+		s.log.Errorf("failed to load key %s: %v", req.KeyName, err)
+		return nil, status.Error(codes.Internal, "failed to load key")
+	}
+
+	artifacts, err := s.db.GetArtifactsForBuild(req.BuildId)
+	if err != nil {
+		s.log.Errorf("failed to get artifacts for build %s: %v", req.BuildId, err)
+		return nil, status.Error(codes.Internal, "failed to get artifacts")
+	}
+	if len(artifacts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "build has no artifacts")
+	}
+
+	knownDigests := map[string]bool{}
+	for _, artifact := range artifacts {
+		knownDigests[artifact.HashSha256] = true
+
+		signedHash, err := s.db.GetTaskArtifactSignatureHash(artifact.Name, key.keyUuid.String())
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			s.log.Errorf("failed to get signature hash for %s: %v", artifact.Name, err)
+			return nil, status.Error(codes.Internal, "failed to get signature hash")
+		}
+		knownDigests[signedHash] = true
+	}
+	for _, subject := range statement.Subject {
+		if !knownDigests[subject.Digest["sha256"]] {
+			return nil, status.Errorf(codes.InvalidArgument, "subject %s is not an artifact of build %s", subject.Name, req.BuildId)
+		}
+	}
+
+	signature, err := s.detachSign(ctx, key, dssePae(dssePayloadType, req.Statement), false)
+	if err != nil {
+		s.log.Errorf("failed to sign provenance: %v", err)
+		return nil, status.Error(codes.Internal, "failed to sign provenance")
+	}
+
+	envelope, err := json.Marshal(&dsseEnvelope{
+		PayloadType: dssePayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(req.Statement),
+		Signatures: []dsseSignature{
+			{
+				KeyId: key.gpgId,
+				Sig:   base64.StdEncoding.EncodeToString(signature),
+			},
+		},
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to marshal envelope")
+	}
+	// In-toto bundles are JSON lines
+	envelope = append(envelope, '\n')
+
+	objectName := peridotworkflow.ProvenanceObjectName(req.BuildId)
+	_, err = s.storage.PutObjectBytes(objectName, envelope)
+	if err != nil {
+		s.log.Errorf("failed to upload provenance %s: %v", objectName, err)
+		return nil, status.Error(codes.Internal, "failed to upload provenance")
+	}
+
+	hash := sha256.Sum256(envelope)
+	return &keykeeperpb.SignProvenanceResponse{
+		ObjectName: objectName,
+		HashSha256: hex.EncodeToString(hash[:]),
+	}, nil
+}
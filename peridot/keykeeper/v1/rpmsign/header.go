@@ -0,0 +1,360 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package rpmsign signs RPM packages without rpm.
+// Only the signature header is rewritten, the lead, the main header
+// and the payload are copied as is.
+package rpmsign
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	leadSize        = 96
+	headerIntroSize = 16
+	indexEntrySize  = 16
+
+	// Limits used by rpm to reject corrupted headers
+	maxIndexEntries = 0xffff
+	maxDataSize     = 256 * 1024 * 1024
+)
+
+var (
+	leadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	headerMagic = []byte{0x8e, 0xad, 0xe8, 0x01, 0x00, 0x00, 0x00, 0x00}
+
+	ErrInvalidPackage = errors.New("not a valid rpm package")
+)
+
+// Tag data types
+const (
+	typeNull        = 0
+	typeChar        = 1
+	typeInt8        = 2
+	typeInt16       = 3
+	typeInt32       = 4
+	typeInt64       = 5
+	typeString      = 6
+	typeBin         = 7
+	typeStringArray = 8
+	typeI18NString  = 9
+)
+
+// Signature header tags
+const (
+	tagHeaderSignatures = 62
+
+	// SigTagDSA is a header-only signature made with a non-RSA key (DSA, ECDSA, EdDSA)
+	SigTagDSA = 267
+	// SigTagRSA is a header-only signature made with an RSA key
+	SigTagRSA = 268
+	// SigTagOpenPGP holds base64 encoded header-only signatures, used by RPM v6.
+	// Unlike the legacy tags it can hold signatures from multiple keys
+	SigTagOpenPGP = 278
+	// SigTagPGP is a header+payload signature made with an RSA key
+	SigTagPGP = 1002
+	// SigTagGPG is a header+payload signature made with a non-RSA key
+	SigTagGPG = 1005
+)
+
+type entry struct {
+	tag   int32
+	typ   uint32
+	count uint32
+	data  []byte
+}
+
+// strings returns the values of a string array entry
+func (e *entry) strings() []string {
+	var ret []string
+	for _, s := range bytes.Split(bytes.TrimSuffix(e.data, []byte{0}), []byte{0}) {
+		ret = append(ret, string(s))
+	}
+	return ret
+}
+
+func stringArrayEntry(tag int32, values []string) *entry {
+	var data []byte
+	for _, value := range values {
+		data = append(data, value...)
+		data = append(data, 0)
+	}
+
+	return &entry{
+		tag:   tag,
+		typ:   typeStringArray,
+		count: uint32(len(values)),
+		data:  data,
+	}
+}
+
+func binEntry(tag int32, data []byte) *entry {
+	return &entry{
+		tag:   tag,
+		typ:   typeBin,
+		count: uint32(len(data)),
+		data:  data,
+	}
+}
+
+// alignment returns the alignment of the data of a type within the data store
+func alignment(typ uint32) int {
+	switch typ {
+	case typeInt16:
+		return 2
+	case typeInt32:
+		return 4
+	case typeInt64:
+		return 8
+	default:
+		return 1
+	}
+}
+
+// readHeader reads a header structure, including the header magic
+func readHeader(r io.Reader) ([]byte, error) {
+	intro := make([]byte, headerIntroSize)
+	_, err := io.ReadFull(r, intro)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(intro[:len(headerMagic)], headerMagic) {
+		return nil, ErrInvalidPackage
+	}
+
+	il := binary.BigEndian.Uint32(intro[8:12])
+	dl := binary.BigEndian.Uint32(intro[12:16])
+	if il < 1 || il > maxIndexEntries || dl > maxDataSize {
+		return nil, fmt.Errorf("%w: invalid header size", ErrInvalidPackage)
+	}
+
+	raw := make([]byte, headerIntroSize+int(il)*indexEntrySize+int(dl))
+	copy(raw, intro)
+	_, err = io.ReadFull(r, raw[headerIntroSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// dataLength returns the length of the data of an entry starting at data
+func dataLength(typ uint32, count uint32, data []byte) (int, error) {
+	switch typ {
+	case typeNull:
+		return 0, nil
+	case typeChar, typeInt8, typeBin:
+		return int(count), nil
+	case typeInt16:
+		return int(count) * 2, nil
+	case typeInt32:
+		return int(count) * 4, nil
+	case typeInt64:
+		return int(count) * 8, nil
+	case typeString, typeStringArray, typeI18NString:
+		if typ == typeString {
+			count = 1
+		}
+		length := 0
+		for i := uint32(0); i < count; i++ {
+			end := bytes.IndexByte(data[length:], 0)
+			if end == -1 {
+				return 0, fmt.Errorf("%w: unterminated string", ErrInvalidPackage)
+			}
+			length += end + 1
+		}
+		return length, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown tag type %d", ErrInvalidPackage, typ)
+	}
+}
+
+// parseEntries parses the entries of a header read by readHeader.
+// The region tag is dropped, it's added again by serializeHeader
+func parseEntries(raw []byte) ([]*entry, error) {
+	il := int(binary.BigEndian.Uint32(raw[8:12]))
+	index := raw[headerIntroSize : headerIntroSize+il*indexEntrySize]
+	data := raw[headerIntroSize+il*indexEntrySize:]
+
+	var entries []*entry
+	for i := 0; i < il; i++ {
+		e := index[i*indexEntrySize : (i+1)*indexEntrySize]
+		tag := int32(binary.BigEndian.Uint32(e[0:4]))
+		typ := binary.BigEndian.Uint32(e[4:8])
+		offset := int32(binary.BigEndian.Uint32(e[8:12]))
+		count := binary.BigEndian.Uint32(e[12:16])
+
+		if i == 0 && tag == tagHeaderSignatures {
+			continue
+		}
+		if offset < 0 || int(offset) > len(data) {
+			return nil, fmt.Errorf("%w: tag %d is out of bounds", ErrInvalidPackage, tag)
+		}
+		length, err := dataLength(typ, count, data[offset:])
+		if err != nil {
+			return nil, err
+		}
+		if int(offset)+length > len(data) {
+			return nil, fmt.Errorf("%w: tag %d is out of bounds", ErrInvalidPackage, tag)
+		}
+
+		entries = append(entries, &entry{
+			tag:   tag,
+			typ:   typ,
+			count: count,
+			data:  data[offset : int(offset)+length],
+		})
+	}
+
+	return entries, nil
+}
+
+// serializeHeader creates a signature header from the entries.
+// The entries are sorted by tag and wrapped in a signature region,
+// the same way rpm does
+func serializeHeader(entries []*entry) []byte {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].tag < entries[j].tag
+	})
+
+	il := len(entries) + 1
+	var index bytes.Buffer
+	var data bytes.Buffer
+	for _, e := range entries {
+		for data.Len()%alignment(e.typ) != 0 {
+			data.WriteByte(0)
+		}
+		_ = binary.Write(&index, binary.BigEndian, []uint32{uint32(e.tag), e.typ, uint32(data.Len()), e.count})
+		data.Write(e.data)
+	}
+
+	// The region entry points to a trailer at the end of the data,
+	// with the negative size of the index as its offset
+	regionOffset := data.Len()
+	_ = binary.Write(&data, binary.BigEndian, []uint32{tagHeaderSignatures, typeBin, uint32(int32(-il * indexEntrySize)), indexEntrySize})
+
+	var ret bytes.Buffer
+	ret.Write(headerMagic)
+	_ = binary.Write(&ret, binary.BigEndian, []uint32{uint32(il), uint32(data.Len())})
+	_ = binary.Write(&ret, binary.BigEndian, []uint32{tagHeaderSignatures, typeBin, uint32(regionOffset), indexEntrySize})
+	ret.Write(index.Bytes())
+	ret.Write(data.Bytes())
+
+	return ret.Bytes()
+}
+
+// pkg is an rpm package with everything but the payload loaded
+type pkg struct {
+	lead          []byte
+	signatures    []*entry
+	header        []byte
+	payloadOffset int64
+}
+
+func readPackage(r io.Reader) (*pkg, error) {
+	lead := make([]byte, leadSize)
+	_, err := io.ReadFull(r, lead)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(lead[:len(leadMagic)], leadMagic) {
+		return nil, ErrInvalidPackage
+	}
+
+	sigHeader, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := parseEntries(sigHeader)
+	if err != nil {
+		return nil, err
+	}
+	// The signature header is padded to a multiple of 8 bytes
+	padding := make([]byte, padLength(len(sigHeader)))
+	_, err = io.ReadFull(r, padding)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkg{
+		lead:          lead,
+		signatures:    signatures,
+		header:        header,
+		payloadOffset: int64(leadSize + len(sigHeader) + len(padding) + len(header)),
+	}, nil
+}
+
+func padLength(length int) int {
+	return (8 - length%8) % 8
+}
+
+// entry returns the signature entry with the given tag
+func (p *pkg) entry(tag int32) *entry {
+	for _, e := range p.signatures {
+		if e.tag == tag {
+			return e
+		}
+	}
+	return nil
+}
+
+// setEntry replaces the signature entry with the same tag
+func (p *pkg) setEntry(newEntry *entry) {
+	p.removeEntries(newEntry.tag)
+	p.signatures = append(p.signatures, newEntry)
+}
+
+func (p *pkg) removeEntries(tags ...int32) {
+	var entries []*entry
+	for _, e := range p.signatures {
+		keep := true
+		for _, tag := range tags {
+			if e.tag == tag {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			entries = append(entries, e)
+		}
+	}
+	p.signatures = entries
+}
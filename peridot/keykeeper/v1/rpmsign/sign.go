@@ -0,0 +1,217 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package rpmsign
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"io"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
+)
+
+var ErrNotSigned = errors.New("package is not signed")
+
+// parseSignature reads a binary OpenPGP signature packet
+func parseSignature(sig []byte) (*packet.Signature, error) {
+	p, err := packet.Read(bytes.NewReader(sig))
+	if err != nil {
+		return nil, err
+	}
+	ret, ok := p.(*packet.Signature)
+	if !ok {
+		return nil, fmt.Errorf("expected signature packet, got %T", p)
+	}
+	return ret, nil
+}
+
+// isRSA reports whether the signature was made with an RSA key
+func isRSA(sig *packet.Signature) bool {
+	switch sig.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+// keyIds returns the IDs of the primary key and subkeys of the entity
+func keyIds(entity *openpgp.Entity) map[uint64]bool {
+	ids := map[uint64]bool{
+		entity.PrimaryKey.KeyId: true,
+	}
+	for _, subkey := range entity.Subkeys {
+		ids[subkey.PublicKey.KeyId] = true
+	}
+	return ids
+}
+
+// Sign signs the package read from r with entity and writes the signed package to w.
+// A header-only and a header+payload signature are added to the legacy tags,
+// replacing existing signatures. The header-only signature is also added to the
+// OpenPGP tag read by RPM v6, which keeps the signatures of other keys.
+func Sign(ctx context.Context, r io.ReadSeeker, w io.Writer, entity *openpgp.Entity) error {
+	p, err := readPackage(r)
+	if err != nil {
+		return err
+	}
+
+	headerSig, err := signer.DetachSign(ctx, entity, bytes.NewReader(p.header), false)
+	if err != nil {
+		return fmt.Errorf("could not sign header: %v", err)
+	}
+	_, err = r.Seek(p.payloadOffset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	headerPayloadSig, err := signer.DetachSign(ctx, entity, io.MultiReader(bytes.NewReader(p.header), r), false)
+	if err != nil {
+		return fmt.Errorf("could not sign header and payload: %v", err)
+	}
+
+	sig, err := parseSignature(headerSig)
+	if err != nil {
+		return err
+	}
+	p.removeEntries(SigTagDSA, SigTagRSA, SigTagPGP, SigTagGPG)
+	if isRSA(sig) {
+		p.setEntry(binEntry(SigTagRSA, headerSig))
+		p.setEntry(binEntry(SigTagPGP, headerPayloadSig))
+	} else {
+		p.setEntry(binEntry(SigTagDSA, headerSig))
+		p.setEntry(binEntry(SigTagGPG, headerPayloadSig))
+	}
+
+	// Replace an earlier signature of the same key
+	var openpgpSigs []string
+	if e := p.entry(SigTagOpenPGP); e != nil {
+		ids := keyIds(entity)
+		for _, value := range e.strings() {
+			existing, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("%w: invalid OpenPGP signature", ErrInvalidPackage)
+			}
+			existingSig, err := parseSignature(existing)
+			if err == nil && existingSig.IssuerKeyId != nil && ids[*existingSig.IssuerKeyId] {
+				continue
+			}
+			openpgpSigs = append(openpgpSigs, value)
+		}
+	}
+	openpgpSigs = append(openpgpSigs, base64.StdEncoding.EncodeToString(headerSig))
+	p.setEntry(stringArrayEntry(SigTagOpenPGP, openpgpSigs))
+
+	sigHeader := serializeHeader(p.signatures)
+	for _, b := range [][]byte{p.lead, sigHeader, make([]byte, padLength(len(sigHeader))), p.header} {
+		_, err = w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = r.Seek(p.payloadOffset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Verify verifies the signatures of the package read from r against keyring.
+// All legacy signatures have to be made by a key in keyring, and signatures
+// in the OpenPGP tag made by keys in keyring have to be valid.
+// ErrNotSigned is returned if the package isn't signed by any key in keyring.
+func Verify(r io.ReadSeeker, keyring openpgp.KeyRing) error {
+	p, err := readPackage(r)
+	if err != nil {
+		return err
+	}
+
+	verified := false
+	for _, tag := range []int32{SigTagRSA, SigTagDSA} {
+		e := p.entry(tag)
+		if e == nil {
+			continue
+		}
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(p.header), bytes.NewReader(e.data), nil)
+		if err != nil {
+			return fmt.Errorf("header signature: %v", err)
+		}
+		verified = true
+	}
+
+	for _, tag := range []int32{SigTagPGP, SigTagGPG} {
+		e := p.entry(tag)
+		if e == nil {
+			continue
+		}
+		_, err = r.Seek(p.payloadOffset, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = openpgp.CheckDetachedSignature(keyring, io.MultiReader(bytes.NewReader(p.header), r), bytes.NewReader(e.data), nil)
+		if err != nil {
+			return fmt.Errorf("header+payload signature: %v", err)
+		}
+		verified = true
+	}
+
+	if e := p.entry(SigTagOpenPGP); e != nil {
+		for _, value := range e.strings() {
+			sig, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("%w: invalid OpenPGP signature", ErrInvalidPackage)
+			}
+			parsed, err := parseSignature(sig)
+			if err != nil {
+				return fmt.Errorf("%w: invalid OpenPGP signature", ErrInvalidPackage)
+			}
+			// Signatures of other keys can't be verified
+			if parsed.IssuerKeyId == nil || len(keyring.KeysById(*parsed.IssuerKeyId)) == 0 {
+				continue
+			}
+			_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(p.header), bytes.NewReader(sig), nil)
+			if err != nil {
+				return fmt.Errorf("OpenPGP signature: %v", err)
+			}
+			verified = true
+		}
+	}
+
+	if !verified {
+		return ErrNotSigned
+	}
+
+	return nil
+}
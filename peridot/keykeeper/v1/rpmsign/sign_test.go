@@ -0,0 +1,348 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package rpmsign
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Signed by the EPEL 7 key, which the tests don't have
+const testPackage = "testdata/epel-release-7-5.noarch.rpm"
+
+func readTestPackage(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(testPackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func newKey(t *testing.T, name string, algorithm packet.PublicKeyAlgorithm) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{
+		Algorithm: algorithm,
+		RSABits:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func sign(t *testing.T, rpm []byte, entity *openpgp.Entity) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	err := Sign(context.Background(), bytes.NewReader(rpm), &out, entity)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return out.Bytes()
+}
+
+func mustReadPackage(t *testing.T, rpm []byte) *pkg {
+	t.Helper()
+	p, err := readPackage(bytes.NewReader(rpm))
+	if err != nil {
+		t.Fatalf("read package: %v", err)
+	}
+	return p
+}
+
+// openpgpIssuers returns the issuer key IDs of the signatures in the OpenPGP tag
+func openpgpIssuers(t *testing.T, p *pkg) []uint64 {
+	t.Helper()
+	e := p.entry(SigTagOpenPGP)
+	if e == nil {
+		return nil
+	}
+	var ret []uint64
+	for _, value := range e.strings() {
+		sig, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseSignature(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, *parsed.IssuerKeyId)
+	}
+	return ret
+}
+
+func TestSignVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  packet.PublicKeyAlgorithm
+		headerTag  int32
+		payloadTag int32
+	}{
+		{"RSA", packet.PubKeyAlgoRSA, SigTagRSA, SigTagPGP},
+		{"EdDSA", packet.PubKeyAlgoEdDSA, SigTagDSA, SigTagGPG},
+	}
+
+	original := readTestPackage(t)
+	originalPkg := mustReadPackage(t, original)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entity := newKey(t, tt.name, tt.algorithm)
+			signed := sign(t, original, entity)
+
+			err := Verify(bytes.NewReader(signed), openpgp.EntityList{entity})
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+
+			p := mustReadPackage(t, signed)
+			for _, tag := range []int32{tt.headerTag, tt.payloadTag, SigTagOpenPGP} {
+				if p.entry(tag) == nil {
+					t.Errorf("signature tag %d is missing", tag)
+				}
+			}
+			if !bytes.Equal(p.header, originalPkg.header) {
+				t.Error("main header was modified")
+			}
+			if !bytes.Equal(signed[p.payloadOffset:], original[originalPkg.payloadOffset:]) {
+				t.Error("payload was modified")
+			}
+
+			other := newKey(t, "other", packet.PubKeyAlgoEdDSA)
+			err = Verify(bytes.NewReader(signed), openpgp.EntityList{other})
+			if err == nil {
+				t.Error("verify succeeded with a different key")
+			}
+
+			tampered := append([]byte{}, signed...)
+			tampered[len(tampered)-1] ^= 0xff
+			err = Verify(bytes.NewReader(tampered), openpgp.EntityList{entity})
+			if err == nil {
+				t.Error("verify succeeded with a tampered payload")
+			}
+		})
+	}
+}
+
+func TestVerifyNotSigned(t *testing.T) {
+	entity := newKey(t, "key", packet.PubKeyAlgoEdDSA)
+
+	// The legacy signatures of the test package are made by the EPEL key
+	p := mustReadPackage(t, readTestPackage(t))
+	p.removeEntries(SigTagDSA, SigTagRSA, SigTagPGP, SigTagGPG)
+	var unsigned bytes.Buffer
+	sigHeader := serializeHeader(p.signatures)
+	for _, b := range [][]byte{p.lead, sigHeader, make([]byte, padLength(len(sigHeader))), p.header} {
+		unsigned.Write(b)
+	}
+	unsigned.Write(readTestPackage(t)[p.payloadOffset:])
+
+	err := Verify(bytes.NewReader(unsigned.Bytes()), openpgp.EntityList{entity})
+	if !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("expected ErrNotSigned, got %v", err)
+	}
+}
+
+func TestResign(t *testing.T) {
+	first := newKey(t, "first", packet.PubKeyAlgoRSA)
+	second := newKey(t, "second", packet.PubKeyAlgoEdDSA)
+
+	signed := sign(t, readTestPackage(t), first)
+	signed = sign(t, signed, second)
+
+	p := mustReadPackage(t, signed)
+	issuers := openpgpIssuers(t, p)
+	if len(issuers) != 2 || issuers[0] != first.PrimaryKey.KeyId || issuers[1] != second.PrimaryKey.KeyId {
+		t.Fatalf("expected OpenPGP signatures of both keys, got %x", issuers)
+	}
+	// Legacy tags only hold the signatures of the latest key
+	if p.entry(SigTagRSA) != nil || p.entry(SigTagPGP) != nil {
+		t.Error("legacy RSA signatures of the first key were kept")
+	}
+	err := Verify(bytes.NewReader(signed), openpgp.EntityList{second})
+	if err != nil {
+		t.Fatalf("verify with second key: %v", err)
+	}
+
+	// Signing with the first key again replaces its OpenPGP signature,
+	// which moves it after the signature of the second key
+	signed = sign(t, signed, first)
+	p = mustReadPackage(t, signed)
+	issuers = openpgpIssuers(t, p)
+	if len(issuers) != 2 || issuers[0] != second.PrimaryKey.KeyId || issuers[1] != first.PrimaryKey.KeyId {
+		t.Fatalf("expected one OpenPGP signature per key, got %x", issuers)
+	}
+	err = Verify(bytes.NewReader(signed), openpgp.EntityList{first, second})
+	if err != nil {
+		t.Fatalf("verify with both keys: %v", err)
+	}
+}
+
+func TestTruncatedPackage(t *testing.T) {
+	rpm := readTestPackage(t)
+	p := mustReadPackage(t, rpm)
+	headerStart := int(p.payloadOffset) - len(p.header)
+
+	for _, length := range []int{0, leadSize / 2, leadSize + headerIntroSize/2, leadSize + headerIntroSize + 8, headerStart - 1, headerStart + headerIntroSize + 8, int(p.payloadOffset) - 1} {
+		_, err := readPackage(bytes.NewReader(rpm[:length]))
+		if err == nil {
+			t.Errorf("package truncated to %d bytes was accepted", length)
+		}
+	}
+}
+
+func TestCorruptedPackage(t *testing.T) {
+	rpm := readTestPackage(t)
+
+	badLead := append([]byte{}, rpm...)
+	badLead[0] = 0
+	_, err := readPackage(bytes.NewReader(badLead))
+	if !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("bad lead magic: expected ErrInvalidPackage, got %v", err)
+	}
+
+	badMagic := append([]byte{}, rpm...)
+	badMagic[leadSize] = 0
+	_, err = readPackage(bytes.NewReader(badMagic))
+	if !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("bad header magic: expected ErrInvalidPackage, got %v", err)
+	}
+
+	for _, il := range []uint32{0, maxIndexEntries + 1} {
+		badSize := append([]byte{}, rpm...)
+		binary.BigEndian.PutUint32(badSize[leadSize+8:], il)
+		_, err = readPackage(bytes.NewReader(badSize))
+		if !errors.Is(err, ErrInvalidPackage) {
+			t.Errorf("index length %d: expected ErrInvalidPackage, got %v", il, err)
+		}
+	}
+}
+
+// rawHeader builds a header structure with a single entry
+func rawHeader(tag int32, typ uint32, offset int32, count uint32, data []byte) []byte {
+	var ret bytes.Buffer
+	ret.Write(headerMagic)
+	_ = binary.Write(&ret, binary.BigEndian, []uint32{1, uint32(len(data))})
+	_ = binary.Write(&ret, binary.BigEndian, []uint32{uint32(tag), typ, uint32(offset), count})
+	ret.Write(data)
+	return ret.Bytes()
+}
+
+func TestParseEntries(t *testing.T) {
+	entries, err := parseEntries(rawHeader(SigTagRSA, typeBin, 0, 4, []byte{1, 2, 3, 4}))
+	if err != nil {
+		t.Fatalf("valid header: %v", err)
+	}
+	if len(entries) != 1 || !bytes.Equal(entries[0].data, []byte{1, 2, 3, 4}) {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+
+	tests := []struct {
+		name   string
+		typ    uint32
+		offset int32
+		count  uint32
+		data   []byte
+	}{
+		{"negative offset", typeBin, -1, 1, []byte{0}},
+		{"offset past data", typeBin, 5, 1, []byte{0, 0, 0, 0}},
+		{"binary past data", typeBin, 2, 4, []byte{0, 0, 0, 0}},
+		{"int32 past data", typeInt32, 0, 2, []byte{0, 0, 0, 0}},
+		{"int64 past data", typeInt64, 0, 1, []byte{0, 0, 0, 0}},
+		{"unterminated string", typeString, 0, 1, []byte("abc")},
+		{"missing string array value", typeStringArray, 0, 2, []byte("abc\x00")},
+		{"string at end of data", typeString, 4, 1, []byte("abc\x00")},
+		{"unknown type", 42, 0, 1, []byte{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseEntries(rawHeader(SigTagRSA, tt.typ, tt.offset, tt.count, tt.data))
+			if !errors.Is(err, ErrInvalidPackage) {
+				t.Fatalf("expected ErrInvalidPackage, got %v", err)
+			}
+		})
+	}
+}
+
+// TestRpmkeysChecksig verifies signed packages with rpm itself
+func TestRpmkeysChecksig(t *testing.T) {
+	rpmkeys, err := exec.LookPath("rpmkeys")
+	if err != nil {
+		t.Skip("rpmkeys is not installed")
+	}
+
+	for _, algorithm := range []packet.PublicKeyAlgorithm{packet.PubKeyAlgoRSA, packet.PubKeyAlgoEdDSA} {
+		entity := newKey(t, "rpmkeys", algorithm)
+		dir := t.TempDir()
+
+		var publicKey bytes.Buffer
+		w, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = entity.Serialize(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = w.Close()
+		publicKeyPath := filepath.Join(dir, "key.asc")
+		err = os.WriteFile(publicKeyPath, publicKey.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rpmPath := filepath.Join(dir, "signed.rpm")
+		err = os.WriteFile(rpmPath, sign(t, readTestPackage(t), entity), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dbPath := filepath.Join(dir, "rpmdb")
+		out, err := exec.Command(rpmkeys, "--dbpath", dbPath, "--import", publicKeyPath).CombinedOutput()
+		if err != nil {
+			t.Fatalf("rpmkeys --import: %v: %s", err, out)
+		}
+		out, err = exec.Command(rpmkeys, "--dbpath", dbPath, "--checksig", "-v", rpmPath).CombinedOutput()
+		if err != nil || strings.Contains(string(out), "NOKEY") || strings.Contains(string(out), "BAD") {
+			t.Fatalf("rpmkeys --checksig failed for key algorithm %d: %v: %s", algorithm, err, out)
+		}
+	}
+}
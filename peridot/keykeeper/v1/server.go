@@ -32,25 +32,25 @@ package keykeeperv1
 
 import (
 	"context"
+	"fmt"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 	"google.golang.org/grpc"
-	"io/ioutil"
 	"math/rand"
 	"os"
-	"os/exec"
 	commonpb "peridot.resf.org/common"
 	peridotdb "peridot.resf.org/peridot/db"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
 	"peridot.resf.org/peridot/keykeeper/v1/store"
 	"peridot.resf.org/peridot/keykeeper/v1/store/awssm"
 	"peridot.resf.org/peridot/lookaside"
-	"peridot.resf.org/peridot/lookaside/s3"
+	"peridot.resf.org/peridot/lookaside/backend"
 	"peridot.resf.org/utils"
-	"strings"
 	"sync"
 	"time"
 )
@@ -66,12 +66,13 @@ type Server struct {
 	worker       worker.Worker
 	temporal     client.Client
 	stores       map[string]store.Store
+	signers      map[string]signer.Backend
 	keys         *sync.Map
 	defaultStore string
 }
 
 func NewServer(db peridotdb.Access, c client.Client) (*Server, error) {
-	storage, err := s3.New(osfs.New("/"))
+	storage, err := backend.New(osfs.New("/"))
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +81,25 @@ func NewServer(db peridotdb.Access, c client.Client) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	stores := map[string]store.Store{"awssm": sm}
+
+	vaultStore, err := newVaultStore()
+	if err != nil {
+		return nil, err
+	}
+	if vaultStore != nil {
+		stores["vault-kv"] = vaultStore
+	}
+
+	defaultStore := viper.GetString("key-store")
+	if stores[defaultStore] == nil {
+		return nil, fmt.Errorf("key store %s is not configured", defaultStore)
+	}
+
+	signers, err := newSignerBackends()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Server{
 		log:     logrus.New(),
@@ -89,9 +109,10 @@ func NewServer(db peridotdb.Access, c client.Client) (*Server, error) {
 			DeadlockDetectionTimeout: 15 * time.Minute,
 		}),
 		temporal:     c,
-		stores:       map[string]store.Store{"awssm": sm},
+		stores:       stores,
+		signers:      signers,
 		keys:         &sync.Map{},
-		defaultStore: "awssm",
+		defaultStore: defaultStore,
 	}, nil
 }
 
@@ -116,48 +137,11 @@ func (s *Server) Run() {
 	s.worker.RegisterActivity(s.SignArtifactActivity)
 	defer s.temporal.Close()
 
-	// Create keykeeper directories (/keykeeper/gpg and /keykeeper/artifacts)
+	// Create keykeeper artifacts directory
 	err := os.MkdirAll("/keykeeper/artifacts", 0755)
 	if err != nil {
 		s.log.Errorf("Failed to create keykeeper artifacts directory: %v", err)
 	}
-	err = os.MkdirAll("/keykeeper/gpg", 0755)
-	if err != nil {
-		s.log.Errorf("Failed to create keykeeper gpg directory: %v", err)
-	}
-
-	// Since we launch each server in a container, we're going to overwrite
-	// some GPG options on each launch
-	// todo(mustafa): Evaluate if this is the best way to do this (even though non-container workloads will never be supported)
-	err = os.MkdirAll("/keykeeper/gpg/.gnupg", 0755)
-	if err != nil {
-		logrus.Fatalf("failed to create /keykeeper/gpg/.gnupg: %v", err)
-	}
-	err = ioutil.WriteFile("/keykeeper/gpg/.gnupg/gpg.conf", []byte("use-agent\npinentry-mode loopback"), 0644)
-	if err != nil {
-		logrus.Fatalf("could not create gpg config file: %v", err)
-	}
-	err = ioutil.WriteFile("/keykeeper/gpg/.gnupg/gpg-agent.conf", []byte("allow-loopback-pinentry"), 0644)
-	if err != nil {
-		logrus.Fatalf("could not create gpg agent config file: %v", err)
-	}
-	// Reload gpg-connect-agent
-	agentReloadCmd := gpgCmdEnv(exec.Command("gpg-connect-agent"))
-	agentReloadCmd.Stdin = strings.NewReader("RELOADAGENT\n")
-	logs, err := logCmdRun(agentReloadCmd)
-	if err != nil {
-		logrus.Fatalf("could not reload gpg-connect-agent: %v\nlogs: %s", err, logs)
-	}
-
-	rpmMacros := `%__gpg_sign_cmd %{__gpg} \
-    gpg --batch --no-verbose --no-armor --pinentry-mode loopback --passphrase %{_peridot_keykeeper_key} \
-    %{?_gpg_digest_algo:--digest-algo %{_gpg_digest_algo}} \
-    --no-secmem-warning \
-    -u "%{_gpg_name}" -sbo %{__signature_filename} %{__plaintext_filename}`
-	err = ioutil.WriteFile("/etc/rpm/macros.gpg", []byte(rpmMacros), 0644)
-	if err != nil {
-		logrus.Fatalf("could not create rpm macros file: %v", err)
-	}
 
 	var wg sync.WaitGroup
 	wg.Add(2)
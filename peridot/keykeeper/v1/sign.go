@@ -38,6 +38,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
@@ -47,13 +48,13 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/anypb"
 	"io"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	peridotworkflow "peridot.resf.org/peridot/builder/v1/workflow"
 	"peridot.resf.org/peridot/db/models"
 	keykeeperpb "peridot.resf.org/peridot/keykeeper/pb"
+	"peridot.resf.org/peridot/keykeeper/v1/rpmsign"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
 	peridotpb "peridot.resf.org/peridot/pb"
 	"peridot.resf.org/utils"
 	"strings"
@@ -196,16 +197,7 @@ func (s *Server) SignArtifactActivity(ctx context.Context, artifactId string, ke
 		tx := s.db.UseTransaction(beginTx)
 
 		rpmSign := func() (*keykeeperpb.SignedArtifact, error) {
-			var outBuf bytes.Buffer
-			opts := []string{
-				"--define", "_gpg_name " + keyName,
-				"--define", "_peridot_keykeeper_key " + key.keyUuid.String(),
-				"--addsign", localPath,
-			}
-			cmd := gpgCmdEnv(exec.Command("rpm", opts...))
-			cmd.Stdout = &outBuf
-			cmd.Stderr = &outBuf
-			err := cmd.Run()
+			err := signRpm(ctx, key, localPath)
 			if err != nil {
 				s.log.Errorf("failed to sign artifact %s: %v", artifact.Name, err)
 				statusErr := status.New(codes.Internal, "failed to sign artifact")
@@ -213,8 +205,7 @@ func (s *Server) SignArtifactActivity(ctx context.Context, artifactId string, ke
 					Reason: "rpmsign-failed",
 					Domain: "keykeeper.peridot.resf.org",
 					Metadata: map[string]string{
-						"logs": outBuf.String(),
-						"err":  err.Error(),
+						"err": err.Error(),
 					},
 				})
 				if err2 != nil {
@@ -259,19 +250,15 @@ func (s *Server) SignArtifactActivity(ctx context.Context, artifactId string, ke
 			}, nil
 		}
 		verifySig := func() error {
-			var outBuf bytes.Buffer
-			opts := []string{
-				"--define", "_gpg_name " + keyName,
-				"--define", "_peridot_keykeeper_key " + key.keyUuid.String(),
-				"--checksig", localPath,
+			f, err := os.Open(localPath)
+			if err != nil {
+				return err
 			}
-			cmd := gpgCmdEnv(exec.Command("rpm", opts...))
-			cmd.Stdout = &outBuf
-			cmd.Stderr = &outBuf
-			err := cmd.Run()
+			defer f.Close()
+
+			err = rpmsign.Verify(f, openpgp.EntityList{key.entity})
 			if err != nil {
 				s.log.Errorf("failed to verify artifact %s: %v", artifact.Name, err)
-				s.log.Errorf("buf: %s", outBuf.String())
 				return fmt.Errorf("failed to verify artifact %s: %v", artifact.Name, err)
 			}
 			return nil
@@ -384,57 +371,54 @@ func (s *Server) SignArtifacts(_ context.Context, req *keykeeperpb.SignArtifacts
 	}, nil
 }
 
-// SignText signs given text with the given key.
-// This method only returns the signature part of the gpg clearsign
-func (s *Server) SignText(_ context.Context, req *keykeeperpb.SignTextRequest) (*keykeeperpb.SignTextResponse, error) {
-	key, err := s.EnsureGPGKey(req.KeyName)
+// signRpm signs the rpm at path in place with the given key
+func signRpm(ctx context.Context, key *LoadedKey, path string) error {
+	in, err := os.Open(path)
 	if err != nil {
-		s.log.Errorf("failed to load key %s: %v", req.KeyName, err)
-		return nil, status.Error(codes.Internal, "failed to load key")
+		return err
+	}
+	defer in.Close()
+
+	signedPath := path + ".signed"
+	out, err := os.Create(signedPath)
+	if err != nil {
+		return err
 	}
+	defer os.Remove(signedPath)
+	defer out.Close()
 
-	tmpFile, err := os.CreateTemp("", "")
+	err = rpmsign.Sign(ctx, in, out, key.entity)
 	if err != nil {
-		s.log.Errorf("failed to create temp file: %v", err)
-		return nil, status.Error(codes.Internal, "failed to create temp file")
+		return err
 	}
-	defer os.Remove(tmpFile.Name())
-	defer tmpFile.Close()
-	err = ioutil.WriteFile(tmpFile.Name(), []byte(req.Text), 0644)
+	err = out.Close()
 	if err != nil {
-		s.log.Errorf("failed to write to temp file: %v", err)
-		return nil, status.Error(codes.Internal, "failed to write to temp file")
+		return err
 	}
 
-	cmdArgs := []string{
-		"--batch",
-		"--no-verbose",
-		"--armor",
-		"--pinentry-mode=loopback",
-		"--no-secmem-warning",
-		"--detach-sign",
-		"--passphrase",
-		key.keyUuid.String(),
-		"-u",
-		req.KeyName,
-		"-o",
-		tmpFile.Name() + ".asc",
-		tmpFile.Name(),
+	return os.Rename(signedPath, path)
+}
+
+// detachSign creates a detached OpenPGP signature of data with the given key.
+// The signature is ASCII armored if armor is set
+func (s *Server) detachSign(ctx context.Context, key *LoadedKey, data []byte, armor bool) ([]byte, error) {
+	return signer.DetachSign(ctx, key.entity, bytes.NewReader(data), armor)
+}
+
+// SignText signs given text with the given key.
+// This method only returns the signature part of the gpg clearsign
+func (s *Server) SignText(ctx context.Context, req *keykeeperpb.SignTextRequest) (*keykeeperpb.SignTextResponse, error) {
+	key, err := s.EnsureGPGKey(req.KeyName)
+	if err != nil {
+		s.log.Errorf("failed to load key %s: %v", req.KeyName, err)
+		return nil, status.Error(codes.Internal, "failed to load key")
 	}
-	cmd := gpgCmdEnv(exec.Command("gpg", cmdArgs...))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
+
+	signedText, err := s.detachSign(ctx, key, []byte(req.Text), true)
 	if err != nil {
 		s.log.Errorf("failed to sign text: %v", err)
 		return nil, status.Error(codes.Internal, "failed to sign text")
 	}
-	defer os.Remove(tmpFile.Name() + ".asc")
-	signedText, err := ioutil.ReadFile(tmpFile.Name() + ".asc")
-	if err != nil {
-		s.log.Errorf("failed to read signed text: %v", err)
-		return nil, status.Error(codes.Internal, "failed to read signed text")
-	}
 
 	return &keykeeperpb.SignTextResponse{
 		Signature: string(signedText),
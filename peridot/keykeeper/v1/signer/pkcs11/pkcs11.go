@@ -0,0 +1,174 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package pkcs11 signs with keys stored on a PKCS#11 token (HSM, SoftHSM).
+// Tokens are accessed through pkcs11-tool from OpenSC, so any module
+// that can be loaded by OpenSC is supported. pkcs11-tool has to be
+// OpenSC 0.21 or newer, as the PIN is passed in the environment
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"os/exec"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
+	"strings"
+)
+
+// pinEnv is the environment variable pkcs11-tool reads the PIN from.
+// Unlike arguments, the environment of a process is only readable by its owner
+const pinEnv = "PERIDOT_PKCS11_PIN"
+
+// digestInfoPrefixes are the DER encoded DigestInfo prefixes for PKCS #1 v1.5 signatures.
+// The RSA-PKCS mechanism only pads the input, the DigestInfo has to be added by the caller
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+type Backend struct {
+	tool       string
+	module     string
+	tokenLabel string
+	pin        string
+}
+
+// New returns a backend for the token configured with the pkcs11 flags
+func New() (*Backend, error) {
+	module := viper.GetString("pkcs11-module")
+	if module == "" {
+		return nil, errors.New("pkcs11-module is required")
+	}
+	tool, err := exec.LookPath(viper.GetString("pkcs11-tool"))
+	if err != nil {
+		return nil, fmt.Errorf("could not find pkcs11-tool: %v", err)
+	}
+
+	return &Backend{
+		tool:       tool,
+		module:     module,
+		tokenLabel: viper.GetString("pkcs11-token-label"),
+		pin:        viper.GetString("pkcs11-pin"),
+	}, nil
+}
+
+func (b *Backend) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	args = append([]string{"--module", b.module}, args...)
+	if b.tokenLabel != "" {
+		args = append(args, "--token-label", b.tokenLabel)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, b.tool, args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", pinEnv, b.pin))
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Signer returns a signer for the RSA key pair with the label ref
+func (b *Backend) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	der, err := b.run(ctx, nil, "--read-object", "--type", "pubkey", "--label", ref)
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key %s: %v", ref, err)
+	}
+
+	// Older versions of OpenSC export RSA public keys in PKCS #1 form
+	var pub crypto.PublicKey
+	pub, err = x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		pub, err = x509.ParsePKCS1PublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key %s: %v", ref, err)
+		}
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: key %s is not an RSA key", signer.ErrUnsupportedKey, ref)
+	}
+
+	return &keySigner{
+		backend: b,
+		label:   ref,
+		pub:     rsaPub,
+	}, nil
+}
+
+type keySigner struct {
+	backend *Backend
+	label   string
+	pub     *rsa.PublicKey
+}
+
+func (k *keySigner) Public() crypto.PublicKey {
+	return k.pub
+}
+
+func (k *keySigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext creates a PKCS #1 v1.5 signature of digest on the token
+func (k *keySigner) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("PSS signatures are not supported")
+	}
+	prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+	}
+	if len(digest) != opts.HashFunc().Size() {
+		return nil, fmt.Errorf("invalid digest length %d", len(digest))
+	}
+
+	input := append(append([]byte{}, prefix...), digest...)
+	args := []string{"--sign", "--mechanism", "RSA-PKCS", "--label", k.label, "--login"}
+	if k.backend.pin != "" {
+		args = append(args, "--pin", "env:"+pinEnv)
+	}
+
+	return k.backend.run(ctx, input, args...)
+}
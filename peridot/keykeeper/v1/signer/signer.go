@@ -0,0 +1,209 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package signer separates key material from the signing operation.
+// Backends only sign digests, so private keys held by an HSM or an
+// external service never have to be exported to keykeeper.
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	gopenpgp "github.com/ProtonMail/gopenpgp/v2/crypto"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedKey is returned for keys that can't be used with a signer backend.
+// Only RSA keys are supported, as go-crypto can only create OpenPGP
+// signatures through crypto.Signer for RSA keys
+var ErrUnsupportedKey = errors.New("only RSA keys are supported by signer backends")
+
+// Backend holds signing keys that never leave it
+type Backend interface {
+	// Signer returns a signer for the key identified by ref.
+	// The format of ref is specific to the backend
+	Signer(ctx context.Context, ref string) (crypto.Signer, error)
+}
+
+// ContextSigner is implemented by signers that call out to a backend.
+// DetachSign uses it to pass the context of the request to the backend,
+// Sign is only called with a background context
+type ContextSigner interface {
+	crypto.Signer
+	SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// boundSigner signs with the context of a single request
+type boundSigner struct {
+	ctx    context.Context
+	signer ContextSigner
+}
+
+func (b *boundSigner) Public() crypto.PublicKey {
+	return b.signer.Public()
+}
+
+func (b *boundSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return b.signer.SignContext(b.ctx, digest, opts)
+}
+
+// withContext returns a copy of entity that signs with ctx if it's held by a ContextSigner.
+// Entities are shared between requests, so the entity itself is left untouched
+func withContext(ctx context.Context, entity *openpgp.Entity) *openpgp.Entity {
+	if entity.PrivateKey == nil {
+		return entity
+	}
+	contextSigner, ok := entity.PrivateKey.PrivateKey.(ContextSigner)
+	if !ok {
+		return entity
+	}
+
+	priv := *entity.PrivateKey
+	priv.PrivateKey = &boundSigner{
+		ctx:    ctx,
+		signer: contextSigner,
+	}
+	ret := *entity
+	ret.PrivateKey = &priv
+
+	return &ret
+}
+
+// config returns the OpenPGP config used for all signatures.
+// Signatures are created at now, which is also used as
+// the creation time of new keys
+func config(now time.Time) *packet.Config {
+	return &packet.Config{
+		DefaultHash: crypto.SHA256,
+		Time: func() time.Time {
+			return now
+		},
+	}
+}
+
+func privateKey(s crypto.Signer, created time.Time) (*packet.PrivateKey, error) {
+	pub, ok := s.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	// RSA signatures are created through crypto.Signer,
+	// so the private key doesn't have to be available
+	return &packet.PrivateKey{
+		PublicKey:  *packet.NewRSAPublicKey(created, pub),
+		PrivateKey: s,
+	}, nil
+}
+
+// NewEntity creates an OpenPGP key for a backend key and self-signs its identity.
+// The creation time is part of the key fingerprint, the stored public key
+// has to be passed to LoadEntity to sign with the same key later
+func NewEntity(s crypto.Signer, name string, email string, created time.Time) (*openpgp.Entity, error) {
+	// OpenPGP timestamps only have a precision of seconds
+	created = created.Truncate(time.Second)
+	priv, err := privateKey(s, created)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := &openpgp.Entity{
+		PrimaryKey: &priv.PublicKey,
+		PrivateKey: priv,
+		Identities: map[string]*openpgp.Identity{},
+	}
+	err = entity.AddUserId(name, "", email, config(created))
+	if err != nil {
+		return nil, fmt.Errorf("could not sign identity: %v", err)
+	}
+
+	return entity, nil
+}
+
+// LoadEntity returns the OpenPGP key of the armored public key, signing with s.
+// It fails if s doesn't hold the private key of the public key
+func LoadEntity(armoredPublicKey string, s crypto.Signer) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key: %v", err)
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("expected one public key, got %d", len(entities))
+	}
+	entity := entities[0]
+
+	priv, err := privateKey(s, entity.PrimaryKey.CreationTime)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(priv.PublicKey.Fingerprint, entity.PrimaryKey.Fingerprint) {
+		return nil, fmt.Errorf("signer does not hold the private key of %s", entity.PrimaryKey.KeyIdString())
+	}
+	entity.PrivateKey = priv
+
+	return entity, nil
+}
+
+// ArmoredPublicKey returns the ASCII armored public key of the entity,
+// with the same headers keykeeper uses for generated keys
+func ArmoredPublicKey(entity *openpgp.Entity) (string, error) {
+	key, err := gopenpgp.NewKeyFromEntity(entity)
+	if err != nil {
+		return "", err
+	}
+
+	return key.GetArmoredPublicKeyWithCustomHeaders("Keykeeper", "resf.keykeeper.v1")
+}
+
+// DetachSign creates a detached signature of data.
+// The signature is ASCII armored if armor is set
+func DetachSign(ctx context.Context, entity *openpgp.Entity, data io.Reader, armor bool) ([]byte, error) {
+	entity = withContext(ctx, entity)
+
+	var buf bytes.Buffer
+	var err error
+	if armor {
+		err = openpgp.ArmoredDetachSign(&buf, entity, data, config(time.Now()))
+	} else {
+		err = openpgp.DetachSign(&buf, entity, data, config(time.Now()))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+)
+
+// KV stores secrets in a KV v2 secrets engine
+type KV struct {
+	client *Client
+	mount  string
+}
+
+type kvWriteRequest struct {
+	Data    map[string]string `json:"data"`
+	Options map[string]int    `json:"options,omitempty"`
+}
+
+type kvReadResponse struct {
+	Data struct {
+		Data map[string]string `json:"data"`
+	} `json:"data"`
+}
+
+func NewKV(client *Client, mount string) *KV {
+	return &KV{
+		client: client,
+		mount:  strings.Trim(mount, "/"),
+	}
+}
+
+func (k *KV) path(key string) string {
+	return fmt.Sprintf("%s/data/%s%s", k.mount, viper.GetString("vault-kv-prefix"), key)
+}
+
+// Create creates a secret, failing if it already exists
+func (k *KV) Create(key string, value string) error {
+	return k.client.do(context.Background(), http.MethodPost, k.path(key), &kvWriteRequest{
+		Data: map[string]string{"value": value},
+		// Check-and-set with version 0 only allows the write if the key doesn't exist
+		Options: map[string]int{"cas": 0},
+	}, nil)
+}
+
+// Set sets the secret value for the given key.
+func (k *KV) Set(key string, value string) error {
+	return k.client.do(context.Background(), http.MethodPost, k.path(key), &kvWriteRequest{
+		Data: map[string]string{"value": value},
+	}, nil)
+}
+
+// Get returns the secret value for the given key.
+func (k *KV) Get(key string) (string, error) {
+	var res kvReadResponse
+	err := k.client.do(context.Background(), http.MethodGet, k.path(key), nil, &res)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := res.Data.Data["value"]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no value", key)
+	}
+
+	return value, nil
+}
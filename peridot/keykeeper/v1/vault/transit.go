@@ -0,0 +1,187 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"peridot.resf.org/peridot/keykeeper/v1/signer"
+	"strconv"
+	"strings"
+)
+
+var transitHashes = map[crypto.Hash]string{
+	crypto.SHA256: "sha2-256",
+	crypto.SHA384: "sha2-384",
+	crypto.SHA512: "sha2-512",
+}
+
+// Transit signs with keys of a Transit secrets engine
+type Transit struct {
+	client *Client
+	mount  string
+}
+
+type transitKeyResponse struct {
+	Data struct {
+		Type          string `json:"type"`
+		LatestVersion int    `json:"latest_version"`
+		Keys          map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	} `json:"data"`
+}
+
+type transitSignRequest struct {
+	Input              string `json:"input"`
+	Prehashed          bool   `json:"prehashed"`
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	KeyVersion         int    `json:"key_version"`
+}
+
+type transitSignResponse struct {
+	Data struct {
+		Signature string `json:"signature"`
+	} `json:"data"`
+}
+
+func NewTransit(client *Client, mount string) *Transit {
+	return &Transit{
+		client: client,
+		mount:  strings.Trim(mount, "/"),
+	}
+}
+
+// Signer returns a signer for the Transit key ref.
+// Ref is the key name, optionally followed by ":<version>" to pin a key version.
+// Without a version the latest version is used, which changes when the key is rotated in Vault
+func (t *Transit) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	name := ref
+	version := 0
+	if i := strings.LastIndex(ref, ":"); i != -1 {
+		var err error
+		name = ref[:i]
+		version, err = strconv.Atoi(ref[i+1:])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid key version in %s", ref)
+		}
+	}
+
+	var res transitKeyResponse
+	err := t.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/keys/%s", t.mount, url.PathEscape(name)), nil, &res)
+	if err != nil {
+		return nil, fmt.Errorf("could not read transit key %s: %v", name, err)
+	}
+	if !strings.HasPrefix(res.Data.Type, "rsa-") {
+		return nil, fmt.Errorf("%w: transit key %s is of type %s", signer.ErrUnsupportedKey, name, res.Data.Type)
+	}
+	if version == 0 {
+		version = res.Data.LatestVersion
+	}
+
+	key, ok := res.Data.Keys[strconv.Itoa(version)]
+	if !ok {
+		return nil, fmt.Errorf("transit key %s has no version %d", name, version)
+	}
+	block, _ := pem.Decode([]byte(key.PublicKey))
+	if block == nil {
+		return nil, fmt.Errorf("could not decode public key of %s", ref)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key of %s: %v", ref, err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: transit key %s is not an RSA key", signer.ErrUnsupportedKey, ref)
+	}
+
+	return &transitSigner{
+		transit: t,
+		name:    name,
+		version: version,
+		pub:     rsaPub,
+	}, nil
+}
+
+type transitSigner struct {
+	transit *Transit
+	name    string
+	version int
+	pub     *rsa.PublicKey
+}
+
+func (s *transitSigner) Public() crypto.PublicKey {
+	return s.pub
+}
+
+func (s *transitSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext creates a PKCS #1 v1.5 signature of digest in Vault
+func (s *transitSigner) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("PSS signatures are not supported")
+	}
+	hash, ok := transitHashes[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+	}
+
+	var res transitSignResponse
+	err := s.transit.client.do(ctx, http.MethodPost, fmt.Sprintf("%s/sign/%s/%s", s.transit.mount, url.PathEscape(s.name), hash), &transitSignRequest{
+		Input:              base64.StdEncoding.EncodeToString(digest),
+		Prehashed:          true,
+		SignatureAlgorithm: "pkcs1v15",
+		KeyVersion:         s.version,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signatures are returned as vault:v<version>:<base64>
+	parts := strings.Split(res.Data.Signature, ":")
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected signature format from vault")
+	}
+
+	return base64.StdEncoding.DecodeString(parts[2])
+}
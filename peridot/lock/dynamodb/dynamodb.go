@@ -0,0 +1,92 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package dynamodb
+
+import (
+	"cirello.io/dynamolock"
+	"context"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"peridot.resf.org/peridot/lock"
+)
+
+// Locker uses a DynamoDB table to hold locks
+type Locker struct {
+	client *dynamolock.Client
+}
+
+type Lock struct {
+	client *dynamolock.Client
+	item   *dynamolock.Lock
+}
+
+func New(ddb *dynamodb.DynamoDB, table string) (*Locker, error) {
+	client, err := dynamolock.New(
+		ddb,
+		table,
+		dynamolock.WithLeaseDuration(lock.LeaseDuration),
+		dynamolock.WithHeartbeatPeriod(lock.HeartbeatPeriod),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Locker{
+		client: client,
+	}, nil
+}
+
+func (l *Locker) AcquireLock(ctx context.Context, key string) (lock.Lock, error) {
+	item, err := l.client.AcquireLockWithContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lock{
+		client: l.client,
+		item:   item,
+	}, nil
+}
+
+func (l *Locker) Close() error {
+	return l.client.Close()
+}
+
+func (l *Lock) Release() error {
+	lockSuccess, err := l.client.ReleaseLock(l.item)
+	if err != nil {
+		return err
+	}
+	if !lockSuccess {
+		return lock.ErrLockLost
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"peridot.resf.org/peridot/lock"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyPrefix is prepended to all lock keys
+const keyPrefix = "/peridot/locks/"
+
+// Locker uses etcd leases to hold locks.
+// The etcd v3 JSON gateway is used, so no client library is required.
+type Locker struct {
+	endpoint string
+	client   *http.Client
+}
+
+type Lock struct {
+	locker  *Locker
+	key     string
+	leaseID string
+	stop    chan struct{}
+	done    chan struct{}
+
+	mu   sync.Mutex
+	lost bool
+}
+
+func New(endpoint string) *Locker {
+	return &Locker{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client: &http.Client{
+			Timeout: lock.HeartbeatPeriod,
+		},
+	}
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// call posts given request to the etcd gateway and decodes the response into res
+func (l *Locker) call(ctx context.Context, path string, req interface{}, res interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd returned %d: %s", resp.StatusCode, string(respBody))
+	}
+	if res == nil {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, res)
+}
+
+func (l *Locker) revoke(leaseID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), lock.HeartbeatPeriod)
+	defer cancel()
+
+	_ = l.call(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": leaseID}, nil)
+}
+
+func (l *Locker) AcquireLock(ctx context.Context, key string) (lock.Lock, error) {
+	var grant struct {
+		ID    string `json:"ID"`
+		Error string `json:"error"`
+	}
+	err := l.call(ctx, "/v3/lease/grant", map[string]interface{}{
+		"TTL": int64(lock.LeaseDuration.Seconds()),
+	}, &grant)
+	if err != nil {
+		return nil, fmt.Errorf("could not grant lease: %v", err)
+	}
+	if grant.ID == "" {
+		return nil, fmt.Errorf("could not grant lease: %s", grant.Error)
+	}
+
+	ret := &Lock{
+		locker:  l,
+		key:     keyPrefix + key,
+		leaseID: grant.ID,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	// Keep the lease alive while waiting as well
+	go ret.heartbeat()
+
+	for attempt := 0; ; attempt++ {
+		// Only put the key if it doesn't exist yet
+		var txn struct {
+			Succeeded bool `json:"succeeded"`
+		}
+		err := l.call(ctx, "/v3/kv/txn", map[string]interface{}{
+			"compare": []map[string]interface{}{
+				{
+					"key":             b64(ret.key),
+					"target":          "CREATE",
+					"result":          "EQUAL",
+					"create_revision": "0",
+				},
+			},
+			"success": []map[string]interface{}{
+				{
+					"request_put": map[string]interface{}{
+						"key":   b64(ret.key),
+						"value": b64(grant.ID),
+						"lease": grant.ID,
+					},
+				},
+			},
+		}, &txn)
+		if err != nil {
+			ret.stopHeartbeat()
+			l.revoke(grant.ID)
+			return nil, fmt.Errorf("could not acquire lock: %v", err)
+		}
+		if txn.Succeeded {
+			return ret, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			ret.stopHeartbeat()
+			l.revoke(grant.ID)
+			return nil, ctx.Err()
+		case <-time.After(lock.RetryDelay(attempt)):
+		}
+	}
+}
+
+func (l *Locker) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
+
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(lock.HeartbeatPeriod)
+	defer ticker.Stop()
+
+	lastRenewal := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			var keepAlive struct {
+				Result struct {
+					TTL string `json:"TTL"`
+				} `json:"result"`
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), lock.HeartbeatPeriod)
+			err := l.locker.call(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": l.leaseID}, &keepAlive)
+			cancel()
+
+			// A missing or zero TTL means the lease has already expired
+			if err == nil && keepAlive.Result.TTL != "" && keepAlive.Result.TTL != "0" {
+				lastRenewal = time.Now()
+				continue
+			}
+			if err == nil || time.Since(lastRenewal) >= lock.LeaseDuration {
+				l.mu.Lock()
+				l.lost = true
+				l.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+func (l *Lock) stopHeartbeat() {
+	close(l.stop)
+	<-l.done
+}
+
+func (l *Lock) Release() error {
+	l.stopHeartbeat()
+	// Revoking the lease deletes the key as well
+	defer l.locker.revoke(l.leaseID)
+
+	l.mu.Lock()
+	lost := l.lost
+	l.mu.Unlock()
+	if lost {
+		return lock.ErrLockLost
+	}
+
+	return nil
+}
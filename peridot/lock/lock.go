@@ -0,0 +1,84 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	// LeaseDuration is how long a lock is valid without a heartbeat
+	LeaseDuration = 10 * time.Second
+	// HeartbeatPeriod is how often a held lock is renewed
+	HeartbeatPeriod = 3 * time.Second
+
+	// minRetryDelay and maxRetryDelay bound the wait between acquire attempts
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = LeaseDuration / 2
+)
+
+// ErrLockLost is returned by Release if the lease expired before release
+var ErrLockLost = errors.New("lost lock before release")
+
+// Locker hands out distributed locks.
+// Locks are held until released, and renewed in the background every HeartbeatPeriod.
+// If the holder is unable to renew the lock for LeaseDuration, another
+// process may acquire it.
+type Locker interface {
+	// AcquireLock blocks until the lock for given key is acquired or ctx is done
+	AcquireLock(ctx context.Context, key string) (Lock, error)
+	Close() error
+}
+
+// Lock is a held lock
+type Lock interface {
+	// Release releases the lock and stops the heartbeat.
+	// ErrLockLost is returned if the lock was lost before release.
+	Release() error
+}
+
+// RetryDelay returns how long to wait after a failed acquire attempt, starting at 0.
+// The delay doubles with every attempt up to half the lease duration, and is
+// randomized so waiting processes don't retry in lockstep
+func RetryDelay(attempt int) time.Duration {
+	delay := maxRetryDelay
+	if attempt < 10 {
+		delay = minRetryDelay << attempt
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,133 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package memory
+
+import (
+	"context"
+	"peridot.resf.org/peridot/lock"
+	"sync"
+	"time"
+)
+
+// Locker is an in-process Locker.
+// It respects the same lease semantics as the distributed implementations,
+// so it's only useful for tests, as locks aren't shared between replicas.
+type Locker struct {
+	mu    sync.Mutex
+	locks map[string]*Lock
+}
+
+type Lock struct {
+	locker    *Locker
+	key       string
+	expiresAt time.Time
+	stop      chan struct{}
+	once      sync.Once
+}
+
+func New() *Locker {
+	return &Locker{
+		locks: map[string]*Lock{},
+	}
+}
+
+// tryAcquire returns a lock if key is not held or its lease expired
+func (l *Locker) tryAcquire(key string) *Lock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if existing := l.locks[key]; existing != nil && time.Now().Before(existing.expiresAt) {
+		return nil
+	}
+
+	ret := &Lock{
+		locker:    l,
+		key:       key,
+		expiresAt: time.Now().Add(lock.LeaseDuration),
+		stop:      make(chan struct{}),
+	}
+	l.locks[key] = ret
+
+	return ret
+}
+
+func (l *Locker) AcquireLock(ctx context.Context, key string) (lock.Lock, error) {
+	for attempt := 0; ; attempt++ {
+		if held := l.tryAcquire(key); held != nil {
+			go held.heartbeat()
+			return held, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lock.RetryDelay(attempt)):
+		}
+	}
+}
+
+func (l *Locker) Close() error {
+	return nil
+}
+
+func (l *Lock) heartbeat() {
+	ticker := time.NewTicker(lock.HeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.locker.mu.Lock()
+			if l.locker.locks[l.key] == l {
+				l.expiresAt = time.Now().Add(lock.LeaseDuration)
+			}
+			l.locker.mu.Unlock()
+		}
+	}
+}
+
+func (l *Lock) Release() error {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	if l.locker.locks[l.key] != l {
+		return lock.ErrLockLost
+	}
+	delete(l.locker.locks, l.key)
+
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"peridot.resf.org/peridot/lock"
+	"sync"
+	"time"
+)
+
+// Locker uses Postgres session level advisory locks.
+// Every held lock pins a connection, and the lock is released by Postgres
+// when that session ends. The session is kept alive with a heartbeat, and
+// idle_session_timeout (Postgres 14+) makes sure a holder that stops
+// sending heartbeats loses the lock after the lease duration.
+type Locker struct {
+	db *sql.DB
+}
+
+type Lock struct {
+	conn *sql.Conn
+	key  string
+	stop chan struct{}
+	done chan struct{}
+
+	mu   sync.Mutex
+	lost bool
+}
+
+// New returns a Locker using given database.
+// A database handle separate from the main pool should be used, as session
+// settings are changed on connections holding a lock.
+func New(db *sql.DB) *Locker {
+	return &Locker{
+		db: db,
+	}
+}
+
+func (l *Locker) AcquireLock(ctx context.Context, key string) (lock.Lock, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get connection: %v", err)
+	}
+
+	// Best effort, older versions of Postgres rely on the session dying
+	// together with the holder instead
+	_, _ = conn.ExecContext(ctx, fmt.Sprintf("set idle_session_timeout = %d", lock.LeaseDuration.Milliseconds()))
+
+	for attempt := 0; ; attempt++ {
+		var acquired bool
+		err := conn.QueryRowContext(ctx, "select pg_try_advisory_lock(hashtextextended($1, 0))", key).Scan(&acquired)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("could not acquire advisory lock: %v", err)
+		}
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(lock.RetryDelay(attempt)):
+		}
+	}
+
+	ret := &Lock{
+		conn: conn,
+		key:  key,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go ret.heartbeat()
+
+	return ret, nil
+}
+
+func (l *Locker) Close() error {
+	return l.db.Close()
+}
+
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(lock.HeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), lock.HeartbeatPeriod)
+			_, err := l.conn.ExecContext(ctx, "select 1")
+			cancel()
+			if err != nil {
+				// The session is gone, and with it the lock
+				l.mu.Lock()
+				l.lost = true
+				l.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+func (l *Lock) Release() error {
+	close(l.stop)
+	<-l.done
+	defer l.conn.Close()
+
+	l.mu.Lock()
+	lost := l.lost
+	l.mu.Unlock()
+	if lost {
+		return lock.ErrLockLost
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lock.LeaseDuration)
+	defer cancel()
+
+	var released bool
+	err := l.conn.QueryRowContext(ctx, "select pg_advisory_unlock(hashtextextended($1, 0))", l.key).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("could not release advisory lock: %v", err)
+	}
+	_, _ = l.conn.ExecContext(ctx, "reset idle_session_timeout")
+	if !released {
+		return lock.ErrLockLost
+	}
+
+	return nil
+}
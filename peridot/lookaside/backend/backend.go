@@ -0,0 +1,61 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package backend
+
+import (
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/spf13/viper"
+	"peridot.resf.org/peridot/lookaside"
+	"peridot.resf.org/peridot/lookaside/filesystem"
+	"peridot.resf.org/peridot/lookaside/gcs"
+	"peridot.resf.org/peridot/lookaside/s3"
+)
+
+const (
+	S3         = "s3"
+	GCS        = "gcs"
+	Filesystem = "filesystem"
+)
+
+// New returns the storage backend selected with the storage-backend flag
+func New(fs billy.Filesystem) (lookaside.Storage, error) {
+	switch backend := viper.GetString("storage-backend"); backend {
+	case S3, "":
+		return s3.New(fs)
+	case GCS:
+		return gcs.New(fs)
+	case Filesystem:
+		return filesystem.New(fs, viper.GetString("storage-path"))
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
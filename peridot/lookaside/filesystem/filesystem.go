@@ -0,0 +1,176 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"github.com/go-git/go-billy/v5"
+	"io"
+	"os"
+	"path/filepath"
+	"peridot.resf.org/peridot/lookaside"
+	"strings"
+)
+
+// Storage stores objects in a local directory.
+// Useful for small deployments and CI, where running S3 or GCS is overkill.
+type Storage struct {
+	root string
+	fs   billy.Filesystem
+}
+
+func New(fs billy.Filesystem, root string) (*Storage, error) {
+	if root == "" {
+		return nil, errors.New("storage path is required")
+	}
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		root: root,
+		fs:   fs,
+	}, nil
+}
+
+// objectPath returns the path on disk for given object.
+// Object names are cleaned so they can't escape the storage root.
+func (s *Storage) objectPath(objectName string) string {
+	return filepath.Join(s.root, filepath.Clean("/"+strings.TrimPrefix(objectName, "/")))
+}
+
+// writeObject atomically writes given reader to the object path
+func (s *Storage) writeObject(objectName string, r io.Reader) (*lookaside.UploadInfo, error) {
+	path := s.objectPath(objectName)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	err = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(f.Name(), 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lookaside.UploadInfo{
+		Location: "file://" + path,
+	}, nil
+}
+
+func (s *Storage) DownloadObject(objectName string, path string) error {
+	r, err := s.OpenObject(objectName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (s *Storage) ReadObject(objectName string) ([]byte, error) {
+	return os.ReadFile(s.objectPath(objectName))
+}
+
+func (s *Storage) PutObject(objectName string, filePath string) (*lookaside.UploadInfo, error) {
+	f, err := s.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return s.writeObject(objectName, f)
+}
+
+func (s *Storage) PutObjectBytes(objectName string, content []byte) (*lookaside.UploadInfo, error) {
+	return s.writeObject(objectName, bytes.NewReader(content))
+}
+
+func (s *Storage) DeleteObject(objectName string) error {
+	err := os.Remove(s.objectPath(objectName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) Write(path string, content []byte) error {
+	_, err := s.PutObjectBytes(path, content)
+	return err
+}
+
+func (s *Storage) Read(path string) ([]byte, error) {
+	return s.ReadObject(path)
+}
+
+func (s *Storage) Exists(path string) (bool, error) {
+	_, err := os.Stat(s.objectPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Storage) OpenObject(objectName string) (io.ReadCloser, error) {
+	return os.Open(s.objectPath(objectName))
+}
@@ -0,0 +1,167 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package gcs
+
+import (
+	"bytes"
+	"cloud.google.com/go/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"peridot.resf.org/peridot/lookaside"
+	"time"
+)
+
+type Storage struct {
+	bucket *storage.BucketHandle
+	name   string
+	fs     billy.Filesystem
+}
+
+// New returns a Storage backed by the bucket in gcs-bucket.
+// Credentials are picked up using Application Default Credentials.
+func New(fs billy.Filesystem) (*Storage, error) {
+	bucket := viper.GetString("gcs-bucket")
+	if bucket == "" {
+		return nil, errors.New("gcs-bucket is required")
+	}
+
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		bucket: client.Bucket(bucket),
+		name:   bucket,
+		fs:     fs,
+	}, nil
+}
+
+func (s *Storage) upload(objectName string, r io.Reader) (*lookaside.UploadInfo, error) {
+	w := s.bucket.Object(objectName).NewWriter(context.Background())
+	_, err := io.Copy(w, r)
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	generation := fmt.Sprintf("%d", w.Attrs().Generation)
+	return &lookaside.UploadInfo{
+		Location:  fmt.Sprintf("gs://%s/%s", s.name, objectName),
+		VersionID: &generation,
+	}, nil
+}
+
+func (s *Storage) DownloadObject(objectName string, path string) error {
+	r, err := s.OpenObject(objectName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (s *Storage) ReadObject(objectName string) ([]byte, error) {
+	r, err := s.OpenObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (s *Storage) PutObject(objectName string, filePath string) (*lookaside.UploadInfo, error) {
+	f, err := s.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return s.upload(objectName, f)
+}
+
+func (s *Storage) PutObjectBytes(objectName string, content []byte) (*lookaside.UploadInfo, error) {
+	return s.upload(objectName, bytes.NewReader(content))
+}
+
+func (s *Storage) DeleteObject(objectName string) error {
+	return s.bucket.Object(objectName).Delete(context.Background())
+}
+
+func (s *Storage) Write(path string, content []byte) error {
+	_, err := s.PutObjectBytes(path, content)
+	return err
+}
+
+func (s *Storage) Read(path string) ([]byte, error) {
+	return s.ReadObject(path)
+}
+
+func (s *Storage) Exists(path string) (bool, error) {
+	_, err := s.bucket.Object(path).Attrs(context.Background())
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Storage) PresignObject(objectName string, expiry time.Duration) (string, error) {
+	return s.bucket.SignedURL(objectName, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(expiry),
+	})
+}
+
+func (s *Storage) OpenObject(objectName string) (io.ReadCloser, error) {
+	return s.bucket.Object(objectName).NewReader(context.Background())
+}
@@ -30,6 +30,11 @@
 
 package lookaside
 
+import (
+	"io"
+	"time"
+)
+
 type UploadInfo struct {
 	Location  string
 	VersionID *string
@@ -48,3 +53,15 @@ type Storage interface {
 	Read(path string) ([]byte, error)
 	Exists(path string) (bool, error)
 }
+
+// Presigner is implemented by backends that can hand out temporary
+// public URLs for objects, so large objects can be served by redirecting
+type Presigner interface {
+	PresignObject(objectName string, expiry time.Duration) (string, error)
+}
+
+// ObjectOpener is implemented by backends that can stream objects
+// instead of reading them into memory
+type ObjectOpener interface {
+	OpenObject(objectName string) (io.ReadCloser, error)
+}
@@ -41,9 +41,11 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/go-git/go-billy/v5"
 	"github.com/spf13/viper"
+	"io"
 	"io/ioutil"
 	"os"
 	"peridot.resf.org/peridot/lookaside"
+	"time"
 )
 
 type Storage struct {
@@ -199,3 +201,24 @@ func (s *Storage) Exists(path string) (bool, error) {
 
 	return true, nil
 }
+
+func (s *Storage) PresignObject(objectName string, expiry time.Duration) (string, error) {
+	req, _ := s.uploader.S3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectName),
+	})
+
+	return req.Presign(expiry)
+}
+
+func (s *Storage) OpenObject(objectName string) (io.ReadCloser, error) {
+	obj, err := s.uploader.S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.Body, nil
+}
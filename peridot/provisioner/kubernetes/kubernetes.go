@@ -0,0 +1,393 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/viper"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"os"
+	"peridot.resf.org/peridot/provisioner"
+	"peridot.resf.org/utils"
+	"strings"
+	"time"
+)
+
+// Provisioner runs workers as pods in the namespace of the service
+type Provisioner struct{}
+
+func New() *Provisioner {
+	return &Provisioner{}
+}
+
+func (p *Provisioner) pods() (corev1.PodInterface, error) {
+	// creates the in-cluster config
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientSet.CoreV1().Pods(utils.GetKubeNS()), nil
+}
+
+// Create creates a new pod with the specified container (the container has to contain peridotbuilder)
+func (p *Provisioner) Create(ctx context.Context, worker *provisioner.Worker) error {
+	if worker.Image == "" {
+		return fmt.Errorf("could not find builder-oci-image for arch %s", worker.Arch)
+	}
+
+	podInterface, err := p.pods()
+	if err != nil {
+		return err
+	}
+
+	imagePullPolicy := v1.PullIfNotPresent
+	if !viper.GetBool("production") {
+		imagePullPolicy = v1.PullAlways
+	}
+
+	var imagePullSecrets []v1.LocalObjectReference
+	if secret := os.Getenv("IMAGE_PULL_SECRET"); secret != "" {
+		imagePullSecrets = []v1.LocalObjectReference{
+			{
+				Name: secret,
+			},
+		}
+	}
+
+	cpu := provisioner.DefaultCPU
+	memory := provisioner.DefaultMemory
+	ephemeralStorage := provisioner.DefaultEphemeralStorage
+	if worker.Resources != nil {
+		if worker.Resources.CPU != "" {
+			cpu = worker.Resources.CPU
+		}
+		if worker.Resources.Memory != "" {
+			memory = worker.Resources.Memory
+		}
+		if worker.Resources.EphemeralStorage != "" {
+			ephemeralStorage = worker.Resources.EphemeralStorage
+		}
+	}
+
+	memoryQuantity, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return err
+	}
+	ephemeralQuantity, err := resource.ParseQuantity(ephemeralStorage)
+	if err != nil {
+		return err
+	}
+	cpuQuantity, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return err
+	}
+	resourceRequirements := v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:              cpuQuantity,
+			v1.ResourceMemory:           memoryQuantity,
+			v1.ResourceEphemeralStorage: ephemeralQuantity,
+		},
+		// Enforce the profile so a worker exceeding it is terminated (and retried
+		// with a larger profile) instead of starving other workers on the node.
+		// CPU is left unlimited, it's throttled rather than exhausted
+		Limits: v1.ResourceList{
+			v1.ResourceMemory:           memoryQuantity,
+			v1.ResourceEphemeralStorage: ephemeralQuantity,
+		},
+	}
+
+	// For now disable resource requirements in dev
+	if os.Getenv("LOCALSTACK_ENDPOINT") != "" || !worker.HighResource {
+		resourceRequirements = v1.ResourceRequirements{}
+	}
+
+	var env []v1.EnvVar
+	for _, e := range worker.Env {
+		envSplit := strings.SplitN(e, "=", 2)
+		if len(envSplit) != 2 {
+			continue
+		}
+		env = append(env, v1.EnvVar{
+			Name:  envSplit[0],
+			Value: envSplit[1],
+		})
+	}
+
+	command := fmt.Sprintf("/bundle/peridotbuilder_%s", worker.GoArch)
+	podConfig := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      worker.Name,
+			Namespace: utils.GetKubeNS(),
+			Labels: map[string]string{
+				"peridot.rockylinux.org/managed-by":              "peridotephemeral",
+				"peridot.rockylinux.org/task-id":                 worker.TaskId,
+				"peridot.rockylinux.org/name":                    worker.Name,
+				"peridot.rockylinux.org/workflow-tolerates-arch": worker.PoolArch,
+				// todo(mustafa): Implement janitor (cron workflow?)
+				"janitor.peridot.rockylinux.org/allow-cleanup":   "yes",
+				"janitor.peridot.rockylinux.org/cleanup-timeout": "864000s",
+			},
+			Annotations: map[string]string{
+				"cluster-autoscaler.kubernetes.io/safe-to-evict": "false",
+			},
+		},
+		Spec: v1.PodSpec{
+			ServiceAccountName: os.Getenv("RESF_SERVICE_ACCOUNT"),
+			ImagePullSecrets:   imagePullSecrets,
+			Containers: []v1.Container{
+				{
+					Name:  "builder",
+					Image: worker.Image,
+					Args:  []string{command},
+					Env:   env,
+					// Defaulting to 1cpu/4GiB for packages without a resource profile
+					Resources:       resourceRequirements,
+					ImagePullPolicy: imagePullPolicy,
+					VolumeMounts: []v1.VolumeMount{
+						{
+							Name:      "urandom",
+							MountPath: "/dev/random",
+						},
+						{
+							Name:      "security-limits",
+							MountPath: "/etc/security/limits.conf",
+						},
+					},
+				},
+			},
+			Volumes: []v1.Volume{
+				{
+					Name: "urandom",
+					VolumeSource: v1.VolumeSource{
+						HostPath: &v1.HostPathVolumeSource{
+							Path: "/dev/urandom",
+						},
+					},
+				},
+				{
+					Name: "security-limits",
+					VolumeSource: v1.VolumeSource{
+						HostPath: &v1.HostPathVolumeSource{
+							Path: "/etc/security/limits.conf",
+						},
+					},
+				},
+			},
+			Tolerations: []v1.Toleration{
+				{
+					Key:      "peridot.rockylinux.org/workflow-tolerates-arch",
+					Operator: v1.TolerationOpEqual,
+					Value:    worker.PoolArch,
+					Effect:   v1.TaintEffectNoSchedule,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyOnFailure,
+		},
+	}
+	if worker.Resources != nil && len(worker.Resources.NodeSelector) > 0 {
+		podConfig.Spec.NodeSelector = worker.Resources.NodeSelector
+	}
+	if os.Getenv("PERIDOT_SITE") == "extarches" {
+		podConfig.Spec.DNSPolicy = v1.DNSNone
+		podConfig.Spec.DNSConfig = &v1.PodDNSConfig{
+			Nameservers: []string{
+				"1.1.1.1",
+				"1.0.0.1",
+			},
+			Searches: []string{
+				"peridotephemeral.svc.cluster.local",
+			},
+		}
+	}
+
+	// Build containers may require a privileged container
+	// to do necessary mounting within the chroot.
+	// The privileges are dropped soon after
+	if worker.Privileged {
+		podConfig.Spec.Containers[0].SecurityContext = &v1.SecurityContext{
+			RunAsUser:                utils.Pointer[int64](0),
+			RunAsGroup:               utils.Pointer[int64](0),
+			RunAsNonRoot:             utils.Pointer[bool](false),
+			ReadOnlyRootFilesystem:   utils.Pointer[bool](false),
+			AllowPrivilegeEscalation: utils.Pointer[bool](true),
+			Privileged:               utils.Pointer[bool](true),
+		}
+	}
+
+	// Set node toleration to the specific arch
+	if !viper.GetBool("k8s-supports-cross-platform-no-affinity") {
+		podConfig.Spec.Affinity = &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{
+									Key:      "peridot.rockylinux.org/workflow-tolerates-arch",
+									Operator: "In",
+									Values:   []string{worker.PoolArch},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	pod, err := podInterface.Create(ctx, podConfig, metav1.CreateOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return nil
+		}
+		return fmt.Errorf("could not create pod: %v", err)
+	}
+
+	for {
+		pod, err = podInterface.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not get pod: %v", err)
+		}
+
+		if pod.Status.Phase == v1.PodFailed {
+			return provisioner.ErrWorkerFailed
+		}
+		if pod.Status.Phase == v1.PodRunning {
+			break
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	// The pod may fail right after it starts running
+	pod, err = podInterface.Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get pod: %v", err)
+	}
+	if pod.Status.Phase == v1.PodFailed {
+		return fmt.Errorf("pod failed")
+	}
+
+	return nil
+}
+
+// Delete deletes the pod that hosts the worker
+func (p *Provisioner) Delete(ctx context.Context, name string) error {
+	podInterface, err := p.pods()
+	if err != nil {
+		return err
+	}
+
+	err = podInterface.Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Exists(ctx context.Context, name string) (bool, error) {
+	podInterface, err := p.pods()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = podInterface.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TerminationReason inspects the pod status for evictions, deadlines and OOM kills.
+// The builder container restarts on failure, so the last termination state is checked as well
+func (p *Provisioner) TerminationReason(ctx context.Context, name string) (string, error) {
+	podInterface, err := p.pods()
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := podInterface.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return "", nil
+		}
+		return "", err
+	}
+
+	switch pod.Status.Reason {
+	case provisioner.ReasonEvicted,
+		provisioner.ReasonDeadlineExceeded:
+		return pod.Status.Reason, nil
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == provisioner.ReasonOOMKilled {
+				return provisioner.ReasonOOMKilled, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func (p *Provisioner) Logs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error) {
+	podInterface, err := p.pods()
+	if err != nil {
+		return nil, err
+	}
+
+	sinceTime := metav1.NewTime(since)
+	logReq := podInterface.GetLogs(name, &v1.PodLogOptions{
+		Container: "builder",
+		Follow:    true,
+		SinceTime: &sinceTime,
+	})
+
+	return logReq.Stream(ctx)
+}
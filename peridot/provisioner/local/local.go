@@ -0,0 +1,258 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package local
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"peridot.resf.org/peridot/provisioner"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type process struct {
+	cmd     *exec.Cmd
+	logPath string
+	done    chan struct{}
+}
+
+// Provisioner runs workers as peridotbuilder processes on the same host.
+// Workers are only tracked in memory, so the provisioner has to run in
+// the same process as the activities that delete workers and ingest logs.
+type Provisioner struct {
+	binary  string
+	workDir string
+
+	lock      sync.Mutex
+	processes map[string]*process
+}
+
+// New returns a provisioner running the given peridotbuilder binary.
+// Worker logs are written to workDir.
+func New(binary string, workDir string) (*Provisioner, error) {
+	if binary == "" {
+		binary = "peridotbuilder"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("could not find builder binary %s: %v", binary, err)
+	}
+	if workDir == "" {
+		workDir = filepath.Join(os.TempDir(), "peridot-workers")
+	}
+	err = os.MkdirAll(workDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provisioner{
+		binary:    path,
+		workDir:   workDir,
+		processes: map[string]*process{},
+	}, nil
+}
+
+func (p *Provisioner) Create(_ context.Context, worker *provisioner.Worker) error {
+	// The builder runs on the host, so only the host architecture can be built
+	if worker.GoArch != runtime.GOARCH {
+		return fmt.Errorf("local workers can only build for %s, requested %s", runtime.GOARCH, worker.GoArch)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if proc, ok := p.processes[worker.Name]; ok {
+		select {
+		case <-proc.done:
+			delete(p.processes, worker.Name)
+		default:
+			return nil
+		}
+	}
+
+	logPath := filepath.Join(p.workDir, fmt.Sprintf("%s.log", worker.Name))
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("could not create log file: %v", err)
+	}
+
+	output := newTimestampWriter(logFile)
+
+	cmd := exec.Command(p.binary)
+	cmd.Env = append(os.Environ(), worker.Env...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Don't forward signals meant for the provisioner to the workers
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err = cmd.Start()
+	if err != nil {
+		_ = output.Close()
+		return fmt.Errorf("could not start worker: %v", err)
+	}
+
+	proc := &process{
+		cmd:     cmd,
+		logPath: logPath,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logrus.Errorf("worker %s exited: %v", worker.Name, err)
+		}
+		_ = output.Close()
+		close(proc.done)
+	}()
+	p.processes[worker.Name] = proc
+
+	// Give the worker a moment to fail on misconfiguration
+	select {
+	case <-proc.done:
+		if cmd.ProcessState.ExitCode() != 0 {
+			return provisioner.ErrWorkerFailed
+		}
+	case <-time.After(time.Second):
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Delete(_ context.Context, name string) error {
+	p.lock.Lock()
+	proc, ok := p.processes[name]
+	delete(p.processes, name)
+	p.lock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-proc.done:
+	default:
+		_ = proc.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-proc.done:
+		case <-time.After(30 * time.Second):
+			_ = proc.cmd.Process.Kill()
+			<-proc.done
+		}
+	}
+
+	return os.Remove(proc.logPath)
+}
+
+func (p *Provisioner) Exists(_ context.Context, name string) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	proc, ok := p.processes[name]
+	if !ok {
+		return false, nil
+	}
+	select {
+	case <-proc.done:
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+func (p *Provisioner) Logs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error) {
+	p.lock.Lock()
+	proc, ok := p.processes[name]
+	p.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("worker %s not found", name)
+	}
+
+	f, err := os.Open(proc.logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer f.Close()
+		reader := bufio.NewReader(f)
+		var partial string
+		exited := false
+		for {
+			line, err := reader.ReadString('\n')
+			partial += line
+			if err == io.EOF {
+				if exited {
+					_ = pw.Close()
+					return
+				}
+				select {
+				case <-ctx.Done():
+					_ = pw.CloseWithError(ctx.Err())
+					return
+				case <-proc.done:
+					// The log is complete once the process exited,
+					// read the rest of it before closing
+					exited = true
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+
+			timestamp, text, found := strings.Cut(partial, " ")
+			partial = ""
+			if !found {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, timestamp)
+			if err != nil || t.Before(since) {
+				continue
+			}
+			_, err = io.WriteString(pw, text)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return pr, nil
+}
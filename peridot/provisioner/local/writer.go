@@ -0,0 +1,98 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package local
+
+import (
+	"bufio"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// timestampWriter prefixes every line written to the worker log with
+// the time it was written at, so logs can be followed from a given time
+type timestampWriter struct {
+	lock   sync.Mutex
+	file   *os.File
+	pw     *io.PipeWriter
+	closed chan struct{}
+}
+
+func newTimestampWriter(file *os.File) *timestampWriter {
+	pr, pw := io.Pipe()
+	w := &timestampWriter{
+		file:   file,
+		pw:     pw,
+		closed: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.closed)
+		defer file.Close()
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		var err error
+		for scanner.Scan() {
+			_, err = file.WriteString(time.Now().UTC().Format(time.RFC3339Nano) + " " + scanner.Text() + "\n")
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = scanner.Err()
+		}
+		if err != nil {
+			// Keep reading so the worker doesn't block on a full pipe
+			logrus.Errorf("could not write worker log %s: %v", file.Name(), err)
+			_, _ = io.Copy(io.Discard, pr)
+		}
+	}()
+
+	return w
+}
+
+// Write is called from both stdout and stderr of the worker
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.pw.Write(p)
+}
+
+// Close flushes the remaining output and closes the log file
+func (w *timestampWriter) Close() error {
+	err := w.pw.Close()
+	<-w.closed
+
+	return err
+}
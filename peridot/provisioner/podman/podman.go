@@ -0,0 +1,221 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package podman
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"os/exec"
+	"peridot.resf.org/peridot/provisioner"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provisioner runs workers as podman containers on the same host.
+// Containers use the host network, so workers can reach services
+// listening on localhost (e.g. a Temporal dev server)
+type Provisioner struct {
+	binary string
+}
+
+// New returns a provisioner using the given podman binary
+func New(binary string) (*Provisioner, error) {
+	if binary == "" {
+		binary = "podman"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("could not find podman binary %s: %v", binary, err)
+	}
+
+	return &Provisioner{
+		binary: path,
+	}, nil
+}
+
+func (p *Provisioner) podman(ctx context.Context, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("podman %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (p *Provisioner) Create(ctx context.Context, worker *provisioner.Worker) error {
+	if worker.Image == "" {
+		return fmt.Errorf("could not find builder-oci-image for arch %s", worker.Arch)
+	}
+
+	exists, err := p.Exists(ctx, worker.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	args := []string{
+		"run",
+		"--detach",
+		"--name", worker.Name,
+		"--network", "host",
+		"--arch", worker.GoArch,
+		"--label", "peridot.rockylinux.org/managed-by=peridotephemeral",
+		"--label", "peridot.rockylinux.org/task-id=" + worker.TaskId,
+	}
+	if worker.Privileged {
+		args = append(args, "--privileged", "--user", "0:0")
+	}
+	// Storage limits require quota support from the storage driver, so only CPU and memory are limited
+	if worker.HighResource && worker.Resources != nil {
+		if worker.Resources.CPU != "" {
+			cpu, err := resource.ParseQuantity(worker.Resources.CPU)
+			if err != nil {
+				return fmt.Errorf("invalid cpu quantity: %v", err)
+			}
+			args = append(args, "--cpus", strconv.FormatFloat(cpu.AsApproximateFloat64(), 'f', -1, 64))
+		}
+		if worker.Resources.Memory != "" {
+			memory, err := resource.ParseQuantity(worker.Resources.Memory)
+			if err != nil {
+				return fmt.Errorf("invalid memory quantity: %v", err)
+			}
+			args = append(args, "--memory", strconv.FormatInt(memory.Value(), 10))
+		}
+	}
+	for _, env := range worker.Env {
+		args = append(args, "--env", env)
+	}
+	args = append(args, worker.Image, fmt.Sprintf("/bundle/peridotbuilder_%s", worker.GoArch))
+
+	_, err = p.podman(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		state, err := p.podman(ctx, "inspect", "--format", "{{.State.Status}} {{.State.ExitCode}}", worker.Name)
+		if err != nil {
+			return err
+		}
+		switch state {
+		case "running 0":
+			return nil
+		case "exited 0":
+			// The worker may already be done
+			return nil
+		}
+		if strings.HasPrefix(state, "exited") {
+			return provisioner.ErrWorkerFailed
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+func (p *Provisioner) Delete(ctx context.Context, name string) error {
+	_, err := p.podman(ctx, "rm", "--force", "--ignore", name)
+	return err
+}
+
+func (p *Provisioner) Exists(ctx context.Context, name string) (bool, error) {
+	err := exec.CommandContext(ctx, p.binary, "container", "exists", name).Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// podman container exists exits with 1 if the container doesn't exist
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TerminationReason reports whether the container was killed by the OOM killer.
+// Eviction and deadlines don't exist for podman containers
+func (p *Provisioner) TerminationReason(ctx context.Context, name string) (string, error) {
+	exists, err := p.Exists(ctx, name)
+	if err != nil || !exists {
+		return "", err
+	}
+
+	oomKilled, err := p.podman(ctx, "inspect", "--format", "{{.State.OOMKilled}}", name)
+	if err != nil {
+		return "", err
+	}
+	if oomKilled == "true" {
+		return provisioner.ReasonOOMKilled, nil
+	}
+
+	return "", nil
+}
+
+// logStream kills the podman logs process once closed
+type logStream struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (l *logStream) Close() error {
+	_ = l.cmd.Process.Kill()
+	return l.ReadCloser.Close()
+}
+
+func (p *Provisioner) Logs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	cmd := exec.CommandContext(ctx, p.binary, "logs", "--follow", "--since", since.Format(time.RFC3339), name)
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	err := cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = pw.CloseWithError(cmd.Wait())
+	}()
+
+	return &logStream{
+		ReadCloser: pr,
+		cmd:        cmd,
+	}, nil
+}
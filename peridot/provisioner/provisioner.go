@@ -0,0 +1,117 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrWorkerFailed is returned by Create if the worker failed to start
+	ErrWorkerFailed = errors.New("worker failed")
+)
+
+// Reasons a worker may be terminated for, as reported by TerminationReasoner
+const (
+	ReasonOOMKilled        = "OOMKilled"
+	ReasonEvicted          = "Evicted"
+	ReasonDeadlineExceeded = "DeadlineExceeded"
+)
+
+// Defaults requested by high resource workers without a resource profile
+const (
+	DefaultCPU              = "1"
+	DefaultMemory           = "4Gi"
+	DefaultEphemeralStorage = "10Gi"
+)
+
+// Resources requested for a worker. Empty values use the defaults of the provisioner.
+// Quantities use the Kubernetes notation (for example "500m" or "8Gi")
+type Resources struct {
+	CPU              string            `json:"cpu"`
+	Memory           string            `json:"memory"`
+	EphemeralStorage string            `json:"ephemeralStorage"`
+	NodeSelector     map[string]string `json:"nodeSelector"`
+}
+
+// Worker is an ephemeral peridotbuilder instance.
+// The worker listens on the task queue with the same name as the worker,
+// and is destroyed once the workflow that requested it is done.
+type Worker struct {
+	Name    string
+	Purpose string
+	TaskId  string
+
+	// Arch of the builder image (e.g. x86_64)
+	Arch string
+	// GoArch of the builder binary (e.g. amd64)
+	GoArch string
+	// PoolArch is the build pool the worker should be scheduled on
+	PoolArch string
+
+	// Image is the builder OCI image, used by container based provisioners
+	Image string
+	// Env is passed to the worker as is (in KEY=value form)
+	Env []string
+
+	HighResource bool
+	Privileged   bool
+	// Resources overrides the defaults of high resource workers
+	Resources *Resources
+}
+
+// Provisioner creates and destroys ephemeral workers
+type Provisioner interface {
+	// Create creates a new worker and returns once the worker is running.
+	// Creating a worker that already exists is not an error.
+	Create(ctx context.Context, worker *Worker) error
+
+	// Delete destroys a worker. Deleting a worker that doesn't exist is not an error.
+	Delete(ctx context.Context, name string) error
+
+	// Exists returns whether the worker still exists
+	Exists(ctx context.Context, name string) (bool, error)
+
+	// Logs follows the output of a worker starting at since.
+	// The returned reader reaches EOF once the worker stops.
+	Logs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error)
+}
+
+// TerminationReasoner is implemented by provisioners that can tell
+// why a worker was terminated, for example to retry with more resources
+type TerminationReasoner interface {
+	// TerminationReason returns one of the Reason constants if the worker
+	// was terminated, or an empty string if it's still healthy
+	TerminationReason(ctx context.Context, name string) (string, error)
+}
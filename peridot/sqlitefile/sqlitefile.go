@@ -0,0 +1,760 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package sqlitefile writes read-only SQLite 3 database files without cgo.
+//
+// Only what's needed to produce static databases is supported. Tables
+// (with an optional INTEGER PRIMARY KEY), indexes and triggers can be created,
+// and the whole database is serialized at once. The schema SQL is stored as
+// given and is only interpreted by the SQLite library reading the file.
+package sqlitefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	pageSize = 4096
+	// sqliteVersion is the library version written to the header
+	sqliteVersion = 3039002
+
+	pageTypeIndexInterior = 0x02
+	pageTypeTableInterior = 0x05
+	pageTypeIndexLeaf     = 0x0a
+	pageTypeTableLeaf     = 0x0d
+)
+
+// Database is an in-memory database that can be serialized with Bytes
+type Database struct {
+	tables   []*Table
+	triggers []*trigger
+}
+
+// Table is a rowid table.
+// Supported values are nil, int, int64, bool, float64, string and []byte.
+type Table struct {
+	name        string
+	sql         string
+	rowidColumn int
+	rows        []row
+	indexes     []*index
+}
+
+type row struct {
+	rowid  int64
+	values []interface{}
+}
+
+type index struct {
+	name    string
+	sql     string
+	columns []int
+}
+
+type trigger struct {
+	name  string
+	table string
+	sql   string
+}
+
+func New() *Database {
+	return &Database{}
+}
+
+// CreateTable adds a table to the database.
+// rowidColumn is the index of the INTEGER PRIMARY KEY column, or -1 if
+// the table doesn't have one.
+func (d *Database) CreateTable(name string, sql string, rowidColumn int) *Table {
+	t := &Table{
+		name:        name,
+		sql:         sql,
+		rowidColumn: rowidColumn,
+	}
+	d.tables = append(d.tables, t)
+
+	return t
+}
+
+// CreateTrigger adds a trigger to the database
+func (d *Database) CreateTrigger(name string, table string, sql string) {
+	d.triggers = append(d.triggers, &trigger{
+		name:  name,
+		table: table,
+		sql:   sql,
+	})
+}
+
+// CreateIndex adds an index over given column indexes to the table
+func (t *Table) CreateIndex(name string, sql string, columns ...int) {
+	t.indexes = append(t.indexes, &index{
+		name:    name,
+		sql:     sql,
+		columns: columns,
+	})
+}
+
+// Insert adds a row to the table.
+// If the table has an INTEGER PRIMARY KEY, its value is used as the rowid
+// and must be unique. Otherwise rowids are assigned sequentially.
+func (t *Table) Insert(values ...interface{}) error {
+	rowid := int64(len(t.rows) + 1)
+	if t.rowidColumn >= 0 {
+		v, ok := normalize(values[t.rowidColumn]).(int64)
+		if !ok {
+			return fmt.Errorf("primary key of %s must be an integer", t.name)
+		}
+		rowid = v
+	}
+
+	normalized := make([]interface{}, len(values))
+	for i, value := range values {
+		normalized[i] = normalize(value)
+	}
+	t.rows = append(t.rows, row{
+		rowid:  rowid,
+		values: normalized,
+	})
+
+	return nil
+}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return int64(0)
+	default:
+		return value
+	}
+}
+
+// Bytes serializes the database
+func (d *Database) Bytes() ([]byte, error) {
+	w := &writer{}
+	// Reserve the first page for the header and schema table
+	w.alloc()
+
+	var schema []row
+	addSchema := func(values ...interface{}) {
+		schema = append(schema, row{
+			rowid:  int64(len(schema) + 1),
+			values: values,
+		})
+	}
+
+	for _, t := range d.tables {
+		rows := make([]row, len(t.rows))
+		copy(rows, t.rows)
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].rowid < rows[j].rowid
+		})
+		for i := 1; i < len(rows); i++ {
+			if rows[i].rowid == rows[i-1].rowid {
+				return nil, fmt.Errorf("duplicate primary key %d in %s", rows[i].rowid, t.name)
+			}
+		}
+
+		var cells []tableCell
+		for _, r := range rows {
+			values := r.values
+			if t.rowidColumn >= 0 {
+				// The INTEGER PRIMARY KEY is an alias for the rowid
+				// and is stored as NULL in the record
+				values = make([]interface{}, len(r.values))
+				copy(values, r.values)
+				values[t.rowidColumn] = nil
+			}
+			cells = append(cells, tableCell{
+				rowid:   r.rowid,
+				payload: encodeRecord(values),
+			})
+		}
+		root, err := w.buildTable(cells, false)
+		if err != nil {
+			return nil, err
+		}
+		addSchema("table", t.name, t.name, int64(root), t.sql)
+
+		for _, idx := range t.indexes {
+			var keys [][]interface{}
+			for _, r := range rows {
+				var key []interface{}
+				for _, column := range idx.columns {
+					key = append(key, r.values[column])
+				}
+				key = append(key, r.rowid)
+				keys = append(keys, key)
+			}
+			sort.SliceStable(keys, func(i, j int) bool {
+				return compareKeys(keys[i], keys[j]) < 0
+			})
+
+			var payloads [][]byte
+			for _, key := range keys {
+				payloads = append(payloads, encodeRecord(key))
+			}
+			root, err := w.buildIndex(payloads)
+			if err != nil {
+				return nil, err
+			}
+			addSchema("index", idx.name, t.name, int64(root), idx.sql)
+		}
+	}
+	for _, t := range d.triggers {
+		addSchema("trigger", t.name, t.table, int64(0), t.sql)
+	}
+
+	// The schema table is always rooted at page 1
+	var schemaCells []tableCell
+	for _, r := range schema {
+		schemaCells = append(schemaCells, tableCell{
+			rowid:   r.rowid,
+			payload: encodeRecord(r.values),
+		})
+	}
+	if _, err := w.buildTable(schemaCells, true); err != nil {
+		return nil, err
+	}
+	w.writeHeader()
+
+	var buf bytes.Buffer
+	for _, p := range w.pages {
+		buf.Write(p)
+	}
+
+	return buf.Bytes(), nil
+}
+
+type writer struct {
+	pages [][]byte
+}
+
+type tableCell struct {
+	rowid   int64
+	payload []byte
+}
+
+// alloc allocates a new page and returns its (1-based) number
+func (w *writer) alloc() (uint32, []byte) {
+	page := make([]byte, pageSize)
+	w.pages = append(w.pages, page)
+	return uint32(len(w.pages)), page
+}
+
+func (w *writer) writeHeader() {
+	h := w.pages[0]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], pageSize)
+	h[18] = 1 // file format write version (legacy)
+	h[19] = 1 // file format read version (legacy)
+	h[20] = 0 // reserved space per page
+	h[21] = 64
+	h[22] = 32
+	h[23] = 32
+	binary.BigEndian.PutUint32(h[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(h[28:], uint32(len(w.pages)))
+	binary.BigEndian.PutUint32(h[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(h[44:], 4) // schema format
+	binary.BigEndian.PutUint32(h[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(h[92:], 1) // version-valid-for
+	binary.BigEndian.PutUint32(h[96:], sqliteVersion)
+}
+
+// payloadLocal returns how much of a payload is stored on the b-tree page
+func payloadLocal(size int, maxLocal int) int {
+	usable := pageSize
+	minLocal := ((usable-12)*32)/255 - 23
+	if size <= maxLocal {
+		return size
+	}
+	k := minLocal + (size-minLocal)%(usable-4)
+	if k <= maxLocal {
+		return k
+	}
+	return minLocal
+}
+
+// writeOverflow stores the overflowing part of a payload and returns the first overflow page
+func (w *writer) writeOverflow(data []byte) uint32 {
+	var first uint32
+	var prev []byte
+	for len(data) > 0 {
+		n, page := w.alloc()
+		if prev == nil {
+			first = n
+		} else {
+			binary.BigEndian.PutUint32(prev, n)
+		}
+		chunk := len(data)
+		if chunk > pageSize-4 {
+			chunk = pageSize - 4
+		}
+		copy(page[4:], data[:chunk])
+		data = data[chunk:]
+		prev = page
+	}
+
+	return first
+}
+
+// payloadCellSize returns the size of the payload part of a cell
+func payloadCellSize(size int, maxLocal int) int {
+	local := payloadLocal(size, maxLocal)
+	ret := len(putVarint(uint64(size))) + local
+	if local < size {
+		ret += 4
+	}
+
+	return ret
+}
+
+// payloadCell returns the payload part of a cell, spilling to overflow pages if needed
+func (w *writer) payloadCell(payload []byte, maxLocal int) []byte {
+	var cell []byte
+	cell = append(cell, putVarint(uint64(len(payload)))...)
+	local := payloadLocal(len(payload), maxLocal)
+	cell = append(cell, payload[:local]...)
+	if local < len(payload) {
+		overflow := make([]byte, 4)
+		binary.BigEndian.PutUint32(overflow, w.writeOverflow(payload[local:]))
+		cell = append(cell, overflow...)
+	}
+
+	return cell
+}
+
+func (w *writer) tableLeafCell(rowid int64, payload []byte) []byte {
+	size := putVarint(uint64(len(payload)))
+	cell := w.payloadCell(payload, pageSize-35)
+	// payloadCell starts with the payload size, rowid goes right after
+	var ret []byte
+	ret = append(ret, size...)
+	ret = append(ret, putVarint(uint64(rowid))...)
+	ret = append(ret, cell[len(size):]...)
+
+	return ret
+}
+
+func indexMaxLocal() int {
+	return ((pageSize-12)*64)/255 - 23
+}
+
+// fits returns whether given cells fit on a page with given header
+func fits(offset int, headerSize int, cells [][]byte) bool {
+	size := offset + headerSize
+	for _, cell := range cells {
+		size += 2 + len(cell)
+	}
+
+	return size <= pageSize
+}
+
+// writePage writes a b-tree page with given cells
+func writePage(page []byte, offset int, pageType byte, cells [][]byte, rightChild uint32) {
+	headerSize := 8
+	if pageType == pageTypeTableInterior || pageType == pageTypeIndexInterior {
+		headerSize = 12
+	}
+
+	contentStart := pageSize
+	for i, cell := range cells {
+		contentStart -= len(cell)
+		copy(page[contentStart:], cell)
+		binary.BigEndian.PutUint16(page[offset+headerSize+i*2:], uint16(contentStart))
+	}
+
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+1:], 0)
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(contentStart))
+	page[offset+7] = 0
+	if headerSize == 12 {
+		binary.BigEndian.PutUint32(page[offset+8:], rightChild)
+	}
+}
+
+type child struct {
+	page   uint32
+	maxKey int64
+}
+
+// buildTable writes a table b-tree and returns its root page.
+// If firstPage is set, the root is written to page 1 after the file header.
+func (w *writer) buildTable(rows []tableCell, firstPage bool) (uint32, error) {
+	var cells [][]byte
+	for _, r := range rows {
+		cells = append(cells, w.tableLeafCell(r.rowid, r.payload))
+	}
+
+	// writeRoot writes the root page if all cells fit on it
+	writeRoot := func(pageType byte, headerSize int, cells [][]byte, rightChild uint32) (uint32, bool) {
+		offset := 0
+		if firstPage {
+			offset = 100
+		}
+		if !fits(offset, headerSize, cells) {
+			return 0, false
+		}
+		if firstPage {
+			writePage(w.pages[0], offset, pageType, cells, rightChild)
+			return 1, true
+		}
+		n, page := w.alloc()
+		writePage(page, 0, pageType, cells, rightChild)
+		return n, true
+	}
+	if root, ok := writeRoot(pageTypeTableLeaf, 8, cells, 0); ok {
+		return root, nil
+	}
+
+	var level []child
+	var pageCells [][]byte
+	for i, cell := range cells {
+		if len(pageCells) > 0 && !fits(0, 8, append(pageCells, cell)) {
+			n, page := w.alloc()
+			writePage(page, 0, pageTypeTableLeaf, pageCells, 0)
+			level = append(level, child{page: n, maxKey: rows[i-1].rowid})
+			pageCells = nil
+		}
+		pageCells = append(pageCells, cell)
+	}
+	n, page := w.alloc()
+	writePage(page, 0, pageTypeTableLeaf, pageCells, 0)
+	level = append(level, child{page: n, maxKey: rows[len(rows)-1].rowid})
+
+	for {
+		// The last child of every page is the right child, the rest are cells
+		var levelCells [][]byte
+		for _, c := range level[:len(level)-1] {
+			levelCells = append(levelCells, tableInteriorCell(c))
+		}
+		if root, ok := writeRoot(pageTypeTableInterior, 12, levelCells, level[len(level)-1].page); ok {
+			return root, nil
+		}
+
+		var next []child
+		for start := 0; start < len(level); {
+			end := start + 1
+			var pageCells [][]byte
+			for end < len(level) {
+				cell := levelCells[end-1]
+				if !fits(0, 12, append(pageCells, cell)) {
+					break
+				}
+				pageCells = append(pageCells, cell)
+				end++
+			}
+			// Don't leave a single child for the last page
+			if end == len(level)-1 && len(pageCells) > 1 {
+				pageCells = pageCells[:len(pageCells)-1]
+				end--
+			}
+
+			n, page := w.alloc()
+			writePage(page, 0, pageTypeTableInterior, pageCells, level[end-1].page)
+			next = append(next, child{page: n, maxKey: level[end-1].maxKey})
+			start = end
+		}
+		level = next
+	}
+}
+
+func tableInteriorCell(c child) []byte {
+	cell := make([]byte, 4)
+	binary.BigEndian.PutUint32(cell, c.page)
+	return append(cell, putVarint(uint64(c.maxKey))...)
+}
+
+// buildIndex writes an index b-tree from sorted keys and returns its root page.
+// Unlike table b-trees, keys in interior pages are not repeated in leaves.
+func (w *writer) buildIndex(payloads [][]byte) (uint32, error) {
+	var children []uint32
+	var dividers [][]byte
+
+	maxLocal := indexMaxLocal()
+
+	// Split keys into leaves first, every key following a full leaf
+	// moves up to the parent as a divider
+	var leaves [][]int
+	var leaf []int
+	used := 8
+	for i := 0; i < len(payloads); i++ {
+		size := 2 + payloadCellSize(len(payloads[i]), maxLocal)
+		if len(leaf) > 0 && used+size > pageSize {
+			leaves = append(leaves, leaf)
+			leaf = nil
+			used = 8
+			dividers = append(dividers, payloads[i])
+			continue
+		}
+		leaf = append(leaf, i)
+		used += size
+	}
+	if len(leaf) == 0 && len(leaves) > 0 {
+		// The last key became a divider without a right sibling.
+		// Move it into its own leaf and promote the previous key instead.
+		prev := leaves[len(leaves)-1]
+		leaf = []int{len(payloads) - 1}
+		dividers[len(dividers)-1] = payloads[prev[len(prev)-1]]
+		leaves[len(leaves)-1] = prev[:len(prev)-1]
+	}
+	leaves = append(leaves, leaf)
+
+	for _, leaf := range leaves {
+		var cells [][]byte
+		for _, i := range leaf {
+			cells = append(cells, w.payloadCell(payloads[i], maxLocal))
+		}
+		n, page := w.alloc()
+		writePage(page, 0, pageTypeIndexLeaf, cells, 0)
+		children = append(children, n)
+	}
+
+	for len(children) > 1 {
+		var nextChildren []uint32
+		var nextDividers [][]byte
+		for start := 0; start < len(children); {
+			end := start
+			used := 12
+			for end < len(dividers) {
+				size := 2 + 4 + payloadCellSize(len(dividers[end]), maxLocal)
+				if end > start && used+size > pageSize {
+					break
+				}
+				used += size
+				end++
+			}
+			// end is the right child of this page. If dividers are left,
+			// the divider after the right child moves up, but never
+			// leave a page without cells.
+			if end < len(dividers) && end+1 == len(dividers) && end-start > 1 {
+				end--
+			}
+
+			var pageCells [][]byte
+			for i := start; i < end; i++ {
+				pageCells = append(pageCells, indexInteriorCell(children[i], w.payloadCell(dividers[i], maxLocal)))
+			}
+			n, page := w.alloc()
+			writePage(page, 0, pageTypeIndexInterior, pageCells, children[end])
+			nextChildren = append(nextChildren, n)
+			if end < len(dividers) {
+				nextDividers = append(nextDividers, dividers[end])
+			}
+			start = end + 1
+		}
+		children = nextChildren
+		dividers = nextDividers
+	}
+
+	return children[0], nil
+}
+
+func indexInteriorCell(left uint32, payloadCell []byte) []byte {
+	cell := make([]byte, 4)
+	binary.BigEndian.PutUint32(cell, left)
+	return append(cell, payloadCell...)
+}
+
+// putVarint encodes v as an SQLite varint
+func putVarint(v uint64) []byte {
+	if v <= 0x7f {
+		return []byte{byte(v)}
+	}
+	if v > 0x00ffffffffffffff {
+		buf := make([]byte, 9)
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return buf
+	}
+
+	var tmp []byte
+	for v > 0 {
+		tmp = append(tmp, byte(v&0x7f))
+		v >>= 7
+	}
+	buf := make([]byte, len(tmp))
+	for i := range tmp {
+		buf[i] = tmp[len(tmp)-1-i]
+		if i < len(tmp)-1 {
+			buf[i] |= 0x80
+		}
+	}
+
+	return buf
+}
+
+// encodeRecord encodes values in the SQLite record format
+func encodeRecord(values []interface{}) []byte {
+	var header []byte
+	var body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = append(header, 0)
+		case int64:
+			serialType, b := encodeInt(v)
+			header = append(header, putVarint(serialType)...)
+			body = append(body, b...)
+		case float64:
+			header = append(header, 7)
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint64(b, math.Float64bits(v))
+			body = append(body, b...)
+		case string:
+			header = append(header, putVarint(uint64(len(v))*2+13)...)
+			body = append(body, v...)
+		case []byte:
+			header = append(header, putVarint(uint64(len(v))*2+12)...)
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("sqlitefile: unsupported value type %T", value))
+		}
+	}
+
+	// The header size includes the size varint itself
+	headerSize := len(header) + 1
+	for len(putVarint(uint64(headerSize)))+len(header) != headerSize {
+		headerSize = len(putVarint(uint64(headerSize))) + len(header)
+	}
+
+	var ret []byte
+	ret = append(ret, putVarint(uint64(headerSize))...)
+	ret = append(ret, header...)
+	return append(ret, body...)
+}
+
+func encodeInt(v int64) (uint64, []byte) {
+	switch {
+	case v == 0:
+		return 8, nil
+	case v == 1:
+		return 9, nil
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, []byte{byte(v)}
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, uint16(v))
+		return 2, b
+	case v >= -(1<<23) && v < 1<<23:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		return 3, b[1:]
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		return 4, b
+	case v >= -(1<<47) && v < 1<<47:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(v))
+		return 5, b[2:]
+	default:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(v))
+		return 6, b
+	}
+}
+
+// valueClass orders values the way SQLite does: NULL, numbers, text, blobs
+func valueClass(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func toFloat(value interface{}) float64 {
+	if v, ok := value.(int64); ok {
+		return float64(v)
+	}
+	return value.(float64)
+}
+
+// compareKeys compares index keys using the BINARY collation
+func compareKeys(a []interface{}, b []interface{}) int {
+	for i := range a {
+		ca, cb := valueClass(a[i]), valueClass(b[i])
+		if ca != cb {
+			return ca - cb
+		}
+
+		switch ca {
+		case 1:
+			ia, aInt := a[i].(int64)
+			ib, bInt := b[i].(int64)
+			if aInt && bInt {
+				if ia != ib {
+					if ia < ib {
+						return -1
+					}
+					return 1
+				}
+				continue
+			}
+			fa, fb := toFloat(a[i]), toFloat(b[i])
+			if fa != fb {
+				if fa < fb {
+					return -1
+				}
+				return 1
+			}
+		case 2:
+			if c := bytes.Compare([]byte(a[i].(string)), []byte(b[i].(string))); c != 0 {
+				return c
+			}
+		case 3:
+			if c := bytes.Compare(a[i].([]byte), b[i].([]byte)); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
@@ -66,6 +66,9 @@ export const ProjectForm = (props: ProjectFormProps) => {
   const [targetVendor, setTargetVendor] = React.useState<string>(
     props.project?.targetVendor ?? 'redhat'
   );
+  const [targetForge, setTargetForge] = React.useState<string>(
+    props.project?.targetForge || 'gitlab'
+  );
   const [followImportDist, setFollowImportDist] = React.useState(
     props.project?.followImportDist ?? false
   );
@@ -94,6 +97,10 @@ export const ProjectForm = (props: ProjectFormProps) => {
     setTargetVendor(event.target.value);
   };
 
+  const handleForgeChange = (event: React.ChangeEvent<HTMLInputElement>) => {
+    setTargetForge(event.target.value);
+  };
+
   const onSubmit = async (event: React.FormEvent<HTMLFormElement>) => {
     setSubmitting(true);
     setErrorMessage(null);
@@ -117,6 +124,7 @@ export const ProjectForm = (props: ProjectFormProps) => {
         followImportDist,
         gitMakePublic,
         targetVendor,
+        targetForge,
       }
     );
 
@@ -275,11 +283,27 @@ export const ProjectForm = (props: ProjectFormProps) => {
           </div>
           <div className="space-y-4 w-1/2">
             <p className="font-bold">Source control</p>
+            <TextField
+              select
+              sx={{ display: 'flex' }}
+              required
+              size="small"
+              label="Target forge"
+              id="targetForge"
+              name="targetForge"
+              value={targetForge}
+              onChange={handleForgeChange}
+            >
+              <MenuItem value="gitlab">GitLab</MenuItem>
+              <MenuItem value="forgejo">Forgejo</MenuItem>
+              <MenuItem value="gitea">Gitea</MenuItem>
+              <MenuItem value="github">GitHub</MenuItem>
+            </TextField>
             <TextField
               sx={{ display: 'flex' }}
               required
               size="small"
-              label="Target Gitlab host"
+              label="Target forge host"
               name="targetGitlabHost"
               defaultValue={props.project?.targetGitlabHost}
             />
@@ -73,6 +73,7 @@ type PrimaryRpmEntry struct {
 	Epoch string `xml:"epoch,attr,omitempty"`
 	Ver   string `xml:"ver,attr,omitempty"`
 	Rel   string `xml:"rel,attr,omitempty"`
+	Pre   string `xml:"pre,attr,omitempty"`
 }
 
 type PrimaryRpmEntries struct {
@@ -51,13 +51,14 @@ type RepoMdDataLocation struct {
 }
 
 type RepoMdData struct {
-	Type         string              `xml:"type,attr,omitempty"`
-	Checksum     *RepoMdDataChecksum `xml:"checksum,omitempty"`
-	OpenChecksum *RepoMdDataChecksum `xml:"open-checksum,omitempty"`
-	Location     *RepoMdDataLocation `xml:"location,omitempty"`
-	Timestamp    int64               `xml:"timestamp,omitempty"`
-	Size         int                 `xml:"size,omitempty"`
-	OpenSize     int                 `xml:"open-size,omitempty"`
+	Type            string              `xml:"type,attr,omitempty"`
+	Checksum        *RepoMdDataChecksum `xml:"checksum,omitempty"`
+	OpenChecksum    *RepoMdDataChecksum `xml:"open-checksum,omitempty"`
+	Location        *RepoMdDataLocation `xml:"location,omitempty"`
+	Timestamp       int64               `xml:"timestamp,omitempty"`
+	Size            int                 `xml:"size,omitempty"`
+	OpenSize        int                 `xml:"open-size,omitempty"`
+	DatabaseVersion int                 `xml:"database_version,omitempty"`
 }
 
 type RepoMdRoot struct {
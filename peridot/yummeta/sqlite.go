@@ -0,0 +1,319 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package yummeta
+
+import (
+	"path/filepath"
+	"peridot.resf.org/peridot/sqlitefile"
+	"strconv"
+	"strings"
+)
+
+// SqliteDatabaseVersion is the createrepo database version of the generated databases
+const SqliteDatabaseVersion = 10
+
+const (
+	sqliteDbInfoTable   = "CREATE TABLE db_info (dbversion INTEGER, checksum TEXT)"
+	sqlitePackagesTable = "CREATE TABLE packages (  pkgKey INTEGER PRIMARY KEY,  pkgId TEXT)"
+	sqlitePkgIdIndex    = "CREATE INDEX pkgId ON packages (pkgId)"
+)
+
+var sqliteDependencyTables = []string{
+	"requires",
+	"provides",
+	"conflicts",
+	"obsoletes",
+	"suggests",
+	"enhances",
+	"recommends",
+	"supplements",
+}
+
+// sqliteInt stores numeric metadata values as integers like createrepo does
+func sqliteInt(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value
+	}
+
+	return i
+}
+
+// sqliteText stores empty optional values as NULL
+func sqliteText(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
+
+func sqliteFileType(fileType string) string {
+	if fileType == "" {
+		return "file"
+	}
+
+	return fileType
+}
+
+func addDbInfo(db *sqlitefile.Database, checksum string) error {
+	return db.CreateTable("db_info", sqliteDbInfoTable, -1).Insert(SqliteDatabaseVersion, checksum)
+}
+
+// PrimarySqlite generates a primary database compatible with createrepo.
+// checksum is the checksum of the compressed primary.xml the database is generated from.
+func PrimarySqlite(primary *PrimaryRoot, checksum string) ([]byte, error) {
+	db := sqlitefile.New()
+	err := addDbInfo(db, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := db.CreateTable("packages", "CREATE TABLE packages (  pkgKey INTEGER PRIMARY KEY,  pkgId TEXT,  name TEXT,  arch TEXT,  version TEXT,  epoch TEXT,  release TEXT,  summary TEXT,  description TEXT,  url TEXT,  time_file INTEGER,  time_build INTEGER,  rpm_license TEXT,  rpm_vendor TEXT,  rpm_group TEXT,  rpm_buildhost TEXT,  rpm_sourcerpm TEXT,  rpm_header_start INTEGER,  rpm_header_end INTEGER,  rpm_packager TEXT,  size_package INTEGER,  size_installed INTEGER,  size_archive INTEGER,  location_href TEXT,  location_base TEXT,  checksum_type TEXT)", 0)
+	packages.CreateIndex("packagename", "CREATE INDEX packagename ON packages (name)", 2)
+	packages.CreateIndex("packageId", "CREATE INDEX packageId ON packages (pkgId)", 1)
+
+	files := db.CreateTable("files", "CREATE TABLE files (  name TEXT,  type TEXT,  pkgKey INTEGER)", -1)
+	files.CreateIndex("filenames", "CREATE INDEX filenames ON files (name)", 0)
+	files.CreateIndex("pkgfiles", "CREATE INDEX pkgfiles ON files (pkgKey)", 2)
+
+	dependencies := map[string]*sqlitefile.Table{}
+	var removals []string
+	removals = append(removals, "    DELETE FROM files WHERE pkgKey = old.pkgKey;")
+	for _, name := range sqliteDependencyTables {
+		extra := ""
+		if name == "requires" {
+			extra = ",  pre BOOLEAN DEFAULT FALSE"
+		}
+		table := db.CreateTable(name, "CREATE TABLE "+name+" (  name TEXT,  flags TEXT,  epoch TEXT,  version TEXT,  release TEXT,  pkgKey INTEGER "+extra+")", -1)
+		table.CreateIndex("pkg"+name, "CREATE INDEX pkg"+name+" on "+name+" (pkgKey)", 5)
+		table.CreateIndex(name+"name", "CREATE INDEX "+name+"name ON "+name+" (name)", 0)
+		dependencies[name] = table
+		removals = append(removals, "    DELETE FROM "+name+" WHERE pkgKey = old.pkgKey;")
+	}
+	db.CreateTrigger("removals", "packages", "CREATE TRIGGER removals AFTER DELETE ON packages  BEGIN"+strings.Join(removals, "")+"  END")
+
+	for i, pkg := range primary.Packages {
+		pkgKey := int64(i + 1)
+
+		version := &PrimaryPackageVersion{}
+		if pkg.Version != nil {
+			version = pkg.Version
+		}
+		checksum := &PrimaryPackageChecksum{}
+		if pkg.Checksum != nil {
+			checksum = pkg.Checksum
+		}
+		pkgTime := &PrimaryPackageTime{}
+		if pkg.Time != nil {
+			pkgTime = pkg.Time
+		}
+		size := &PrimaryPackageSize{}
+		if pkg.Size != nil {
+			size = pkg.Size
+		}
+		location := &PrimaryPackageLocation{}
+		if pkg.Location != nil {
+			location = pkg.Location
+		}
+		format := &PrimaryPackageFormat{}
+		if pkg.Format != nil {
+			format = pkg.Format
+		}
+		headerRange := &PrimaryRpmHeaderRange{}
+		if format.RpmHeaderRange != nil {
+			headerRange = format.RpmHeaderRange
+		}
+
+		err := packages.Insert(
+			pkgKey,
+			checksum.Value,
+			pkg.Name,
+			pkg.Arch,
+			version.Ver,
+			version.Epoch,
+			version.Rel,
+			pkg.Summary,
+			pkg.Description,
+			pkg.Url,
+			sqliteInt(pkgTime.File),
+			sqliteInt(pkgTime.Build),
+			format.RpmLicense,
+			format.RpmVendor,
+			format.RpmGroup,
+			format.RpmBuildHost,
+			format.RpmSourceRpm,
+			sqliteInt(headerRange.Start),
+			sqliteInt(headerRange.End),
+			pkg.Packager,
+			sqliteInt(size.Package),
+			sqliteInt(size.Installed),
+			sqliteInt(size.Archive),
+			location.Href,
+			nil,
+			checksum.Type,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range format.File {
+			err := files.Insert(file.Value, sqliteFileType(file.Type), pkgKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		entries := map[string]*PrimaryRpmEntries{
+			"requires":    format.RpmRequires,
+			"provides":    format.RpmProvides,
+			"conflicts":   format.RpmConflicts,
+			"obsoletes":   format.RpmObsoletes,
+			"suggests":    format.RpmSuggests,
+			"enhances":    format.RpmEnhances,
+			"recommends":  format.RpmRecommends,
+			"supplements": format.RpmSupplements,
+		}
+		for _, name := range sqliteDependencyTables {
+			if entries[name] == nil {
+				continue
+			}
+			for _, entry := range entries[name].RpmEntries {
+				values := []interface{}{
+					entry.Name,
+					sqliteText(entry.Flags),
+					sqliteText(entry.Epoch),
+					sqliteText(entry.Ver),
+					sqliteText(entry.Rel),
+					pkgKey,
+				}
+				if name == "requires" {
+					values = append(values, entry.Pre == "1" || entry.Pre == "true")
+				}
+				err := dependencies[name].Insert(values...)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return db.Bytes()
+}
+
+// FilelistsSqlite generates a filelists database compatible with createrepo.
+// checksum is the checksum of the compressed filelists.xml the database is generated from.
+func FilelistsSqlite(filelists *FilelistsRoot, checksum string) ([]byte, error) {
+	db := sqlitefile.New()
+	err := addDbInfo(db, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := db.CreateTable("packages", sqlitePackagesTable, 0)
+	packages.CreateIndex("pkgId", sqlitePkgIdIndex, 1)
+
+	filelist := db.CreateTable("filelist", "CREATE TABLE filelist (  pkgKey INTEGER,  dirname TEXT,  filenames TEXT,  filetypes TEXT)", -1)
+	filelist.CreateIndex("keyfile", "CREATE INDEX keyfile ON filelist (pkgKey)", 0)
+	filelist.CreateIndex("dirnames", "CREATE INDEX dirnames ON filelist (dirname)", 1)
+
+	db.CreateTrigger("remove_filelist", "packages", "CREATE TRIGGER remove_filelist AFTER DELETE ON packages  BEGIN    DELETE FROM filelist WHERE pkgKey = old.pkgKey;  END")
+
+	for i, pkg := range filelists.Packages {
+		pkgKey := int64(i + 1)
+		err := packages.Insert(pkgKey, pkg.PkgId)
+		if err != nil {
+			return nil, err
+		}
+
+		// Files are grouped by directory, keeping the order they first appear in
+		var dirnames []string
+		names := map[string][]string{}
+		types := map[string][]string{}
+		for _, file := range pkg.Files {
+			dirname, basename := filepath.Split(file.Value)
+			if len(dirname) > 1 {
+				dirname = strings.TrimSuffix(dirname, "/")
+			}
+			if _, ok := names[dirname]; !ok {
+				dirnames = append(dirnames, dirname)
+			}
+			names[dirname] = append(names[dirname], basename)
+			types[dirname] = append(types[dirname], sqliteFileType(file.Type)[:1])
+		}
+		for _, dirname := range dirnames {
+			err := filelist.Insert(pkgKey, dirname, strings.Join(names[dirname], "/"), strings.Join(types[dirname], ""))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return db.Bytes()
+}
+
+// OtherSqlite generates an other database compatible with createrepo.
+// checksum is the checksum of the compressed other.xml the database is generated from.
+func OtherSqlite(other *OtherRoot, checksum string) ([]byte, error) {
+	db := sqlitefile.New()
+	err := addDbInfo(db, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := db.CreateTable("packages", sqlitePackagesTable, 0)
+	packages.CreateIndex("pkgId", sqlitePkgIdIndex, 1)
+
+	changelog := db.CreateTable("changelog", "CREATE TABLE changelog (  pkgKey INTEGER,  author TEXT,  date INTEGER,  changelog TEXT)", -1)
+	changelog.CreateIndex("keychange", "CREATE INDEX keychange ON changelog (pkgKey)", 0)
+
+	db.CreateTrigger("remove_changelogs", "packages", "CREATE TRIGGER remove_changelogs AFTER DELETE ON packages  BEGIN    DELETE FROM changelog WHERE pkgKey = old.pkgKey;  END")
+
+	for i, pkg := range other.Packages {
+		pkgKey := int64(i + 1)
+		err := packages.Insert(pkgKey, pkg.PkgId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range pkg.Changelogs {
+			err := changelog.Insert(pkgKey, entry.Author, sqliteInt(entry.Date), entry.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return db.Bytes()
+}
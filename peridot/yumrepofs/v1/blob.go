@@ -36,20 +36,17 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/spf13/viper"
 	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"io/ioutil"
+	"peridot.resf.org/peridot/db/models"
 	yumrepofspb "peridot.resf.org/peridot/yumrepofs/pb"
 	"peridot.resf.org/utils"
 	"regexp"
 	"strings"
-	"time"
 )
 
 var (
@@ -67,14 +64,10 @@ func (s *Server) GetBlob(ctx context.Context, req *yumrepofspb.GetBlobRequest) (
 	}
 
 	if strings.HasSuffix(req.Blob, ".sqlite.gz") {
-		s3Req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
-			Bucket: aws.String(viper.GetString("s3-bucket")),
-			Key:    utils.StringP(fmt.Sprintf("sqlite-files/%s", req.Blob)),
-		})
-		urlStr, err := s3Req.Presign(24 * time.Hour)
+		urlStr, err := s.objectURL(fmt.Sprintf("sqlite-files/%s", req.Blob))
 		if err != nil {
-			s.log.Errorf("failed to presign s3 request: %v", err)
-			return nil, status.Error(codes.Internal, "failed to presign s3 request")
+			s.log.Errorf("failed to get object url: %v", err)
+			return nil, status.Error(codes.Internal, "failed to get object url")
 		}
 
 		header := metadata.Pairs("Location", urlStr)
@@ -100,6 +93,29 @@ func (s *Server) GetBlob(ctx context.Context, req *yumrepofspb.GetBlobRequest) (
 		return nil, ErrCouldNotFindRevision
 	}
 
+	data, contentType, err := RevisionBlob(revision, req.Blob)
+	if err != nil {
+		if err == ErrInvalidBlob {
+			return nil, err
+		}
+		return nil, utils.InternalError
+	}
+
+	return &httpbody.HttpBody{
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// RevisionBlob returns the content and content type of given metadata blob
+// (for example <revision>-PRIMARY.xml.gz) stored in a revision.
+// Blobs without the .gz suffix are decompressed.
+func RevisionBlob(revision *models.RepositoryRevision, blobName string) ([]byte, string, error) {
+	if !RegexBlob.MatchString(blobName) {
+		return nil, "", ErrInvalidBlob
+	}
+	blob := RegexBlob.FindStringSubmatch(blobName)
+
 	var dataB64 string
 	switch blob[2] {
 	case "PRIMARY":
@@ -115,13 +131,13 @@ func (s *Server) GetBlob(ctx context.Context, req *yumrepofspb.GetBlobRequest) (
 	case "UPDATEINFO":
 		dataB64 = revision.UpdateinfoXml
 	default:
-		return nil, ErrInvalidBlob
+		return nil, "", ErrInvalidBlob
 	}
 
 	contentType := "application/xml+gzip"
 	data, err := base64.StdEncoding.DecodeString(dataB64)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if blob[3] == ".yaml.gz" {
@@ -138,17 +154,14 @@ func (s *Server) GetBlob(ctx context.Context, req *yumrepofspb.GetBlobRequest) (
 		buf.Write(data)
 		r, err := gzip.NewReader(&buf)
 		if err != nil {
-			return nil, utils.InternalError
+			return nil, "", err
 		}
 
 		data, err = ioutil.ReadAll(r)
 		if err != nil {
-			return nil, utils.InternalError
+			return nil, "", err
 		}
 	}
 
-	return &httpbody.HttpBody{
-		ContentType: contentType,
-		Data:        data,
-	}, nil
+	return data, contentType, nil
 }
@@ -0,0 +1,339 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package yumrepofsv1
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"os"
+	"path/filepath"
+	peridotdb "peridot.resf.org/peridot/db"
+	"peridot.resf.org/peridot/db/models"
+	"peridot.resf.org/peridot/lookaside"
+	peridotpb "peridot.resf.org/peridot/pb"
+	"peridot.resf.org/peridot/yummeta"
+	"strings"
+)
+
+// Exporter writes repository revisions as plain yum repositories, so
+// they can be served by any web server or synced by mirrors.
+//
+// Every repository is exported to <dir>/<repo>/<arch>, which is a symlink
+// to a hidden directory named after the revision. New revisions are written
+// next to the current one, with unchanged RPMs hardlinked from the current tree,
+// and the symlink is swapped atomically once the new tree is complete.
+// The previous tree is kept around so in-flight syncs can complete.
+type Exporter struct {
+	log     *logrus.Logger
+	db      peridotdb.Access
+	storage lookaside.Storage
+}
+
+func NewExporter(db peridotdb.Access, storage lookaside.Storage) *Exporter {
+	return &Exporter{
+		log:     logrus.New(),
+		db:      db,
+		storage: storage,
+	}
+}
+
+// ExportProject exports given repositories and architectures of a project.
+// If repos or archs is empty, all repositories or architectures of the project are exported.
+func (e *Exporter) ExportProject(projectId string, repos []string, archs []string, dir string) error {
+	projects, err := e.db.ListProjects(&peridotpb.ProjectFilters{
+		Id: wrapperspb.String(projectId),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list projects: %v", err)
+	}
+	if len(projects) == 0 {
+		return fmt.Errorf("project %s not found", projectId)
+	}
+	project := projects[0]
+
+	if len(repos) == 0 {
+		repositories, err := e.db.FindRepositoriesForProject(projectId, nil, false)
+		if err != nil {
+			return fmt.Errorf("could not list repositories: %v", err)
+		}
+		for _, repo := range repositories {
+			repos = append(repos, repo.Name)
+		}
+	}
+	if len(archs) == 0 {
+		archs = project.Archs
+	}
+
+	for _, repo := range repos {
+		for _, arch := range archs {
+			exported, err := e.ExportRepository(projectId, repo, arch, dir)
+			if err != nil {
+				return fmt.Errorf("could not export %s/%s: %v", repo, arch, err)
+			}
+			if exported {
+				e.log.Infof("exported %s/%s", repo, arch)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ExportRepository exports the latest active revision of a repository.
+// Returns false if the export is already up to date or the repository
+// has no revisions for the architecture yet.
+func (e *Exporter) ExportRepository(projectId string, repoName string, arch string, dir string) (bool, error) {
+	revision, err := e.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(projectId, repoName, arch)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			e.log.Infof("skipping %s/%s, no revisions found", repoName, arch)
+			return false, nil
+		}
+		return false, fmt.Errorf("could not get latest revision: %v", err)
+	}
+
+	parent := filepath.Join(dir, repoName)
+	dest := filepath.Join(parent, arch)
+	treeName := fmt.Sprintf(".%s-%s", arch, revision.ID.String())
+
+	currentTree, err := os.Readlink(dest)
+	if err != nil {
+		if _, statErr := os.Lstat(dest); statErr == nil {
+			return false, fmt.Errorf("%s exists and is not a symlink", dest)
+		}
+		currentTree = ""
+	}
+	if currentTree == treeName {
+		return false, nil
+	}
+
+	tmpTree := filepath.Join(parent, treeName+".tmp")
+	err = os.RemoveAll(tmpTree)
+	if err != nil {
+		return false, err
+	}
+	err = os.MkdirAll(filepath.Join(tmpTree, "repodata"), 0755)
+	if err != nil {
+		return false, err
+	}
+
+	err = e.writeRepodata(revision, tmpTree)
+	if err != nil {
+		_ = os.RemoveAll(tmpTree)
+		return false, err
+	}
+
+	var previousTree string
+	if currentTree != "" {
+		previousTree = filepath.Join(parent, currentTree)
+	}
+	err = e.writePackages(revision, tmpTree, previousTree)
+	if err != nil {
+		_ = os.RemoveAll(tmpTree)
+		return false, err
+	}
+
+	err = os.Rename(tmpTree, filepath.Join(parent, treeName))
+	if err != nil {
+		return false, err
+	}
+
+	// Swap the symlink atomically
+	tmpLink := dest + ".tmp"
+	_ = os.Remove(tmpLink)
+	err = os.Symlink(treeName, tmpLink)
+	if err != nil {
+		return false, err
+	}
+	err = os.Rename(tmpLink, dest)
+	if err != nil {
+		return false, err
+	}
+
+	// Remove older trees, keeping the new and previous one
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "."+arch+"-") || name == treeName || name == currentTree {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(parent, name))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (e *Exporter) writeRepodata(revision *models.RepositoryRevision, tree string) error {
+	repomdXml, err := base64.StdEncoding.DecodeString(revision.RepomdXml)
+	if err != nil {
+		return fmt.Errorf("could not decode repomd.xml: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(tree, "repodata", "repomd.xml"), repomdXml, 0644)
+	if err != nil {
+		return err
+	}
+
+	var repomd yummeta.RepoMdRoot
+	err = xml.Unmarshal(repomdXml, &repomd)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal repomd.xml: %v", err)
+	}
+
+	for _, data := range repomd.Data {
+		if data.Location == nil {
+			continue
+		}
+		blobName := filepath.Base(data.Location.Href)
+
+		var content []byte
+		if strings.HasSuffix(blobName, ".sqlite.gz") {
+			content, err = e.storage.ReadObject(filepath.Join("sqlite-files", blobName))
+		} else {
+			content, _, err = RevisionBlob(revision, blobName)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", blobName, err)
+		}
+
+		err = os.WriteFile(filepath.Join(tree, "repodata", blobName), content, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	signatureObject := filepath.Join("repo-signatures", revision.ID.String()+".xml.asc")
+	exists, err := e.storage.Exists(signatureObject)
+	if err != nil {
+		return fmt.Errorf("could not check repomd.xml signature: %v", err)
+	}
+	if exists {
+		signature, err := e.storage.ReadObject(signatureObject)
+		if err != nil {
+			return fmt.Errorf("could not read repomd.xml signature: %v", err)
+		}
+		err = os.WriteFile(filepath.Join(tree, "repodata", "repomd.xml.asc"), signature, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Exporter) writePackages(revision *models.RepositoryRevision, tree string, previousTree string) error {
+	primaryXml, _, err := RevisionBlob(revision, revision.ID.String()+"-PRIMARY.xml")
+	if err != nil {
+		return fmt.Errorf("could not read primary.xml: %v", err)
+	}
+	var primary yummeta.PrimaryRoot
+	err = yummeta.UnmarshalPrimary(primaryXml, &primary)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal primary.xml: %v", err)
+	}
+
+	// Same mappings served by GetUrlMappings
+	urlMappings := map[string]string{}
+	if len(revision.UrlMappings) > 0 {
+		err = json.Unmarshal(revision.UrlMappings, &urlMappings)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal url mappings: %v", err)
+		}
+	}
+
+	for _, pkg := range primary.Packages {
+		if pkg.Location == nil {
+			continue
+		}
+		href := filepath.Clean(pkg.Location.Href)
+		if !strings.HasPrefix(href, "Packages/") {
+			return fmt.Errorf("unexpected package location %s", pkg.Location.Href)
+		}
+
+		target := filepath.Join(tree, href)
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		// A package can be replaced under the same path (e.g. when re-signed),
+		// so a file in the previous tree is only reused if its checksum matches
+		if previousTree != "" && pkg.Checksum != nil && pkg.Checksum.Type == "sha256" {
+			previous := filepath.Join(previousTree, href)
+			if checksum, err := fileSha256(previous); err == nil && checksum == pkg.Checksum.Value {
+				if err := os.Link(previous, target); err == nil {
+					continue
+				}
+			}
+		}
+
+		objectName := strings.TrimPrefix(href, "Packages/")
+		if mapped, ok := urlMappings[objectName]; ok {
+			objectName = mapped
+		}
+		err = e.storage.DownloadObject(objectName, target)
+		if err != nil {
+			return fmt.Errorf("could not download %s: %v", objectName, err)
+		}
+	}
+
+	return nil
+}
+
+// fileSha256 returns the hex encoded sha256 of a file
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
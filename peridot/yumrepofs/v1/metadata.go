@@ -36,10 +36,23 @@ import (
 	"encoding/json"
 	"google.golang.org/genproto/googleapis/api/httpbody"
 	"path/filepath"
+	"peridot.resf.org/peridot/db/models"
 	yumrepofspb "peridot.resf.org/peridot/yumrepofs/pb"
 	"peridot.resf.org/utils"
+	"strings"
+	"time"
 )
 
+// getRevision returns the revision pinned in given snapshot,
+// or the latest active revision if snapshot is empty
+func (s *Server) getRevision(projectId string, snapshot string, repoName string, arch string) (*models.RepositoryRevision, error) {
+	if snapshot != "" {
+		return s.db.GetSnapshotRepositoryRevisionByProjectIdAndNameAndArch(projectId, snapshot, repoName, arch)
+	}
+
+	return s.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(projectId, repoName, arch)
+}
+
 func (s *Server) GetRepoMd(_ context.Context, req *yumrepofspb.GetRepoMdRequest) (*httpbody.HttpBody, error) {
 	if err := req.ValidateAll(); err != nil {
 		return nil, err
@@ -48,7 +61,7 @@ func (s *Server) GetRepoMd(_ context.Context, req *yumrepofspb.GetRepoMdRequest)
 		req.Arch = "i686"
 	}
 
-	latestRevision, err := s.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(req.ProjectId, req.RepoName, req.Arch)
+	latestRevision, err := s.getRevision(req.ProjectId, req.Snapshot, req.RepoName, req.Arch)
 	if err != nil {
 		return nil, utils.CouldNotFindObject
 	}
@@ -73,7 +86,7 @@ func (s *Server) GetRepoMdSignature(_ context.Context, req *yumrepofspb.GetRepoM
 		req.Arch = "i686"
 	}
 
-	latestRevision, err := s.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(req.ProjectId, req.RepoName, req.Arch)
+	latestRevision, err := s.getRevision(req.ProjectId, req.Snapshot, req.RepoName, req.Arch)
 	if err != nil {
 		return nil, utils.CouldNotFindObject
 	}
@@ -98,14 +111,29 @@ func (s *Server) GetPublicKey(_ context.Context, req *yumrepofspb.GetPublicKeyRe
 		req.Arch = "i686"
 	}
 
-	key, err := s.db.GetDefaultKeyForProject(req.ProjectId)
+	keys, err := s.db.ListKeysForProject(req.ProjectId)
 	if err != nil {
+		s.log.Errorf("failed to list keys for project %s: %v", req.ProjectId, err)
+		return nil, utils.InternalError
+	}
+
+	// Serve every published key as one bundle, so clients keep trusting
+	// artifacts signed with a retiring key during a rotation.
+	// The default key is listed first
+	var publicKeys []string
+	now := time.Now()
+	for _, key := range keys {
+		if key.Published(now) {
+			publicKeys = append(publicKeys, strings.TrimSpace(key.PublicKey))
+		}
+	}
+	if len(publicKeys) == 0 {
 		return nil, utils.CouldNotFindObject
 	}
 
 	return &httpbody.HttpBody{
 		ContentType: "application/pgp-keys",
-		Data:        []byte(key.PublicKey),
+		Data:        []byte(strings.Join(publicKeys, "\n\n") + "\n"),
 	}, nil
 }
 
@@ -117,7 +145,7 @@ func (s *Server) GetUrlMappings(_ context.Context, req *yumrepofspb.GetUrlMappin
 		req.Arch = "i686"
 	}
 
-	latestRevision, err := s.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(req.ProjectId, req.RepoName, req.Arch)
+	latestRevision, err := s.getRevision(req.ProjectId, req.Snapshot, req.RepoName, req.Arch)
 	if err != nil {
 		return nil, utils.CouldNotFindObject
 	}
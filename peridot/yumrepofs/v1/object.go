@@ -0,0 +1,126 @@
+// Copyright (c) All respective contributors to the Peridot Project. All rights reserved.
+// Copyright (c) 2021-2022 Rocky Enterprise Software Foundation, Inc. All rights reserved.
+// Copyright (c) 2021-2022 Ctrl IQ, Inc. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package yumrepofsv1
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"peridot.resf.org/peridot/lookaside"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// objectExpiry is how long redirect URLs for objects are valid
+const objectExpiry = 24 * time.Hour
+
+// signObject returns the signature for an object URL that expires at given time
+func (s *Server) signObject(objectName string, expires int64) string {
+	mac := hmac.New(sha256.New, s.objectSecret)
+	_, _ = fmt.Fprintf(mac, "%s:%d", objectName, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// objectURL returns a temporary URL for given object.
+// Backends that can presign URLs are redirected to directly, other backends
+// are served by yumrepofs itself through a signed URL.
+func (s *Server) objectURL(objectName string) (string, error) {
+	if s.s3 != nil {
+		req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: aws.String(viper.GetString("s3-bucket")),
+			Key:    aws.String(objectName),
+		})
+		return req.Presign(objectExpiry)
+	}
+
+	if presigner, ok := s.storage.(lookaside.Presigner); ok {
+		return presigner.PresignObject(objectName, objectExpiry)
+	}
+
+	expires := time.Now().Add(objectExpiry).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.signObject(objectName, expires))
+
+	objectUrl := url.URL{
+		Path:     "/v1/objects/" + objectName,
+		RawQuery: query.Encode(),
+	}
+
+	return strings.TrimSuffix(os.Getenv("YUMREPOFS_HTTP_PUBLIC_URL"), "/") + objectUrl.String(), nil
+}
+
+// serveObject serves objects for URLs signed by objectURL
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request) {
+	objectName := chi.URLParam(r, "*")
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		http.Error(w, "link expired", http.StatusForbidden)
+		return
+	}
+	signature := r.URL.Query().Get("signature")
+	if !hmac.Equal([]byte(signature), []byte(s.signObject(objectName, expires))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	var body io.ReadCloser
+	if opener, ok := s.storage.(lookaside.ObjectOpener); ok {
+		body, err = opener.OpenObject(objectName)
+	} else {
+		var content []byte
+		content, err = s.storage.ReadObject(objectName)
+		body = io.NopCloser(bytes.NewReader(content))
+	}
+	if err != nil {
+		s.log.Errorf("could not read object %s: %v", objectName, err)
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, err = io.Copy(w, body)
+	if err != nil {
+		s.log.Errorf("could not send object %s: %v", objectName, err)
+	}
+}
@@ -32,50 +32,56 @@ package yumrepofsv1
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/spf13/viper"
+	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"path/filepath"
+	peridotworkflow "peridot.resf.org/peridot/builder/v1/workflow"
 	yumrepofspb "peridot.resf.org/peridot/yumrepofs/pb"
 	"peridot.resf.org/utils"
 	"strings"
-	"time"
 )
 
-func (s *Server) GetRpm(ctx context.Context, req *yumrepofspb.GetRpmRequest) (*yumrepofspb.GetRpmResponse, error) {
-	if err := req.ValidateAll(); err != nil {
-		return nil, err
-	}
-	if req.Arch == "i386" {
-		req.Arch = "i686"
-	}
-
+// rpmObjectName resolves the object name of a requested RPM
+func (s *Server) rpmObjectName(req *yumrepofspb.GetRpmRequest) (string, error) {
 	fileName := fmt.Sprintf("%s/%s.rpm", req.ParentTaskId, strings.TrimSuffix(req.FileName, ".rpm"))
 	if len(req.ParentTaskId) == 1 {
-		latestRevision, err := s.db.GetLatestActiveRepositoryRevisionByProjectIdAndNameAndArch(req.ProjectId, req.RepoName, req.Arch)
+		latestRevision, err := s.getRevision(req.ProjectId, req.Snapshot, req.RepoName, req.Arch)
 		if err != nil {
-			return nil, utils.CouldNotFindObject
+			return "", utils.CouldNotFindObject
 		}
 		var urlMappings map[string]string
 		err = json.Unmarshal(latestRevision.UrlMappings, &urlMappings)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		fileName = urlMappings[fileName]
 	}
-	s3Req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
-		Bucket: aws.String(viper.GetString("s3-bucket")),
-		Key:    &fileName,
-	})
-	urlStr, err := s3Req.Presign(24 * time.Hour)
+
+	return fileName, nil
+}
+
+func (s *Server) GetRpm(ctx context.Context, req *yumrepofspb.GetRpmRequest) (*yumrepofspb.GetRpmResponse, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if req.Arch == "i386" {
+		req.Arch = "i686"
+	}
+
+	fileName, err := s.rpmObjectName(req)
+	if err != nil {
+		return nil, err
+	}
+	urlStr, err := s.objectURL(fileName)
 	if err != nil {
-		s.log.Errorf("failed to presign s3 request: %v", err)
-		return nil, status.Error(codes.Internal, "failed to presign s3 request")
+		s.log.Errorf("failed to get object url: %v", err)
+		return nil, status.Error(codes.Internal, "failed to get object url")
 	}
 
 	header := metadata.Pairs("Location", urlStr)
@@ -89,3 +95,40 @@ func (s *Server) GetRpm(ctx context.Context, req *yumrepofspb.GetRpmRequest) (*y
 		RedirectUrl: urlStr,
 	}, nil
 }
+
+func (s *Server) GetRpmProvenance(_ context.Context, req *yumrepofspb.GetRpmRequest) (*httpbody.HttpBody, error) {
+	if err := req.ValidateAll(); err != nil {
+		return nil, err
+	}
+	if req.Arch == "i386" {
+		req.Arch = "i686"
+	}
+
+	fileName, err := s.rpmObjectName(req)
+	if err != nil {
+		return nil, err
+	}
+	// Signed RPMs are stored as {dir}/{gpgId}/{file}, while the build
+	// only references the unsigned artifact {dir}/{file}
+	artifactDir := strings.SplitN(fileName, "/", 2)[0]
+	artifactName := filepath.Join(artifactDir, filepath.Base(fileName))
+
+	buildId, err := s.db.GetBuildIdByTaskArtifactName(req.ProjectId, artifactName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.CouldNotFindObject
+		}
+		s.log.Errorf("failed to get build for artifact %s: %v", artifactName, err)
+		return nil, utils.InternalError
+	}
+
+	envelope, err := s.storage.ReadObject(peridotworkflow.ProvenanceObjectName(buildId))
+	if err != nil {
+		return nil, utils.CouldNotFindObject
+	}
+
+	return &httpbody.HttpBody{
+		ContentType: "application/json",
+		Data:        envelope,
+	}, nil
+}
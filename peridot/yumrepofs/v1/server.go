@@ -32,6 +32,7 @@ package yumrepofsv1
 
 import (
 	"context"
+	"crypto/rand"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
@@ -47,7 +48,7 @@ import (
 	commonpb "peridot.resf.org/common"
 	peridotdb "peridot.resf.org/peridot/db"
 	"peridot.resf.org/peridot/lookaside"
-	"peridot.resf.org/peridot/lookaside/s3"
+	"peridot.resf.org/peridot/lookaside/backend"
 	yumrepofspb "peridot.resf.org/peridot/yumrepofs/pb"
 	"peridot.resf.org/utils"
 )
@@ -58,27 +59,48 @@ type Server struct {
 	log     *logrus.Logger
 	db      peridotdb.Access
 	storage lookaside.Storage
-	s3      *awss3.S3
+	// s3 is only set if the s3 storage backend is used
+	s3           *awss3.S3
+	objectSecret []byte
 }
 
 func NewServer(db peridotdb.Access, session *session.Session) (*Server, error) {
-	storage, err := s3.New(osfs.New("/"))
+	log := logrus.New()
+
+	storage, err := backend.New(osfs.New("/"))
 	if err != nil {
 		return nil, err
 	}
 
-	cfg := &aws.Config{
-		UseDualStackEndpoint: endpoints.DualStackEndpointStateEnabled,
+	// Objects for backends without presigning support are served by
+	// yumrepofs, and the URLs are signed with this secret
+	objectSecret := []byte(viper.GetString("object-secret"))
+	if len(objectSecret) == 0 {
+		log.Warn("object-secret not set, object URLs will only be valid for this replica")
+		objectSecret = make([]byte, 32)
+		_, err = rand.Read(objectSecret)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if assumeRole := viper.GetString("s3-assume-role"); assumeRole != "" {
-		cfg.Credentials = stscreds.NewCredentials(session, assumeRole)
+
+	var s3Client *awss3.S3
+	if session != nil && viper.GetString("storage-backend") == backend.S3 {
+		cfg := &aws.Config{
+			UseDualStackEndpoint: endpoints.DualStackEndpointStateEnabled,
+		}
+		if assumeRole := viper.GetString("s3-assume-role"); assumeRole != "" {
+			cfg.Credentials = stscreds.NewCredentials(session, assumeRole)
+		}
+		s3Client = awss3.New(session, cfg)
 	}
 
 	return &Server{
-		log:     logrus.New(),
-		db:      db,
-		storage: storage,
-		s3:      awss3.New(session, cfg),
+		log:          log,
+		db:           db,
+		storage:      storage,
+		s3:           s3Client,
+		objectSecret: objectSecret,
 	}, nil
 }
 
@@ -105,6 +127,8 @@ func (s *Server) Run() {
 					s.log.Fatalf("could not register handler - %v", err)
 				}
 			}
+
+			r.Router.Get("/v1/objects/*", s.serveObject)
 		},
 		func(r *utils.RegisterServer) {
 			commonpb.RegisterHealthCheckServiceServer(r.Server, &utils.HealthServer{})
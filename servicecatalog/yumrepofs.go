@@ -42,3 +42,7 @@ func Yumrepofs() string {
 func YumrepofsRepo(projectId string, repo string, arch string) string {
 	return fmt.Sprintf("%s/v1/projects/%s/repo/%s/%s", Yumrepofs(), projectId, repo, arch)
 }
+
+func YumrepofsSnapshotRepo(projectId string, snapshot string, repo string, arch string) string {
+	return fmt.Sprintf("%s/v1/projects/%s/snapshot/%s/%s/%s", Yumrepofs(), projectId, snapshot, repo, arch)
+}